@@ -214,7 +214,7 @@ func genGetAlertsAction(deviceID string) func() {
 				fmt.Printf("\nresponse status code != 200: %v\n", resp)
 			}
 		} else {
-			resp, err := grpcClient.GetAlerts(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+			resp, err := grpcClient.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID})
 			if err != nil {
 				fmt.Printf("\nerror: %v\n", err)
 			}
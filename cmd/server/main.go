@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -15,6 +21,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
 	"liyu1981.xyz/iot-metrics-service/pkg/common"
 	"liyu1981.xyz/iot-metrics-service/pkg/db"
 	iotGrpc "liyu1981.xyz/iot-metrics-service/pkg/grpc"
@@ -31,17 +38,50 @@ func main() {
 		log.Fatal("Error loading .env file, copy .env.example to .env first if in development")
 	}
 
-	var dbInstance *db.DB
+	var dialector gorm.Dialector
 	iotDbType := os.Getenv(common.EnvKeyIOTDBType)
 	switch iotDbType {
 	case "file":
-		dbInstance = db.GetInstance(db.UseSqliteDialector())
+		dialector = db.UseSqliteDialector()
 	case "memory":
-		dbInstance = db.GetInstance(db.UseMemorySqliteDialector())
+		dialector = db.UseMemorySqliteDialector()
+	case "postgres":
+		dialector = db.UsePostgresDialector()
 	default:
 		log.Fatal("Unknown IOT_DB_TYPE: " + iotDbType)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(dialector, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var dbOptions db.Options
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTDbMaxOpenConns)); value != "" {
+		if dbOptions.MaxOpenConns, err = strconv.Atoi(value); err != nil || dbOptions.MaxOpenConns < 0 {
+			log.Fatal("Invalid IOT_DB_MAX_OPEN_CONNS, should be a positive int value")
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTDbMaxIdleConns)); value != "" {
+		if dbOptions.MaxIdleConns, err = strconv.Atoi(value); err != nil || dbOptions.MaxIdleConns < 0 {
+			log.Fatal("Invalid IOT_DB_MAX_IDLE_CONNS, should be a positive int value")
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTDbBusyTimeout)); value != "" {
+		if dbOptions.BusyTimeout, err = time.ParseDuration(value); err != nil || dbOptions.BusyTimeout < 0 {
+			log.Fatal("Invalid IOT_DB_BUSY_TIMEOUT, should be a duration like 5s")
+		}
+	}
+
+	// pending migrations are applied at startup
+	dbInstance, err := db.New(dialector, dbOptions)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dbInstance.Close()
+
 	grpcHostPort := strings.TrimSpace(os.Getenv(common.EnvKeyIOTGrpcHostPort))
 	httpHostPort := strings.TrimSpace(os.Getenv(common.EnvKeyIOTHttpHostPort))
 
@@ -58,41 +98,154 @@ func main() {
 
 	logger := common.GetLogger()
 
+	var requireRegisteredDevices bool
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTRequireRegisteredDevices)); value != "" {
+		if requireRegisteredDevices, err = strconv.ParseBool(value); err != nil {
+			log.Fatal("Invalid IOT_REQUIRE_REGISTERED_DEVICES, should be true or false")
+		}
+	}
+
 	iotCore := iot.IOT{
-		Db: *dbInstance,
+		Db:                       *dbInstance,
+		Broker:                   iot.NewAlertBroker(64),
+		RequireRegisteredDevices: requireRegisteredDevices,
 	}
-	iotCore.WithServices(iot.ServiceOpts{
+	if _, err = iotCore.WithServices(iot.ServiceOpts{
 		Metric: iotCore.GetIMetric(),
 		Alert:  iotCore.GetIAlert(),
 		Config: iotCore.GetIConfig(),
-	})
+		Rule:   iotCore.GetIRule(),
+		Device: iotCore.GetIDevice(),
+		Group:  iotCore.GetIGroup(),
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	offlineCheckInterval := iot.DefaultOfflineCheckInterval
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTOfflineCheckInterval)); value != "" {
+		if offlineCheckInterval, err = time.ParseDuration(value); err != nil || offlineCheckInterval < 0 {
+			log.Fatal("Invalid IOT_OFFLINE_CHECK_INTERVAL, should be a duration like 30s, 0 disables the check")
+		}
+	}
+	if offlineCheckInterval > 0 {
+		offlineWatcher, err := iot.NewOfflineWatcher(&iotCore, offlineCheckInterval)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer offlineWatcher.Close()
+		logger.Info("Offline watcher created with:", zap.Duration("interval", offlineCheckInterval))
+	}
 
+	retentionInterval := iot.DefaultRetentionInterval
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTRetentionInterval)); value != "" {
+		if retentionInterval, err = time.ParseDuration(value); err != nil || retentionInterval < 0 {
+			log.Fatal("Invalid IOT_RETENTION_INTERVAL, should be a duration like 1h, 0 disables pruning")
+		}
+	}
+	retention := iot.RetentionOptions{Interval: retentionInterval}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTRetentionMetrics)); value != "" {
+		if retention.MetricMaxAge, err = time.ParseDuration(value); err != nil || retention.MetricMaxAge < 0 {
+			log.Fatal("Invalid IOT_RETENTION_METRICS, should be a duration like 720h, 0 keeps metrics forever")
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTRetentionAlerts)); value != "" {
+		if retention.AlertMaxAge, err = time.ParseDuration(value); err != nil || retention.AlertMaxAge < 0 {
+			log.Fatal("Invalid IOT_RETENTION_ALERTS, should be a duration like 2160h, 0 keeps alerts forever")
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTRetentionBatchSize)); value != "" {
+		if retention.BatchSize, err = strconv.Atoi(value); err != nil || retention.BatchSize < 0 {
+			log.Fatal("Invalid IOT_RETENTION_BATCH_SIZE, should be a positive int value")
+		}
+	}
+	if retentionInterval > 0 {
+		iotCore.Retention = iot.NewRetentionPruner(dbInstance.Conn, retention)
+		defer iotCore.Retention.Close()
+		logger.Info("Retention pruner created with:", zap.Reflect("options", iotCore.Retention.Options()))
+	}
+
+	var webhookURLs []string
+	for _, url := range strings.Split(os.Getenv(common.EnvKeyIOTWebhookURLs), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			webhookURLs = append(webhookURLs, url)
+		}
+	}
+	if len(webhookURLs) > 0 {
+		iotCore.Notifier = iot.NewWebhookNotifier(dbInstance.Conn, iot.WebhookOptions{
+			URLs:   webhookURLs,
+			Secret: os.Getenv(common.EnvKeyIOTWebhookSecret),
+		})
+		defer iotCore.Notifier.Close()
+		logger.Info("Webhook notifier created with:", zap.Strings("urls", webhookURLs))
+	}
+
+	var ingest iot.IngestOptions
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTIngestQueueSize)); value != "" {
+		if ingest.QueueSize, err = strconv.Atoi(value); err != nil || ingest.QueueSize < 0 {
+			log.Fatal("Invalid IOT_INGEST_QUEUE_SIZE, should be a positive int value, 0 writes metrics synchronously")
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTIngestWorkers)); value != "" {
+		if ingest.Workers, err = strconv.Atoi(value); err != nil || ingest.Workers < 0 {
+			log.Fatal("Invalid IOT_INGEST_WORKERS, should be a positive int value")
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTIngestBatchSize)); value != "" {
+		if ingest.BatchSize, err = strconv.Atoi(value); err != nil || ingest.BatchSize < 0 {
+			log.Fatal("Invalid IOT_INGEST_BATCH_SIZE, should be a positive int value")
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(common.EnvKeyIOTIngestFlushInterval)); value != "" {
+		if ingest.FlushInterval, err = time.ParseDuration(value); err != nil || ingest.FlushInterval < 0 {
+			log.Fatal("Invalid IOT_INGEST_FLUSH_INTERVAL, should be a duration like 100ms")
+		}
+	}
+	if ingest.QueueSize > 0 {
+		iotCore.Ingester = iot.NewMetricIngester(&iotCore, ingest)
+		defer iotCore.Ingester.Close()
+		logger.Info("Metric ingester created with:", zap.Reflect("options", iotCore.Ingester.Options()))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var grpcServer *grpc.Server
 	if grpcHostPort != "" {
 		logger.Info("Starting gRPC server on port " + grpcHostPort)
-		go func() {
-			iotGrpcServer := iotGrpc.IOTServer{
-				Iot:              &iotCore,
-				RateLimiterStore: iot.NewRateLimiterStore(rate.Limit(defaultRate), int(defaultBurst)),
-			}
-			interceptor := iotGrpcServer.CreateRateLimitInterceptor([]proto.Message{
-				&pb.PostMetricsRequest{},
-				&pb.UpdateConfigRequest{},
-				&pb.DeviceRequest{},
-			})
-			s := grpc.NewServer(grpc.UnaryInterceptor(interceptor))
-			reflection.Register(s)
-			pb.RegisterIOTServiceServer(s, &iotGrpcServer)
-			logger.Info("gRPC server created with:",
-				zap.String("default_limiter",
-					fmt.Sprintf("{\"default_rate\": %v, \"default_burst\": %v}", defaultRate, defaultBurst)))
-
-			listener, err := net.Listen("tcp", grpcHostPort)
-			if err != nil {
-				log.Fatalf("failed to listen: %v", err)
-			}
+		iotGrpcServer := iotGrpc.IOTServer{
+			Iot:              &iotCore,
+			RateLimiterStore: iot.NewRateLimiterStore(rate.Limit(defaultRate), int(defaultBurst)),
+		}
+		interceptor := iotGrpcServer.CreateRateLimitInterceptor([]proto.Message{
+			&pb.PostMetricsRequest{},
+			&pb.PostMetricsBatchRequest{},
+			&pb.UpdateConfigRequest{},
+			&pb.DeviceRequest{},
+			&pb.GetMetricsRequest{},
+			&pb.AggregateMetricsRequest{},
+			&pb.RuleRequest{},
+			&pb.RuleIdRequest{},
+			&pb.GetAlertsRequest{},
+			&pb.AlertActionRequest{},
+		})
+		streamInterceptor := iotGrpcServer.CreateStreamRateLimitInterceptor([]proto.Message{
+			&pb.PostMetricsRequest{},
+		})
+		grpcServer = grpc.NewServer(grpc.UnaryInterceptor(interceptor), grpc.StreamInterceptor(streamInterceptor))
+		reflection.Register(grpcServer)
+		pb.RegisterIOTServiceServer(grpcServer, &iotGrpcServer)
+		logger.Info("gRPC server created with:",
+			zap.String("default_limiter",
+				fmt.Sprintf("{\"default_rate\": %v, \"default_burst\": %v}", defaultRate, defaultBurst)))
+
+		listener, err := net.Listen("tcp", grpcHostPort)
+		if err != nil {
+			log.Fatalf("failed to listen: %v", err)
+		}
 
+		go func() {
 			logger.Info("start gRPC server on " + grpcHostPort)
-			if err := s.Serve(listener); err != nil {
+			if err := grpcServer.Serve(listener); err != nil {
 				log.Fatalf("grpc server failed to serve: %v", err)
 			}
 		}()
@@ -115,8 +268,25 @@ func main() {
 		zap.String("default_limiter",
 			fmt.Sprintf("{\"default_rate\": %v, \"default_burst\": %v}", defaultRate, defaultBurst)))
 
-	logger.Info("Starting HTTP server on: " + httpHostPort)
-	if err := rs.Server.Run(httpHostPort); err != nil {
-		log.Fatalf("http server failed to serve: %v", err)
+	httpServer := &http.Server{Addr: httpHostPort, Handler: rs.Server}
+	go func() {
+		logger.Info("start HTTP server on " + httpHostPort)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("http server failed to serve: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Shutting down, draining requests and queued metrics")
+
+	// stop taking requests first, the deferred closes then drain the
+	// ingester and the background workers before the database is closed
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down HTTP server", zap.Error(err))
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
 	}
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+	"liyu1981.xyz/iot-metrics-service/pkg/db"
+)
+
+const migrateUsage = "usage: server migrate up|down|status [version] [--destructive]"
+
+// runMigrate runs the migrate subcommand. Up applies the pending migrations up
+// to version, all of them by default, down reverts the migrations newer than
+// version, only the last one by default, and status lists them all. Down
+// refuses to revert migrations losing data unless --destructive is given.
+func runMigrate(dialector gorm.Dialector, args []string) error {
+	destructive := false
+	if n := slices.Index(args, "--destructive"); n >= 0 {
+		destructive = true
+		args = slices.Delete(slices.Clone(args), n, n+1)
+	}
+	if len(args) == 0 || len(args) > 2 || (destructive && args[0] != "down") {
+		return errors.New(migrateUsage)
+	}
+
+	version := -1
+	if len(args) == 2 {
+		var err error
+		if version, err = strconv.Atoi(args[1]); err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q, %s", args[1], migrateUsage)
+		}
+	}
+
+	conn, err := db.Open(dialector)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if sqlDB, err := conn.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(conn, max(version, 0))
+		for _, migration := range applied {
+			fmt.Printf("applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+
+	case "down":
+		if version < 0 {
+			current, err := db.CurrentVersion(conn)
+			if err != nil {
+				return err
+			}
+			version = previousVersion(current)
+		}
+		reverted, err := db.MigrateDown(conn, version, destructive)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no migrations to revert")
+		}
+
+	case "status":
+		if version >= 0 {
+			return errors.New(migrateUsage)
+		}
+		statuses, err := db.MigrateStatus(conn)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+// previousVersion is the version before current, which down reverts to by
+// default
+func previousVersion(current int) int {
+	previous := 0
+	for _, migration := range db.Migrations {
+		if migration.Version >= current {
+			break
+		}
+		previous = migration.Version
+	}
+	return previous
+}
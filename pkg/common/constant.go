@@ -7,6 +7,11 @@ const (
 
 	EnvKeyIOTDBType string = "IOT_DB_TYPE"
 	EnvKeyIOTDbPath string = "IOT_DB_PATH"
+	EnvKeyIOTDbDSN  string = "IOT_DB_DSN"
+
+	EnvKeyIOTDbMaxOpenConns string = "IOT_DB_MAX_OPEN_CONNS"
+	EnvKeyIOTDbMaxIdleConns string = "IOT_DB_MAX_IDLE_CONNS"
+	EnvKeyIOTDbBusyTimeout  string = "IOT_DB_BUSY_TIMEOUT"
 
 	EnvKeyIOTHttpHostPort string = "IOT_HTTP_HOST_PORT"
 	EnvKeyIOTGrpcHostPort string = "IOT_GRPC_HOST_PORT"
@@ -14,11 +19,33 @@ const (
 	EnvKeyIOTDefaultRate  string = "IOT_DEFAULT_RATE"
 	EnvKeyIOTDefaultBurst string = "IOT_DEFAULT_BURST"
 
-	LoggerNameIOTCore       string = "iot_core"
-	LoggerNameRestfulServer string = "restful_server"
-	LoggerNameGrpcServer    string = "grpc_server"
-	LoggerFieldIOTCategory  string = "category"
-	LoggerCategoryIOTMetric string = "metric"
-	LoggerCategoryIOTAlert  string = "alert"
-	LoggerCategoryIOTConfig string = "config"
+	EnvKeyIOTWebhookURLs   string = "IOT_WEBHOOK_URLS"
+	EnvKeyIOTWebhookSecret string = "IOT_WEBHOOK_SECRET"
+
+	EnvKeyIOTRequireRegisteredDevices string = "IOT_REQUIRE_REGISTERED_DEVICES"
+
+	EnvKeyIOTOfflineCheckInterval string = "IOT_OFFLINE_CHECK_INTERVAL"
+
+	EnvKeyIOTRetentionInterval  string = "IOT_RETENTION_INTERVAL"
+	EnvKeyIOTRetentionMetrics   string = "IOT_RETENTION_METRICS"
+	EnvKeyIOTRetentionAlerts    string = "IOT_RETENTION_ALERTS"
+	EnvKeyIOTRetentionBatchSize string = "IOT_RETENTION_BATCH_SIZE"
+
+	EnvKeyIOTIngestQueueSize     string = "IOT_INGEST_QUEUE_SIZE"
+	EnvKeyIOTIngestWorkers       string = "IOT_INGEST_WORKERS"
+	EnvKeyIOTIngestBatchSize     string = "IOT_INGEST_BATCH_SIZE"
+	EnvKeyIOTIngestFlushInterval string = "IOT_INGEST_FLUSH_INTERVAL"
+
+	LoggerNameIOTCore          string = "iot_core"
+	LoggerNameRestfulServer    string = "restful_server"
+	LoggerNameGrpcServer       string = "grpc_server"
+	LoggerFieldIOTCategory     string = "category"
+	LoggerCategoryIOTMetric    string = "metric"
+	LoggerCategoryIOTAlert     string = "alert"
+	LoggerCategoryIOTConfig    string = "config"
+	LoggerCategoryIOTNotify    string = "notify"
+	LoggerCategoryIOTDevice    string = "device"
+	LoggerCategoryIOTOffline   string = "offline"
+	LoggerCategoryIOTRetention string = "retention"
+	LoggerCategoryIOTIngest    string = "ingest"
 )
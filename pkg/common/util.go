@@ -31,3 +31,21 @@ func Reducer[T any, R any](items []T, reduceFn func(R, T) R, initAcc R) R {
 	}
 	return finalAcc
 }
+
+// ClonePtr returns a pointer to a copy of *p, nil when p is nil
+func ClonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// ValueOrZero returns *p, or the zero value of T when p is nil
+func ValueOrZero[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -1,56 +1,187 @@
 package db
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	constant "liyu1981.xyz/iot-metrics-service/pkg/common"
-	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+const (
+	DialectSqlite   = "sqlite"
+	DialectPostgres = "postgres"
+
+	ExtensionTimescale = "timescaledb"
 )
 
 type DB struct {
 	Conn *gorm.DB
 }
 
+// Options tune the connection pool of a DB, zero values keep the defaults of
+// database/sql and of the driver
+type Options struct {
+	MaxOpenConns int
+	MaxIdleConns int
+	// BusyTimeout is how long sqlite waits for a lock held by another
+	// connection before failing, postgres does not use it
+	BusyTimeout time.Duration
+}
+
 var (
 	instance *DB
 	once     sync.Once
 )
 
-func GetInstance(dialector gorm.Dialector) *DB {
+// New opens the database of dialector and applies the pending migrations.
+// Every call returns a DB of its own, which is closed with Close.
+func New(dialector gorm.Dialector, opts Options) (*DB, error) {
 	var logger = constant.GetLogger()
-	once.Do(func() {
-		conn, err := gorm.Open(dialector, &gorm.Config{})
-		if err != nil {
-			log.Fatal("Failed to connect to database:", err)
-		}
 
-		logger.Info("Connected to database with dialector:", zap.String("dialector", dialector.Name()))
+	conn, err := Open(withSqliteParams(dialector, opts.BusyTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	}
 
-		instance = &DB{Conn: conn}
+	logger.Info("Connected to database with dialector:", zap.String("dialector", dialector.Name()))
 
-		err = instance.Conn.AutoMigrate(&models.Config{}, &models.Metric{}, &models.Alert{})
-		if err != nil {
-			log.Fatal("Failed to migrate database:", err)
-		}
+	if err := Setup(conn); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to set up database: %w", err)
+	}
 
-		logger.Info("Database migration completed")
+	logger.Info("Database migration completed")
 
-		if err := instance.Conn.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
-			log.Fatal("Failed to enable sqlite foreign key support", err)
-		}
+	return &DB{Conn: conn}, nil
+}
 
-		if err := instance.Conn.Exec("PRAGMA journal_mode = WAL").Error; err != nil {
-			log.Fatal("Failed to set sqlite journal mode", err)
+// Close closes the connections of the database, an in-memory database is gone
+// afterwards
+func (d *DB) Close() error {
+	sqlDB, err := d.Conn.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// GetInstance returns the database shared by the whole process, opened with
+// dialector and the default options on the first call. Use New for a database
+// of its own.
+func GetInstance(dialector gorm.Dialector) *DB {
+	once.Do(func() {
+		var err error
+		if instance, err = New(dialector, Options{}); err != nil {
+			log.Fatal(err)
 		}
 	})
 	return instance
 }
 
+// withSqliteParams enables foreign keys and sets the busy timeout of a sqlite
+// dialector in its DSN, so every connection of the pool gets them. A PRAGMA
+// would only set them on the one connection it ran on.
+func withSqliteParams(dialector gorm.Dialector, timeout time.Duration) gorm.Dialector {
+	d, ok := dialector.(*sqlite.Dialector)
+	if !ok {
+		return dialector
+	}
+
+	params := []string{"_foreign_keys=1"}
+	if timeout > 0 {
+		params = append(params, fmt.Sprintf("_busy_timeout=%d", timeout.Milliseconds()))
+	}
+	separator := "?"
+	if strings.Contains(d.DSN, "?") {
+		separator = "&"
+	}
+	return &sqlite.Dialector{
+		DriverName: d.DriverName,
+		DSN:        d.DSN + separator + strings.Join(params, "&"),
+		Conn:       d.Conn,
+	}
+}
+
+// Open connects to the database of dialector without setting it up, GetInstance
+// is the set up connection of the server
+func Open(dialector gorm.Dialector) (*gorm.DB, error) {
+	return gorm.Open(dialector, &gorm.Config{})
+}
+
+// Setup applies the pending migrations and prepares the connection for its
+// dialect
+func Setup(conn *gorm.DB) error {
+	if _, err := MigrateUp(conn, 0); err != nil {
+		return err
+	}
+
+	switch conn.Dialector.Name() {
+	case DialectSqlite:
+		return setupSqlite(conn)
+	case DialectPostgres:
+		return setupPostgres(conn)
+	}
+	return nil
+}
+
+// setupSqlite sets the journal mode, which is kept in the database file.
+// Foreign keys are enabled per connection by New.
+func setupSqlite(conn *gorm.DB) error {
+	if err := conn.Exec("PRAGMA journal_mode = WAL").Error; err != nil {
+		return fmt.Errorf("failed to set sqlite journal mode: %w", err)
+	}
+
+	return nil
+}
+
+// setupPostgres enables TimescaleDB when the server has it installed, metrics
+// are then aggregated with its time_bucket function
+func setupPostgres(conn *gorm.DB) error {
+	var available int64
+	err := conn.Raw("SELECT COUNT(*) FROM pg_available_extensions WHERE name = ?", ExtensionTimescale).Scan(&available).Error
+	if err != nil {
+		return fmt.Errorf("failed to list postgres extensions: %w", err)
+	}
+	if available == 0 {
+		return nil
+	}
+
+	if err := conn.Exec("CREATE EXTENSION IF NOT EXISTS " + ExtensionTimescale).Error; err != nil {
+		return fmt.Errorf("failed to enable %s: %w", ExtensionTimescale, err)
+	}
+	return nil
+}
+
+// HasTimescale tells whether TimescaleDB is enabled in the database of conn
+func HasTimescale(conn *gorm.DB) (bool, error) {
+	if conn.Dialector.Name() != DialectPostgres {
+		return false, nil
+	}
+
+	var count int64
+	err := conn.Raw("SELECT COUNT(*) FROM pg_extension WHERE extname = ?", ExtensionTimescale).Scan(&count).Error
+	return count > 0, err
+}
+
 func UseSqliteDialector() gorm.Dialector {
 	var dbPath string
 	var found bool
@@ -60,6 +191,16 @@ func UseSqliteDialector() gorm.Dialector {
 	return sqlite.Open(dbPath)
 }
 
+var memoryDatabases atomic.Int64
+
+// UseMemorySqliteDialector opens a new empty in-memory database on every call,
+// the connections of one DB share it
 func UseMemorySqliteDialector() gorm.Dialector {
-	return sqlite.Open("file::memory:?cache=shared")
+	return sqlite.Open(fmt.Sprintf("file:iot-memory-%d?mode=memory&cache=shared", memoryDatabases.Add(1)))
+}
+
+// UsePostgresDialector connects to the postgres server of the IOT_DB_DSN
+// environment variable, a keyword/value or URL connection string
+func UsePostgresDialector() gorm.Dialector {
+	return postgres.Open(os.Getenv(constant.EnvKeyIOTDbDSN))
 }
@@ -38,10 +38,11 @@ func TestWithEnvPath(t *testing.T) {
 		_ = os.Remove(testPath)
 	}()
 
-	instance := GetInstance(UseSqliteDialector())
-	if instance == nil || instance.Conn == nil {
-		t.Fatal("Expected non-nil DB connection")
+	instance, err := New(UseSqliteDialector(), Options{})
+	if err != nil || instance == nil || instance.Conn == nil {
+		t.Fatalf("Expected non-nil DB connection, got %v", err)
 	}
+	defer instance.Close()
 
 	if _, err := os.Stat(testPath); os.IsNotExist(err) {
 		t.Errorf("Expected database file to be created at %s", testPath)
@@ -1,12 +1,17 @@
 package db
 
 import (
+	"context"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
 	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
 
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -28,7 +33,7 @@ func TestWithMemorySqlite(t *testing.T) {
 		t.Fatal("Expected non-nil DB instance")
 	}
 
-	var tables = []string{"metrics", "configs", "alerts"}
+	var tables = []string{"metrics", "configs", "alerts", "alert_rules", "webhook_deliveries", "devices", "device_tags", "group_configs", "config_changes"}
 	for _, table := range tables {
 		if !tableExists(instance.Conn, table) {
 			t.Errorf("Expected table %q to exist after migration", table)
@@ -67,3 +72,96 @@ func TestSingletonConcurrency(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupSqlite(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	instance := GetInstance(UseMemorySqliteDialector())
+
+	// setting up an already migrated database is fine
+	if err := Setup(instance.Conn); err != nil {
+		t.Fatalf("Expected no error setting up again, got %v", err)
+	}
+
+	var foreignKeys int
+	if err := instance.Conn.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error; err != nil || foreignKeys != 1 {
+		t.Errorf("Expected sqlite foreign keys to be enabled, got %d, %v", foreignKeys, err)
+	}
+
+	timescale, err := HasTimescale(instance.Conn)
+	if err != nil || timescale {
+		t.Errorf("Expected no TimescaleDB on sqlite, got %v, %v", timescale, err)
+	}
+}
+
+func TestNewIsolated(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	first, err := New(UseMemorySqliteDialector(), Options{MaxOpenConns: 4, MaxIdleConns: 2})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer first.Close()
+	second, err := New(UseMemorySqliteDialector(), Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer second.Close()
+
+	if err := first.Conn.Create(&models.Config{DeviceID: "d1"}).Error; err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	var count int64
+	if err := second.Conn.Model(&models.Config{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("Expected the databases to be independent, got %d configs, %v", count, err)
+	}
+
+	sqlDB, err := first.Conn.DB()
+	if err != nil {
+		t.Fatalf("Failed to get sql.DB: %v", err)
+	}
+	if stats := sqlDB.Stats(); stats.MaxOpenConnections != 4 {
+		t.Errorf("Expected 4 max open connections, got %d", stats.MaxOpenConnections)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+	if err := first.Conn.Model(&models.Config{}).Count(&count).Error; err == nil {
+		t.Error("Expected a closed database to fail")
+	}
+}
+
+func TestNewBusyTimeout(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	instance, err := New(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), Options{MaxOpenConns: 4, BusyTimeout: 1234 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer instance.Close()
+
+	sqlDB, err := instance.Conn.DB()
+	if err != nil {
+		t.Fatalf("Failed to get connection pool: %v", err)
+	}
+
+	// hold all connections of the pool at once, so each one is a different
+	// connection
+	for n := range 4 {
+		conn, err := sqlDB.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get connection %d: %v", n, err)
+		}
+		defer conn.Close()
+
+		var timeout, foreignKeys int
+		if err := conn.QueryRowContext(context.Background(), "PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 1234 {
+			t.Errorf("Expected a busy timeout of 1234ms on connection %d, got %d, %v", n, timeout, err)
+		}
+		if err := conn.QueryRowContext(context.Background(), "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil || foreignKeys != 1 {
+			t.Errorf("Expected foreign keys on connection %d, got %d, %v", n, foreignKeys, err)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownVersion = errors.New("unknown schema version")
+	ErrIrreversible   = errors.New("irreversible migration")
+	ErrDestructive    = errors.New("destructive migration")
+)
+
+// Migration is one versioned change of the schema. Up and Down run in a
+// transaction together with the bookkeeping in the schema_version table, so a
+// failing step leaves the database at the previous version.
+//
+// Steps must not use the structs of pkg/models, those follow the latest
+// schema. Create tables from structs frozen at the version of the step, and
+// change them with the migrator or plain SQL working on both sqlite and
+// postgres.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	// Down is nil when the step can not be reverted
+	Down func(tx *gorm.DB) error
+	// Destructive steps lose data when reverted, dropping tables or columns
+	Destructive bool
+}
+
+// Migrations are the steps of the schema, ordered by version. New steps are
+// appended, released ones are never changed.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up:      upInitialSchema,
+	},
+	{
+		Version: 2,
+		Name:    "metric and alert time indexes",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_metrics_device_time ON metrics (device_id, timestamp, id)").Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX IF NOT EXISTS idx_alerts_device_time ON alerts (device_id, timestamp, id)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP INDEX IF EXISTS idx_alerts_device_time").Error; err != nil {
+				return err
+			}
+			return tx.Exec("DROP INDEX IF EXISTS idx_metrics_device_time").Error
+		},
+	},
+	{
+		Version: 3,
+		Name:    "metric values",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v3Metric{}, &v3MetricValue{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v3MetricValue{})
+		},
+		Destructive: true,
+	},
+	{
+		Version:     4,
+		Name:        "alert types of any metric",
+		Up:          upAlertTypes,
+		Down:        downAlertTypes,
+		Destructive: true,
+	},
+	{
+		Version:     5,
+		Name:        "alert rules",
+		Up:          upAlertRules,
+		Down:        downAlertRules,
+		Destructive: true,
+	},
+	{
+		Version:     6,
+		Name:        "alert state and hysteresis",
+		Up:          upAlertState,
+		Down:        downAlertState,
+		Destructive: true,
+	},
+	{
+		Version: 7,
+		Name:    "alert rule hold",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v7AlertRule{}, "HoldFor", "HoldSamples")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "alert_rules", nil, "hold_for", "hold_samples")
+		},
+		Destructive: true,
+	},
+	{
+		Version: 8,
+		Name:    "alert status",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v8Alert{}, "Status", "AcknowledgedBy", "AcknowledgedAt", "ResolvedBy", "Note")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "alerts", []string{"idx_alerts_status"}, "status", "acknowledged_by", "acknowledged_at", "resolved_by", "note")
+		},
+		Destructive: true,
+	},
+	{
+		Version: 9,
+		Name:    "webhook deliveries",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v9WebhookDelivery{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v9WebhookDelivery{})
+		},
+		Destructive: true,
+	},
+	{
+		Version: 10,
+		Name:    "device registry",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v10Device{}, &v10DeviceTag{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v10DeviceTag{}, &v10Device{})
+		},
+		Destructive: true,
+	},
+	{
+		Version: 11,
+		Name:    "device heartbeat",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v11Config{}, "HeartbeatInterval", "LastSeenAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "configs", nil, "heartbeat_interval", "last_seen_at")
+		},
+		Destructive: true,
+	},
+	{
+		Version: 12,
+		Name:    "group configs",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v12GroupConfig{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v12GroupConfig{})
+		},
+		Destructive: true,
+	},
+	{
+		Version: 13,
+		Name:    "config versions",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v13Config{}, "Version")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "configs", nil, "version")
+		},
+		Destructive: true,
+	},
+	{
+		Version: 14,
+		Name:    "config history",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v14ConfigChange{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v14ConfigChange{})
+		},
+		Destructive: true,
+	},
+	{
+		Version: 15,
+		Name:    "group retention",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v15GroupConfig{}, "MetricRetention", "AlertRetention")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "group_configs", nil, "metric_retention", "alert_retention")
+		},
+		Destructive: true,
+	},
+}
+
+// SchemaVersion is a row of the schema_version table, one per applied
+// migration
+type SchemaVersion struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// MigrationStatus tells whether a migration is applied, AppliedAt is nil when
+// it is pending
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LatestVersion is the version of the schema after all migrations
+func LatestVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+func appliedVersions(conn *gorm.DB) (map[int]SchemaVersion, error) {
+	if err := conn.AutoMigrate(&SchemaVersion{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	var rows []SchemaVersion
+	if err := conn.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := map[int]SchemaVersion{}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// CurrentVersion is the version of the newest applied migration, 0 for an
+// empty database
+func CurrentVersion(conn *gorm.DB) (int, error) {
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	current := 0
+	for version := range applied {
+		current = max(current, version)
+	}
+	return current, nil
+}
+
+// MigrateStatus lists all migrations, oldest first
+func MigrateStatus(conn *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(Migrations))
+	for _, migration := range Migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func checkVersion(version int) error {
+	if version == 0 {
+		return nil
+	}
+	for _, migration := range Migrations {
+		if migration.Version == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+}
+
+// MigrateUp applies the pending migrations up to and including target, all of
+// them when target is 0, and returns the applied ones
+func MigrateUp(conn *gorm.DB, target int) ([]Migration, error) {
+	if err := checkVersion(target); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range Migrations {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaVersion{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to migrate up to version %d %q: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts the applied migrations newer than target, newest first,
+// and returns the reverted ones. Nothing is reverted when one of them is
+// irreversible, which the initial schema is, or destructive without
+// allowDestructive.
+func MigrateDown(conn *gorm.DB, target int, allowDestructive bool) ([]Migration, error) {
+	if err := checkVersion(target); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for n := len(Migrations) - 1; n >= 0; n-- {
+		migration := Migrations[n]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		switch {
+		case migration.Down == nil:
+			return nil, fmt.Errorf("%w: version %d %q", ErrIrreversible, migration.Version, migration.Name)
+		case migration.Destructive && !allowDestructive:
+			return nil, fmt.Errorf("%w: reverting version %d %q loses data", ErrDestructive, migration.Version, migration.Name)
+		}
+		pending = append(pending, migration)
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaVersion{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to migrate down from version %d %q: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
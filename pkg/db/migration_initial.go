@@ -0,0 +1,47 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// the tables of the initial schema, frozen at version 1
+
+type initialMetric struct {
+	ID          uint   `gorm:"primaryKey"`
+	DeviceID    string `gorm:"index"`
+	Timestamp   time.Time
+	Temperature float64
+	Battery     float64
+}
+
+func (initialMetric) TableName() string { return "metrics" }
+
+type initialConfig struct {
+	DeviceID             string `gorm:"primaryKey"`
+	TemperatureThreshold float64
+	BatteryThreshold     float64
+
+	Metrics []initialMetric `gorm:"foreignKey:DeviceID;references:DeviceID"`
+	Alerts  []initialAlert  `gorm:"foreignKey:DeviceID;references:DeviceID"`
+}
+
+func (initialConfig) TableName() string { return "configs" }
+
+type initialAlert struct {
+	ID        uint   `gorm:"primaryKey"`
+	DeviceID  string `gorm:"index"`
+	Timestamp time.Time
+	Type      string `gorm:"type:varchar(20);check:type IN ('temperature','battery')"`
+	Message   string
+}
+
+func (initialAlert) TableName() string { return "alerts" }
+
+// upInitialSchema creates the tables, and adopts databases created by
+// AutoMigrate before there were versioned migrations: tables there already
+// are kept as they are, the later steps add what they miss.
+func upInitialSchema(tx *gorm.DB) error {
+	return createTables(tx, &initialConfig{}, &initialMetric{}, &initialAlert{})
+}
@@ -0,0 +1,412 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// createTables creates the tables of models which do not exist yet. A model
+// declaring a relation comes before the model holding its foreign key, so the
+// key is created along with the table.
+func createTables(tx *gorm.DB, models ...any) error {
+	var missing []any
+	for _, model := range models {
+		if !tx.Migrator().HasTable(model) {
+			missing = append(missing, model)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return tx.Migrator().CreateTable(missing...)
+}
+
+// addColumns adds the fields of model its table misses, along with their
+// indexes
+func addColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+
+	indexes, err := parseIndexes(tx, model)
+	if err != nil {
+		return err
+	}
+	for name := range indexes {
+		if !tx.Migrator().HasIndex(model, name) {
+			if err := tx.Migrator().CreateIndex(model, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func parseIndexes(tx *gorm.DB, model any) (map[string]bool, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for name := range stmt.Schema.ParseIndexes() {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// dropColumns drops columns of table with plain SQL, which sqlite supports
+// since 3.35 without recreating the table. Indexes on the columns must be
+// dropped first.
+func dropColumns(tx *gorm.DB, table string, indexes []string, columns ...string) error {
+	for _, index := range indexes {
+		if err := tx.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", index)).Error; err != nil {
+			return err
+		}
+	}
+	for _, column := range columns {
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createAlertIndexes restores the indexes of the alerts table, which sqlite
+// loses when the table is recreated to change a column or constraint
+func createAlertIndexes(tx *gorm.DB) error {
+	if err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_alerts_device_id ON alerts (device_id)").Error; err != nil {
+		return err
+	}
+	return tx.Exec("CREATE INDEX IF NOT EXISTS idx_alerts_device_time ON alerts (device_id, timestamp, id)").Error
+}
+
+// version 3, values of named metrics
+
+type v3Metric struct {
+	ID          uint   `gorm:"primaryKey"`
+	DeviceID    string `gorm:"index"`
+	Timestamp   time.Time
+	Temperature float64
+	Battery     float64
+
+	Values []v3MetricValue `gorm:"foreignKey:MetricID;constraint:OnDelete:CASCADE"`
+}
+
+func (v3Metric) TableName() string { return "metrics" }
+
+type v3MetricValue struct {
+	ID       uint   `gorm:"primaryKey"`
+	MetricID uint   `gorm:"index"`
+	Name     string `gorm:"type:varchar(64);index"`
+	Value    float64
+	Unit     string `gorm:"type:varchar(16)"`
+}
+
+func (v3MetricValue) TableName() string { return "metric_values" }
+
+// version 4, alert types of any metric
+
+type v4Alert struct {
+	ID        uint   `gorm:"primaryKey"`
+	DeviceID  string `gorm:"index"`
+	Timestamp time.Time
+	Type      string `gorm:"type:varchar(64)"`
+	Message   string
+}
+
+func (v4Alert) TableName() string { return "alerts" }
+
+// upAlertTypes lifts the limit of alert types to temperature and battery,
+// rules raise alerts of any metric
+func upAlertTypes(tx *gorm.DB) error {
+	if !tx.Migrator().HasConstraint(&initialAlert{}, "chk_alerts_type") {
+		return nil
+	}
+	if err := tx.Migrator().DropConstraint(&initialAlert{}, "chk_alerts_type"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().AlterColumn(&v4Alert{}, "Type"); err != nil {
+		return err
+	}
+	return createAlertIndexes(tx)
+}
+
+// downAlertTypes deletes the alerts of other types than temperature and
+// battery, so the limit can be put back
+func downAlertTypes(tx *gorm.DB) error {
+	if err := tx.Exec("DELETE FROM alerts WHERE type NOT IN ('temperature', 'battery')").Error; err != nil {
+		return err
+	}
+	if err := tx.Migrator().AlterColumn(&initialAlert{}, "Type"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().CreateConstraint(&initialAlert{}, "chk_alerts_type"); err != nil {
+		return err
+	}
+	return createAlertIndexes(tx)
+}
+
+// version 5, alert rules
+
+type v5AlertRule struct {
+	ID             uint   `gorm:"primaryKey"`
+	DeviceID       string `gorm:"index"`
+	Metric         string `gorm:"type:varchar(64)"`
+	Operator       string `gorm:"type:varchar(8)"`
+	Threshold      float64
+	UpperThreshold float64
+	Severity       string `gorm:"type:varchar(16)"`
+}
+
+func (v5AlertRule) TableName() string { return "alert_rules" }
+
+type v5Alert struct {
+	ID        uint   `gorm:"primaryKey"`
+	DeviceID  string `gorm:"index"`
+	Timestamp time.Time
+	Type      string `gorm:"type:varchar(64)"`
+	Severity  string `gorm:"type:varchar(16)"`
+	RuleID    *uint  `gorm:"index"`
+	Message   string
+}
+
+func (v5Alert) TableName() string { return "alerts" }
+
+func upAlertRules(tx *gorm.DB) error {
+	if err := createTables(tx, &v5AlertRule{}); err != nil {
+		return err
+	}
+	return addColumns(tx, &v5Alert{}, "Severity", "RuleID")
+}
+
+func downAlertRules(tx *gorm.DB) error {
+	if err := dropColumns(tx, "alerts", []string{"idx_alerts_rule_id"}, "severity", "rule_id"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(&v5AlertRule{})
+}
+
+// version 6, alert state and hysteresis
+
+type v6Config struct {
+	DeviceID              string `gorm:"primaryKey"`
+	TemperatureThreshold  float64
+	BatteryThreshold      float64
+	TemperatureHysteresis float64
+	BatteryHysteresis     float64
+}
+
+func (v6Config) TableName() string { return "configs" }
+
+type v6AlertRule struct {
+	ID             uint   `gorm:"primaryKey"`
+	DeviceID       string `gorm:"index"`
+	Metric         string `gorm:"type:varchar(64)"`
+	Operator       string `gorm:"type:varchar(8)"`
+	Threshold      float64
+	UpperThreshold float64
+	Severity       string `gorm:"type:varchar(16)"`
+	Hysteresis     float64
+}
+
+func (v6AlertRule) TableName() string { return "alert_rules" }
+
+type v6Alert struct {
+	ID         uint   `gorm:"primaryKey"`
+	DeviceID   string `gorm:"index"`
+	Timestamp  time.Time
+	Type       string `gorm:"type:varchar(64)"`
+	Severity   string `gorm:"type:varchar(16)"`
+	RuleID     *uint  `gorm:"index"`
+	Message    string
+	State      string `gorm:"type:varchar(16);index"`
+	LastSeen   time.Time
+	Count      int
+	ResolvedAt *time.Time
+}
+
+func (v6Alert) TableName() string { return "alerts" }
+
+func upAlertState(tx *gorm.DB) error {
+	if err := addColumns(tx, &v6Config{}, "TemperatureHysteresis", "BatteryHysteresis"); err != nil {
+		return err
+	}
+	if err := addColumns(tx, &v6AlertRule{}, "Hysteresis"); err != nil {
+		return err
+	}
+	return addColumns(tx, &v6Alert{}, "State", "LastSeen", "Count", "ResolvedAt")
+}
+
+func downAlertState(tx *gorm.DB) error {
+	if err := dropColumns(tx, "alerts", []string{"idx_alerts_state"}, "state", "last_seen", "count", "resolved_at"); err != nil {
+		return err
+	}
+	if err := dropColumns(tx, "alert_rules", nil, "hysteresis"); err != nil {
+		return err
+	}
+	return dropColumns(tx, "configs", nil, "temperature_hysteresis", "battery_hysteresis")
+}
+
+// version 7, alert rules held for a duration or samples
+
+type v7AlertRule struct {
+	ID             uint   `gorm:"primaryKey"`
+	DeviceID       string `gorm:"index"`
+	Metric         string `gorm:"type:varchar(64)"`
+	Operator       string `gorm:"type:varchar(8)"`
+	Threshold      float64
+	UpperThreshold float64
+	Severity       string `gorm:"type:varchar(16)"`
+	Hysteresis     float64
+	HoldFor        time.Duration
+	HoldSamples    int
+}
+
+func (v7AlertRule) TableName() string { return "alert_rules" }
+
+// version 8, alert acknowledgement
+
+type v8Alert struct {
+	ID             uint   `gorm:"primaryKey"`
+	DeviceID       string `gorm:"index"`
+	Timestamp      time.Time
+	Type           string `gorm:"type:varchar(64)"`
+	Severity       string `gorm:"type:varchar(16)"`
+	RuleID         *uint  `gorm:"index"`
+	Message        string
+	State          string `gorm:"type:varchar(16);index"`
+	LastSeen       time.Time
+	Count          int
+	ResolvedAt     *time.Time
+	Status         string `gorm:"type:varchar(16);default:open;index"`
+	AcknowledgedBy string
+	AcknowledgedAt *time.Time
+	ResolvedBy     string
+	Note           string
+}
+
+func (v8Alert) TableName() string { return "alerts" }
+
+// version 9, webhook deliveries
+
+type v9WebhookDelivery struct {
+	ID         uint `gorm:"primaryKey"`
+	AlertID    uint `gorm:"index"`
+	URL        string
+	Event      string `gorm:"type:varchar(32)"`
+	Attempts   int
+	StatusCode int
+	Error      string
+	Delivered  bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (v9WebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+// version 10, device registry
+
+type v10Device struct {
+	ID         string `gorm:"primaryKey;type:varchar(128)"`
+	Name       string
+	Model      string
+	Firmware   string
+	Location   string
+	Enabled    bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	LastSeenAt *time.Time
+}
+
+func (v10Device) TableName() string { return "devices" }
+
+type v10DeviceTag struct {
+	DeviceID string `gorm:"primaryKey;type:varchar(128)"`
+	Tag      string `gorm:"primaryKey;type:varchar(64);index"`
+}
+
+func (v10DeviceTag) TableName() string { return "device_tags" }
+
+// version 11, device heartbeat
+
+type v11Config struct {
+	DeviceID              string `gorm:"primaryKey"`
+	TemperatureThreshold  float64
+	BatteryThreshold      float64
+	TemperatureHysteresis float64
+	BatteryHysteresis     float64
+	HeartbeatInterval     time.Duration
+	LastSeenAt            *time.Time
+}
+
+func (v11Config) TableName() string { return "configs" }
+
+// version 12, group configs
+
+type v12GroupConfig struct {
+	Tag                   string `gorm:"primaryKey;type:varchar(64)"`
+	Priority              int
+	TemperatureThreshold  *float64
+	BatteryThreshold      *float64
+	TemperatureHysteresis *float64
+	BatteryHysteresis     *float64
+	HeartbeatInterval     *time.Duration
+	UpdatedAt             time.Time
+}
+
+func (v12GroupConfig) TableName() string { return "group_configs" }
+
+// version 13, config versions
+
+type v13Config struct {
+	DeviceID              string `gorm:"primaryKey"`
+	TemperatureThreshold  float64
+	BatteryThreshold      float64
+	TemperatureHysteresis float64
+	BatteryHysteresis     float64
+	HeartbeatInterval     time.Duration
+	LastSeenAt            *time.Time
+	Version               uint64 `gorm:"not null;default:1"`
+}
+
+func (v13Config) TableName() string { return "configs" }
+
+// version 14, config history
+
+type v14ConfigChange struct {
+	ID        uint   `gorm:"primaryKey"`
+	DeviceID  string `gorm:"index"`
+	Timestamp time.Time
+	Version   uint64
+	// the json of the config values before and after the change
+	Old    string
+	New    string
+	Actor  string
+	Source string `gorm:"type:varchar(16)"`
+}
+
+func (v14ConfigChange) TableName() string { return "config_changes" }
+
+// version 15, group retention
+
+type v15GroupConfig struct {
+	Tag                   string `gorm:"primaryKey;type:varchar(64)"`
+	Priority              int
+	TemperatureThreshold  *float64
+	BatteryThreshold      *float64
+	TemperatureHysteresis *float64
+	BatteryHysteresis     *float64
+	HeartbeatInterval     *time.Duration
+	MetricRetention       *time.Duration
+	AlertRetention        *time.Duration
+	UpdatedAt             time.Time
+}
+
+func (v15GroupConfig) TableName() string { return "group_configs" }
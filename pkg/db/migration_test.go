@@ -0,0 +1,340 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+// openTempSqlite opens an empty sqlite file database, unlike GetInstance
+func openTempSqlite(t *testing.T) *gorm.DB {
+	conn, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+// tableSchemas describes the tables by their columns, indexes, foreign keys
+// and checks, regardless of the order of the columns, which differs between a
+// table created at once and one grown by migrations
+func tableSchemas(t *testing.T, conn *gorm.DB) map[string]string {
+	var tables []struct {
+		Name string
+		Sql  string
+	}
+	err := conn.Raw(`SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_version', 'sqlite_sequence')`).Scan(&tables).Error
+	if err != nil {
+		t.Fatalf("Failed to read sqlite schema: %v", err)
+	}
+
+	schemas := map[string]string{}
+	for _, table := range tables {
+		var lines []string
+
+		var columns []struct {
+			Name      string
+			Type      string
+			NotNull   bool `gorm:"column:notnull"`
+			DfltValue *string
+			Pk        int
+		}
+		if err := conn.Raw("SELECT name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?)", table.Name).Scan(&columns).Error; err != nil {
+			t.Fatalf("Failed to read columns of %s: %v", table.Name, err)
+		}
+		for _, column := range columns {
+			lines = append(lines, fmt.Sprintf("column %s %s notnull=%t default=%s pk=%d", column.Name, column.Type, column.NotNull, common.ValueOrZero(column.DfltValue), column.Pk))
+		}
+
+		var indexes []struct {
+			Name   string
+			Unique bool `gorm:"column:unique"`
+		}
+		if err := conn.Raw("SELECT name, \"unique\" FROM pragma_index_list(?) WHERE origin = 'c'", table.Name).Scan(&indexes).Error; err != nil {
+			t.Fatalf("Failed to read indexes of %s: %v", table.Name, err)
+		}
+		for _, index := range indexes {
+			var columns []string
+			if err := conn.Raw("SELECT name FROM pragma_index_info(?) ORDER BY seqno", index.Name).Scan(&columns).Error; err != nil {
+				t.Fatalf("Failed to read index %s: %v", index.Name, err)
+			}
+			lines = append(lines, fmt.Sprintf("index %s unique=%t (%s)", index.Name, index.Unique, strings.Join(columns, ", ")))
+		}
+
+		var keys []struct {
+			Table    string
+			From     string
+			To       string
+			OnDelete string
+		}
+		if err := conn.Raw(`SELECT "table", "from", "to", on_delete FROM pragma_foreign_key_list(?)`, table.Name).Scan(&keys).Error; err != nil {
+			t.Fatalf("Failed to read foreign keys of %s: %v", table.Name, err)
+		}
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("foreign key %s references %s(%s) on delete %s", key.From, key.Table, key.To, key.OnDelete))
+		}
+
+		for _, check := range regexp.MustCompile("CONSTRAINT `?(\\w+)`? CHECK").FindAllStringSubmatch(table.Sql, -1) {
+			lines = append(lines, "check "+check[1])
+		}
+
+		slices.Sort(lines)
+		schemas[table.Name] = strings.Join(lines, "\n")
+	}
+	return schemas
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	conn := openTempSqlite(t)
+
+	statuses, err := MigrateStatus(conn)
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	if len(statuses) != len(Migrations) {
+		t.Fatalf("Expected %d migrations, got %d", len(Migrations), len(statuses))
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Errorf("Expected migration %d to be pending", status.Version)
+		}
+	}
+
+	applied, err := MigrateUp(conn, 1)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("Expected to apply migration 1, got %v, %v", applied, err)
+	}
+	if !conn.Migrator().HasTable("metrics") || conn.Migrator().HasIndex("metrics", "idx_metrics_device_time") {
+		t.Error("Expected the initial schema without the time indexes")
+	}
+
+	applied, err = MigrateUp(conn, 0)
+	if err != nil || len(applied) != len(Migrations)-1 {
+		t.Fatalf("Expected to apply the remaining migrations, got %v, %v", applied, err)
+	}
+	if !conn.Migrator().HasIndex("metrics", "idx_metrics_device_time") {
+		t.Error("Expected the metric time index")
+	}
+	if version, err := CurrentVersion(conn); err != nil || version != LatestVersion() {
+		t.Errorf("Expected version %d, got %d, %v", LatestVersion(), version, err)
+	}
+
+	// nothing is left to apply
+	if applied, err := MigrateUp(conn, 0); err != nil || len(applied) != 0 {
+		t.Errorf("Expected no migrations to apply, got %v, %v", applied, err)
+	}
+
+	statuses, err = MigrateStatus(conn)
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied", status.Version)
+		}
+	}
+
+	// reverting the later steps loses data, which must be asked for
+	if _, err := MigrateDown(conn, 1, false); !errors.Is(err, ErrDestructive) {
+		t.Errorf("Expected ErrDestructive, got %v", err)
+	}
+	if version, err := CurrentVersion(conn); err != nil || version != LatestVersion() {
+		t.Errorf("Expected to stay at version %d, got %d, %v", LatestVersion(), version, err)
+	}
+
+	reverted, err := MigrateDown(conn, 1, true)
+	if err != nil || len(reverted) != len(Migrations)-1 {
+		t.Fatalf("Expected to revert down to version 1, got %v, %v", reverted, err)
+	}
+	if conn.Migrator().HasIndex("metrics", "idx_metrics_device_time") {
+		t.Error("Expected the metric time index to be dropped")
+	}
+	if version, err := CurrentVersion(conn); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %d, %v", version, err)
+	}
+
+	initial := openTempSqlite(t)
+	if _, err := MigrateUp(initial, 1); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	want, got := tableSchemas(t, initial), tableSchemas(t, conn)
+	if len(got) != len(want) {
+		t.Errorf("Expected tables %v, got %v", want, got)
+	}
+	for table, schema := range want {
+		if got[table] != schema {
+			t.Errorf("Expected table %s to be\n%s\ngot\n%s", table, schema, got[table])
+		}
+	}
+
+	// the initial schema is never reverted
+	if _, err := MigrateDown(conn, 0, true); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("Expected ErrIrreversible, got %v", err)
+	}
+	if !conn.Migrator().HasTable("metrics") || !conn.Migrator().HasTable("configs") {
+		t.Error("Expected the tables to be kept")
+	}
+	if version, err := CurrentVersion(conn); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %d, %v", version, err)
+	}
+
+	if _, err := MigrateUp(conn, 99); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestMigrateFailedStep(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	conn := openTempSqlite(t)
+	if _, err := MigrateUp(conn, 0); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	original := Migrations
+	defer func() { Migrations = original }()
+	Migrations = append(append([]Migration{}, original...), Migration{
+		Version: LatestVersion() + 1,
+		Name:    "broken",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("ALTER TABLE metrics ADD COLUMN broken INTEGER").Error; err != nil {
+				return err
+			}
+			return errors.New("broken")
+		},
+		Down: func(tx *gorm.DB) error { return nil },
+	})
+
+	if _, err := MigrateUp(conn, 0); err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+	// the step was rolled back as a whole
+	if conn.Migrator().HasColumn("metrics", "broken") {
+		t.Error("Expected the column of the failed step to be rolled back")
+	}
+	if version, err := CurrentVersion(conn); err != nil || version != original[len(original)-1].Version {
+		t.Errorf("Expected to stay at version %d, got %d, %v", original[len(original)-1].Version, version, err)
+	}
+}
+
+func TestMigrateFromInitialSchema(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	conn := openTempSqlite(t)
+	if _, err := MigrateUp(conn, 1); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	now := time.Now()
+	if err := conn.Create(&initialConfig{DeviceID: "d1", TemperatureThreshold: 30.0}).Error; err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if err := conn.Create(&initialMetric{DeviceID: "d1", Timestamp: now, Temperature: 35.0}).Error; err != nil {
+		t.Fatalf("Failed to create metric: %v", err)
+	}
+	if err := conn.Create(&initialAlert{DeviceID: "d1", Timestamp: now, Type: "temperature", Message: "too hot"}).Error; err != nil {
+		t.Fatalf("Failed to create alert: %v", err)
+	}
+	if err := conn.Create(&initialAlert{DeviceID: "d1", Timestamp: now, Type: "humidity"}).Error; err == nil {
+		t.Error("Expected the initial schema to refuse other alert types")
+	}
+
+	if _, err := MigrateUp(conn, 0); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	var alert models.Alert
+	if err := conn.Where("device_id = ?", "d1").First(&alert).Error; err != nil || alert.Type != "temperature" || alert.Message != "too hot" {
+		t.Errorf("Expected the alert to be kept, got %+v, %v", alert, err)
+	}
+	if err := conn.Create(&models.Alert{DeviceID: "d1", Timestamp: now, Type: "humidity"}).Error; err != nil {
+		t.Errorf("Expected alerts of any metric, got %v", err)
+	}
+	var config models.Config
+	if err := conn.Where("device_id = ?", "d1").First(&config).Error; err != nil || common.ValueOrZero(config.TemperatureThreshold) != 30.0 || config.Version != 1 {
+		t.Errorf("Expected the config to be kept, got %+v, %v", config, err)
+	}
+	for _, index := range []string{"idx_alerts_device_id", "idx_alerts_device_time", "idx_metrics_device_time"} {
+		if !conn.Migrator().HasIndex("alerts", index) && !conn.Migrator().HasIndex("metrics", index) {
+			t.Errorf("Expected the index %s to be kept", index)
+		}
+	}
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	migrated := openTempSqlite(t)
+	if _, err := MigrateUp(migrated, 0); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	autoMigrated := openTempSqlite(t)
+	err := autoMigrated.AutoMigrate(&models.Config{}, &models.Metric{}, &models.MetricValue{}, &models.Alert{}, &models.AlertRule{}, &models.WebhookDelivery{}, &models.Device{}, &models.DeviceTag{}, &models.GroupConfig{}, &models.ConfigChange{})
+	if err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+	// the time indexes are not declared on the models
+	if err := Migrations[1].Up(autoMigrated); err != nil {
+		t.Fatalf("Failed to create the time indexes: %v", err)
+	}
+
+	want := tableSchemas(t, autoMigrated)
+	got := tableSchemas(t, migrated)
+	if len(got) != len(want) {
+		t.Errorf("Expected tables %v, got %v", want, got)
+	}
+	for table, schema := range want {
+		if got[table] != schema {
+			t.Errorf("Expected table %s to be\n%s\ngot\n%s", table, schema, got[table])
+		}
+	}
+}
+
+func TestMigrateAdoptsAutoMigratedDatabase(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	// a database created by AutoMigrate before there were versioned migrations
+	conn := openTempSqlite(t)
+	if err := conn.AutoMigrate(&models.Config{}, &models.Metric{}, &models.MetricValue{}, &models.Alert{}); err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+	threshold := 30.0
+	if err := conn.Create(&models.Config{DeviceID: "d1", TemperatureThreshold: &threshold}).Error; err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if err := conn.Create(&models.Metric{DeviceID: "d1", Timestamp: time.Now(), Temperature: 20.0}).Error; err != nil {
+		t.Fatalf("Failed to create metric: %v", err)
+	}
+
+	if _, err := MigrateUp(conn, 0); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	var count int64
+	if err := conn.Model(&models.Metric{}).Where("device_id = ?", "d1").Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("Expected the metric to be kept, got %d, %v", count, err)
+	}
+	if !conn.Migrator().HasTable("device_tags") {
+		t.Error("Expected the missing tables to be created")
+	}
+	if version, err := CurrentVersion(conn); err != nil || version != LatestVersion() {
+		t.Errorf("Expected version %d, got %d, %v", LatestVersion(), version, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"go.uber.org/mock/gomock"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
@@ -18,6 +19,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"liyu1981.xyz/iot-metrics-service/pkg/common"
 	"liyu1981.xyz/iot-metrics-service/pkg/db"
@@ -32,6 +34,10 @@ const bufSize = 1024 * 1024
 
 var listener *bufconn.Listener
 
+func ptr[T any](v T) *T {
+	return &v
+}
+
 func dialer() func(context.Context, string) (net.Conn, error) {
 	return func(ctx context.Context, s string) (net.Conn, error) {
 		return listener.Dial()
@@ -39,22 +45,34 @@ func dialer() func(context.Context, string) (net.Conn, error) {
 }
 
 func startTestServer(t *testing.T) pb.IOTServiceClient {
+	client, _ := startTestServerWithBroker(t, nil)
+	return client
+}
+
+func startTestServerWithBroker(t *testing.T, broker *iot.AlertBroker) (pb.IOTServiceClient, *iot.IOT) {
 	listener = bufconn.Listen(bufSize)
 
 	iotCore := iot.IOT{
-		Db: *db.GetInstance(db.UseMemorySqliteDialector()),
+		Db:     *db.GetInstance(db.UseMemorySqliteDialector()),
+		Broker: broker,
 	}
-	iotCore.WithServices(iot.ServiceOpts{
+	if _, err := iotCore.WithServices(iot.ServiceOpts{
 		Metric: iotCore.GetIMetric(),
 		Alert:  iotCore.GetIAlert(),
 		Config: iotCore.GetIConfig(),
-	})
+		Rule:   iotCore.GetIRule(),
+		Device: iotCore.GetIDevice(),
+		Group:  iotCore.GetIGroup(),
+	}); err != nil {
+		t.Fatalf("Failed to set up services: %v", err)
+	}
 
 	iotServer := IOTServer{Iot: &iotCore}
 	interceptor := grpc.UnaryInterceptor(iotServer.CreateRateLimitInterceptor([]proto.Message{
 		&pb.PostMetricsRequest{},
 		&pb.UpdateConfigRequest{},
 		&pb.DeviceRequest{},
+		&pb.GetAlertsRequest{},
 	}))
 	server := grpc.NewServer(interceptor)
 	pb.RegisterIOTServiceServer(server, &iotServer)
@@ -69,7 +87,7 @@ func startTestServer(t *testing.T) pb.IOTServiceClient {
 	)
 	require.NoError(t, err)
 
-	return pb.NewIOTServiceClient(conn)
+	return pb.NewIOTServiceClient(conn), &iotCore
 }
 
 func TestPostMetricsAndGetAlerts(t *testing.T) {
@@ -97,10 +115,29 @@ func TestPostMetricsAndGetAlerts(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	resp, err := client.GetAlerts(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	resp, err := client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID})
 	require.NoError(t, err)
 	require.True(t, resp.Status.Success)
 	require.Len(t, resp.Alerts, 2)
+
+	// the same breach again is counted on the firing alerts
+	_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric: &pb.MetricRequest{
+			Timestamp:   timestamppb.New(time.Now()),
+			Temperature: 36.0,
+			Battery:     10.0,
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err = client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.Len(t, resp.Alerts, 2)
+	for _, alert := range resp.Alerts {
+		assert.Equal(t, "firing", alert.State)
+		assert.Equal(t, int64(2), alert.Count)
+	}
 }
 
 func TestPostMetricsEdgeCases(t *testing.T) {
@@ -126,19 +163,28 @@ func startTestServerWithInterceptor(t *testing.T, limiterStore *iot.RateLimiterS
 	iotCore := iot.IOT{
 		Db: *db.GetInstance(db.UseMemorySqliteDialector()),
 	}
-	iotCore.WithServices(iot.ServiceOpts{
+	if _, err := iotCore.WithServices(iot.ServiceOpts{
 		Metric: iotCore.GetIMetric(),
 		Alert:  iotCore.GetIAlert(),
 		Config: iotCore.GetIConfig(),
-	})
+		Rule:   iotCore.GetIRule(),
+		Device: iotCore.GetIDevice(),
+		Group:  iotCore.GetIGroup(),
+	}); err != nil {
+		t.Fatalf("Failed to set up services: %v", err)
+	}
 
 	iotServer := IOTServer{Iot: &iotCore, RateLimiterStore: limiterStore}
 	interceptor := iotServer.CreateRateLimitInterceptor([]proto.Message{
 		&pb.PostMetricsRequest{},
 		&pb.UpdateConfigRequest{},
 		&pb.DeviceRequest{},
+		&pb.GetAlertsRequest{},
+	})
+	streamInterceptor := iotServer.CreateStreamRateLimitInterceptor([]proto.Message{
+		&pb.PostMetricsRequest{},
 	})
-	server := grpc.NewServer(grpc.UnaryInterceptor(interceptor))
+	server := grpc.NewServer(grpc.UnaryInterceptor(interceptor), grpc.StreamInterceptor(streamInterceptor))
 	pb.RegisterIOTServiceServer(server, &iotServer)
 
 	go func() {
@@ -214,6 +260,154 @@ func TestRateLimitInterceptor_PostMetrics(t *testing.T) {
 	require.NoError(t, err, "expected request after sleep to pass")
 }
 
+func TestStreamMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 30.0,
+			BatteryThreshold:     20.0,
+		},
+	})
+	require.NoError(t, err)
+
+	stream, err := client.StreamMetrics(context.Background())
+	require.NoError(t, err)
+
+	for i := range 3 {
+		err = stream.Send(&pb.PostMetricsRequest{
+			DeviceId: deviceID,
+			Metric: &pb.MetricRequest{
+				Timestamp:   timestamppb.New(time.Now()),
+				Temperature: float64(20 + i),
+				Battery:     50.0,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	// one invalid message and one for a device without config
+	err = stream.Send(&pb.PostMetricsRequest{DeviceId: deviceID, Metric: &pb.MetricRequest{Temperature: 20, Battery: 50}})
+	require.NoError(t, err)
+	err = stream.Send(&pb.PostMetricsRequest{
+		DeviceId: uuid.NewString(),
+		Metric:   &pb.MetricRequest{Timestamp: timestamppb.New(time.Now()), Temperature: 20, Battery: 50},
+	})
+	require.NoError(t, err)
+
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.False(t, resp.Status.Success)
+	assert.Equal(t, int64(5), resp.Received)
+	assert.Equal(t, int64(3), resp.Accepted)
+	assert.Equal(t, int64(2), resp.Rejected)
+	assert.Equal(t, int64(0), resp.RateLimited)
+
+	metrics, err := client.GetMetrics(context.Background(), &pb.GetMetricsRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	assert.Len(t, metrics.Metrics, 3)
+}
+
+func TestStreamRateLimitInterceptor_StreamMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	limiterStore := iot.NewRateLimiterStore(0.1, 2) // burst of 2, then very slow refill
+	client := startTestServerWithInterceptor(t, limiterStore)
+
+	deviceID := uuid.NewString()
+
+	// relax the limiter for the config call, so the stream starts with a full burst
+	limiterStore.SetLimiter(deviceID, 1000, 1000)
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 30.0,
+			BatteryThreshold:     20.0,
+		},
+	})
+	require.NoError(t, err)
+	limiterStore.SetLimiter(deviceID, 0.1, 2)
+
+	stream, err := client.StreamMetrics(context.Background())
+	require.NoError(t, err)
+
+	for range 4 {
+		err = stream.Send(&pb.PostMetricsRequest{
+			DeviceId: deviceID,
+			Metric: &pb.MetricRequest{
+				Timestamp:   timestamppb.New(time.Now()),
+				Temperature: 25.0,
+				Battery:     50.0,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.False(t, resp.Status.Success)
+	assert.Equal(t, int64(4), resp.Received)
+	assert.Equal(t, int64(2), resp.Accepted)
+	assert.Equal(t, int64(2), resp.RateLimited)
+}
+
+func TestWatchAlerts(t *testing.T) {
+	common.SetTestLoggerNop()
+	client, iotCore := startTestServerWithBroker(t, iot.NewAlertBroker(8))
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 30.0,
+			BatteryThreshold:     20.0,
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchAlerts(ctx, &pb.WatchAlertsRequest{DeviceId: deviceID, Types: []string{"temperature"}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return iotCore.Broker.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric: &pb.MetricRequest{
+			Timestamp:   timestamppb.New(time.Now()),
+			Temperature: 35.0,
+			Battery:     10.0,
+		},
+	})
+	require.NoError(t, err)
+
+	alert, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, deviceID, alert.DeviceId)
+	assert.Equal(t, "temperature", alert.Type)
+
+	cancel()
+	require.Eventually(t, func() bool { return iotCore.Broker.SubscriberCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchAlerts_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	// without broker the stream fails right away
+	stream, err := client.WatchAlerts(context.Background(), &pb.WatchAlertsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
 func startTestServerWithMocks(t *testing.T, useMockIMetric, useMockIAlert, useMockIConfig bool) (
 	*gomock.Controller,
 	pb.IOTServiceClient,
@@ -244,17 +438,23 @@ func startTestServerWithMocks(t *testing.T, useMockIMetric, useMockIAlert, useMo
 	if useMockIConfig {
 		iConfig = iMockConfig
 	}
-	iotCore.WithServices(iot.ServiceOpts{
+	if _, err := iotCore.WithServices(iot.ServiceOpts{
 		Metric: iMetric,
 		Alert:  iAlert,
 		Config: iConfig,
-	})
+		Rule:   iotCore.GetIRule(),
+		Device: iotCore.GetIDevice(),
+		Group:  iotCore.GetIGroup(),
+	}); err != nil {
+		t.Fatalf("Failed to set up services: %v", err)
+	}
 
 	iotServer := IOTServer{Iot: &iotCore}
 	interceptor := grpc.UnaryInterceptor(iotServer.CreateRateLimitInterceptor([]proto.Message{
 		&pb.PostMetricsRequest{},
 		&pb.UpdateConfigRequest{},
 		&pb.DeviceRequest{},
+		&pb.GetAlertsRequest{},
 	}))
 	server := grpc.NewServer(interceptor)
 	pb.RegisterIOTServiceServer(server, &iotServer)
@@ -300,6 +500,21 @@ func TestPostConfig_EdgeCases(t *testing.T) {
 			assert.False(t, r.Status.Success, "expected UpdateConfig to fail")
 			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected UpdateConfig to fail with validation error")
 		}
+
+		{
+			// negative heartbeat interval will fail validation
+			r, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+				DeviceId: deviceID,
+				Config: &pb.ConfigRequest{
+					TemperatureThreshold: 30.0,
+					BatteryThreshold:     30.0,
+					HeartbeatInterval:    durationpb.New(-time.Minute),
+				},
+			})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected UpdateConfig to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected UpdateConfig to fail with validation error")
+		}
 	}
 
 	{
@@ -311,7 +526,7 @@ func TestPostConfig_EdgeCases(t *testing.T) {
 		{
 			// internal error should fail too
 			mockIConfig.EXPECT().
-				UpsertConfig(gomock.Eq(deviceID), gomock.Any()).
+				UpsertConfig(gomock.Eq(deviceID), gomock.Any(), gomock.Any()).
 				Return(fmt.Errorf("test error")).
 				Times(1)
 			r, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
@@ -328,126 +543,1110 @@ func TestPostConfig_EdgeCases(t *testing.T) {
 	}
 }
 
-func TestGetAlerts_EdgeCases(t *testing.T) {
+func TestUpdateConfig_Heartbeat(t *testing.T) {
 	common.SetTestLoggerNop()
+	client, iotCore := startTestServerWithBroker(t, nil)
 
-	{
-		client := startTestServer(t)
-		// deviceID := uuid.NewString()
+	deviceID := uuid.NewString()
 
-		{
-			// empty DeviceId will fail validation
-			r, err := client.GetAlerts(context.Background(), &pb.DeviceRequest{DeviceId: ""})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected GetAlerts to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected GetAlerts to fail with validation error")
-		}
-	}
+	r, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 30.0,
+			BatteryThreshold:     1.0,
+			HeartbeatInterval:    durationpb.New(5 * time.Minute),
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, r.Status.Success, r.Status.Message)
 
-	{
-		ctrl, client, _, mockIAlert, _ := startTestServerWithMocks(t, false, true, false)
-		defer ctrl.Finish()
+	_, err = client.CreateDevice(context.Background(), &pb.DeviceInfoRequest{Device: &pb.Device{Id: deviceID}})
+	require.NoError(t, err)
+	_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric:   &pb.MetricRequest{Timestamp: timestamppb.New(time.Now()), Temperature: 25.0, Battery: 50.0},
+	})
+	require.NoError(t, err)
 
-		deviceID := uuid.NewString()
+	config, err := iotCore.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, ptr(5*time.Minute), config.HeartbeatInterval)
+	require.NotNil(t, config.LastSeenAt)
 
-		{
-			// internal error should fail too
-			mockIAlert.EXPECT().
-				GetDeviceAlerts(gomock.Eq(deviceID)).
-				Return(nil, fmt.Errorf("test error")).
-				Times(1)
-			r, err := client.GetAlerts(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected GetAlerts to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "test error"), "expected GetAlerts to fail with test error")
-		}
-	}
+	device, err := client.GetDevice(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.NotNil(t, device.Device.LastSeenAt)
+	assert.True(t, config.LastSeenAt.Equal(device.Device.LastSeenAt.AsTime()))
 }
 
-func TestPostMetrics_EdgeCases(t *testing.T) {
+func TestUpdateConfig_Patch(t *testing.T) {
 	common.SetTestLoggerNop()
+	client := startTestServer(t)
 
-	{
-		client := startTestServer(t)
-		deviceID := uuid.NewString()
+	deviceID := uuid.NewString()
 
-		{
-			// empty DeviceId will fail validation
-			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{DeviceId: ""})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
-		}
+	r, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config:   &pb.ConfigRequest{TemperatureThreshold: 30.0, BatteryThreshold: 20.0},
+	})
+	require.NoError(t, err)
+	require.True(t, r.Status.Success)
+	// the config is only returned for patches and conditional updates
+	assert.Nil(t, r.Config)
+
+	battery := 15.0
+	r, err = client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId:        deviceID,
+		Patch:           &pb.ConfigPatch{BatteryThreshold: &battery},
+		ExpectedVersion: 1,
+	})
+	require.NoError(t, err)
+	require.True(t, r.Status.Success, r.Status.Message)
+	assert.Equal(t, uint64(2), r.Config.Version)
+	assert.Equal(t, 30.0, r.Config.TemperatureThreshold)
+	assert.Equal(t, 15.0, r.Config.BatteryThreshold)
 
-		{
-			// empty temperature or battery will fail validation
-			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
-				DeviceId: deviceID,
-				Metric:   &pb.MetricRequest{},
-			})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
-		}
+	_, err = client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId:        deviceID,
+		Patch:           &pb.ConfigPatch{BatteryThreshold: &battery},
+		ExpectedVersion: 1,
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
 
-		{
-			// empty timestamp will fail validate too
-			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
-				DeviceId: deviceID,
-				Metric: &pb.MetricRequest{
-					Temperature: 30.0,
-					Battery:     30.0,
-				},
-			})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
-		}
+	_, err = client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId:        deviceID,
+		Config:          &pb.ConfigRequest{TemperatureThreshold: 40.0, BatteryThreshold: 20.0},
+		ExpectedVersion: 1,
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
 
-		{
-			// zero timestamp will fail validate too
-			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
-				DeviceId: deviceID,
-				Metric: &pb.MetricRequest{
-					Timestamp:   timestamppb.New(time.Time{}),
-					Temperature: 30.0,
-					Battery:     30.0,
-				},
-			})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
-		}
+	r, err = client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId:        deviceID,
+		Config:          &pb.ConfigRequest{TemperatureThreshold: 40.0, BatteryThreshold: 20.0},
+		ExpectedVersion: 2,
+	})
+	require.NoError(t, err)
+	require.True(t, r.Status.Success, r.Status.Message)
+	assert.Equal(t, uint64(3), r.Config.Version)
+	assert.Equal(t, 40.0, r.Config.TemperatureThreshold)
+
+	got, err := client.GetConfig(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), got.Config.Version)
+
+	for _, patch := range []*pb.ConfigPatch{{}, {HeartbeatInterval: durationpb.New(-time.Minute)}} {
+		r, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{DeviceId: deviceID, Patch: patch})
+		require.NoError(t, err)
+		assert.False(t, r.Status.Success)
+		assert.True(t, strings.Contains(r.Status.Message, "validation error"), r.Status.Message)
 	}
 }
 
-func TestPostLimiter_EdgeCases(t *testing.T) {
+func TestGetAndDeleteConfig(t *testing.T) {
 	common.SetTestLoggerNop()
+	client := startTestServer(t)
 
-	{
-		client := startTestServer(t)
-		deviceID := uuid.NewString()
+	deviceID := uuid.NewString()
 
-		{
-			// empty DeviceId will fail validation
-			r, err := client.PostLimiter(context.Background(), &pb.PostLimiterRequest{DeviceId: ""})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected PostLimiter to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostLimiter to fail with validation error")
-		}
+	_, err := client.GetConfig(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
 
-		{
-			// empty rate or burst will fail validation
-			r, err := client.PostLimiter(context.Background(), &pb.PostLimiterRequest{
-				DeviceId: deviceID,
-			})
-			assert.NoError(t, err)
-			assert.False(t, r.Status.Success, "expected PostLimiter to fail")
-			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostLimiter to fail with validation error")
-		}
+	_, err = client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config:   &pb.ConfigRequest{TemperatureThreshold: 30.0, BatteryThreshold: 1.0, HeartbeatInterval: durationpb.New(time.Minute)},
+	})
+	require.NoError(t, err)
 
-		{
-			// empty rate or burst will fail validation
+	got, err := client.GetConfig(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.True(t, got.Status.Success)
+	assert.Equal(t, deviceID, got.Config.DeviceId)
+	assert.Equal(t, 30.0, got.Config.TemperatureThreshold)
+	assert.Equal(t, time.Minute, got.Config.HeartbeatInterval.AsDuration())
+	assert.Nil(t, got.Config.LastSeenAt)
+
+	_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric:   &pb.MetricRequest{Timestamp: timestamppb.New(time.Now()), Temperature: 25.0, Battery: 50.0},
+	})
+	require.NoError(t, err)
+
+	// deleting resets the config of a device with metrics
+	deleted, err := client.DeleteConfig(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.True(t, deleted.Status.Success)
+
+	got, err = client.GetConfig(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	assert.Zero(t, got.Config.TemperatureThreshold)
+	assert.Nil(t, got.Config.HeartbeatInterval)
+	assert.NotNil(t, got.Config.LastSeenAt)
+
+	_, err = client.DeleteConfig(context.Background(), &pb.DeviceRequest{DeviceId: uuid.NewString()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	resp, err := client.GetConfig(context.Background(), &pb.DeviceRequest{DeviceId: ""})
+	require.NoError(t, err)
+	assert.False(t, resp.Status.Success)
+}
+
+func TestGetConfigHistory(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-actor", "bob")
+
+	_, err := client.UpdateConfig(ctx, &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config:   &pb.ConfigRequest{TemperatureThreshold: 30.0, BatteryThreshold: 20.0},
+	})
+	require.NoError(t, err)
+	_, err = client.DeleteConfig(ctx, &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+
+	resp, err := client.GetConfigHistory(context.Background(), &pb.GetConfigHistoryRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Changes, 2)
+
+	reset, created := resp.Changes[0], resp.Changes[1]
+	assert.Nil(t, created.Old)
+	assert.Equal(t, 30.0, created.New.TemperatureThreshold)
+	assert.Equal(t, uint64(1), created.Version)
+	assert.Equal(t, "bob", created.Actor)
+	assert.Equal(t, "grpc", created.Source)
+	assert.Equal(t, 20.0, reset.Old.BatteryThreshold)
+	assert.Zero(t, reset.New.BatteryThreshold)
+	assert.Equal(t, uint64(2), reset.Version)
+
+	for _, req := range []*pb.GetConfigHistoryRequest{{}, {DeviceId: deviceID, Limit: -1}, {DeviceId: deviceID, Cursor: "!"}} {
+		resp, err := client.GetConfigHistory(context.Background(), req)
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+		assert.True(t, strings.Contains(resp.Status.Message, "validation error"), resp.Status.Message)
+	}
+}
+
+func TestGetAlerts_Query(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 30.0,
+			BatteryThreshold:     20.0,
+		},
+	})
+	require.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// raises a temperature and a battery alert
+	r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric: &pb.MetricRequest{
+			Timestamp:   timestamppb.New(base),
+			Temperature: 40.0,
+			Battery:     10.0,
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, r.Status.Success)
+
+	resp, err := client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID, Limit: 1})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Alerts, 1)
+	require.NotEmpty(t, resp.NextCursor)
+	first := resp.Alerts[0].Type
+
+	resp, err = client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID, Limit: 1, Cursor: resp.NextCursor})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Alerts, 1)
+	assert.NotEqual(t, first, resp.Alerts[0].Type)
+	assert.Empty(t, resp.NextCursor)
+
+	resp, err = client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID, Types: []string{"battery"}})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Alerts, 1)
+	assert.Equal(t, "battery", resp.Alerts[0].Type)
+
+	// alerts are stamped when raised, not with the metric timestamp
+	resp, err = client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID, From: timestamppb.New(time.Now().Add(time.Hour))})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	assert.Len(t, resp.Alerts, 0)
+}
+
+func TestListAlerts(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	prefix := "fleet_" + uuid.NewString() + "/"
+	deviceA, deviceB := prefix+"a", prefix+"b"
+
+	for _, metric := range []struct {
+		deviceID    string
+		temperature float64
+		battery     float64
+	}{
+		{deviceA, 40.0, 10.0},
+		{deviceB, 40.0, 50.0},
+	} {
+		_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+			DeviceId: metric.deviceID,
+			Config:   &pb.ConfigRequest{TemperatureThreshold: 30.0, BatteryThreshold: 20.0},
+		})
+		require.NoError(t, err)
+		r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+			DeviceId: metric.deviceID,
+			Metric: &pb.MetricRequest{
+				Timestamp:   timestamppb.Now(),
+				Temperature: metric.temperature,
+				Battery:     metric.battery,
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, r.Status.Success)
+	}
+
+	resp, err := client.ListAlerts(context.Background(), &pb.ListAlertsRequest{DevicePrefix: prefix})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	assert.Len(t, resp.Alerts, 3)
+
+	resp, err = client.ListAlerts(context.Background(), &pb.ListAlertsRequest{DevicePrefix: prefix, Types: []string{"battery"}, State: "firing"})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Alerts, 1)
+	assert.Equal(t, deviceA, resp.Alerts[0].DeviceId)
+
+	resp, err = client.ListAlerts(context.Background(), &pb.ListAlertsRequest{DevicePrefix: prefix, Limit: 2})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Alerts, 2)
+	resp, err = client.ListAlerts(context.Background(), &pb.ListAlertsRequest{DevicePrefix: prefix, Limit: 2, Cursor: resp.NextCursor})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Alerts, 1)
+	assert.Empty(t, resp.NextCursor)
+
+	resp, err = client.ListAlerts(context.Background(), &pb.ListAlertsRequest{DevicePrefix: prefix, Summary: true})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	assert.Len(t, resp.Alerts, 0)
+	require.Len(t, resp.Summary, 2)
+	assert.Equal(t, deviceA, resp.Summary[0].DeviceId)
+	assert.Equal(t, int32(2), resp.Summary[0].Count)
+	assert.Equal(t, int32(2), resp.Summary[0].Firing)
+	assert.Equal(t, deviceB, resp.Summary[1].DeviceId)
+	assert.Equal(t, int32(1), resp.Summary[1].Count)
+	assert.NotNil(t, resp.Summary[1].LastAlertAt)
+}
+
+func TestListAlerts_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		client := startTestServer(t)
+
+		for _, req := range []*pb.ListAlertsRequest{
+			{Status: "closed"},
+			{State: "pending"},
+			{Limit: -1},
+			{Cursor: "bogus"},
+		} {
+			r, err := client.ListAlerts(context.Background(), req)
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected ListAlerts to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected ListAlerts to fail with validation error")
+		}
+	}
+
+	{
+		ctrl, client, _, mockIAlert, _ := startTestServerWithMocks(t, false, true, false)
+		defer ctrl.Finish()
+
+		mockIAlert.EXPECT().ListAlerts(gomock.Any()).Return(nil, fmt.Errorf("test error")).Times(1)
+		mockIAlert.EXPECT().SummarizeAlerts(gomock.Any()).Return(nil, fmt.Errorf("test error")).Times(1)
+
+		for _, req := range []*pb.ListAlertsRequest{{}, {Summary: true}} {
+			r, err := client.ListAlerts(context.Background(), req)
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected ListAlerts to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "test error"), "expected ListAlerts to fail with test error")
+		}
+	}
+}
+
+func TestGetAlerts_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		client := startTestServer(t)
+		// deviceID := uuid.NewString()
+
+		for _, req := range []*pb.GetAlertsRequest{
+			// empty DeviceId will fail validation
+			{DeviceId: ""},
+			{DeviceId: uuid.NewString(), Limit: -1},
+			{DeviceId: uuid.NewString(), Cursor: "bogus"},
+			{DeviceId: uuid.NewString(), State: "pending"},
+		} {
+			r, err := client.GetAlerts(context.Background(), req)
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected GetAlerts to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected GetAlerts to fail with validation error")
+		}
+	}
+
+	{
+		ctrl, client, _, mockIAlert, _ := startTestServerWithMocks(t, false, true, false)
+		defer ctrl.Finish()
+
+		deviceID := uuid.NewString()
+
+		{
+			// internal error should fail too
+			mockIAlert.EXPECT().
+				GetDeviceAlerts(gomock.Eq(deviceID), gomock.Any()).
+				Return(nil, fmt.Errorf("test error")).
+				Times(1)
+			r, err := client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected GetAlerts to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "test error"), "expected GetAlerts to fail with test error")
+		}
+	}
+}
+
+func TestPostMetrics_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		client := startTestServer(t)
+		deviceID := uuid.NewString()
+
+		{
+			// empty DeviceId will fail validation
+			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{DeviceId: ""})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
+		}
+
+		{
+			// empty temperature or battery will fail validation
+			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+				DeviceId: deviceID,
+				Metric:   &pb.MetricRequest{},
+			})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
+		}
+
+		{
+			// empty timestamp will fail validate too
+			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+				DeviceId: deviceID,
+				Metric: &pb.MetricRequest{
+					Temperature: 30.0,
+					Battery:     30.0,
+				},
+			})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
+		}
+
+		{
+			// zero timestamp will fail validate too
+			r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+				DeviceId: deviceID,
+				Metric: &pb.MetricRequest{
+					Timestamp:   timestamppb.New(time.Time{}),
+					Temperature: 30.0,
+					Battery:     30.0,
+				},
+			})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected PostMetrics to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetrics to fail with validation error")
+		}
+	}
+}
+
+func TestPostMetricsWithValues(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 100.0,
+			BatteryThreshold:     1.0,
+		},
+	})
+	require.NoError(t, err)
+
+	r, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric: &pb.MetricRequest{
+			Timestamp:   timestamppb.New(time.Now()),
+			Temperature: 25.0,
+			Battery:     50.0,
+			Values: []*pb.MetricValue{
+				{Name: "pressure", Value: 1013.2, Unit: "hPa"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, r.Status.Success)
+
+	// duplicated names are rejected
+	r, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric: &pb.MetricRequest{
+			Timestamp:   timestamppb.New(time.Now()),
+			Temperature: 25.0,
+			Battery:     50.0,
+			Values: []*pb.MetricValue{
+				{Name: "pressure", Value: 1013.2},
+				{Name: "pressure", Value: 1013.3},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.False(t, r.Status.Success)
+	assert.True(t, strings.Contains(r.Status.Message, "validation error"))
+
+	resp, err := client.GetMetrics(context.Background(), &pb.GetMetricsRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.Len(t, resp.Metrics, 1)
+	require.Len(t, resp.Metrics[0].Values, 1)
+	assert.Equal(t, "pressure", resp.Metrics[0].Values[0].Name)
+	assert.Equal(t, 1013.2, resp.Metrics[0].Values[0].Value)
+	assert.Equal(t, "hPa", resp.Metrics[0].Values[0].Unit)
+}
+
+func TestPostMetricsBatch(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 30.0,
+			BatteryThreshold:     20.0,
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err := client.PostMetricsBatch(context.Background(), &pb.PostMetricsBatchRequest{
+		DeviceId: deviceID,
+		Metrics: []*pb.MetricRequest{
+			{Timestamp: timestamppb.New(time.Now()), Temperature: 25.0, Battery: 50.0},
+			{Temperature: 25.0, Battery: 50.0},
+			{Timestamp: timestamppb.New(time.Now()), Temperature: 35.0, Battery: 50.0},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Results, 3)
+	assert.True(t, resp.Results[0].Success)
+	assert.False(t, resp.Results[1].Success)
+	assert.True(t, strings.Contains(resp.Results[1].Message, "validation error"))
+	assert.True(t, resp.Results[2].Success)
+
+	metrics, err := client.GetMetrics(context.Background(), &pb.GetMetricsRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	assert.Len(t, metrics.Metrics, 2)
+
+	alerts, err := client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	assert.Len(t, alerts.Alerts, 1)
+}
+
+func TestPostMetricsBatch_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		client := startTestServer(t)
+		deviceID := uuid.NewString()
+
+		for _, req := range []*pb.PostMetricsBatchRequest{
+			{DeviceId: ""},
+			{DeviceId: deviceID},
+			// nothing in the batch is valid
+			{DeviceId: deviceID, Metrics: []*pb.MetricRequest{{Temperature: 25.0, Battery: 50.0}}},
+		} {
+			r, err := client.PostMetricsBatch(context.Background(), req)
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected PostMetricsBatch to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostMetricsBatch to fail with validation error")
+		}
+	}
+
+	{
+		ctrl, client, mockIMetric, _, _ := startTestServerWithMocks(t, true, false, false)
+		defer ctrl.Finish()
+
+		deviceID := uuid.NewString()
+
+		mockIMetric.EXPECT().
+			UpsertMetrics(gomock.Eq(deviceID), gomock.Any()).
+			Return(fmt.Errorf("test error")).
+			Times(1)
+		r, err := client.PostMetricsBatch(context.Background(), &pb.PostMetricsBatchRequest{
+			DeviceId: deviceID,
+			Metrics: []*pb.MetricRequest{
+				{Timestamp: timestamppb.New(time.Now()), Temperature: 25.0, Battery: 50.0},
+			},
+		})
+		assert.NoError(t, err)
+		assert.False(t, r.Status.Success, "expected PostMetricsBatch to fail")
+		assert.True(t, strings.Contains(r.Status.Message, "test error"), "expected PostMetricsBatch to fail with test error")
+		require.Len(t, r.Results, 1)
+		assert.False(t, r.Results[0].Success)
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 100.0,
+			BatteryThreshold:     1.0,
+		},
+	})
+	require.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+			DeviceId: deviceID,
+			Metric: &pb.MetricRequest{
+				Timestamp:   timestamppb.New(base.Add(time.Duration(i) * time.Minute)),
+				Temperature: float64(20 + i),
+				Battery:     50.0,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	resp, err := client.GetMetrics(context.Background(), &pb.GetMetricsRequest{DeviceId: deviceID, Limit: 2})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Metrics, 2)
+	assert.Equal(t, 22.0, resp.Metrics[0].Temperature)
+	require.NotEmpty(t, resp.NextCursor)
+
+	resp, err = client.GetMetrics(context.Background(), &pb.GetMetricsRequest{DeviceId: deviceID, Limit: 2, Cursor: resp.NextCursor})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Metrics, 1)
+	assert.Equal(t, 20.0, resp.Metrics[0].Temperature)
+	assert.Empty(t, resp.NextCursor)
+
+	resp, err = client.GetMetrics(context.Background(), &pb.GetMetricsRequest{
+		DeviceId: deviceID,
+		From:     timestamppb.New(base.Add(1 * time.Minute)),
+		To:       timestamppb.New(base.Add(1 * time.Minute)),
+		Order:    "asc",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Metrics, 1)
+	assert.Equal(t, 21.0, resp.Metrics[0].Temperature)
+}
+
+func TestGetMetrics_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		client := startTestServer(t)
+		deviceID := uuid.NewString()
+
+		for _, req := range []*pb.GetMetricsRequest{
+			{DeviceId: ""},
+			{DeviceId: deviceID, Order: "sideways"},
+			{DeviceId: deviceID, Limit: -1},
+			{DeviceId: deviceID, Cursor: "bogus"},
+		} {
+			r, err := client.GetMetrics(context.Background(), req)
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected GetMetrics to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected GetMetrics to fail with validation error")
+		}
+	}
+
+	{
+		ctrl, client, mockIMetric, _, _ := startTestServerWithMocks(t, true, false, false)
+		defer ctrl.Finish()
+
+		deviceID := uuid.NewString()
+
+		mockIMetric.EXPECT().
+			GetDeviceMetrics(gomock.Eq(deviceID), gomock.Any()).
+			Return(nil, fmt.Errorf("test error")).
+			Times(1)
+		r, err := client.GetMetrics(context.Background(), &pb.GetMetricsRequest{DeviceId: deviceID})
+		assert.NoError(t, err)
+		assert.False(t, r.Status.Success, "expected GetMetrics to fail")
+		assert.True(t, strings.Contains(r.Status.Message, "test error"), "expected GetMetrics to fail with test error")
+	}
+}
+
+func TestAggregateMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 100.0,
+			BatteryThreshold:     1.0,
+		},
+	})
+	require.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+			DeviceId: deviceID,
+			Metric: &pb.MetricRequest{
+				Timestamp:   timestamppb.New(base.Add(time.Duration(i) * 30 * time.Minute)),
+				Temperature: float64(20 + i),
+				Battery:     float64(90 - i),
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	resp, err := client.AggregateMetrics(context.Background(), &pb.AggregateMetricsRequest{
+		DeviceId: deviceID,
+		Interval: durationpb.New(time.Hour),
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	require.Len(t, resp.Buckets, 2)
+	assert.Equal(t, int64(2), resp.Buckets[0].Count)
+	assert.Equal(t, 20.0, resp.Buckets[0].Temperature.Min)
+	assert.Equal(t, 21.0, resp.Buckets[0].Temperature.Max)
+	assert.Equal(t, 88.0, resp.Buckets[1].Battery.Max)
+	assert.True(t, base.Add(time.Hour).Equal(resp.Buckets[1].Start.AsTime()))
+}
+
+func TestAggregateMetrics_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		client := startTestServer(t)
+		deviceID := uuid.NewString()
+
+		for _, req := range []*pb.AggregateMetricsRequest{
+			{DeviceId: "", Interval: durationpb.New(time.Hour)},
+			{DeviceId: deviceID},
+			{DeviceId: deviceID, Interval: durationpb.New(time.Second)},
+		} {
+			r, err := client.AggregateMetrics(context.Background(), req)
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected AggregateMetrics to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected AggregateMetrics to fail with validation error")
+		}
+	}
+
+	{
+		ctrl, client, mockIMetric, _, _ := startTestServerWithMocks(t, true, false, false)
+		defer ctrl.Finish()
+
+		deviceID := uuid.NewString()
+
+		mockIMetric.EXPECT().
+			AggregateDeviceMetrics(gomock.Eq(deviceID), gomock.Any()).
+			Return(nil, fmt.Errorf("test error")).
+			Times(1)
+		r, err := client.AggregateMetrics(context.Background(), &pb.AggregateMetricsRequest{
+			DeviceId: deviceID,
+			Interval: durationpb.New(time.Hour),
+		})
+		assert.NoError(t, err)
+		assert.False(t, r.Status.Success, "expected AggregateMetrics to fail")
+		assert.True(t, strings.Contains(r.Status.Message, "test error"), "expected AggregateMetrics to fail with test error")
+	}
+}
+
+func TestAlertActions(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 30.0,
+			BatteryThreshold:     20.0,
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric: &pb.MetricRequest{
+			Timestamp:   timestamppb.New(time.Now()),
+			Temperature: 35.0,
+			Battery:     50.0,
+		},
+	})
+	require.NoError(t, err)
+
+	open, err := client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID, Status: "open"})
+	require.NoError(t, err)
+	require.Len(t, open.Alerts, 1)
+	id := open.Alerts[0].Id
+
+	acked, err := client.AcknowledgeAlert(context.Background(), &pb.AlertActionRequest{DeviceId: deviceID, Id: id, By: "alice", Note: "on it"})
+	require.NoError(t, err)
+	require.True(t, acked.Status.Success)
+	assert.Equal(t, "acknowledged", acked.Alert.Status)
+	assert.Equal(t, "alice", acked.Alert.AcknowledgedBy)
+	assert.NotNil(t, acked.Alert.AcknowledgedAt)
+
+	_, err = client.AcknowledgeAlert(context.Background(), &pb.AlertActionRequest{DeviceId: deviceID, Id: id, By: "alice"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	resolved, err := client.ResolveAlert(context.Background(), &pb.AlertActionRequest{DeviceId: deviceID, Id: id, By: "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "resolved", resolved.Alert.Status)
+	assert.Equal(t, "bob", resolved.Alert.ResolvedBy)
+	assert.Equal(t, "on it", resolved.Alert.Note)
+
+	open, err = client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID, Status: "open"})
+	require.NoError(t, err)
+	assert.Len(t, open.Alerts, 0)
+}
+
+func TestAlertActions_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	{
+		r, err := client.AcknowledgeAlert(context.Background(), &pb.AlertActionRequest{DeviceId: deviceID, Id: 1})
+		require.NoError(t, err)
+		assert.False(t, r.Status.Success)
+		assert.True(t, strings.Contains(r.Status.Message, "validation error"))
+	}
+
+	{
+		_, err := client.ResolveAlert(context.Background(), &pb.AlertActionRequest{DeviceId: deviceID, Id: 999999, By: "alice"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+
+	{
+		r, err := client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID, Status: "closed"})
+		require.NoError(t, err)
+		assert.False(t, r.Status.Success)
+		assert.True(t, strings.Contains(r.Status.Message, "validation error"))
+	}
+}
+
+func TestRules(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config: &pb.ConfigRequest{
+			TemperatureThreshold: 100.0,
+			BatteryThreshold:     1.0,
+		},
+	})
+	require.NoError(t, err)
+
+	created, err := client.CreateRule(context.Background(), &pb.RuleRequest{
+		DeviceId: deviceID,
+		Rule: &pb.AlertRule{
+			Metric:         "voltage",
+			Operator:       "outside",
+			Threshold:      3.0,
+			UpperThreshold: 3.6,
+			HoldFor:        durationpb.New(time.Minute),
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, created.Status.Success)
+	assert.Equal(t, time.Minute, created.Rule.HoldFor.AsDuration())
+	assert.NotZero(t, created.Rule.Id)
+	assert.Equal(t, "warning", created.Rule.Severity)
+
+	list, err := client.ListRules(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.True(t, list.Status.Success)
+	require.Len(t, list.Rules, 1)
+
+	updated, err := client.UpdateRule(context.Background(), &pb.RuleRequest{
+		DeviceId: deviceID,
+		Rule: &pb.AlertRule{
+			Id:             created.Rule.Id,
+			Metric:         "voltage",
+			Operator:       "outside",
+			Threshold:      3.0,
+			UpperThreshold: 3.3,
+			Severity:       "critical",
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, updated.Status.Success)
+
+	posted, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{
+		DeviceId: deviceID,
+		Metric: &pb.MetricRequest{
+			Timestamp:   timestamppb.New(time.Now()),
+			Temperature: 25.0,
+			Battery:     50.0,
+			Values:      []*pb.MetricValue{{Name: "voltage", Value: 3.4, Unit: "V"}},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, posted.Status.Success)
+
+	alerts, err := client.GetAlerts(context.Background(), &pb.GetAlertsRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.Len(t, alerts.Alerts, 1)
+	assert.Equal(t, "voltage", alerts.Alerts[0].Type)
+	assert.Equal(t, "critical", alerts.Alerts[0].Severity)
+	assert.Equal(t, created.Rule.Id, alerts.Alerts[0].RuleId)
+
+	deleted, err := client.DeleteRule(context.Background(), &pb.RuleIdRequest{DeviceId: deviceID, Id: created.Rule.Id})
+	require.NoError(t, err)
+	require.True(t, deleted.Status.Success)
+
+	_, err = client.GetRule(context.Background(), &pb.RuleIdRequest{DeviceId: deviceID, Id: created.Rule.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestRules_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	deviceID := uuid.NewString()
+
+	{
+		resp, err := client.CreateRule(context.Background(), &pb.RuleRequest{DeviceId: deviceID})
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+		assert.True(t, strings.Contains(resp.Status.Message, "validation error"))
+	}
+
+	{
+		resp, err := client.CreateRule(context.Background(), &pb.RuleRequest{
+			DeviceId: deviceID,
+			Rule:     &pb.AlertRule{Metric: "voltage", Operator: "<>"},
+		})
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+		assert.True(t, strings.Contains(resp.Status.Message, "validation error"))
+	}
+
+	{
+		resp, err := client.ListRules(context.Background(), &pb.DeviceRequest{DeviceId: ""})
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+	}
+
+	{
+		_, err := client.UpdateRule(context.Background(), &pb.RuleRequest{
+			DeviceId: deviceID,
+			Rule:     &pb.AlertRule{Id: 999999, Metric: "voltage", Operator: "<"},
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+
+	{
+		_, err := client.DeleteRule(context.Background(), &pb.RuleIdRequest{DeviceId: deviceID, Id: 999999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+}
+
+func TestDevices(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	prefix := "fleet_" + uuid.NewString() + "/"
+	deviceID := prefix + "1"
+
+	created, err := client.CreateDevice(context.Background(), &pb.DeviceInfoRequest{
+		Device: &pb.Device{Id: deviceID, Name: "Boiler room", Model: "TH-100", Tags: []string{"heating", "building-1"}},
+	})
+	require.NoError(t, err)
+	require.True(t, created.Status.Success, created.Status.Message)
+	assert.Equal(t, []string{"building-1", "heating"}, created.Device.Tags)
+	// enabled unless told otherwise
+	assert.True(t, created.Device.GetEnabled())
+	assert.Nil(t, created.Device.LastSeenAt)
+
+	_, err = client.CreateDevice(context.Background(), &pb.DeviceInfoRequest{Device: &pb.Device{Id: deviceID}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	disabled := false
+	_, err = client.CreateDevice(context.Background(), &pb.DeviceInfoRequest{
+		Device: &pb.Device{Id: prefix + "2", Enabled: &disabled},
+	})
+	require.NoError(t, err)
+
+	got, err := client.GetDevice(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	assert.Equal(t, "Boiler room", got.Device.Name)
+
+	updated, err := client.UpdateDevice(context.Background(), &pb.DeviceInfoRequest{
+		Device: &pb.Device{Id: deviceID, Name: "Boiler room", Firmware: "1.3.0", Tags: []string{"heating"}, Enabled: &disabled},
+	})
+	require.NoError(t, err)
+	require.True(t, updated.Status.Success)
+	assert.Equal(t, "1.3.0", updated.Device.Firmware)
+	assert.Empty(t, updated.Device.Model)
+	assert.False(t, updated.Device.GetEnabled())
+
+	list, err := client.ListDevices(context.Background(), &pb.ListDevicesRequest{Prefix: prefix, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, list.Devices, 1)
+	assert.Equal(t, deviceID, list.Devices[0].Id)
+	require.NotEmpty(t, list.NextCursor)
+
+	list, err = client.ListDevices(context.Background(), &pb.ListDevicesRequest{Prefix: prefix, Cursor: list.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, list.Devices, 1)
+	assert.Equal(t, prefix+"2", list.Devices[0].Id)
+	assert.Empty(t, list.NextCursor)
+
+	list, err = client.ListDevices(context.Background(), &pb.ListDevicesRequest{Prefix: prefix, Tag: "heating", Enabled: &disabled})
+	require.NoError(t, err)
+	require.Len(t, list.Devices, 1)
+	assert.Equal(t, deviceID, list.Devices[0].Id)
+
+	deleted, err := client.DeleteDevice(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.True(t, deleted.Status.Success)
+
+	_, err = client.GetDevice(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestDevices_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	for _, req := range []*pb.DeviceInfoRequest{
+		{},
+		{Device: &pb.Device{}},
+		{Device: &pb.Device{Id: uuid.NewString(), Tags: []string{"bad tag"}}},
+	} {
+		resp, err := client.CreateDevice(context.Background(), req)
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+		assert.True(t, strings.Contains(resp.Status.Message, "validation error"), resp.Status.Message)
+	}
+
+	for _, req := range []*pb.ListDevicesRequest{{Limit: -1}, {Cursor: "!"}} {
+		resp, err := client.ListDevices(context.Background(), req)
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+		assert.True(t, strings.Contains(resp.Status.Message, "validation error"), resp.Status.Message)
+	}
+
+	{
+		resp, err := client.GetDevice(context.Background(), &pb.DeviceRequest{DeviceId: ""})
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+	}
+
+	{
+		_, err := client.UpdateDevice(context.Background(), &pb.DeviceInfoRequest{Device: &pb.Device{Id: uuid.NewString()}})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+
+	{
+		_, err := client.DeleteDevice(context.Background(), &pb.DeviceRequest{DeviceId: uuid.NewString()})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+}
+
+func TestPostMetrics_RequireRegisteredDevices(t *testing.T) {
+	common.SetTestLoggerNop()
+	client, iotCore := startTestServerWithBroker(t, nil)
+	iotCore.RequireRegisteredDevices = true
+
+	deviceID := uuid.NewString()
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config:   &pb.ConfigRequest{TemperatureThreshold: 100.0, BatteryThreshold: 1.0},
+	})
+	require.NoError(t, err)
+
+	metric := &pb.MetricRequest{Timestamp: timestamppb.New(time.Now()), Temperature: 25.0, Battery: 50.0}
+
+	_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{DeviceId: deviceID, Metric: metric})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.PostMetricsBatch(context.Background(), &pb.PostMetricsBatchRequest{DeviceId: deviceID, Metrics: []*pb.MetricRequest{metric}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.CreateDevice(context.Background(), &pb.DeviceInfoRequest{Device: &pb.Device{Id: deviceID}})
+	require.NoError(t, err)
+
+	resp, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{DeviceId: deviceID, Metric: metric})
+	require.NoError(t, err)
+	assert.True(t, resp.Status.Success)
+}
+
+func TestPostLimiter_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		client := startTestServer(t)
+		deviceID := uuid.NewString()
+
+		{
+			// empty DeviceId will fail validation
+			r, err := client.PostLimiter(context.Background(), &pb.PostLimiterRequest{DeviceId: ""})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected PostLimiter to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostLimiter to fail with validation error")
+		}
+
+		{
+			// empty rate or burst will fail validation
+			r, err := client.PostLimiter(context.Background(), &pb.PostLimiterRequest{
+				DeviceId: deviceID,
+			})
+			assert.NoError(t, err)
+			assert.False(t, r.Status.Success, "expected PostLimiter to fail")
+			assert.True(t, strings.Contains(r.Status.Message, "validation error"), "expected PostLimiter to fail with validation error")
+		}
+
+		{
+			// empty rate or burst will fail validation
 			r, err := client.PostLimiter(context.Background(), &pb.PostLimiterRequest{
 				DeviceId:   deviceID,
 				DeviceRate: 3.0,
@@ -475,3 +1674,169 @@ func TestPostLimiter_EdgeCases(t *testing.T) {
 		}
 	}
 }
+
+func TestGroups(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	tag := "g-" + uuid.NewString()
+	deviceID := uuid.NewString()
+	_, err := client.CreateDevice(context.Background(), &pb.DeviceInfoRequest{Device: &pb.Device{Id: deviceID, Tags: []string{tag}}})
+	require.NoError(t, err)
+
+	battery := 15.0
+	upserted, err := client.UpsertGroupConfig(context.Background(), &pb.GroupConfigRequest{
+		Group: &pb.GroupConfig{
+			Tag:      tag,
+			Priority: 2,
+			Defaults: &pb.ConfigPatch{BatteryThreshold: &battery, HeartbeatInterval: durationpb.New(5 * time.Minute)},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, upserted.Status.Success, upserted.Status.Message)
+	assert.Equal(t, int32(2), upserted.Group.Priority)
+	assert.Equal(t, 15.0, upserted.Group.Defaults.GetBatteryThreshold())
+	assert.Nil(t, upserted.Group.Defaults.TemperatureThreshold)
+
+	got, err := client.GetGroupConfig(context.Background(), &pb.GroupRequest{Tag: tag})
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, got.Group.Defaults.HeartbeatInterval.AsDuration())
+
+	list, err := client.ListGroupConfigs(context.Background(), &pb.ListGroupConfigsRequest{})
+	require.NoError(t, err)
+	assert.Contains(t, common.Mapper(list.Groups, func(g *pb.GroupConfig) string { return g.Tag }), tag)
+
+	temperature := 40.0
+	bulk, err := client.UpdateConfigsByTag(context.Background(), &pb.UpdateConfigsByTagRequest{
+		Tag:   tag,
+		Patch: &pb.ConfigPatch{TemperatureThreshold: &temperature},
+	})
+	require.NoError(t, err)
+	require.True(t, bulk.Status.Success, bulk.Status.Message)
+	assert.Equal(t, int32(1), bulk.Updated)
+
+	resolved, err := client.ResolveConfig(context.Background(), &pb.DeviceRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	require.True(t, resolved.Status.Success)
+	assert.Equal(t, 40.0, resolved.Config.TemperatureThreshold)
+	assert.Equal(t, "device", resolved.Sources.TemperatureThreshold)
+	assert.Equal(t, 15.0, resolved.Config.BatteryThreshold)
+	assert.Equal(t, "group:"+tag, resolved.Sources.BatteryThreshold)
+	assert.Equal(t, 5*time.Minute, resolved.Config.HeartbeatInterval.AsDuration())
+	assert.Equal(t, "default", resolved.Sources.BatteryHysteresis)
+
+	deleted, err := client.DeleteGroupConfig(context.Background(), &pb.GroupRequest{Tag: tag})
+	require.NoError(t, err)
+	require.True(t, deleted.Status.Success)
+
+	_, err = client.GetGroupConfig(context.Background(), &pb.GroupRequest{Tag: tag})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGroups_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+	client := startTestServer(t)
+
+	negative := -1.0
+	for _, req := range []*pb.GroupConfigRequest{
+		{},
+		{Group: &pb.GroupConfig{Tag: "bad tag"}},
+		{Group: &pb.GroupConfig{Tag: "g-" + uuid.NewString(), Defaults: &pb.ConfigPatch{BatteryHysteresis: &negative}}},
+		{Group: &pb.GroupConfig{Tag: "g-" + uuid.NewString(), Defaults: &pb.ConfigPatch{HeartbeatInterval: durationpb.New(-time.Minute)}}},
+	} {
+		resp, err := client.UpsertGroupConfig(context.Background(), req)
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+		assert.True(t, strings.Contains(resp.Status.Message, "validation error"), resp.Status.Message)
+	}
+
+	{
+		resp, err := client.UpdateConfigsByTag(context.Background(), &pb.UpdateConfigsByTagRequest{Tag: "g-" + uuid.NewString()})
+		require.NoError(t, err)
+		assert.False(t, resp.Status.Success)
+		assert.True(t, strings.Contains(resp.Status.Message, "validation error"), resp.Status.Message)
+	}
+
+	{
+		_, err := client.DeleteGroupConfig(context.Background(), &pb.GroupRequest{Tag: "g-" + uuid.NewString()})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+
+	{
+		_, err := client.ResolveConfig(context.Background(), &pb.DeviceRequest{DeviceId: uuid.NewString()})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+}
+
+func TestRetention(t *testing.T) {
+	common.SetTestLoggerNop()
+	client, iotCore := startTestServerWithBroker(t, nil)
+
+	_, err := client.GetRetention(context.Background(), &pb.GetRetentionRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	tag := "g-" + uuid.NewString()
+	upserted, err := client.UpsertGroupConfig(context.Background(), &pb.GroupConfigRequest{
+		Group: &pb.GroupConfig{Tag: tag, MetricRetention: durationpb.New(720 * time.Hour), AlertRetention: durationpb.New(0)},
+	})
+	require.NoError(t, err)
+	require.True(t, upserted.Status.Success, upserted.Status.Message)
+	assert.Equal(t, 720*time.Hour, upserted.Group.MetricRetention.AsDuration())
+	require.NotNil(t, upserted.Group.AlertRetention)
+	assert.Zero(t, upserted.Group.AlertRetention.AsDuration())
+
+	invalid, err := client.UpsertGroupConfig(context.Background(), &pb.GroupConfigRequest{
+		Group: &pb.GroupConfig{Tag: tag, AlertRetention: durationpb.New(-time.Hour)},
+	})
+	require.NoError(t, err)
+	assert.False(t, invalid.Status.Success)
+
+	iotCore.Retention = iot.NewRetentionPruner(iotCore.Db.Conn, iot.RetentionOptions{
+		AlertMaxAge: 24 * time.Hour,
+		Interval:    10 * time.Millisecond,
+	})
+	defer iotCore.Retention.Close()
+
+	require.Eventually(t, func() bool { return iotCore.Retention.Stats().Runs > 0 }, time.Second, 10*time.Millisecond)
+
+	resp, err := client.GetRetention(context.Background(), &pb.GetRetentionRequest{})
+	require.NoError(t, err)
+	require.True(t, resp.Status.Success)
+	assert.Equal(t, 24*time.Hour, resp.Policy.AlertMaxAge.AsDuration())
+	assert.Zero(t, resp.Policy.MetricMaxAge.AsDuration())
+	assert.Equal(t, int32(1000), resp.Policy.BatchSize)
+	assert.Positive(t, resp.Runs)
+	assert.NotNil(t, resp.LastRunAt)
+	assert.Contains(t, resp.Pruned, iot.RetentionTableAlerts)
+}
+
+func TestPostMetrics_Ingester(t *testing.T) {
+	common.SetTestLoggerNop()
+	client, iotCore := startTestServerWithBroker(t, nil)
+	// the first metric waits for a batch to fill, the queue is then full
+	iotCore.Ingester = iot.NewMetricIngester(iotCore, iot.IngestOptions{QueueSize: 1, Workers: 1, BatchSize: 2, FlushInterval: time.Hour})
+
+	deviceID := uuid.NewString()
+	_, err := client.UpdateConfig(context.Background(), &pb.UpdateConfigRequest{
+		DeviceId: deviceID,
+		Config:   &pb.ConfigRequest{TemperatureThreshold: 100.0, BatteryThreshold: 1.0},
+	})
+	require.NoError(t, err)
+
+	metric := &pb.MetricRequest{Timestamp: timestamppb.New(time.Now()), Temperature: 25.0, Battery: 50.0}
+
+	resp, err := client.PostMetrics(context.Background(), &pb.PostMetricsRequest{DeviceId: deviceID, Metric: metric})
+	require.NoError(t, err)
+	assert.True(t, resp.Status.Success)
+
+	_, err = client.PostMetricsBatch(context.Background(), &pb.PostMetricsBatchRequest{DeviceId: deviceID, Metrics: []*pb.MetricRequest{metric}})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	iotCore.Ingester.Close()
+	_, err = client.PostMetrics(context.Background(), &pb.PostMetricsRequest{DeviceId: deviceID, Metric: metric})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	metrics, err := client.GetMetrics(context.Background(), &pb.GetMetricsRequest{DeviceId: deviceID})
+	require.NoError(t, err)
+	assert.Len(t, metrics.Metrics, 1)
+}
@@ -2,13 +2,20 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 
 	z "github.com/Oudwins/zog"
 	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"liyu1981.xyz/iot-metrics-service/pkg/common"
 	pb "liyu1981.xyz/iot-metrics-service/pkg/grpc/iot_metric_service"
+	"liyu1981.xyz/iot-metrics-service/pkg/iot"
 	"liyu1981.xyz/iot-metrics-service/pkg/models"
 )
 
@@ -17,39 +24,89 @@ func validateDeviceID(deviceID *string) z.ZogIssueList {
 	return deviceIdValidator.Validate(deviceID)
 }
 
-func (s *IOTServer) PostMetrics(ctx context.Context, req *pb.PostMetricsRequest) (*pb.PostMetricsResponse, error) {
-	if err := validateDeviceID(&req.DeviceId); err != nil {
-		return &pb.PostMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+// validateMetric returns the validation error message of a metric, or empty
+// string when it is valid
+func validateMetric(metric *pb.MetricRequest) string {
+	if metric == nil {
+		return "validation error: metric can not be empty"
 	}
 
-	{
-		var metricValidator = z.Struct(z.Shape{
-			// Timestamp need to be validated separately
-			"Temperature": z.Float64().Required(),
-			"Battery":     z.Float64().Required(),
-		})
+	var metricValidator = z.Struct(z.Shape{
+		// Timestamp need to be validated separately
+		"Temperature": z.Float64().Required(),
+		"Battery":     z.Float64().Required(),
+	})
 
-		if err := metricValidator.Validate(req.Metric); err != nil {
-			return &pb.PostMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
-		}
+	if err := metricValidator.Validate(metric); err != nil {
+		return fmt.Sprintf("validation error: %v", err)
 	}
 
-	{
-		if req.Metric.Timestamp == nil {
-			return &pb.PostMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: timestamp can not be empty"}}, nil
-		}
-		t := req.Metric.Timestamp.AsTime()
-		if t.IsZero() {
-			return &pb.PostMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: timestamp can not be parsed"}}, nil
-		}
+	if metric.Timestamp == nil {
+		return "validation error: timestamp can not be empty"
+	}
+	t := metric.Timestamp.AsTime()
+	if t.IsZero() {
+		return "validation error: timestamp can not be parsed"
+	}
+
+	if err := iot.ValidateMetricValues(toMetricValues(metric.Values)); err != nil {
+		return fmt.Sprintf("validation error: %v", err)
 	}
 
-	err := s.Iot.Metric.UpsertMetric(req.DeviceId, &models.Metric{
-		Timestamp:   req.Metric.Timestamp.AsTime(),
-		Temperature: req.Metric.Temperature,
-		Battery:     req.Metric.Battery,
+	return ""
+}
+
+func toMetricValues(values []*pb.MetricValue) []models.MetricValue {
+	return common.Mapper(values, func(v *pb.MetricValue) models.MetricValue {
+		return models.MetricValue{Name: v.Name, Value: v.Value, Unit: v.Unit}
 	})
+}
+
+func toMetric(metric *pb.MetricRequest) models.Metric {
+	return models.Metric{
+		Timestamp:   metric.Timestamp.AsTime(),
+		Temperature: metric.Temperature,
+		Battery:     metric.Battery,
+		Values:      toMetricValues(metric.Values),
+	}
+}
+
+// isDeviceRejected tells whether metrics were refused because the device is
+// not registered or disabled
+func isDeviceRejected(err error) bool {
+	return errors.Is(err, iot.ErrDeviceNotRegistered) || errors.Is(err, iot.ErrDeviceDisabled)
+}
+
+// ingestError is the status of metrics the ingester could not take, nil for
+// any other error
+func ingestError(err error) error {
+	switch {
+	case errors.Is(err, iot.ErrIngestQueueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, iot.ErrIngestClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return nil
+}
+
+func (s *IOTServer) PostMetrics(ctx context.Context, req *pb.PostMetricsRequest) (*pb.PostMetricsResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.PostMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
 
+	if msg := validateMetric(req.Metric); msg != "" {
+		return &pb.PostMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: msg}}, nil
+	}
+
+	metric := toMetric(req.Metric)
+	err := s.Iot.Metric.UpsertMetric(req.DeviceId, &metric)
+
+	if isDeviceRejected(err) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if st := ingestError(err); st != nil {
+		return nil, st
+	}
 	if err != nil {
 		return &pb.PostMetricsResponse{
 			Status: &pb.StatusResponse{Success: false, Message: err.Error()},
@@ -61,27 +118,180 @@ func (s *IOTServer) PostMetrics(ctx context.Context, req *pb.PostMetricsRequest)
 	}, nil
 }
 
+func (s *IOTServer) PostMetricsBatch(ctx context.Context, req *pb.PostMetricsBatchRequest) (*pb.PostMetricsBatchResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.PostMetricsBatchResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	if len(req.Metrics) == 0 || len(req.Metrics) > iot.MaxMetricBatchSize {
+		return &pb.PostMetricsBatchResponse{Status: &pb.StatusResponse{
+			Success: false,
+			Message: fmt.Sprintf("validation error: batch must contain 1 to %d metrics", iot.MaxMetricBatchSize),
+		}}, nil
+	}
+
+	results := make([]*pb.BatchItemStatus, len(req.Metrics))
+	var valid []models.Metric
+	var validIndexes []int
+	for idx, metric := range req.Metrics {
+		if msg := validateMetric(metric); msg != "" {
+			results[idx] = &pb.BatchItemStatus{Index: int32(idx), Success: false, Message: msg}
+			continue
+		}
+		valid = append(valid, toMetric(metric))
+		validIndexes = append(validIndexes, idx)
+	}
+
+	if len(valid) == 0 {
+		return &pb.PostMetricsBatchResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: no valid metrics in batch"}, Results: results}, nil
+	}
+
+	// all valid metrics go in one transaction, so they succeed or fail together
+	err := s.Iot.Metric.UpsertMetrics(req.DeviceId, valid)
+	if isDeviceRejected(err) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if st := ingestError(err); st != nil {
+		return nil, st
+	}
+	for _, idx := range validIndexes {
+		if err != nil {
+			results[idx] = &pb.BatchItemStatus{Index: int32(idx), Success: false, Message: err.Error()}
+		} else {
+			results[idx] = &pb.BatchItemStatus{Index: int32(idx), Success: true, Message: "OK"}
+		}
+	}
+
+	if err != nil {
+		return &pb.PostMetricsBatchResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}, Results: results}, nil
+	}
+
+	return &pb.PostMetricsBatchResponse{
+		Status:  &pb.StatusResponse{Success: true, Message: fmt.Sprintf("accepted %d of %d metrics", len(valid), len(req.Metrics))},
+		Results: results,
+	}, nil
+}
+
+func (s *IOTServer) StreamMetrics(stream pb.IOTService_StreamMetricsServer) error {
+	resp := &pb.StreamMetricsResponse{}
+	lastError := ""
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if status.Code(err) == codes.ResourceExhausted {
+				// message dropped by the stream rate limit interceptor
+				resp.Received++
+				resp.RateLimited++
+				continue
+			}
+			return err
+		}
+
+		resp.Received++
+
+		if err := validateDeviceID(&req.DeviceId); err != nil {
+			resp.Rejected++
+			lastError = fmt.Sprintf("validation error: %v", err)
+			continue
+		}
+
+		if msg := validateMetric(req.Metric); msg != "" {
+			resp.Rejected++
+			lastError = msg
+			continue
+		}
+
+		metric := toMetric(req.Metric)
+		if err := s.Iot.Metric.UpsertMetric(req.DeviceId, &metric); err != nil {
+			if errors.Is(err, iot.ErrIngestQueueFull) {
+				// the ingester pushes back like the rate limit does
+				resp.RateLimited++
+				lastError = err.Error()
+				continue
+			}
+			resp.Rejected++
+			lastError = err.Error()
+			continue
+		}
+
+		resp.Accepted++
+	}
+
+	if resp.Accepted == resp.Received {
+		resp.Status = &pb.StatusResponse{Success: true, Message: "OK"}
+	} else {
+		message := fmt.Sprintf("accepted %d of %d metrics", resp.Accepted, resp.Received)
+		if lastError != "" {
+			message += ", last error: " + lastError
+		}
+		resp.Status = &pb.StatusResponse{Success: false, Message: message}
+	}
+
+	return stream.SendAndClose(resp)
+}
+
 func (s *IOTServer) UpdateConfig(ctx context.Context, req *pb.UpdateConfigRequest) (*pb.UpdateConfigResponse, error) {
 	if err := validateDeviceID(&req.DeviceId); err != nil {
 		return &pb.UpdateConfigResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
 	}
 
+	if req.Patch != nil {
+		patch := toConfigPatch(req.Patch)
+		config, err := s.Iot.Config.PatchConfig(req.DeviceId, &patch, req.ExpectedVersion, changeOrigin(ctx))
+		if err != nil {
+			st, err := configStatus(err)
+			return &pb.UpdateConfigResponse{Status: st}, err
+		}
+		return &pb.UpdateConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Config: toPbDeviceConfig(*config)}, nil
+	}
+
 	var updateConfigValidator = z.Struct(z.Shape{
-		"TemperatureThreshold": z.Float64().Required(),
-		"BatteryThreshold":     z.Float64().Required(),
+		"TemperatureThreshold":  z.Float64().Required(),
+		"BatteryThreshold":      z.Float64().Required(),
+		"TemperatureHysteresis": z.Float64().GTE(0).Optional(),
+		"BatteryHysteresis":     z.Float64().GTE(0).Optional(),
 	})
 
 	if err := updateConfigValidator.Validate(req.Config); err != nil {
 		return &pb.UpdateConfigResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
 	}
 
+	// an update sets every field, the ones left out of the request to zero
+	heartbeat := req.Config.HeartbeatInterval.AsDuration()
 	payload := models.Config{
-		TemperatureThreshold: req.Config.TemperatureThreshold,
-		BatteryThreshold:     req.Config.BatteryThreshold,
+		TemperatureThreshold: &req.Config.TemperatureThreshold,
+		BatteryThreshold:     &req.Config.BatteryThreshold,
+
+		TemperatureHysteresis: &req.Config.TemperatureHysteresis,
+		BatteryHysteresis:     &req.Config.BatteryHysteresis,
+		HeartbeatInterval:     &heartbeat,
 	}
 
-	err := s.Iot.Config.UpsertConfig(req.DeviceId, &payload)
+	// a conditional update replaces every field through a full patch
+	if req.ExpectedVersion != 0 {
+		config, err := s.Iot.Config.PatchConfig(req.DeviceId, &models.ConfigPatch{
+			TemperatureThreshold:  payload.TemperatureThreshold,
+			BatteryThreshold:      payload.BatteryThreshold,
+			TemperatureHysteresis: payload.TemperatureHysteresis,
+			BatteryHysteresis:     payload.BatteryHysteresis,
+			HeartbeatInterval:     payload.HeartbeatInterval,
+		}, req.ExpectedVersion, changeOrigin(ctx))
+		if err != nil {
+			st, err := configStatus(err)
+			return &pb.UpdateConfigResponse{Status: st}, err
+		}
+		return &pb.UpdateConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Config: toPbDeviceConfig(*config)}, nil
+	}
 
+	err := s.Iot.Config.UpsertConfig(req.DeviceId, &payload, changeOrigin(ctx))
+
+	if errors.Is(err, iot.ErrInvalidConfig) {
+		return &pb.UpdateConfigResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
 	if err != nil {
 		return &pb.UpdateConfigResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
 	}
@@ -89,14 +299,185 @@ func (s *IOTServer) UpdateConfig(ctx context.Context, req *pb.UpdateConfigReques
 	return &pb.UpdateConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}}, nil
 }
 
-func (s *IOTServer) GetAlerts(ctx context.Context, req *pb.DeviceRequest) (*pb.GetAlertsResponse, error) {
+// changeOrigin identifies the client changing a config by the optional
+// x-actor metadata
+func changeOrigin(ctx context.Context) models.ChangeOrigin {
+	origin := models.ChangeOrigin{Source: models.ChangeSourceGRPC}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if actors := md.Get("x-actor"); len(actors) > 0 {
+			origin.Actor = actors[0]
+		}
+	}
+	return origin
+}
+
+// configStatus converts the error of a config operation into a status
+// response, an unknown config is reported as NotFound and a version mismatch
+// as Aborted
+func configStatus(err error) (*pb.StatusResponse, error) {
+	switch {
+	case errors.Is(err, iot.ErrConfigConflict):
+		return nil, status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, iot.ErrConfigNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, iot.ErrInvalidConfig):
+		return &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}, nil
+	default:
+		return &pb.StatusResponse{Success: false, Message: err.Error()}, nil
+	}
+}
+
+func (s *IOTServer) GetConfig(ctx context.Context, req *pb.DeviceRequest) (*pb.ConfigResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.ConfigResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	config, err := s.Iot.Config.GetDeviceConfig(req.DeviceId)
+	if err != nil {
+		st, err := configStatus(err)
+		return &pb.ConfigResponse{Status: st}, err
+	}
+
+	return &pb.ConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Config: toPbDeviceConfig(*config)}, nil
+}
+
+func (s *IOTServer) DeleteConfig(ctx context.Context, req *pb.DeviceRequest) (*pb.DeleteConfigResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.DeleteConfigResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	if err := s.Iot.Config.DeleteConfig(req.DeviceId, changeOrigin(ctx)); err != nil {
+		st, err := configStatus(err)
+		return &pb.DeleteConfigResponse{Status: st}, err
+	}
+
+	return &pb.DeleteConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}}, nil
+}
+
+func toPbConfigValues(v *models.ConfigValues) *pb.ConfigValues {
+	if v == nil {
+		return nil
+	}
+	values := &pb.ConfigValues{
+		TemperatureThreshold:  common.ValueOrZero(v.TemperatureThreshold),
+		BatteryThreshold:      common.ValueOrZero(v.BatteryThreshold),
+		TemperatureHysteresis: common.ValueOrZero(v.TemperatureHysteresis),
+		BatteryHysteresis:     common.ValueOrZero(v.BatteryHysteresis),
+	}
+	if v.HeartbeatInterval != nil {
+		values.HeartbeatInterval = durationpb.New(*v.HeartbeatInterval)
+	}
+	return values
+}
+
+func toPbConfigChange(c models.ConfigChange) *pb.ConfigChange {
+	return &pb.ConfigChange{
+		Id:        uint64(c.ID),
+		DeviceId:  c.DeviceID,
+		Timestamp: timestamppb.New(c.Timestamp),
+		Version:   c.Version,
+		Old:       toPbConfigValues(c.Old),
+		New:       toPbConfigValues(c.New),
+		Actor:     c.Actor,
+		Source:    string(c.Source),
+	}
+}
+
+func (s *IOTServer) GetConfigHistory(ctx context.Context, req *pb.GetConfigHistoryRequest) (*pb.GetConfigHistoryResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.GetConfigHistoryResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	var limitValidator = z.Int32().GTE(0)
+	if err := limitValidator.Validate(&req.Limit); err != nil {
+		return &pb.GetConfigHistoryResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	query := models.ConfigChangeQuery{
+		Limit:  int(req.Limit),
+		Cursor: req.Cursor,
+	}
+	if req.From != nil {
+		from := req.From.AsTime()
+		query.From = &from
+	}
+	if req.To != nil {
+		to := req.To.AsTime()
+		query.To = &to
+	}
+
+	page, err := s.Iot.Config.GetConfigHistory(req.DeviceId, &query)
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			return &pb.GetConfigHistoryResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+		}
+		return &pb.GetConfigHistoryResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
+	}
+
+	return &pb.GetConfigHistoryResponse{
+		Status:     &pb.StatusResponse{Success: true, Message: "OK"},
+		Changes:    common.Mapper(page.Changes, toPbConfigChange),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// toAlertQuery validates and converts the alert filters shared by GetAlerts
+// and ListAlerts
+func toAlertQuery(alertStatus string, state string, types []string, from *timestamppb.Timestamp, to *timestamppb.Timestamp, limit int32, cursor string) (models.AlertQuery, z.ZogIssueList) {
+	var statusValidator = z.String().OneOf([]string{
+		"",
+		string(models.AlertStatusOpen),
+		string(models.AlertStatusAcknowledged),
+		string(models.AlertStatusResolved),
+	})
+	if err := statusValidator.Validate(&alertStatus); err != nil {
+		return models.AlertQuery{}, err
+	}
+
+	var stateValidator = z.String().OneOf([]string{"", string(models.AlertStateFiring), string(models.AlertStateResolved)})
+	if err := stateValidator.Validate(&state); err != nil {
+		return models.AlertQuery{}, err
+	}
+
+	var limitValidator = z.Int32().GTE(0)
+	if err := limitValidator.Validate(&limit); err != nil {
+		return models.AlertQuery{}, err
+	}
+
+	query := models.AlertQuery{
+		Status: models.AlertStatus(alertStatus),
+		State:  models.AlertState(state),
+		Types:  common.Mapper(types, func(t string) models.AlertType { return models.AlertType(t) }),
+		Limit:  int(limit),
+		Cursor: cursor,
+	}
+	if from != nil {
+		t := from.AsTime()
+		query.From = &t
+	}
+	if to != nil {
+		t := to.AsTime()
+		query.To = &t
+	}
+	return query, nil
+}
+
+func (s *IOTServer) GetAlerts(ctx context.Context, req *pb.GetAlertsRequest) (*pb.GetAlertsResponse, error) {
 	if err := validateDeviceID(&req.DeviceId); err != nil {
 		return &pb.GetAlertsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
 	}
 
-	alerts, err := s.Iot.Alert.GetDeviceAlerts(req.DeviceId)
+	query, issues := toAlertQuery(req.Status, req.State, req.Types, req.From, req.To, req.Limit, req.Cursor)
+	if issues != nil {
+		return &pb.GetAlertsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", issues)}}, nil
+	}
+
+	page, err := s.Iot.Alert.GetDeviceAlerts(req.DeviceId, &query)
 
 	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			return &pb.GetAlertsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+		}
 		return &pb.GetAlertsResponse{
 			Status: &pb.StatusResponse{
 				Success: false,
@@ -111,18 +492,677 @@ func (s *IOTServer) GetAlerts(ctx context.Context, req *pb.DeviceRequest) (*pb.G
 			Success: true,
 			Message: "OK",
 		},
-		Alerts: common.Mapper(alerts, func(a models.Alert) *pb.Alert {
-			return &pb.Alert{
-				Id:        uint64(a.ID),
-				DeviceId:  a.DeviceID,
-				Timestamp: timestamppb.New(a.Timestamp),
-				Type:      string(a.Type),
-				Message:   a.Message,
+		Alerts:     common.Mapper(page.Alerts, toPbAlert),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// ListAlerts queries alerts across all devices, or counts them per device in
+// summary mode
+func (s *IOTServer) ListAlerts(ctx context.Context, req *pb.ListAlertsRequest) (*pb.ListAlertsResponse, error) {
+	alertQuery, issues := toAlertQuery(req.Status, req.State, req.Types, req.From, req.To, req.Limit, req.Cursor)
+	if issues != nil {
+		return &pb.ListAlertsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", issues)}}, nil
+	}
+	query := &models.FleetAlertQuery{AlertQuery: alertQuery, DevicePrefix: req.DevicePrefix}
+
+	if req.Summary {
+		summaries, err := s.Iot.Alert.SummarizeAlerts(query)
+		if err != nil {
+			return &pb.ListAlertsResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
+		}
+		return &pb.ListAlertsResponse{
+			Status: &pb.StatusResponse{Success: true, Message: "OK"},
+			Summary: common.Mapper(summaries, func(summary models.AlertSummary) *pb.AlertSummary {
+				return &pb.AlertSummary{
+					DeviceId:    summary.DeviceID,
+					Count:       int32(summary.Count),
+					Firing:      int32(summary.Firing),
+					Open:        int32(summary.Open),
+					LastAlertAt: timestamppb.New(summary.LastAlertAt),
+				}
+			}),
+		}, nil
+	}
+
+	page, err := s.Iot.Alert.ListAlerts(query)
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			return &pb.ListAlertsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+		}
+		return &pb.ListAlertsResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
+	}
+
+	return &pb.ListAlertsResponse{
+		Status:     &pb.StatusResponse{Success: true, Message: "OK"},
+		Alerts:     common.Mapper(page.Alerts, toPbAlert),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *IOTServer) alertAction(req *pb.AlertActionRequest, action func(deviceID string, id uint, by string, note string) (*models.Alert, error)) (*pb.AlertResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.AlertResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+	if req.By == "" {
+		return &pb.AlertResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: by can not be empty"}}, nil
+	}
+
+	alert, err := action(req.DeviceId, uint(req.Id), req.By, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, iot.ErrAlertNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, iot.ErrAlertStatusConflict):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return &pb.AlertResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
+	}
+
+	return &pb.AlertResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Alert: toPbAlert(*alert)}, nil
+}
+
+func (s *IOTServer) AcknowledgeAlert(ctx context.Context, req *pb.AlertActionRequest) (*pb.AlertResponse, error) {
+	return s.alertAction(req, s.Iot.Alert.AcknowledgeAlert)
+}
+
+func (s *IOTServer) ResolveAlert(ctx context.Context, req *pb.AlertActionRequest) (*pb.AlertResponse, error) {
+	return s.alertAction(req, s.Iot.Alert.ResolveAlert)
+}
+
+func toPbAlert(a models.Alert) *pb.Alert {
+	alert := &pb.Alert{
+		Id:        uint64(a.ID),
+		DeviceId:  a.DeviceID,
+		Timestamp: timestamppb.New(a.Timestamp),
+		Type:      string(a.Type),
+		Message:   a.Message,
+		Severity:  string(a.Severity),
+		State:     string(a.State),
+		LastSeen:  timestamppb.New(a.LastSeen),
+		Count:     int64(a.Count),
+
+		Status:         string(a.Status),
+		AcknowledgedBy: a.AcknowledgedBy,
+		ResolvedBy:     a.ResolvedBy,
+		Note:           a.Note,
+	}
+	if a.RuleID != nil {
+		alert.RuleId = uint64(*a.RuleID)
+	}
+	if a.ResolvedAt != nil {
+		alert.ResolvedAt = timestamppb.New(*a.ResolvedAt)
+	}
+	if a.AcknowledgedAt != nil {
+		alert.AcknowledgedAt = timestamppb.New(*a.AcknowledgedAt)
+	}
+	return alert
+}
+
+func (s *IOTServer) WatchAlerts(req *pb.WatchAlertsRequest, stream pb.IOTService_WatchAlertsServer) error {
+	if s.Iot.Broker == nil {
+		return status.Errorf(codes.Unavailable, "alert broker is not used")
+	}
+
+	sub := s.Iot.Broker.Subscribe(iot.AlertFilter{
+		DeviceID: req.DeviceId,
+		Types:    common.Mapper(req.Types, func(t string) models.AlertType { return models.AlertType(t) }),
+	})
+	defer sub.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case alert, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(toPbAlert(alert)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *IOTServer) GetMetrics(ctx context.Context, req *pb.GetMetricsRequest) (*pb.GetMetricsResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.GetMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	var orderValidator = z.String().OneOf([]string{"", string(models.SortOrderAsc), string(models.SortOrderDesc)})
+	if err := orderValidator.Validate(&req.Order); err != nil {
+		return &pb.GetMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	var limitValidator = z.Int32().GTE(0)
+	if err := limitValidator.Validate(&req.Limit); err != nil {
+		return &pb.GetMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	query := models.MetricQuery{
+		Limit:  int(req.Limit),
+		Cursor: req.Cursor,
+		Order:  models.SortOrder(req.Order),
+	}
+	if req.From != nil {
+		from := req.From.AsTime()
+		query.From = &from
+	}
+	if req.To != nil {
+		to := req.To.AsTime()
+		query.To = &to
+	}
+
+	page, err := s.Iot.Metric.GetDeviceMetrics(req.DeviceId, &query)
+
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			return &pb.GetMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+		}
+		return &pb.GetMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
+	}
+
+	return &pb.GetMetricsResponse{
+		Status: &pb.StatusResponse{
+			Success: true,
+			Message: "OK",
+		},
+		Metrics: common.Mapper(page.Metrics, func(m models.Metric) *pb.Metric {
+			return &pb.Metric{
+				Id:          uint64(m.ID),
+				DeviceId:    m.DeviceID,
+				Timestamp:   timestamppb.New(m.Timestamp),
+				Temperature: m.Temperature,
+				Battery:     m.Battery,
+				Values: common.Mapper(m.Values, func(v models.MetricValue) *pb.MetricValue {
+					return &pb.MetricValue{Name: v.Name, Value: v.Value, Unit: v.Unit}
+				}),
+			}
+		}),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *IOTServer) AggregateMetrics(ctx context.Context, req *pb.AggregateMetricsRequest) (*pb.AggregateMetricsResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.AggregateMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	if req.Interval == nil {
+		return &pb.AggregateMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: interval can not be empty"}}, nil
+	}
+
+	query := models.MetricAggregateQuery{
+		Interval: req.Interval.AsDuration(),
+	}
+	if req.From != nil {
+		from := req.From.AsTime()
+		query.From = &from
+	}
+	if req.To != nil {
+		to := req.To.AsTime()
+		query.To = &to
+	}
+
+	buckets, err := s.Iot.Metric.AggregateDeviceMetrics(req.DeviceId, &query)
+
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidInterval) {
+			return &pb.AggregateMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+		}
+		return &pb.AggregateMetricsResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
+	}
+
+	toPbStats := func(stats models.MetricStats) *pb.MetricStats {
+		return &pb.MetricStats{Min: stats.Min, Max: stats.Max, Avg: stats.Avg, Last: stats.Last}
+	}
+
+	return &pb.AggregateMetricsResponse{
+		Status: &pb.StatusResponse{
+			Success: true,
+			Message: "OK",
+		},
+		Buckets: common.Mapper(buckets, func(b models.MetricBucket) *pb.MetricBucket {
+			return &pb.MetricBucket{
+				Start:       timestamppb.New(b.Start),
+				Count:       int64(b.Count),
+				Temperature: toPbStats(b.Temperature),
+				Battery:     toPbStats(b.Battery),
 			}
 		}),
 	}, nil
 }
 
+func toPbRule(r models.AlertRule) *pb.AlertRule {
+	return &pb.AlertRule{
+		Id:             uint64(r.ID),
+		DeviceId:       r.DeviceID,
+		Metric:         r.Metric,
+		Operator:       string(r.Operator),
+		Threshold:      r.Threshold,
+		UpperThreshold: r.UpperThreshold,
+		Severity:       string(r.Severity),
+		Hysteresis:     r.Hysteresis,
+		HoldFor:        durationpb.New(r.HoldFor),
+		HoldSamples:    int32(r.HoldSamples),
+	}
+}
+
+func toRule(r *pb.AlertRule) models.AlertRule {
+	return models.AlertRule{
+		Metric:         r.Metric,
+		Operator:       models.RuleOperator(r.Operator),
+		Threshold:      r.Threshold,
+		UpperThreshold: r.UpperThreshold,
+		Severity:       models.AlertSeverity(r.Severity),
+		Hysteresis:     r.Hysteresis,
+		HoldFor:        r.HoldFor.AsDuration(),
+		HoldSamples:    int(r.HoldSamples),
+	}
+}
+
+// ruleStatus converts the error of a rule operation into a status response,
+// unknown rules are reported as NotFound
+func ruleStatus(err error) (*pb.StatusResponse, error) {
+	switch {
+	case errors.Is(err, iot.ErrRuleNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, iot.ErrInvalidRule):
+		return &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}, nil
+	default:
+		return &pb.StatusResponse{Success: false, Message: err.Error()}, nil
+	}
+}
+
+func (s *IOTServer) CreateRule(ctx context.Context, req *pb.RuleRequest) (*pb.RuleResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.RuleResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+	if req.Rule == nil {
+		return &pb.RuleResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: rule can not be empty"}}, nil
+	}
+
+	input := toRule(req.Rule)
+	rule, err := s.Iot.Rule.CreateRule(req.DeviceId, &input)
+	if err != nil {
+		st, err := ruleStatus(err)
+		return &pb.RuleResponse{Status: st}, err
+	}
+
+	return &pb.RuleResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Rule: toPbRule(*rule)}, nil
+}
+
+func (s *IOTServer) ListRules(ctx context.Context, req *pb.DeviceRequest) (*pb.ListRulesResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.ListRulesResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	rules, err := s.Iot.Rule.GetDeviceRules(req.DeviceId)
+	if err != nil {
+		return &pb.ListRulesResponse{Status: &pb.StatusResponse{Success: false, Message: err.Error()}}, nil
+	}
+
+	return &pb.ListRulesResponse{
+		Status: &pb.StatusResponse{Success: true, Message: "OK"},
+		Rules:  common.Mapper(rules, toPbRule),
+	}, nil
+}
+
+func (s *IOTServer) GetRule(ctx context.Context, req *pb.RuleIdRequest) (*pb.RuleResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.RuleResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	rule, err := s.Iot.Rule.GetDeviceRule(req.DeviceId, uint(req.Id))
+	if err != nil {
+		st, err := ruleStatus(err)
+		return &pb.RuleResponse{Status: st}, err
+	}
+
+	return &pb.RuleResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Rule: toPbRule(*rule)}, nil
+}
+
+func (s *IOTServer) UpdateRule(ctx context.Context, req *pb.RuleRequest) (*pb.RuleResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.RuleResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+	if req.Rule == nil {
+		return &pb.RuleResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: rule can not be empty"}}, nil
+	}
+
+	input := toRule(req.Rule)
+	rule, err := s.Iot.Rule.UpdateRule(req.DeviceId, uint(req.Rule.Id), &input)
+	if err != nil {
+		st, err := ruleStatus(err)
+		return &pb.RuleResponse{Status: st}, err
+	}
+
+	return &pb.RuleResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Rule: toPbRule(*rule)}, nil
+}
+
+func (s *IOTServer) DeleteRule(ctx context.Context, req *pb.RuleIdRequest) (*pb.DeleteRuleResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.DeleteRuleResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	if err := s.Iot.Rule.DeleteRule(req.DeviceId, uint(req.Id)); err != nil {
+		st, err := ruleStatus(err)
+		return &pb.DeleteRuleResponse{Status: st}, err
+	}
+
+	return &pb.DeleteRuleResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}}, nil
+}
+
+func toPbDevice(d models.Device) *pb.Device {
+	device := &pb.Device{
+		Id:        d.ID,
+		Name:      d.Name,
+		Model:     d.Model,
+		Firmware:  d.Firmware,
+		Location:  d.Location,
+		Tags:      d.Tags,
+		Enabled:   &d.Enabled,
+		CreatedAt: timestamppb.New(d.CreatedAt),
+		UpdatedAt: timestamppb.New(d.UpdatedAt),
+	}
+	if d.LastSeenAt != nil {
+		device.LastSeenAt = timestamppb.New(*d.LastSeenAt)
+	}
+	return device
+}
+
+func toDevice(d *pb.Device) models.Device {
+	return models.Device{
+		ID:       d.Id,
+		Name:     d.Name,
+		Model:    d.Model,
+		Firmware: d.Firmware,
+		Location: d.Location,
+		Tags:     d.Tags,
+		Enabled:  d.Enabled == nil || *d.Enabled,
+	}
+}
+
+// deviceStatus converts the error of a device operation into a status
+// response, unknown devices are reported as NotFound
+func deviceStatus(err error) (*pb.StatusResponse, error) {
+	switch {
+	case errors.Is(err, iot.ErrDeviceNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, iot.ErrDeviceExists):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, iot.ErrInvalidDevice), errors.Is(err, iot.ErrInvalidCursor):
+		return &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}, nil
+	default:
+		return &pb.StatusResponse{Success: false, Message: err.Error()}, nil
+	}
+}
+
+func (s *IOTServer) CreateDevice(ctx context.Context, req *pb.DeviceInfoRequest) (*pb.DeviceResponse, error) {
+	if req.Device == nil {
+		return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: device can not be empty"}}, nil
+	}
+	if err := validateDeviceID(&req.Device.Id); err != nil {
+		return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	input := toDevice(req.Device)
+	device, err := s.Iot.Device.CreateDevice(&input, changeOrigin(ctx))
+	if err != nil {
+		st, err := deviceStatus(err)
+		return &pb.DeviceResponse{Status: st}, err
+	}
+
+	return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Device: toPbDevice(*device)}, nil
+}
+
+func (s *IOTServer) ListDevices(ctx context.Context, req *pb.ListDevicesRequest) (*pb.ListDevicesResponse, error) {
+	var limitValidator = z.Int32().GTE(0).Optional()
+	if err := limitValidator.Validate(&req.Limit); err != nil {
+		return &pb.ListDevicesResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	page, err := s.Iot.Device.ListDevices(&models.DeviceQuery{
+		Prefix:  req.Prefix,
+		Tag:     req.Tag,
+		Enabled: req.Enabled,
+		Limit:   int(req.Limit),
+		Cursor:  req.Cursor,
+	})
+	if err != nil {
+		st, err := deviceStatus(err)
+		return &pb.ListDevicesResponse{Status: st}, err
+	}
+
+	return &pb.ListDevicesResponse{
+		Status:     &pb.StatusResponse{Success: true, Message: "OK"},
+		Devices:    common.Mapper(page.Devices, toPbDevice),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *IOTServer) GetDevice(ctx context.Context, req *pb.DeviceRequest) (*pb.DeviceResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	device, err := s.Iot.Device.GetDevice(req.DeviceId)
+	if err != nil {
+		st, err := deviceStatus(err)
+		return &pb.DeviceResponse{Status: st}, err
+	}
+
+	return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Device: toPbDevice(*device)}, nil
+}
+
+func (s *IOTServer) UpdateDevice(ctx context.Context, req *pb.DeviceInfoRequest) (*pb.DeviceResponse, error) {
+	if req.Device == nil {
+		return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: device can not be empty"}}, nil
+	}
+	if err := validateDeviceID(&req.Device.Id); err != nil {
+		return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	input := toDevice(req.Device)
+	device, err := s.Iot.Device.UpdateDevice(req.Device.Id, &input, changeOrigin(ctx))
+	if err != nil {
+		st, err := deviceStatus(err)
+		return &pb.DeviceResponse{Status: st}, err
+	}
+
+	return &pb.DeviceResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Device: toPbDevice(*device)}, nil
+}
+
+func (s *IOTServer) DeleteDevice(ctx context.Context, req *pb.DeviceRequest) (*pb.DeleteDeviceResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.DeleteDeviceResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	if err := s.Iot.Device.DeleteDevice(req.DeviceId); err != nil {
+		st, err := deviceStatus(err)
+		return &pb.DeleteDeviceResponse{Status: st}, err
+	}
+
+	return &pb.DeleteDeviceResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}}, nil
+}
+
+func toPbConfigPatch(p models.ConfigPatch) *pb.ConfigPatch {
+	patch := &pb.ConfigPatch{
+		TemperatureThreshold:  p.TemperatureThreshold,
+		BatteryThreshold:      p.BatteryThreshold,
+		TemperatureHysteresis: p.TemperatureHysteresis,
+		BatteryHysteresis:     p.BatteryHysteresis,
+	}
+	if p.HeartbeatInterval != nil {
+		patch.HeartbeatInterval = durationpb.New(*p.HeartbeatInterval)
+	}
+	return patch
+}
+
+func toConfigPatch(p *pb.ConfigPatch) models.ConfigPatch {
+	if p == nil {
+		return models.ConfigPatch{}
+	}
+	patch := models.ConfigPatch{
+		TemperatureThreshold:  p.TemperatureThreshold,
+		BatteryThreshold:      p.BatteryThreshold,
+		TemperatureHysteresis: p.TemperatureHysteresis,
+		BatteryHysteresis:     p.BatteryHysteresis,
+	}
+	if p.HeartbeatInterval != nil {
+		heartbeat := p.HeartbeatInterval.AsDuration()
+		patch.HeartbeatInterval = &heartbeat
+	}
+	return patch
+}
+
+func toPbGroupConfig(g models.GroupConfig) *pb.GroupConfig {
+	group := &pb.GroupConfig{
+		Tag:       g.Tag,
+		Priority:  int32(g.Priority),
+		Defaults:  toPbConfigPatch(g.ConfigPatch),
+		UpdatedAt: timestamppb.New(g.UpdatedAt),
+	}
+	if g.MetricRetention != nil {
+		group.MetricRetention = durationpb.New(*g.MetricRetention)
+	}
+	if g.AlertRetention != nil {
+		group.AlertRetention = durationpb.New(*g.AlertRetention)
+	}
+	return group
+}
+
+func toGroupConfig(g *pb.GroupConfig) *models.GroupConfig {
+	group := &models.GroupConfig{
+		Priority:    int(g.Priority),
+		ConfigPatch: toConfigPatch(g.Defaults),
+	}
+	if g.MetricRetention != nil {
+		retention := g.MetricRetention.AsDuration()
+		group.MetricRetention = &retention
+	}
+	if g.AlertRetention != nil {
+		retention := g.AlertRetention.AsDuration()
+		group.AlertRetention = &retention
+	}
+	return group
+}
+
+// toPbDeviceConfig reports unset fields as zero, the sources of a resolved
+// config tell them apart
+func toPbDeviceConfig(c models.Config) *pb.DeviceConfig {
+	config := &pb.DeviceConfig{
+		DeviceId:              c.DeviceID,
+		TemperatureThreshold:  common.ValueOrZero(c.TemperatureThreshold),
+		BatteryThreshold:      common.ValueOrZero(c.BatteryThreshold),
+		TemperatureHysteresis: common.ValueOrZero(c.TemperatureHysteresis),
+		BatteryHysteresis:     common.ValueOrZero(c.BatteryHysteresis),
+		Version:               c.Version,
+	}
+	if c.HeartbeatInterval != nil {
+		config.HeartbeatInterval = durationpb.New(*c.HeartbeatInterval)
+	}
+	if c.LastSeenAt != nil {
+		config.LastSeenAt = timestamppb.New(*c.LastSeenAt)
+	}
+	return config
+}
+
+// groupStatus converts the error of a group or config operation into a
+// status response, unknown groups and configs are reported as NotFound
+func groupStatus(err error) (*pb.StatusResponse, error) {
+	switch {
+	case errors.Is(err, iot.ErrGroupNotFound), errors.Is(err, iot.ErrConfigNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, iot.ErrInvalidConfig):
+		return &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}, nil
+	default:
+		return &pb.StatusResponse{Success: false, Message: err.Error()}, nil
+	}
+}
+
+func (s *IOTServer) UpsertGroupConfig(ctx context.Context, req *pb.GroupConfigRequest) (*pb.GroupConfigResponse, error) {
+	if req.Group == nil {
+		return &pb.GroupConfigResponse{Status: &pb.StatusResponse{Success: false, Message: "validation error: group can not be empty"}}, nil
+	}
+
+	group, err := s.Iot.Group.UpsertGroupConfig(req.Group.Tag, toGroupConfig(req.Group))
+	if err != nil {
+		st, err := groupStatus(err)
+		return &pb.GroupConfigResponse{Status: st}, err
+	}
+
+	return &pb.GroupConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Group: toPbGroupConfig(*group)}, nil
+}
+
+func (s *IOTServer) GetGroupConfig(ctx context.Context, req *pb.GroupRequest) (*pb.GroupConfigResponse, error) {
+	group, err := s.Iot.Group.GetGroupConfig(req.Tag)
+	if err != nil {
+		st, err := groupStatus(err)
+		return &pb.GroupConfigResponse{Status: st}, err
+	}
+
+	return &pb.GroupConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Group: toPbGroupConfig(*group)}, nil
+}
+
+func (s *IOTServer) ListGroupConfigs(ctx context.Context, req *pb.ListGroupConfigsRequest) (*pb.ListGroupConfigsResponse, error) {
+	groups, err := s.Iot.Group.ListGroupConfigs()
+	if err != nil {
+		st, err := groupStatus(err)
+		return &pb.ListGroupConfigsResponse{Status: st}, err
+	}
+
+	return &pb.ListGroupConfigsResponse{
+		Status: &pb.StatusResponse{Success: true, Message: "OK"},
+		Groups: common.Mapper(groups, toPbGroupConfig),
+	}, nil
+}
+
+func (s *IOTServer) DeleteGroupConfig(ctx context.Context, req *pb.GroupRequest) (*pb.DeleteGroupConfigResponse, error) {
+	if err := s.Iot.Group.DeleteGroupConfig(req.Tag); err != nil {
+		st, err := groupStatus(err)
+		return &pb.DeleteGroupConfigResponse{Status: st}, err
+	}
+
+	return &pb.DeleteGroupConfigResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}}, nil
+}
+
+func (s *IOTServer) UpdateConfigsByTag(ctx context.Context, req *pb.UpdateConfigsByTagRequest) (*pb.UpdateConfigsByTagResponse, error) {
+	patch := toConfigPatch(req.Patch)
+	updated, err := s.Iot.Group.UpdateConfigsByTag(req.Tag, &patch, changeOrigin(ctx))
+	if err != nil {
+		st, err := groupStatus(err)
+		return &pb.UpdateConfigsByTagResponse{Status: st}, err
+	}
+
+	return &pb.UpdateConfigsByTagResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}, Updated: int32(updated)}, nil
+}
+
+func (s *IOTServer) ResolveConfig(ctx context.Context, req *pb.DeviceRequest) (*pb.ResolvedConfigResponse, error) {
+	if err := validateDeviceID(&req.DeviceId); err != nil {
+		return &pb.ResolvedConfigResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
+	}
+
+	resolved, err := s.Iot.Config.ResolveConfig(req.DeviceId)
+	if err != nil {
+		st, err := groupStatus(err)
+		return &pb.ResolvedConfigResponse{Status: st}, err
+	}
+
+	return &pb.ResolvedConfigResponse{
+		Status: &pb.StatusResponse{Success: true, Message: "OK"},
+		Config: toPbDeviceConfig(resolved.Config),
+		Sources: &pb.ConfigSources{
+			TemperatureThreshold:  resolved.Sources.TemperatureThreshold,
+			BatteryThreshold:      resolved.Sources.BatteryThreshold,
+			TemperatureHysteresis: resolved.Sources.TemperatureHysteresis,
+			BatteryHysteresis:     resolved.Sources.BatteryHysteresis,
+			HeartbeatInterval:     resolved.Sources.HeartbeatInterval,
+		},
+	}, nil
+}
+
 func (s *IOTServer) PostLimiter(ctx context.Context, req *pb.PostLimiterRequest) (*pb.PostLimiterResponse, error) {
 	if err := validateDeviceID(&req.DeviceId); err != nil {
 		return &pb.PostLimiterResponse{Status: &pb.StatusResponse{Success: false, Message: fmt.Sprintf("validation error: %v", err)}}, nil
@@ -150,3 +1190,29 @@ func (s *IOTServer) PostLimiter(ctx context.Context, req *pb.PostLimiterRequest)
 	s.RateLimiterStore.SetLimiter(req.DeviceId, rate.Limit(req.DeviceRate), int(req.DeviceBurst))
 	return &pb.PostLimiterResponse{Status: &pb.StatusResponse{Success: true, Message: "OK"}}, nil
 }
+
+func (s *IOTServer) GetRetention(ctx context.Context, req *pb.GetRetentionRequest) (*pb.RetentionResponse, error) {
+	if s.Iot.Retention == nil {
+		return nil, status.Errorf(codes.Unavailable, "retention is not used")
+	}
+
+	opts := s.Iot.Retention.Options()
+	stats := s.Iot.Retention.Stats()
+	resp := &pb.RetentionResponse{
+		Status: &pb.StatusResponse{Success: true, Message: "OK"},
+		Policy: &pb.RetentionPolicy{
+			Interval:     durationpb.New(opts.Interval),
+			MetricMaxAge: durationpb.New(opts.MetricMaxAge),
+			AlertMaxAge:  durationpb.New(opts.AlertMaxAge),
+			BatchSize:    int32(opts.BatchSize),
+		},
+		Runs:       int32(stats.Runs),
+		LastError:  stats.LastError,
+		Pruned:     stats.Pruned,
+		LastPruned: stats.LastPruned,
+	}
+	if stats.LastRunAt != nil {
+		resp.LastRunAt = timestamppb.New(*stats.LastRunAt)
+	}
+	return resp, nil
+}
@@ -11,14 +11,18 @@ import (
 	"liyu1981.xyz/iot-metrics-service/pkg/common"
 )
 
-func (i *IOTServer) CreateRateLimitInterceptor(targetReqTypes []proto.Message) grpc.UnaryServerInterceptor {
-	targetTypeMap := common.Reducer(targetReqTypes,
+func toTargetTypeMap(targetReqTypes []proto.Message) map[reflect.Type]bool {
+	return common.Reducer(targetReqTypes,
 		func(m map[reflect.Type]bool, t proto.Message) map[reflect.Type]bool {
 			m[reflect.TypeOf(t)] = true
 			return m
 		},
 		map[reflect.Type]bool{},
 	)
+}
+
+func (i *IOTServer) CreateRateLimitInterceptor(targetReqTypes []proto.Message) grpc.UnaryServerInterceptor {
+	targetTypeMap := toTargetTypeMap(targetReqTypes)
 
 	return func(
 		ctx context.Context,
@@ -38,3 +42,45 @@ func (i *IOTServer) CreateRateLimitInterceptor(targetReqTypes []proto.Message) g
 		return handler(ctx, req)
 	}
 }
+
+// rateLimitedServerStream checks the device limiter for every received message.
+// A limited message is consumed and reported as ResourceExhausted from
+// RecvMsg, the stream itself stays usable so handlers may skip it and go on.
+type rateLimitedServerStream struct {
+	grpc.ServerStream
+	server        *IOTServer
+	targetTypeMap map[reflect.Type]bool
+}
+
+func (s *rateLimitedServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if _, ok := s.targetTypeMap[reflect.TypeOf(m)]; ok {
+		if r, ok := m.(interface{ GetDeviceId() string }); ok {
+			if !s.server.CheckDeviceLimiter(r.GetDeviceId()) {
+				return status.Errorf(codes.ResourceExhausted, "rate limit exceeded")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (i *IOTServer) CreateStreamRateLimitInterceptor(targetReqTypes []proto.Message) grpc.StreamServerInterceptor {
+	targetTypeMap := toTargetTypeMap(targetReqTypes)
+
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &rateLimitedServerStream{
+			ServerStream:  ss,
+			server:        i,
+			targetTypeMap: targetTypeMap,
+		})
+	}
+}
@@ -9,6 +9,7 @@ package iot_metric_service
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -22,18 +23,79 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type MetricValue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value         float64                `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+	Unit          string                 `protobuf:"bytes,3,opt,name=unit,proto3" json:"unit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricValue) Reset() {
+	*x = MetricValue{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricValue) ProtoMessage() {}
+
+func (x *MetricValue) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricValue.ProtoReflect.Descriptor instead.
+func (*MetricValue) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MetricValue) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MetricValue) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *MetricValue) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
 type MetricRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Temperature   float64                `protobuf:"fixed64,2,opt,name=temperature,proto3" json:"temperature,omitempty"`
 	Battery       float64                `protobuf:"fixed64,3,opt,name=battery,proto3" json:"battery,omitempty"`
+	Values        []*MetricValue         `protobuf:"bytes,4,rep,name=values,proto3" json:"values,omitempty"` // named series besides temperature and battery
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *MetricRequest) Reset() {
 	*x = MetricRequest{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[0]
+	mi := &file_pkg_grpc_service_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -45,7 +107,7 @@ func (x *MetricRequest) String() string {
 func (*MetricRequest) ProtoMessage() {}
 
 func (x *MetricRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[0]
+	mi := &file_pkg_grpc_service_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -58,7 +120,7 @@ func (x *MetricRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MetricRequest.ProtoReflect.Descriptor instead.
 func (*MetricRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{0}
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{1}
 }
 
 func (x *MetricRequest) GetTimestamp() *timestamppb.Timestamp {
@@ -82,17 +144,27 @@ func (x *MetricRequest) GetBattery() float64 {
 	return 0
 }
 
+func (x *MetricRequest) GetValues() []*MetricValue {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
 type ConfigRequest struct {
-	state                protoimpl.MessageState `protogen:"open.v1"`
-	TemperatureThreshold float64                `protobuf:"fixed64,1,opt,name=temperature_threshold,json=temperatureThreshold,proto3" json:"temperature_threshold,omitempty"`
-	BatteryThreshold     float64                `protobuf:"fixed64,2,opt,name=battery_threshold,json=batteryThreshold,proto3" json:"battery_threshold,omitempty"`
-	unknownFields        protoimpl.UnknownFields
-	sizeCache            protoimpl.SizeCache
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	TemperatureThreshold  float64                `protobuf:"fixed64,1,opt,name=temperature_threshold,json=temperatureThreshold,proto3" json:"temperature_threshold,omitempty"`
+	BatteryThreshold      float64                `protobuf:"fixed64,2,opt,name=battery_threshold,json=batteryThreshold,proto3" json:"battery_threshold,omitempty"`
+	TemperatureHysteresis float64                `protobuf:"fixed64,3,opt,name=temperature_hysteresis,json=temperatureHysteresis,proto3" json:"temperature_hysteresis,omitempty"`
+	BatteryHysteresis     float64                `protobuf:"fixed64,4,opt,name=battery_hysteresis,json=batteryHysteresis,proto3" json:"battery_hysteresis,omitempty"`
+	HeartbeatInterval     *durationpb.Duration   `protobuf:"bytes,5,opt,name=heartbeat_interval,json=heartbeatInterval,proto3" json:"heartbeat_interval,omitempty"` // unset disables the offline check
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
 }
 
 func (x *ConfigRequest) Reset() {
 	*x = ConfigRequest{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[1]
+	mi := &file_pkg_grpc_service_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -104,7 +176,7 @@ func (x *ConfigRequest) String() string {
 func (*ConfigRequest) ProtoMessage() {}
 
 func (x *ConfigRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[1]
+	mi := &file_pkg_grpc_service_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -117,7 +189,7 @@ func (x *ConfigRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ConfigRequest.ProtoReflect.Descriptor instead.
 func (*ConfigRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{1}
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *ConfigRequest) GetTemperatureThreshold() float64 {
@@ -134,6 +206,27 @@ func (x *ConfigRequest) GetBatteryThreshold() float64 {
 	return 0
 }
 
+func (x *ConfigRequest) GetTemperatureHysteresis() float64 {
+	if x != nil {
+		return x.TemperatureHysteresis
+	}
+	return 0
+}
+
+func (x *ConfigRequest) GetBatteryHysteresis() float64 {
+	if x != nil {
+		return x.BatteryHysteresis
+	}
+	return 0
+}
+
+func (x *ConfigRequest) GetHeartbeatInterval() *durationpb.Duration {
+	if x != nil {
+		return x.HeartbeatInterval
+	}
+	return nil
+}
+
 type PostMetricsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
@@ -144,7 +237,7 @@ type PostMetricsRequest struct {
 
 func (x *PostMetricsRequest) Reset() {
 	*x = PostMetricsRequest{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[2]
+	mi := &file_pkg_grpc_service_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -156,7 +249,7 @@ func (x *PostMetricsRequest) String() string {
 func (*PostMetricsRequest) ProtoMessage() {}
 
 func (x *PostMetricsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[2]
+	mi := &file_pkg_grpc_service_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -169,7 +262,7 @@ func (x *PostMetricsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PostMetricsRequest.ProtoReflect.Descriptor instead.
 func (*PostMetricsRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{2}
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *PostMetricsRequest) GetDeviceId() string {
@@ -186,17 +279,71 @@ func (x *PostMetricsRequest) GetMetric() *MetricRequest {
 	return nil
 }
 
-type UpdateConfigRequest struct {
+type PostMetricsBatchRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
-	Config        *ConfigRequest         `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
+	Metrics       []*MetricRequest       `protobuf:"bytes,2,rep,name=metrics,proto3" json:"metrics,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *PostMetricsBatchRequest) Reset() {
+	*x = PostMetricsBatchRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostMetricsBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostMetricsBatchRequest) ProtoMessage() {}
+
+func (x *PostMetricsBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostMetricsBatchRequest.ProtoReflect.Descriptor instead.
+func (*PostMetricsBatchRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PostMetricsBatchRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *PostMetricsBatchRequest) GetMetrics() []*MetricRequest {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+type UpdateConfigRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId        string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Config          *ConfigRequest         `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`                                           // replaces the whole config, ignored when patch is set
+	ExpectedVersion uint64                 `protobuf:"varint,3,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"` // the config must be at this version, 0 skips the check
+	Patch           *ConfigPatch           `protobuf:"bytes,4,opt,name=patch,proto3" json:"patch,omitempty"`                                             // changes only the set fields
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
 func (x *UpdateConfigRequest) Reset() {
 	*x = UpdateConfigRequest{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[3]
+	mi := &file_pkg_grpc_service_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -208,7 +355,7 @@ func (x *UpdateConfigRequest) String() string {
 func (*UpdateConfigRequest) ProtoMessage() {}
 
 func (x *UpdateConfigRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[3]
+	mi := &file_pkg_grpc_service_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -221,7 +368,7 @@ func (x *UpdateConfigRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateConfigRequest.ProtoReflect.Descriptor instead.
 func (*UpdateConfigRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{3}
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *UpdateConfigRequest) GetDeviceId() string {
@@ -238,6 +385,20 @@ func (x *UpdateConfigRequest) GetConfig() *ConfigRequest {
 	return nil
 }
 
+func (x *UpdateConfigRequest) GetExpectedVersion() uint64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+func (x *UpdateConfigRequest) GetPatch() *ConfigPatch {
+	if x != nil {
+		return x.Patch
+	}
+	return nil
+}
+
 type DeviceRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
@@ -247,7 +408,7 @@ type DeviceRequest struct {
 
 func (x *DeviceRequest) Reset() {
 	*x = DeviceRequest{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[4]
+	mi := &file_pkg_grpc_service_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -259,7 +420,7 @@ func (x *DeviceRequest) String() string {
 func (*DeviceRequest) ProtoMessage() {}
 
 func (x *DeviceRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[4]
+	mi := &file_pkg_grpc_service_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -272,7 +433,7 @@ func (x *DeviceRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeviceRequest.ProtoReflect.Descriptor instead.
 func (*DeviceRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{4}
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *DeviceRequest) GetDeviceId() string {
@@ -283,19 +444,30 @@ func (x *DeviceRequest) GetDeviceId() string {
 }
 
 type Alert struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	DeviceId      string                 `protobuf:"bytes,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
-	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
-	Message       string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	DeviceId       string                 `protobuf:"bytes,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Timestamp      *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Type           string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Message        string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	Severity       string                 `protobuf:"bytes,6,opt,name=severity,proto3" json:"severity,omitempty"`
+	RuleId         uint64                 `protobuf:"varint,7,opt,name=rule_id,json=ruleId,proto3" json:"rule_id,omitempty"` // 0 when raised by the config thresholds
+	State          string                 `protobuf:"bytes,8,opt,name=state,proto3" json:"state,omitempty"`                  // firing or resolved
+	LastSeen       *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	Count          int64                  `protobuf:"varint,10,opt,name=count,proto3" json:"count,omitempty"` // number of breaches while firing
+	ResolvedAt     *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=resolved_at,json=resolvedAt,proto3" json:"resolved_at,omitempty"`
+	Status         string                 `protobuf:"bytes,12,opt,name=status,proto3" json:"status,omitempty"` // open, acknowledged or resolved
+	AcknowledgedBy string                 `protobuf:"bytes,13,opt,name=acknowledged_by,json=acknowledgedBy,proto3" json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=acknowledged_at,json=acknowledgedAt,proto3" json:"acknowledged_at,omitempty"`
+	ResolvedBy     string                 `protobuf:"bytes,15,opt,name=resolved_by,json=resolvedBy,proto3" json:"resolved_by,omitempty"` // empty when the alert resolved by itself
+	Note           string                 `protobuf:"bytes,16,opt,name=note,proto3" json:"note,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *Alert) Reset() {
 	*x = Alert{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[5]
+	mi := &file_pkg_grpc_service_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -307,7 +479,7 @@ func (x *Alert) String() string {
 func (*Alert) ProtoMessage() {}
 
 func (x *Alert) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[5]
+	mi := &file_pkg_grpc_service_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -320,7 +492,7 @@ func (x *Alert) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Alert.ProtoReflect.Descriptor instead.
 func (*Alert) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{5}
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *Alert) GetId() uint64 {
@@ -358,123 +530,111 @@ func (x *Alert) GetMessage() string {
 	return ""
 }
 
-type AlertList struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	Alerts        []*Alert               `protobuf:"bytes,2,rep,name=alerts,proto3" json:"alerts,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *AlertList) Reset() {
-	*x = AlertList{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[6]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *AlertList) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *Alert) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
 }
 
-func (*AlertList) ProtoMessage() {}
-
-func (x *AlertList) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[6]
+func (x *Alert) GetRuleId() uint64 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.RuleId
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use AlertList.ProtoReflect.Descriptor instead.
-func (*AlertList) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{6}
+func (x *Alert) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
 }
 
-func (x *AlertList) GetStatus() *StatusResponse {
+func (x *Alert) GetLastSeen() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Status
+		return x.LastSeen
 	}
 	return nil
 }
 
-func (x *AlertList) GetAlerts() []*Alert {
+func (x *Alert) GetCount() int64 {
 	if x != nil {
-		return x.Alerts
+		return x.Count
 	}
-	return nil
+	return 0
 }
 
-type PostMetricsResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *Alert) GetResolvedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ResolvedAt
+	}
+	return nil
 }
 
-func (x *PostMetricsResponse) Reset() {
-	*x = PostMetricsResponse{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[7]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *Alert) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
 }
 
-func (x *PostMetricsResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *Alert) GetAcknowledgedBy() string {
+	if x != nil {
+		return x.AcknowledgedBy
+	}
+	return ""
 }
 
-func (*PostMetricsResponse) ProtoMessage() {}
-
-func (x *PostMetricsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[7]
+func (x *Alert) GetAcknowledgedAt() *timestamppb.Timestamp {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.AcknowledgedAt
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use PostMetricsResponse.ProtoReflect.Descriptor instead.
-func (*PostMetricsResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{7}
+func (x *Alert) GetResolvedBy() string {
+	if x != nil {
+		return x.ResolvedBy
+	}
+	return ""
 }
 
-func (x *PostMetricsResponse) GetStatus() *StatusResponse {
+func (x *Alert) GetNote() string {
 	if x != nil {
-		return x.Status
+		return x.Note
 	}
-	return nil
+	return ""
 }
 
-type UpdateConfigResponse struct {
+type GetAlertsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // empty for alerts of any status
+	Types         []string               `protobuf:"bytes,3,rep,name=types,proto3" json:"types,omitempty"`   // empty for alerts of any type
+	From          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=to,proto3" json:"to,omitempty"`
+	Limit         int32                  `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,7,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	State         string                 `protobuf:"bytes,8,opt,name=state,proto3" json:"state,omitempty"` // "firing" or "resolved", empty for both
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UpdateConfigResponse) Reset() {
-	*x = UpdateConfigResponse{}
+func (x *GetAlertsRequest) Reset() {
+	*x = GetAlertsRequest{}
 	mi := &file_pkg_grpc_service_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UpdateConfigResponse) String() string {
+func (x *GetAlertsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UpdateConfigResponse) ProtoMessage() {}
+func (*GetAlertsRequest) ProtoMessage() {}
 
-func (x *UpdateConfigResponse) ProtoReflect() protoreflect.Message {
+func (x *GetAlertsRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_pkg_grpc_service_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -486,41 +646,3297 @@ func (x *UpdateConfigResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UpdateConfigResponse.ProtoReflect.Descriptor instead.
-func (*UpdateConfigResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use GetAlertsRequest.ProtoReflect.Descriptor instead.
+func (*GetAlertsRequest) Descriptor() ([]byte, []int) {
 	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *UpdateConfigResponse) GetStatus() *StatusResponse {
+func (x *GetAlertsRequest) GetDeviceId() string {
 	if x != nil {
-		return x.Status
+		return x.DeviceId
 	}
-	return nil
+	return ""
 }
 
-type GetAlertsResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+func (x *GetAlertsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *GetAlertsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *GetAlertsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *GetAlertsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *GetAlertsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetAlertsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *GetAlertsRequest) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+type ListAlertsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DevicePrefix  string                 `protobuf:"bytes,1,opt,name=device_prefix,json=devicePrefix,proto3" json:"device_prefix,omitempty"` // empty for all devices
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	State         string                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Types         []string               `protobuf:"bytes,4,rep,name=types,proto3" json:"types,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=to,proto3" json:"to,omitempty"`
+	Limit         int32                  `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,8,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Summary       bool                   `protobuf:"varint,9,opt,name=summary,proto3" json:"summary,omitempty"` // count alerts per device instead of listing them
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAlertsRequest) Reset() {
+	*x = ListAlertsRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAlertsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAlertsRequest) ProtoMessage() {}
+
+func (x *ListAlertsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAlertsRequest.ProtoReflect.Descriptor instead.
+func (*ListAlertsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListAlertsRequest) GetDevicePrefix() string {
+	if x != nil {
+		return x.DevicePrefix
+	}
+	return ""
+}
+
+func (x *ListAlertsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListAlertsRequest) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ListAlertsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *ListAlertsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *ListAlertsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *ListAlertsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListAlertsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *ListAlertsRequest) GetSummary() bool {
+	if x != nil {
+		return x.Summary
+	}
+	return false
+}
+
+type AlertSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Firing        int32                  `protobuf:"varint,3,opt,name=firing,proto3" json:"firing,omitempty"`
+	Open          int32                  `protobuf:"varint,4,opt,name=open,proto3" json:"open,omitempty"`
+	LastAlertAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_alert_at,json=lastAlertAt,proto3" json:"last_alert_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlertSummary) Reset() {
+	*x = AlertSummary{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlertSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlertSummary) ProtoMessage() {}
+
+func (x *AlertSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlertSummary.ProtoReflect.Descriptor instead.
+func (*AlertSummary) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AlertSummary) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *AlertSummary) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *AlertSummary) GetFiring() int32 {
+	if x != nil {
+		return x.Firing
+	}
+	return 0
+}
+
+func (x *AlertSummary) GetOpen() int32 {
+	if x != nil {
+		return x.Open
+	}
+	return 0
+}
+
+func (x *AlertSummary) GetLastAlertAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastAlertAt
+	}
+	return nil
+}
+
+type ListAlertsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Alerts        []*Alert               `protobuf:"bytes,2,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	Summary       []*AlertSummary        `protobuf:"bytes,4,rep,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAlertsResponse) Reset() {
+	*x = ListAlertsResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAlertsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAlertsResponse) ProtoMessage() {}
+
+func (x *ListAlertsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAlertsResponse.ProtoReflect.Descriptor instead.
+func (*ListAlertsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListAlertsResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *ListAlertsResponse) GetAlerts() []*Alert {
+	if x != nil {
+		return x.Alerts
+	}
+	return nil
+}
+
+func (x *ListAlertsResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+func (x *ListAlertsResponse) GetSummary() []*AlertSummary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+type AlertActionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Id            uint64                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	By            string                 `protobuf:"bytes,3,opt,name=by,proto3" json:"by,omitempty"`
+	Note          string                 `protobuf:"bytes,4,opt,name=note,proto3" json:"note,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlertActionRequest) Reset() {
+	*x = AlertActionRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlertActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlertActionRequest) ProtoMessage() {}
+
+func (x *AlertActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlertActionRequest.ProtoReflect.Descriptor instead.
+func (*AlertActionRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AlertActionRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *AlertActionRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *AlertActionRequest) GetBy() string {
+	if x != nil {
+		return x.By
+	}
+	return ""
+}
+
+func (x *AlertActionRequest) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+type AlertResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Alert         *Alert                 `protobuf:"bytes,2,opt,name=alert,proto3" json:"alert,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlertResponse) Reset() {
+	*x = AlertResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlertResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlertResponse) ProtoMessage() {}
+
+func (x *AlertResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlertResponse.ProtoReflect.Descriptor instead.
+func (*AlertResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AlertResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *AlertResponse) GetAlert() *Alert {
+	if x != nil {
+		return x.Alert
+	}
+	return nil
+}
+
+type WatchAlertsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"` // empty to watch all devices
+	Types         []string               `protobuf:"bytes,2,rep,name=types,proto3" json:"types,omitempty"`                       // empty to watch all alert types
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchAlertsRequest) Reset() {
+	*x = WatchAlertsRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchAlertsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchAlertsRequest) ProtoMessage() {}
+
+func (x *WatchAlertsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchAlertsRequest.ProtoReflect.Descriptor instead.
+func (*WatchAlertsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *WatchAlertsRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *WatchAlertsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+type AlertList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Alerts        []*Alert               `protobuf:"bytes,2,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlertList) Reset() {
+	*x = AlertList{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlertList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlertList) ProtoMessage() {}
+
+func (x *AlertList) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlertList.ProtoReflect.Descriptor instead.
+func (*AlertList) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AlertList) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *AlertList) GetAlerts() []*Alert {
+	if x != nil {
+		return x.Alerts
+	}
+	return nil
+}
+
+type PostMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostMetricsResponse) Reset() {
+	*x = PostMetricsResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostMetricsResponse) ProtoMessage() {}
+
+func (x *PostMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostMetricsResponse.ProtoReflect.Descriptor instead.
+func (*PostMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PostMetricsResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type BatchItemStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchItemStatus) Reset() {
+	*x = BatchItemStatus{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchItemStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchItemStatus) ProtoMessage() {}
+
+func (x *BatchItemStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchItemStatus.ProtoReflect.Descriptor instead.
+func (*BatchItemStatus) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BatchItemStatus) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *BatchItemStatus) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *BatchItemStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type PostMetricsBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Results       []*BatchItemStatus     `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostMetricsBatchResponse) Reset() {
+	*x = PostMetricsBatchResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostMetricsBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostMetricsBatchResponse) ProtoMessage() {}
+
+func (x *PostMetricsBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostMetricsBatchResponse.ProtoReflect.Descriptor instead.
+func (*PostMetricsBatchResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PostMetricsBatchResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *PostMetricsBatchResponse) GetResults() []*BatchItemStatus {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type StreamMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Received      int64                  `protobuf:"varint,2,opt,name=received,proto3" json:"received,omitempty"`
+	Accepted      int64                  `protobuf:"varint,3,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Rejected      int64                  `protobuf:"varint,4,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	RateLimited   int64                  `protobuf:"varint,5,opt,name=rate_limited,json=rateLimited,proto3" json:"rate_limited,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamMetricsResponse) Reset() {
+	*x = StreamMetricsResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamMetricsResponse) ProtoMessage() {}
+
+func (x *StreamMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamMetricsResponse.ProtoReflect.Descriptor instead.
+func (*StreamMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *StreamMetricsResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *StreamMetricsResponse) GetReceived() int64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *StreamMetricsResponse) GetAccepted() int64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *StreamMetricsResponse) GetRejected() int64 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *StreamMetricsResponse) GetRateLimited() int64 {
+	if x != nil {
+		return x.RateLimited
+	}
+	return 0
+}
+
+type UpdateConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Config        *DeviceConfig          `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"` // set for a patch or with expected_version
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateConfigResponse) Reset() {
+	*x = UpdateConfigResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateConfigResponse) ProtoMessage() {}
+
+func (x *UpdateConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateConfigResponse.ProtoReflect.Descriptor instead.
+func (*UpdateConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *UpdateConfigResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *UpdateConfigResponse) GetConfig() *DeviceConfig {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+type GetAlertsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Alerts        []*Alert               `protobuf:"bytes,2,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAlertsResponse) Reset() {
+	*x = GetAlertsResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAlertsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAlertsResponse) ProtoMessage() {}
+
+func (x *GetAlertsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAlertsResponse.ProtoReflect.Descriptor instead.
+func (*GetAlertsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *GetAlertsResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *GetAlertsResponse) GetAlerts() []*Alert {
+	if x != nil {
+		return x.Alerts
+	}
+	return nil
+}
+
+func (x *GetAlertsResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type StatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *StatusResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *StatusResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type Metric struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	DeviceId      string                 `protobuf:"bytes,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Temperature   float64                `protobuf:"fixed64,4,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	Battery       float64                `protobuf:"fixed64,5,opt,name=battery,proto3" json:"battery,omitempty"`
+	Values        []*MetricValue         `protobuf:"bytes,6,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metric) Reset() {
+	*x = Metric{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metric) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metric) ProtoMessage() {}
+
+func (x *Metric) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metric.ProtoReflect.Descriptor instead.
+func (*Metric) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Metric) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Metric) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *Metric) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Metric) GetTemperature() float64 {
+	if x != nil {
+		return x.Temperature
+	}
+	return 0
+}
+
+func (x *Metric) GetBattery() float64 {
+	if x != nil {
+		return x.Battery
+	}
+	return 0
+}
+
+func (x *Metric) GetValues() []*MetricValue {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type GetMetricsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,5,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Order         string                 `protobuf:"bytes,6,opt,name=order,proto3" json:"order,omitempty"` // "asc" or "desc", default "desc"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMetricsRequest) Reset() {
+	*x = GetMetricsRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMetricsRequest) ProtoMessage() {}
+
+func (x *GetMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMetricsRequest.ProtoReflect.Descriptor instead.
+func (*GetMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *GetMetricsRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *GetMetricsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *GetMetricsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *GetMetricsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetMetricsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *GetMetricsRequest) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+type GetMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Metrics       []*Metric              `protobuf:"bytes,2,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMetricsResponse) Reset() {
+	*x = GetMetricsResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMetricsResponse) ProtoMessage() {}
+
+func (x *GetMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMetricsResponse.ProtoReflect.Descriptor instead.
+func (*GetMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *GetMetricsResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *GetMetricsResponse) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+func (x *GetMetricsResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type AggregateMetricsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Interval      *durationpb.Duration   `protobuf:"bytes,4,opt,name=interval,proto3" json:"interval,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateMetricsRequest) Reset() {
+	*x = AggregateMetricsRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateMetricsRequest) ProtoMessage() {}
+
+func (x *AggregateMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateMetricsRequest.ProtoReflect.Descriptor instead.
+func (*AggregateMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *AggregateMetricsRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *AggregateMetricsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *AggregateMetricsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *AggregateMetricsRequest) GetInterval() *durationpb.Duration {
+	if x != nil {
+		return x.Interval
+	}
+	return nil
+}
+
+type MetricStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Min           float64                `protobuf:"fixed64,1,opt,name=min,proto3" json:"min,omitempty"`
+	Max           float64                `protobuf:"fixed64,2,opt,name=max,proto3" json:"max,omitempty"`
+	Avg           float64                `protobuf:"fixed64,3,opt,name=avg,proto3" json:"avg,omitempty"`
+	Last          float64                `protobuf:"fixed64,4,opt,name=last,proto3" json:"last,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricStats) Reset() {
+	*x = MetricStats{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricStats) ProtoMessage() {}
+
+func (x *MetricStats) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricStats.ProtoReflect.Descriptor instead.
+func (*MetricStats) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *MetricStats) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *MetricStats) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *MetricStats) GetAvg() float64 {
+	if x != nil {
+		return x.Avg
+	}
+	return 0
+}
+
+func (x *MetricStats) GetLast() float64 {
+	if x != nil {
+		return x.Last
+	}
+	return 0
+}
+
+type MetricBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Temperature   *MetricStats           `protobuf:"bytes,3,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	Battery       *MetricStats           `protobuf:"bytes,4,opt,name=battery,proto3" json:"battery,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricBucket) Reset() {
+	*x = MetricBucket{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricBucket) ProtoMessage() {}
+
+func (x *MetricBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricBucket.ProtoReflect.Descriptor instead.
+func (*MetricBucket) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *MetricBucket) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *MetricBucket) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *MetricBucket) GetTemperature() *MetricStats {
+	if x != nil {
+		return x.Temperature
+	}
+	return nil
+}
+
+func (x *MetricBucket) GetBattery() *MetricStats {
+	if x != nil {
+		return x.Battery
+	}
+	return nil
+}
+
+type AggregateMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Buckets       []*MetricBucket        `protobuf:"bytes,2,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateMetricsResponse) Reset() {
+	*x = AggregateMetricsResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateMetricsResponse) ProtoMessage() {}
+
+func (x *AggregateMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateMetricsResponse.ProtoReflect.Descriptor instead.
+func (*AggregateMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *AggregateMetricsResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *AggregateMetricsResponse) GetBuckets() []*MetricBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type AlertRule struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	DeviceId       string                 `protobuf:"bytes,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Metric         string                 `protobuf:"bytes,3,opt,name=metric,proto3" json:"metric,omitempty"`
+	Operator       string                 `protobuf:"bytes,4,opt,name=operator,proto3" json:"operator,omitempty"`                                     // one of >, >=, <, <=, == and outside
+	Threshold      float64                `protobuf:"fixed64,5,opt,name=threshold,proto3" json:"threshold,omitempty"`                                 // lower bound when operator is outside
+	UpperThreshold float64                `protobuf:"fixed64,6,opt,name=upper_threshold,json=upperThreshold,proto3" json:"upper_threshold,omitempty"` // only used when operator is outside
+	Severity       string                 `protobuf:"bytes,7,opt,name=severity,proto3" json:"severity,omitempty"`                                     // info, warning (default) or critical
+	Hysteresis     float64                `protobuf:"fixed64,8,opt,name=hysteresis,proto3" json:"hysteresis,omitempty"`                               // band to come back inside before the alert resolves
+	HoldFor        *durationpb.Duration   `protobuf:"bytes,9,opt,name=hold_for,json=holdFor,proto3" json:"hold_for,omitempty"`                        // breach must last this long before alerting
+	HoldSamples    int32                  `protobuf:"varint,10,opt,name=hold_samples,json=holdSamples,proto3" json:"hold_samples,omitempty"`          // breach must last this many consecutive samples
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AlertRule) Reset() {
+	*x = AlertRule{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlertRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlertRule) ProtoMessage() {}
+
+func (x *AlertRule) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlertRule.ProtoReflect.Descriptor instead.
+func (*AlertRule) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *AlertRule) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *AlertRule) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *AlertRule) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+func (x *AlertRule) GetOperator() string {
+	if x != nil {
+		return x.Operator
+	}
+	return ""
+}
+
+func (x *AlertRule) GetThreshold() float64 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+func (x *AlertRule) GetUpperThreshold() float64 {
+	if x != nil {
+		return x.UpperThreshold
+	}
+	return 0
+}
+
+func (x *AlertRule) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *AlertRule) GetHysteresis() float64 {
+	if x != nil {
+		return x.Hysteresis
+	}
+	return 0
+}
+
+func (x *AlertRule) GetHoldFor() *durationpb.Duration {
+	if x != nil {
+		return x.HoldFor
+	}
+	return nil
+}
+
+func (x *AlertRule) GetHoldSamples() int32 {
+	if x != nil {
+		return x.HoldSamples
+	}
+	return 0
+}
+
+type RuleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Rule          *AlertRule             `protobuf:"bytes,2,opt,name=rule,proto3" json:"rule,omitempty"` // rule.id selects the rule to update
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RuleRequest) Reset() {
+	*x = RuleRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RuleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RuleRequest) ProtoMessage() {}
+
+func (x *RuleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RuleRequest.ProtoReflect.Descriptor instead.
+func (*RuleRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *RuleRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *RuleRequest) GetRule() *AlertRule {
+	if x != nil {
+		return x.Rule
+	}
+	return nil
+}
+
+type RuleIdRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Id            uint64                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RuleIdRequest) Reset() {
+	*x = RuleIdRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RuleIdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RuleIdRequest) ProtoMessage() {}
+
+func (x *RuleIdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RuleIdRequest.ProtoReflect.Descriptor instead.
+func (*RuleIdRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *RuleIdRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *RuleIdRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type RuleResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Rule          *AlertRule             `protobuf:"bytes,2,opt,name=rule,proto3" json:"rule,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RuleResponse) Reset() {
+	*x = RuleResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RuleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RuleResponse) ProtoMessage() {}
+
+func (x *RuleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RuleResponse.ProtoReflect.Descriptor instead.
+func (*RuleResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *RuleResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *RuleResponse) GetRule() *AlertRule {
+	if x != nil {
+		return x.Rule
+	}
+	return nil
+}
+
+type ListRulesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Rules         []*AlertRule           `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRulesResponse) Reset() {
+	*x = ListRulesResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRulesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRulesResponse) ProtoMessage() {}
+
+func (x *ListRulesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRulesResponse.ProtoReflect.Descriptor instead.
+func (*ListRulesResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *ListRulesResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *ListRulesResponse) GetRules() []*AlertRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type DeleteRuleResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRuleResponse) Reset() {
+	*x = DeleteRuleResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRuleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRuleResponse) ProtoMessage() {}
+
+func (x *DeleteRuleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRuleResponse.ProtoReflect.Descriptor instead.
+func (*DeleteRuleResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *DeleteRuleResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type PostLimiterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	DeviceRate    float64                `protobuf:"fixed64,2,opt,name=device_rate,json=deviceRate,proto3" json:"device_rate,omitempty"`
+	DeviceBurst   int32                  `protobuf:"varint,3,opt,name=device_burst,json=deviceBurst,proto3" json:"device_burst,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostLimiterRequest) Reset() {
+	*x = PostLimiterRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostLimiterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostLimiterRequest) ProtoMessage() {}
+
+func (x *PostLimiterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostLimiterRequest.ProtoReflect.Descriptor instead.
+func (*PostLimiterRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *PostLimiterRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *PostLimiterRequest) GetDeviceRate() float64 {
+	if x != nil {
+		return x.DeviceRate
+	}
+	return 0
+}
+
+func (x *PostLimiterRequest) GetDeviceBurst() int32 {
+	if x != nil {
+		return x.DeviceBurst
+	}
+	return 0
+}
+
+type PostLimiterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostLimiterResponse) Reset() {
+	*x = PostLimiterResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostLimiterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostLimiterResponse) ProtoMessage() {}
+
+func (x *PostLimiterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostLimiterResponse.ProtoReflect.Descriptor instead.
+func (*PostLimiterResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *PostLimiterResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type Device struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	Firmware      string                 `protobuf:"bytes,4,opt,name=firmware,proto3" json:"firmware,omitempty"`
+	Location      string                 `protobuf:"bytes,5,opt,name=location,proto3" json:"location,omitempty"`
+	Tags          []string               `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	Enabled       *bool                  `protobuf:"varint,7,opt,name=enabled,proto3,oneof" json:"enabled,omitempty"` // defaults to true when registering
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	LastSeenAt    *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=last_seen_at,json=lastSeenAt,proto3" json:"last_seen_at,omitempty"` // unset until the device posts metrics
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Device) Reset() {
+	*x = Device{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Device) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Device) ProtoMessage() {}
+
+func (x *Device) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Device.ProtoReflect.Descriptor instead.
+func (*Device) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *Device) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Device) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Device) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Device) GetFirmware() string {
+	if x != nil {
+		return x.Firmware
+	}
+	return ""
+}
+
+func (x *Device) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *Device) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Device) GetEnabled() bool {
+	if x != nil && x.Enabled != nil {
+		return *x.Enabled
+	}
+	return false
+}
+
+func (x *Device) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Device) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Device) GetLastSeenAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeenAt
+	}
+	return nil
+}
+
+type DeviceInfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        *Device                `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"` // device.id selects the device to update
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceInfoRequest) Reset() {
+	*x = DeviceInfoRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceInfoRequest) ProtoMessage() {}
+
+func (x *DeviceInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceInfoRequest.ProtoReflect.Descriptor instead.
+func (*DeviceInfoRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *DeviceInfoRequest) GetDevice() *Device {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+type DeviceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Device        *Device                `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceResponse) Reset() {
+	*x = DeviceResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceResponse) ProtoMessage() {}
+
+func (x *DeviceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceResponse.ProtoReflect.Descriptor instead.
+func (*DeviceResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *DeviceResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *DeviceResponse) GetDevice() *Device {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+type ListDevicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prefix        string                 `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Tag           string                 `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+	Enabled       *bool                  `protobuf:"varint,3,opt,name=enabled,proto3,oneof" json:"enabled,omitempty"`
+	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,5,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesRequest) Reset() {
+	*x = ListDevicesRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesRequest) ProtoMessage() {}
+
+func (x *ListDevicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesRequest.ProtoReflect.Descriptor instead.
+func (*ListDevicesRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *ListDevicesRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *ListDevicesRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *ListDevicesRequest) GetEnabled() bool {
+	if x != nil && x.Enabled != nil {
+		return *x.Enabled
+	}
+	return false
+}
+
+func (x *ListDevicesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListDevicesRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type ListDevicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Devices       []*Device              `protobuf:"bytes,2,rep,name=devices,proto3" json:"devices,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesResponse) Reset() {
+	*x = ListDevicesResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesResponse) ProtoMessage() {}
+
+func (x *ListDevicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesResponse.ProtoReflect.Descriptor instead.
+func (*ListDevicesResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *ListDevicesResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *ListDevicesResponse) GetDevices() []*Device {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+func (x *ListDevicesResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type DeleteDeviceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDeviceResponse) Reset() {
+	*x = DeleteDeviceResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDeviceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDeviceResponse) ProtoMessage() {}
+
+func (x *DeleteDeviceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDeviceResponse.ProtoReflect.Descriptor instead.
+func (*DeleteDeviceResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *DeleteDeviceResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type ConfigPatch struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	TemperatureThreshold  *float64               `protobuf:"fixed64,1,opt,name=temperature_threshold,json=temperatureThreshold,proto3,oneof" json:"temperature_threshold,omitempty"`
+	BatteryThreshold      *float64               `protobuf:"fixed64,2,opt,name=battery_threshold,json=batteryThreshold,proto3,oneof" json:"battery_threshold,omitempty"`
+	TemperatureHysteresis *float64               `protobuf:"fixed64,3,opt,name=temperature_hysteresis,json=temperatureHysteresis,proto3,oneof" json:"temperature_hysteresis,omitempty"`
+	BatteryHysteresis     *float64               `protobuf:"fixed64,4,opt,name=battery_hysteresis,json=batteryHysteresis,proto3,oneof" json:"battery_hysteresis,omitempty"`
+	HeartbeatInterval     *durationpb.Duration   `protobuf:"bytes,5,opt,name=heartbeat_interval,json=heartbeatInterval,proto3" json:"heartbeat_interval,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *ConfigPatch) Reset() {
+	*x = ConfigPatch{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigPatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigPatch) ProtoMessage() {}
+
+func (x *ConfigPatch) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigPatch.ProtoReflect.Descriptor instead.
+func (*ConfigPatch) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *ConfigPatch) GetTemperatureThreshold() float64 {
+	if x != nil && x.TemperatureThreshold != nil {
+		return *x.TemperatureThreshold
+	}
+	return 0
+}
+
+func (x *ConfigPatch) GetBatteryThreshold() float64 {
+	if x != nil && x.BatteryThreshold != nil {
+		return *x.BatteryThreshold
+	}
+	return 0
+}
+
+func (x *ConfigPatch) GetTemperatureHysteresis() float64 {
+	if x != nil && x.TemperatureHysteresis != nil {
+		return *x.TemperatureHysteresis
+	}
+	return 0
+}
+
+func (x *ConfigPatch) GetBatteryHysteresis() float64 {
+	if x != nil && x.BatteryHysteresis != nil {
+		return *x.BatteryHysteresis
+	}
+	return 0
+}
+
+func (x *ConfigPatch) GetHeartbeatInterval() *durationpb.Duration {
+	if x != nil {
+		return x.HeartbeatInterval
+	}
+	return nil
+}
+
+type GroupConfig struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Tag       string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Priority  int32                  `protobuf:"varint,2,opt,name=priority,proto3" json:"priority,omitempty"` // higher wins when a device is in several groups
+	Defaults  *ConfigPatch           `protobuf:"bytes,3,opt,name=defaults,proto3" json:"defaults,omitempty"`  // unset fields are not defaulted by the group
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// max age the rows of the devices are kept for, unset leaves the default of
+	// the retention pruner and zero keeps them forever
+	MetricRetention *durationpb.Duration `protobuf:"bytes,5,opt,name=metric_retention,json=metricRetention,proto3" json:"metric_retention,omitempty"`
+	AlertRetention  *durationpb.Duration `protobuf:"bytes,6,opt,name=alert_retention,json=alertRetention,proto3" json:"alert_retention,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *GroupConfig) Reset() {
+	*x = GroupConfig{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupConfig) ProtoMessage() {}
+
+func (x *GroupConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupConfig.ProtoReflect.Descriptor instead.
+func (*GroupConfig) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *GroupConfig) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *GroupConfig) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *GroupConfig) GetDefaults() *ConfigPatch {
+	if x != nil {
+		return x.Defaults
+	}
+	return nil
+}
+
+func (x *GroupConfig) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *GroupConfig) GetMetricRetention() *durationpb.Duration {
+	if x != nil {
+		return x.MetricRetention
+	}
+	return nil
+}
+
+func (x *GroupConfig) GetAlertRetention() *durationpb.Duration {
+	if x != nil {
+		return x.AlertRetention
+	}
+	return nil
+}
+
+type GroupConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         *GroupConfig           `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"` // group.tag selects the group
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupConfigRequest) Reset() {
+	*x = GroupConfigRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupConfigRequest) ProtoMessage() {}
+
+func (x *GroupConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupConfigRequest.ProtoReflect.Descriptor instead.
+func (*GroupConfigRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *GroupConfigRequest) GetGroup() *GroupConfig {
+	if x != nil {
+		return x.Group
+	}
+	return nil
+}
+
+type GroupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupRequest) Reset() {
+	*x = GroupRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupRequest) ProtoMessage() {}
+
+func (x *GroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupRequest.ProtoReflect.Descriptor instead.
+func (*GroupRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *GroupRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+type GroupConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Group         *GroupConfig           `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupConfigResponse) Reset() {
+	*x = GroupConfigResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupConfigResponse) ProtoMessage() {}
+
+func (x *GroupConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupConfigResponse.ProtoReflect.Descriptor instead.
+func (*GroupConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *GroupConfigResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *GroupConfigResponse) GetGroup() *GroupConfig {
+	if x != nil {
+		return x.Group
+	}
+	return nil
+}
+
+type ListGroupConfigsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListGroupConfigsRequest) Reset() {
+	*x = ListGroupConfigsRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListGroupConfigsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListGroupConfigsRequest) ProtoMessage() {}
+
+func (x *ListGroupConfigsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListGroupConfigsRequest.ProtoReflect.Descriptor instead.
+func (*ListGroupConfigsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{49}
+}
+
+type ListGroupConfigsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Groups        []*GroupConfig         `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListGroupConfigsResponse) Reset() {
+	*x = ListGroupConfigsResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListGroupConfigsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListGroupConfigsResponse) ProtoMessage() {}
+
+func (x *ListGroupConfigsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListGroupConfigsResponse.ProtoReflect.Descriptor instead.
+func (*ListGroupConfigsResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *ListGroupConfigsResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *ListGroupConfigsResponse) GetGroups() []*GroupConfig {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type DeleteGroupConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteGroupConfigResponse) Reset() {
+	*x = DeleteGroupConfigResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteGroupConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteGroupConfigResponse) ProtoMessage() {}
+
+func (x *DeleteGroupConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteGroupConfigResponse.ProtoReflect.Descriptor instead.
+func (*DeleteGroupConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *DeleteGroupConfigResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type UpdateConfigsByTagRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Patch         *ConfigPatch           `protobuf:"bytes,2,opt,name=patch,proto3" json:"patch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateConfigsByTagRequest) Reset() {
+	*x = UpdateConfigsByTagRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateConfigsByTagRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateConfigsByTagRequest) ProtoMessage() {}
+
+func (x *UpdateConfigsByTagRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateConfigsByTagRequest.ProtoReflect.Descriptor instead.
+func (*UpdateConfigsByTagRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *UpdateConfigsByTagRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *UpdateConfigsByTagRequest) GetPatch() *ConfigPatch {
+	if x != nil {
+		return x.Patch
+	}
+	return nil
+}
+
+type UpdateConfigsByTagResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Updated       int32                  `protobuf:"varint,2,opt,name=updated,proto3" json:"updated,omitempty"` // number of devices updated
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateConfigsByTagResponse) Reset() {
+	*x = UpdateConfigsByTagResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateConfigsByTagResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateConfigsByTagResponse) ProtoMessage() {}
+
+func (x *UpdateConfigsByTagResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateConfigsByTagResponse.ProtoReflect.Descriptor instead.
+func (*UpdateConfigsByTagResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *UpdateConfigsByTagResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *UpdateConfigsByTagResponse) GetUpdated() int32 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+type DeviceConfig struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId              string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	TemperatureThreshold  float64                `protobuf:"fixed64,2,opt,name=temperature_threshold,json=temperatureThreshold,proto3" json:"temperature_threshold,omitempty"`
+	BatteryThreshold      float64                `protobuf:"fixed64,3,opt,name=battery_threshold,json=batteryThreshold,proto3" json:"battery_threshold,omitempty"`
+	TemperatureHysteresis float64                `protobuf:"fixed64,4,opt,name=temperature_hysteresis,json=temperatureHysteresis,proto3" json:"temperature_hysteresis,omitempty"`
+	BatteryHysteresis     float64                `protobuf:"fixed64,5,opt,name=battery_hysteresis,json=batteryHysteresis,proto3" json:"battery_hysteresis,omitempty"`
+	HeartbeatInterval     *durationpb.Duration   `protobuf:"bytes,6,opt,name=heartbeat_interval,json=heartbeatInterval,proto3" json:"heartbeat_interval,omitempty"`
+	LastSeenAt            *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=last_seen_at,json=lastSeenAt,proto3" json:"last_seen_at,omitempty"`
+	Version               uint64                 `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"` // counts the changes of the config
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *DeviceConfig) Reset() {
+	*x = DeviceConfig{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceConfig) ProtoMessage() {}
+
+func (x *DeviceConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceConfig.ProtoReflect.Descriptor instead.
+func (*DeviceConfig) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *DeviceConfig) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *DeviceConfig) GetTemperatureThreshold() float64 {
+	if x != nil {
+		return x.TemperatureThreshold
+	}
+	return 0
+}
+
+func (x *DeviceConfig) GetBatteryThreshold() float64 {
+	if x != nil {
+		return x.BatteryThreshold
+	}
+	return 0
+}
+
+func (x *DeviceConfig) GetTemperatureHysteresis() float64 {
+	if x != nil {
+		return x.TemperatureHysteresis
+	}
+	return 0
+}
+
+func (x *DeviceConfig) GetBatteryHysteresis() float64 {
+	if x != nil {
+		return x.BatteryHysteresis
+	}
+	return 0
+}
+
+func (x *DeviceConfig) GetHeartbeatInterval() *durationpb.Duration {
+	if x != nil {
+		return x.HeartbeatInterval
+	}
+	return nil
+}
+
+func (x *DeviceConfig) GetLastSeenAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeenAt
+	}
+	return nil
+}
+
+func (x *DeviceConfig) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type ConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Config        *DeviceConfig          `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfigResponse) Reset() {
+	*x = ConfigResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigResponse) ProtoMessage() {}
+
+func (x *ConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigResponse.ProtoReflect.Descriptor instead.
+func (*ConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *ConfigResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *ConfigResponse) GetConfig() *DeviceConfig {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+type DeleteConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteConfigResponse) Reset() {
+	*x = DeleteConfigResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteConfigResponse) ProtoMessage() {}
+
+func (x *DeleteConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteConfigResponse.ProtoReflect.Descriptor instead.
+func (*DeleteConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{56}
+}
+
+func (x *DeleteConfigResponse) GetStatus() *StatusResponse {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type ConfigValues struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	TemperatureThreshold  float64                `protobuf:"fixed64,1,opt,name=temperature_threshold,json=temperatureThreshold,proto3" json:"temperature_threshold,omitempty"`
+	BatteryThreshold      float64                `protobuf:"fixed64,2,opt,name=battery_threshold,json=batteryThreshold,proto3" json:"battery_threshold,omitempty"`
+	TemperatureHysteresis float64                `protobuf:"fixed64,3,opt,name=temperature_hysteresis,json=temperatureHysteresis,proto3" json:"temperature_hysteresis,omitempty"`
+	BatteryHysteresis     float64                `protobuf:"fixed64,4,opt,name=battery_hysteresis,json=batteryHysteresis,proto3" json:"battery_hysteresis,omitempty"`
+	HeartbeatInterval     *durationpb.Duration   `protobuf:"bytes,5,opt,name=heartbeat_interval,json=heartbeatInterval,proto3" json:"heartbeat_interval,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *ConfigValues) Reset() {
+	*x = ConfigValues{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigValues) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigValues) ProtoMessage() {}
+
+func (x *ConfigValues) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigValues.ProtoReflect.Descriptor instead.
+func (*ConfigValues) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *ConfigValues) GetTemperatureThreshold() float64 {
+	if x != nil {
+		return x.TemperatureThreshold
+	}
+	return 0
+}
+
+func (x *ConfigValues) GetBatteryThreshold() float64 {
+	if x != nil {
+		return x.BatteryThreshold
+	}
+	return 0
+}
+
+func (x *ConfigValues) GetTemperatureHysteresis() float64 {
+	if x != nil {
+		return x.TemperatureHysteresis
+	}
+	return 0
+}
+
+func (x *ConfigValues) GetBatteryHysteresis() float64 {
+	if x != nil {
+		return x.BatteryHysteresis
+	}
+	return 0
+}
+
+func (x *ConfigValues) GetHeartbeatInterval() *durationpb.Duration {
+	if x != nil {
+		return x.HeartbeatInterval
+	}
+	return nil
+}
+
+type ConfigChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	DeviceId      string                 `protobuf:"bytes,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Version       uint64                 `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"` // of the config after the change, 0 when deleted
+	Old           *ConfigValues          `protobuf:"bytes,5,opt,name=old,proto3" json:"old,omitempty"`          // unset when the config was created
+	New           *ConfigValues          `protobuf:"bytes,6,opt,name=new,proto3" json:"new,omitempty"`          // unset when the config was deleted
+	Actor         string                 `protobuf:"bytes,7,opt,name=actor,proto3" json:"actor,omitempty"`      // x-actor metadata or X-Actor header of the change
+	Source        string                 `protobuf:"bytes,8,opt,name=source,proto3" json:"source,omitempty"`    // "http" or "grpc"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfigChange) Reset() {
+	*x = ConfigChange{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[58]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigChange) ProtoMessage() {}
+
+func (x *ConfigChange) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[58]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigChange.ProtoReflect.Descriptor instead.
+func (*ConfigChange) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{58}
+}
+
+func (x *ConfigChange) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ConfigChange) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *ConfigChange) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *ConfigChange) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ConfigChange) GetOld() *ConfigValues {
+	if x != nil {
+		return x.Old
+	}
+	return nil
+}
+
+func (x *ConfigChange) GetNew() *ConfigValues {
+	if x != nil {
+		return x.New
+	}
+	return nil
+}
+
+func (x *ConfigChange) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *ConfigChange) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type GetConfigHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor        string                 `protobuf:"bytes,5,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigHistoryRequest) Reset() {
+	*x = GetConfigHistoryRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[59]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigHistoryRequest) ProtoMessage() {}
+
+func (x *GetConfigHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[59]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{59}
+}
+
+func (x *GetConfigHistoryRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *GetConfigHistoryRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *GetConfigHistoryRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *GetConfigHistoryRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetConfigHistoryRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type GetConfigHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
 	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	Alerts        []*Alert               `protobuf:"bytes,2,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	Changes       []*ConfigChange        `protobuf:"bytes,2,rep,name=changes,proto3" json:"changes,omitempty"` // newest first
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetAlertsResponse) Reset() {
-	*x = GetAlertsResponse{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[9]
+func (x *GetConfigHistoryResponse) Reset() {
+	*x = GetConfigHistoryResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[60]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetAlertsResponse) String() string {
+func (x *GetConfigHistoryResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetAlertsResponse) ProtoMessage() {}
+func (*GetConfigHistoryResponse) ProtoMessage() {}
 
-func (x *GetAlertsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[9]
+func (x *GetConfigHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[60]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -531,48 +3947,132 @@ func (x *GetAlertsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetAlertsResponse.ProtoReflect.Descriptor instead.
-func (*GetAlertsResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use GetConfigHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetConfigHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{60}
 }
 
-func (x *GetAlertsResponse) GetStatus() *StatusResponse {
+func (x *GetConfigHistoryResponse) GetStatus() *StatusResponse {
 	if x != nil {
 		return x.Status
 	}
 	return nil
 }
 
-func (x *GetAlertsResponse) GetAlerts() []*Alert {
+func (x *GetConfigHistoryResponse) GetChanges() []*ConfigChange {
 	if x != nil {
-		return x.Alerts
+		return x.Changes
 	}
 	return nil
 }
 
-type StatusResponse struct {
+func (x *GetConfigHistoryResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type ConfigSources struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	TemperatureThreshold  string                 `protobuf:"bytes,1,opt,name=temperature_threshold,json=temperatureThreshold,proto3" json:"temperature_threshold,omitempty"` // "device", "group:<tag>" or "default"
+	BatteryThreshold      string                 `protobuf:"bytes,2,opt,name=battery_threshold,json=batteryThreshold,proto3" json:"battery_threshold,omitempty"`
+	TemperatureHysteresis string                 `protobuf:"bytes,3,opt,name=temperature_hysteresis,json=temperatureHysteresis,proto3" json:"temperature_hysteresis,omitempty"`
+	BatteryHysteresis     string                 `protobuf:"bytes,4,opt,name=battery_hysteresis,json=batteryHysteresis,proto3" json:"battery_hysteresis,omitempty"`
+	HeartbeatInterval     string                 `protobuf:"bytes,5,opt,name=heartbeat_interval,json=heartbeatInterval,proto3" json:"heartbeat_interval,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *ConfigSources) Reset() {
+	*x = ConfigSources{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[61]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigSources) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigSources) ProtoMessage() {}
+
+func (x *ConfigSources) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[61]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigSources.ProtoReflect.Descriptor instead.
+func (*ConfigSources) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{61}
+}
+
+func (x *ConfigSources) GetTemperatureThreshold() string {
+	if x != nil {
+		return x.TemperatureThreshold
+	}
+	return ""
+}
+
+func (x *ConfigSources) GetBatteryThreshold() string {
+	if x != nil {
+		return x.BatteryThreshold
+	}
+	return ""
+}
+
+func (x *ConfigSources) GetTemperatureHysteresis() string {
+	if x != nil {
+		return x.TemperatureHysteresis
+	}
+	return ""
+}
+
+func (x *ConfigSources) GetBatteryHysteresis() string {
+	if x != nil {
+		return x.BatteryHysteresis
+	}
+	return ""
+}
+
+func (x *ConfigSources) GetHeartbeatInterval() string {
+	if x != nil {
+		return x.HeartbeatInterval
+	}
+	return ""
+}
+
+type ResolvedConfigResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Config        *DeviceConfig          `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
+	Sources       *ConfigSources         `protobuf:"bytes,3,opt,name=sources,proto3" json:"sources,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StatusResponse) Reset() {
-	*x = StatusResponse{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[10]
+func (x *ResolvedConfigResponse) Reset() {
+	*x = ResolvedConfigResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[62]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StatusResponse) String() string {
+func (x *ResolvedConfigResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatusResponse) ProtoMessage() {}
+func (*ResolvedConfigResponse) ProtoMessage() {}
 
-func (x *StatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[10]
+func (x *ResolvedConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[62]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -583,49 +4083,53 @@ func (x *StatusResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
-func (*StatusResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use ResolvedConfigResponse.ProtoReflect.Descriptor instead.
+func (*ResolvedConfigResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{62}
 }
 
-func (x *StatusResponse) GetSuccess() bool {
+func (x *ResolvedConfigResponse) GetStatus() *StatusResponse {
 	if x != nil {
-		return x.Success
+		return x.Status
 	}
-	return false
+	return nil
 }
 
-func (x *StatusResponse) GetMessage() string {
+func (x *ResolvedConfigResponse) GetConfig() *DeviceConfig {
 	if x != nil {
-		return x.Message
+		return x.Config
 	}
-	return ""
+	return nil
 }
 
-type PostLimiterRequest struct {
+func (x *ResolvedConfigResponse) GetSources() *ConfigSources {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+type GetRetentionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
-	DeviceRate    float64                `protobuf:"fixed64,2,opt,name=device_rate,json=deviceRate,proto3" json:"device_rate,omitempty"`
-	DeviceBurst   int32                  `protobuf:"varint,3,opt,name=device_burst,json=deviceBurst,proto3" json:"device_burst,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PostLimiterRequest) Reset() {
-	*x = PostLimiterRequest{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[11]
+func (x *GetRetentionRequest) Reset() {
+	*x = GetRetentionRequest{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[63]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PostLimiterRequest) String() string {
+func (x *GetRetentionRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PostLimiterRequest) ProtoMessage() {}
+func (*GetRetentionRequest) ProtoMessage() {}
 
-func (x *PostLimiterRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[11]
+func (x *GetRetentionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[63]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -636,54 +4140,107 @@ func (x *PostLimiterRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PostLimiterRequest.ProtoReflect.Descriptor instead.
-func (*PostLimiterRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{11}
+// Deprecated: Use GetRetentionRequest.ProtoReflect.Descriptor instead.
+func (*GetRetentionRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{63}
 }
 
-func (x *PostLimiterRequest) GetDeviceId() string {
+type RetentionPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Interval      *durationpb.Duration   `protobuf:"bytes,1,opt,name=interval,proto3" json:"interval,omitempty"`
+	MetricMaxAge  *durationpb.Duration   `protobuf:"bytes,2,opt,name=metric_max_age,json=metricMaxAge,proto3" json:"metric_max_age,omitempty"` // zero keeps metrics forever
+	AlertMaxAge   *durationpb.Duration   `protobuf:"bytes,3,opt,name=alert_max_age,json=alertMaxAge,proto3" json:"alert_max_age,omitempty"`    // zero keeps resolved alerts forever
+	BatchSize     int32                  `protobuf:"varint,4,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetentionPolicy) Reset() {
+	*x = RetentionPolicy{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[64]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetentionPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetentionPolicy) ProtoMessage() {}
+
+func (x *RetentionPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[64]
 	if x != nil {
-		return x.DeviceId
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *PostLimiterRequest) GetDeviceRate() float64 {
+// Deprecated: Use RetentionPolicy.ProtoReflect.Descriptor instead.
+func (*RetentionPolicy) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{64}
+}
+
+func (x *RetentionPolicy) GetInterval() *durationpb.Duration {
 	if x != nil {
-		return x.DeviceRate
+		return x.Interval
 	}
-	return 0
+	return nil
 }
 
-func (x *PostLimiterRequest) GetDeviceBurst() int32 {
+func (x *RetentionPolicy) GetMetricMaxAge() *durationpb.Duration {
 	if x != nil {
-		return x.DeviceBurst
+		return x.MetricMaxAge
+	}
+	return nil
+}
+
+func (x *RetentionPolicy) GetAlertMaxAge() *durationpb.Duration {
+	if x != nil {
+		return x.AlertMaxAge
+	}
+	return nil
+}
+
+func (x *RetentionPolicy) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
 	}
 	return 0
 }
 
-type PostLimiterResponse struct {
+type RetentionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Status        *StatusResponse        `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Policy        *RetentionPolicy       `protobuf:"bytes,2,opt,name=policy,proto3" json:"policy,omitempty"`
+	Runs          int32                  `protobuf:"varint,3,opt,name=runs,proto3" json:"runs,omitempty"`
+	LastRunAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_run_at,json=lastRunAt,proto3" json:"last_run_at,omitempty"`
+	LastError     string                 `protobuf:"bytes,5,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	Pruned        map[string]int64       `protobuf:"bytes,6,rep,name=pruned,proto3" json:"pruned,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`                           // rows pruned per table since the start
+	LastPruned    map[string]int64       `protobuf:"bytes,7,rep,name=last_pruned,json=lastPruned,proto3" json:"last_pruned,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // rows pruned per table by the last run
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PostLimiterResponse) Reset() {
-	*x = PostLimiterResponse{}
-	mi := &file_pkg_grpc_service_proto_msgTypes[12]
+func (x *RetentionResponse) Reset() {
+	*x = RetentionResponse{}
+	mi := &file_pkg_grpc_service_proto_msgTypes[65]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PostLimiterResponse) String() string {
+func (x *RetentionResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PostLimiterResponse) ProtoMessage() {}
+func (*RetentionResponse) ProtoMessage() {}
 
-func (x *PostLimiterResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_grpc_service_proto_msgTypes[12]
+func (x *RetentionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_grpc_service_proto_msgTypes[65]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -694,70 +4251,446 @@ func (x *PostLimiterResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PostLimiterResponse.ProtoReflect.Descriptor instead.
-func (*PostLimiterResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use RetentionResponse.ProtoReflect.Descriptor instead.
+func (*RetentionResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_grpc_service_proto_rawDescGZIP(), []int{65}
 }
 
-func (x *PostLimiterResponse) GetStatus() *StatusResponse {
+func (x *RetentionResponse) GetStatus() *StatusResponse {
 	if x != nil {
 		return x.Status
 	}
 	return nil
 }
 
+func (x *RetentionResponse) GetPolicy() *RetentionPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+func (x *RetentionResponse) GetRuns() int32 {
+	if x != nil {
+		return x.Runs
+	}
+	return 0
+}
+
+func (x *RetentionResponse) GetLastRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastRunAt
+	}
+	return nil
+}
+
+func (x *RetentionResponse) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *RetentionResponse) GetPruned() map[string]int64 {
+	if x != nil {
+		return x.Pruned
+	}
+	return nil
+}
+
+func (x *RetentionResponse) GetLastPruned() map[string]int64 {
+	if x != nil {
+		return x.LastPruned
+	}
+	return nil
+}
+
 var File_pkg_grpc_service_proto protoreflect.FileDescriptor
 
 const file_pkg_grpc_service_proto_rawDesc = "" +
 	"\n" +
-	"\x16pkg/grpc/service.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x85\x01\n" +
+	"\x16pkg/grpc/service.proto\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"K\n" +
+	"\vMetricValue\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value\x12\x12\n" +
+	"\x04unit\x18\x03 \x01(\tR\x04unit\"\xab\x01\n" +
 	"\rMetricRequest\x128\n" +
 	"\ttimestamp\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12 \n" +
 	"\vtemperature\x18\x02 \x01(\x01R\vtemperature\x12\x18\n" +
-	"\abattery\x18\x03 \x01(\x01R\abattery\"q\n" +
+	"\abattery\x18\x03 \x01(\x01R\abattery\x12$\n" +
+	"\x06values\x18\x04 \x03(\v2\f.MetricValueR\x06values\"\xa1\x02\n" +
 	"\rConfigRequest\x123\n" +
 	"\x15temperature_threshold\x18\x01 \x01(\x01R\x14temperatureThreshold\x12+\n" +
-	"\x11battery_threshold\x18\x02 \x01(\x01R\x10batteryThreshold\"Y\n" +
+	"\x11battery_threshold\x18\x02 \x01(\x01R\x10batteryThreshold\x125\n" +
+	"\x16temperature_hysteresis\x18\x03 \x01(\x01R\x15temperatureHysteresis\x12-\n" +
+	"\x12battery_hysteresis\x18\x04 \x01(\x01R\x11batteryHysteresis\x12H\n" +
+	"\x12heartbeat_interval\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x11heartbeatInterval\"Y\n" +
 	"\x12PostMetricsRequest\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12&\n" +
-	"\x06metric\x18\x02 \x01(\v2\x0e.MetricRequestR\x06metric\"Z\n" +
+	"\x06metric\x18\x02 \x01(\v2\x0e.MetricRequestR\x06metric\"`\n" +
+	"\x17PostMetricsBatchRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12(\n" +
+	"\ametrics\x18\x02 \x03(\v2\x0e.MetricRequestR\ametrics\"\xa9\x01\n" +
 	"\x13UpdateConfigRequest\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12&\n" +
-	"\x06config\x18\x02 \x01(\v2\x0e.ConfigRequestR\x06config\",\n" +
+	"\x06config\x18\x02 \x01(\v2\x0e.ConfigRequestR\x06config\x12)\n" +
+	"\x10expected_version\x18\x03 \x01(\x04R\x0fexpectedVersion\x12\"\n" +
+	"\x05patch\x18\x04 \x01(\v2\f.ConfigPatchR\x05patch\",\n" +
 	"\rDeviceRequest\x12\x1b\n" +
-	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\"\x9c\x01\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\"\xae\x04\n" +
 	"\x05Alert\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1b\n" +
 	"\tdevice_id\x18\x02 \x01(\tR\bdeviceId\x128\n" +
 	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x12\n" +
 	"\x04type\x18\x04 \x01(\tR\x04type\x12\x18\n" +
-	"\amessage\x18\x05 \x01(\tR\amessage\"T\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\x12\x1a\n" +
+	"\bseverity\x18\x06 \x01(\tR\bseverity\x12\x17\n" +
+	"\arule_id\x18\a \x01(\x04R\x06ruleId\x12\x14\n" +
+	"\x05state\x18\b \x01(\tR\x05state\x127\n" +
+	"\tlast_seen\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\x12\x14\n" +
+	"\x05count\x18\n" +
+	" \x01(\x03R\x05count\x12;\n" +
+	"\vresolved_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"resolvedAt\x12\x16\n" +
+	"\x06status\x18\f \x01(\tR\x06status\x12'\n" +
+	"\x0facknowledged_by\x18\r \x01(\tR\x0eacknowledgedBy\x12C\n" +
+	"\x0facknowledged_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\x0eacknowledgedAt\x12\x1f\n" +
+	"\vresolved_by\x18\x0f \x01(\tR\n" +
+	"resolvedBy\x12\x12\n" +
+	"\x04note\x18\x10 \x01(\tR\x04note\"\xfd\x01\n" +
+	"\x10GetAlertsRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x14\n" +
+	"\x05types\x18\x03 \x03(\tR\x05types\x12.\n" +
+	"\x04from\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05limit\x18\x06 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\a \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05state\x18\b \x01(\tR\x05state\"\xa0\x02\n" +
+	"\x11ListAlertsRequest\x12#\n" +
+	"\rdevice_prefix\x18\x01 \x01(\tR\fdevicePrefix\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x12\x14\n" +
+	"\x05types\x18\x04 \x03(\tR\x05types\x12.\n" +
+	"\x04from\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05limit\x18\a \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\b \x01(\tR\x06cursor\x12\x18\n" +
+	"\asummary\x18\t \x01(\bR\asummary\"\xad\x01\n" +
+	"\fAlertSummary\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x16\n" +
+	"\x06firing\x18\x03 \x01(\x05R\x06firing\x12\x12\n" +
+	"\x04open\x18\x04 \x01(\x05R\x04open\x12>\n" +
+	"\rlast_alert_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vlastAlertAt\"\xa7\x01\n" +
+	"\x12ListAlertsResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x1e\n" +
+	"\x06alerts\x18\x02 \x03(\v2\x06.AlertR\x06alerts\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\x12'\n" +
+	"\asummary\x18\x04 \x03(\v2\r.AlertSummaryR\asummary\"e\n" +
+	"\x12AlertActionRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x04R\x02id\x12\x0e\n" +
+	"\x02by\x18\x03 \x01(\tR\x02by\x12\x12\n" +
+	"\x04note\x18\x04 \x01(\tR\x04note\"V\n" +
+	"\rAlertResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x1c\n" +
+	"\x05alert\x18\x02 \x01(\v2\x06.AlertR\x05alert\"G\n" +
+	"\x12WatchAlertsRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x14\n" +
+	"\x05types\x18\x02 \x03(\tR\x05types\"T\n" +
 	"\tAlertList\x12'\n" +
 	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x1e\n" +
 	"\x06alerts\x18\x02 \x03(\v2\x06.AlertR\x06alerts\">\n" +
 	"\x13PostMetricsResponse\x12'\n" +
-	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"?\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"[\n" +
+	"\x0fBatchItemStatus\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"o\n" +
+	"\x18PostMetricsBatchResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12*\n" +
+	"\aresults\x18\x02 \x03(\v2\x10.BatchItemStatusR\aresults\"\xb7\x01\n" +
+	"\x15StreamMetricsResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x1a\n" +
+	"\breceived\x18\x02 \x01(\x03R\breceived\x12\x1a\n" +
+	"\baccepted\x18\x03 \x01(\x03R\baccepted\x12\x1a\n" +
+	"\brejected\x18\x04 \x01(\x03R\brejected\x12!\n" +
+	"\frate_limited\x18\x05 \x01(\x03R\vrateLimited\"f\n" +
 	"\x14UpdateConfigResponse\x12'\n" +
-	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"\\\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12%\n" +
+	"\x06config\x18\x02 \x01(\v2\r.DeviceConfigR\x06config\"}\n" +
 	"\x11GetAlertsResponse\x12'\n" +
 	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x1e\n" +
-	"\x06alerts\x18\x02 \x03(\v2\x06.AlertR\x06alerts\"D\n" +
+	"\x06alerts\x18\x02 \x03(\v2\x06.AlertR\x06alerts\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"D\n" +
 	"\x0eStatusResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"u\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xd1\x01\n" +
+	"\x06Metric\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1b\n" +
+	"\tdevice_id\x18\x02 \x01(\tR\bdeviceId\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12 \n" +
+	"\vtemperature\x18\x04 \x01(\x01R\vtemperature\x12\x18\n" +
+	"\abattery\x18\x05 \x01(\x01R\abattery\x12$\n" +
+	"\x06values\x18\x06 \x03(\v2\f.MetricValueR\x06values\"\xd0\x01\n" +
+	"\x11GetMetricsRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05order\x18\x06 \x01(\tR\x05order\"\x81\x01\n" +
+	"\x12GetMetricsResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12!\n" +
+	"\ametrics\x18\x02 \x03(\v2\a.MetricR\ametrics\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"\xc9\x01\n" +
+	"\x17AggregateMetricsRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x125\n" +
+	"\binterval\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\binterval\"W\n" +
+	"\vMetricStats\x12\x10\n" +
+	"\x03min\x18\x01 \x01(\x01R\x03min\x12\x10\n" +
+	"\x03max\x18\x02 \x01(\x01R\x03max\x12\x10\n" +
+	"\x03avg\x18\x03 \x01(\x01R\x03avg\x12\x12\n" +
+	"\x04last\x18\x04 \x01(\x01R\x04last\"\xae\x01\n" +
+	"\fMetricBucket\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\x12.\n" +
+	"\vtemperature\x18\x03 \x01(\v2\f.MetricStatsR\vtemperature\x12&\n" +
+	"\abattery\x18\x04 \x01(\v2\f.MetricStatsR\abattery\"l\n" +
+	"\x18AggregateMetricsResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12'\n" +
+	"\abuckets\x18\x02 \x03(\v2\r.MetricBucketR\abuckets\"\xc8\x02\n" +
+	"\tAlertRule\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1b\n" +
+	"\tdevice_id\x18\x02 \x01(\tR\bdeviceId\x12\x16\n" +
+	"\x06metric\x18\x03 \x01(\tR\x06metric\x12\x1a\n" +
+	"\boperator\x18\x04 \x01(\tR\boperator\x12\x1c\n" +
+	"\tthreshold\x18\x05 \x01(\x01R\tthreshold\x12'\n" +
+	"\x0fupper_threshold\x18\x06 \x01(\x01R\x0eupperThreshold\x12\x1a\n" +
+	"\bseverity\x18\a \x01(\tR\bseverity\x12\x1e\n" +
+	"\n" +
+	"hysteresis\x18\b \x01(\x01R\n" +
+	"hysteresis\x124\n" +
+	"\bhold_for\x18\t \x01(\v2\x19.google.protobuf.DurationR\aholdFor\x12!\n" +
+	"\fhold_samples\x18\n" +
+	" \x01(\x05R\vholdSamples\"J\n" +
+	"\vRuleRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x1e\n" +
+	"\x04rule\x18\x02 \x01(\v2\n" +
+	".AlertRuleR\x04rule\"<\n" +
+	"\rRuleIdRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x04R\x02id\"W\n" +
+	"\fRuleResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x1e\n" +
+	"\x04rule\x18\x02 \x01(\v2\n" +
+	".AlertRuleR\x04rule\"^\n" +
+	"\x11ListRulesResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12 \n" +
+	"\x05rules\x18\x02 \x03(\v2\n" +
+	".AlertRuleR\x05rules\"=\n" +
+	"\x12DeleteRuleResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"u\n" +
 	"\x12PostLimiterRequest\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x1f\n" +
 	"\vdevice_rate\x18\x02 \x01(\x01R\n" +
 	"deviceRate\x12!\n" +
 	"\fdevice_burst\x18\x03 \x01(\x05R\vdeviceBurst\">\n" +
 	"\x13PostLimiterResponse\x12'\n" +
-	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status2\xee\x01\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"\xed\x02\n" +
+	"\x06Device\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12\x1a\n" +
+	"\bfirmware\x18\x04 \x01(\tR\bfirmware\x12\x1a\n" +
+	"\blocation\x18\x05 \x01(\tR\blocation\x12\x12\n" +
+	"\x04tags\x18\x06 \x03(\tR\x04tags\x12\x1d\n" +
+	"\aenabled\x18\a \x01(\bH\x00R\aenabled\x88\x01\x01\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12<\n" +
+	"\flast_seen_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"lastSeenAtB\n" +
+	"\n" +
+	"\b_enabled\"4\n" +
+	"\x11DeviceInfoRequest\x12\x1f\n" +
+	"\x06device\x18\x01 \x01(\v2\a.DeviceR\x06device\"Z\n" +
+	"\x0eDeviceResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x1f\n" +
+	"\x06device\x18\x02 \x01(\v2\a.DeviceR\x06device\"\x97\x01\n" +
+	"\x12ListDevicesRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\x12\x10\n" +
+	"\x03tag\x18\x02 \x01(\tR\x03tag\x12\x1d\n" +
+	"\aenabled\x18\x03 \x01(\bH\x00R\aenabled\x88\x01\x01\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\tR\x06cursorB\n" +
+	"\n" +
+	"\b_enabled\"\x82\x01\n" +
+	"\x13ListDevicesResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12!\n" +
+	"\adevices\x18\x02 \x03(\v2\a.DeviceR\adevices\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"?\n" +
+	"\x14DeleteDeviceResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"\x95\x03\n" +
+	"\vConfigPatch\x128\n" +
+	"\x15temperature_threshold\x18\x01 \x01(\x01H\x00R\x14temperatureThreshold\x88\x01\x01\x120\n" +
+	"\x11battery_threshold\x18\x02 \x01(\x01H\x01R\x10batteryThreshold\x88\x01\x01\x12:\n" +
+	"\x16temperature_hysteresis\x18\x03 \x01(\x01H\x02R\x15temperatureHysteresis\x88\x01\x01\x122\n" +
+	"\x12battery_hysteresis\x18\x04 \x01(\x01H\x03R\x11batteryHysteresis\x88\x01\x01\x12H\n" +
+	"\x12heartbeat_interval\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x11heartbeatIntervalB\x18\n" +
+	"\x16_temperature_thresholdB\x14\n" +
+	"\x12_battery_thresholdB\x19\n" +
+	"\x17_temperature_hysteresisB\x15\n" +
+	"\x13_battery_hysteresis\"\xaa\x02\n" +
+	"\vGroupConfig\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\x1a\n" +
+	"\bpriority\x18\x02 \x01(\x05R\bpriority\x12(\n" +
+	"\bdefaults\x18\x03 \x01(\v2\f.ConfigPatchR\bdefaults\x129\n" +
+	"\n" +
+	"updated_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12D\n" +
+	"\x10metric_retention\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x0fmetricRetention\x12B\n" +
+	"\x0falert_retention\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x0ealertRetention\"8\n" +
+	"\x12GroupConfigRequest\x12\"\n" +
+	"\x05group\x18\x01 \x01(\v2\f.GroupConfigR\x05group\" \n" +
+	"\fGroupRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\"b\n" +
+	"\x13GroupConfigResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\"\n" +
+	"\x05group\x18\x02 \x01(\v2\f.GroupConfigR\x05group\"\x19\n" +
+	"\x17ListGroupConfigsRequest\"i\n" +
+	"\x18ListGroupConfigsResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12$\n" +
+	"\x06groups\x18\x02 \x03(\v2\f.GroupConfigR\x06groups\"D\n" +
+	"\x19DeleteGroupConfigResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"Q\n" +
+	"\x19UpdateConfigsByTagRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\"\n" +
+	"\x05patch\x18\x02 \x01(\v2\f.ConfigPatchR\x05patch\"_\n" +
+	"\x1aUpdateConfigsByTagResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12\x18\n" +
+	"\aupdated\x18\x02 \x01(\x05R\aupdated\"\x95\x03\n" +
+	"\fDeviceConfig\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x123\n" +
+	"\x15temperature_threshold\x18\x02 \x01(\x01R\x14temperatureThreshold\x12+\n" +
+	"\x11battery_threshold\x18\x03 \x01(\x01R\x10batteryThreshold\x125\n" +
+	"\x16temperature_hysteresis\x18\x04 \x01(\x01R\x15temperatureHysteresis\x12-\n" +
+	"\x12battery_hysteresis\x18\x05 \x01(\x01R\x11batteryHysteresis\x12H\n" +
+	"\x12heartbeat_interval\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x11heartbeatInterval\x12<\n" +
+	"\flast_seen_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"lastSeenAt\x12\x18\n" +
+	"\aversion\x18\b \x01(\x04R\aversion\"`\n" +
+	"\x0eConfigResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12%\n" +
+	"\x06config\x18\x02 \x01(\v2\r.DeviceConfigR\x06config\"?\n" +
+	"\x14DeleteConfigResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\"\xa0\x02\n" +
+	"\fConfigValues\x123\n" +
+	"\x15temperature_threshold\x18\x01 \x01(\x01R\x14temperatureThreshold\x12+\n" +
+	"\x11battery_threshold\x18\x02 \x01(\x01R\x10batteryThreshold\x125\n" +
+	"\x16temperature_hysteresis\x18\x03 \x01(\x01R\x15temperatureHysteresis\x12-\n" +
+	"\x12battery_hysteresis\x18\x04 \x01(\x01R\x11batteryHysteresis\x12H\n" +
+	"\x12heartbeat_interval\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x11heartbeatInterval\"\xff\x01\n" +
+	"\fConfigChange\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1b\n" +
+	"\tdevice_id\x18\x02 \x01(\tR\bdeviceId\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\x04R\aversion\x12\x1f\n" +
+	"\x03old\x18\x05 \x01(\v2\r.ConfigValuesR\x03old\x12\x1f\n" +
+	"\x03new\x18\x06 \x01(\v2\r.ConfigValuesR\x03new\x12\x14\n" +
+	"\x05actor\x18\a \x01(\tR\x05actor\x12\x16\n" +
+	"\x06source\x18\b \x01(\tR\x06source\"\xc0\x01\n" +
+	"\x17GetConfigHistoryRequest\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\tR\x06cursor\"\x8d\x01\n" +
+	"\x18GetConfigHistoryResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12'\n" +
+	"\achanges\x18\x02 \x03(\v2\r.ConfigChangeR\achanges\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"\x86\x02\n" +
+	"\rConfigSources\x123\n" +
+	"\x15temperature_threshold\x18\x01 \x01(\tR\x14temperatureThreshold\x12+\n" +
+	"\x11battery_threshold\x18\x02 \x01(\tR\x10batteryThreshold\x125\n" +
+	"\x16temperature_hysteresis\x18\x03 \x01(\tR\x15temperatureHysteresis\x12-\n" +
+	"\x12battery_hysteresis\x18\x04 \x01(\tR\x11batteryHysteresis\x12-\n" +
+	"\x12heartbeat_interval\x18\x05 \x01(\tR\x11heartbeatInterval\"\x92\x01\n" +
+	"\x16ResolvedConfigResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12%\n" +
+	"\x06config\x18\x02 \x01(\v2\r.DeviceConfigR\x06config\x12(\n" +
+	"\asources\x18\x03 \x01(\v2\x0e.ConfigSourcesR\asources\"\x15\n" +
+	"\x13GetRetentionRequest\"\xe7\x01\n" +
+	"\x0fRetentionPolicy\x125\n" +
+	"\binterval\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\binterval\x12?\n" +
+	"\x0emetric_max_age\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\fmetricMaxAge\x12=\n" +
+	"\ralert_max_age\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\valertMaxAge\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18\x04 \x01(\x05R\tbatchSize\"\xcc\x03\n" +
+	"\x11RetentionResponse\x12'\n" +
+	"\x06status\x18\x01 \x01(\v2\x0f.StatusResponseR\x06status\x12(\n" +
+	"\x06policy\x18\x02 \x01(\v2\x10.RetentionPolicyR\x06policy\x12\x12\n" +
+	"\x04runs\x18\x03 \x01(\x05R\x04runs\x12:\n" +
+	"\vlast_run_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tlastRunAt\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x05 \x01(\tR\tlastError\x126\n" +
+	"\x06pruned\x18\x06 \x03(\v2\x1e.RetentionResponse.PrunedEntryR\x06pruned\x12C\n" +
+	"\vlast_pruned\x18\a \x03(\v2\".RetentionResponse.LastPrunedEntryR\n" +
+	"lastPruned\x1a9\n" +
+	"\vPrunedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a=\n" +
+	"\x0fLastPrunedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x012\xab\x0e\n" +
 	"\n" +
 	"IOTService\x128\n" +
-	"\vPostMetrics\x12\x13.PostMetricsRequest\x1a\x14.PostMetricsResponse\x12;\n" +
-	"\fUpdateConfig\x12\x14.UpdateConfigRequest\x1a\x15.UpdateConfigResponse\x12/\n" +
-	"\tGetAlerts\x12\x0e.DeviceRequest\x1a\x12.GetAlertsResponse\x128\n" +
-	"\vPostLimiter\x12\x13.PostLimiterRequest\x1a\x14.PostLimiterResponseB\x15Z\x13/iot_metric_serviceb\x06proto3"
+	"\vPostMetrics\x12\x13.PostMetricsRequest\x1a\x14.PostMetricsResponse\x12G\n" +
+	"\x10PostMetricsBatch\x12\x18.PostMetricsBatchRequest\x1a\x19.PostMetricsBatchResponse\x12>\n" +
+	"\rStreamMetrics\x12\x13.PostMetricsRequest\x1a\x16.StreamMetricsResponse(\x01\x12;\n" +
+	"\fUpdateConfig\x12\x14.UpdateConfigRequest\x1a\x15.UpdateConfigResponse\x12,\n" +
+	"\tGetConfig\x12\x0e.DeviceRequest\x1a\x0f.ConfigResponse\x125\n" +
+	"\fDeleteConfig\x12\x0e.DeviceRequest\x1a\x15.DeleteConfigResponse\x12G\n" +
+	"\x10GetConfigHistory\x12\x18.GetConfigHistoryRequest\x1a\x19.GetConfigHistoryResponse\x122\n" +
+	"\tGetAlerts\x12\x11.GetAlertsRequest\x1a\x12.GetAlertsResponse\x125\n" +
+	"\n" +
+	"ListAlerts\x12\x12.ListAlertsRequest\x1a\x13.ListAlertsResponse\x127\n" +
+	"\x10AcknowledgeAlert\x12\x13.AlertActionRequest\x1a\x0e.AlertResponse\x123\n" +
+	"\fResolveAlert\x12\x13.AlertActionRequest\x1a\x0e.AlertResponse\x12,\n" +
+	"\vWatchAlerts\x12\x13.WatchAlertsRequest\x1a\x06.Alert0\x01\x128\n" +
+	"\vPostLimiter\x12\x13.PostLimiterRequest\x1a\x14.PostLimiterResponse\x125\n" +
+	"\n" +
+	"GetMetrics\x12\x12.GetMetricsRequest\x1a\x13.GetMetricsResponse\x12G\n" +
+	"\x10AggregateMetrics\x12\x18.AggregateMetricsRequest\x1a\x19.AggregateMetricsResponse\x12)\n" +
+	"\n" +
+	"CreateRule\x12\f.RuleRequest\x1a\r.RuleResponse\x12/\n" +
+	"\tListRules\x12\x0e.DeviceRequest\x1a\x12.ListRulesResponse\x12(\n" +
+	"\aGetRule\x12\x0e.RuleIdRequest\x1a\r.RuleResponse\x12)\n" +
+	"\n" +
+	"UpdateRule\x12\f.RuleRequest\x1a\r.RuleResponse\x121\n" +
+	"\n" +
+	"DeleteRule\x12\x0e.RuleIdRequest\x1a\x13.DeleteRuleResponse\x123\n" +
+	"\fCreateDevice\x12\x12.DeviceInfoRequest\x1a\x0f.DeviceResponse\x128\n" +
+	"\vListDevices\x12\x13.ListDevicesRequest\x1a\x14.ListDevicesResponse\x12,\n" +
+	"\tGetDevice\x12\x0e.DeviceRequest\x1a\x0f.DeviceResponse\x123\n" +
+	"\fUpdateDevice\x12\x12.DeviceInfoRequest\x1a\x0f.DeviceResponse\x125\n" +
+	"\fDeleteDevice\x12\x0e.DeviceRequest\x1a\x15.DeleteDeviceResponse\x12>\n" +
+	"\x11UpsertGroupConfig\x12\x13.GroupConfigRequest\x1a\x14.GroupConfigResponse\x125\n" +
+	"\x0eGetGroupConfig\x12\r.GroupRequest\x1a\x14.GroupConfigResponse\x12G\n" +
+	"\x10ListGroupConfigs\x12\x18.ListGroupConfigsRequest\x1a\x19.ListGroupConfigsResponse\x12>\n" +
+	"\x11DeleteGroupConfig\x12\r.GroupRequest\x1a\x1a.DeleteGroupConfigResponse\x12M\n" +
+	"\x12UpdateConfigsByTag\x12\x1a.UpdateConfigsByTagRequest\x1a\x1b.UpdateConfigsByTagResponse\x128\n" +
+	"\rResolveConfig\x12\x0e.DeviceRequest\x1a\x17.ResolvedConfigResponse\x128\n" +
+	"\fGetRetention\x12\x14.GetRetentionRequest\x1a\x12.RetentionResponseB\x15Z\x13/iot_metric_serviceb\x06proto3"
 
 var (
 	file_pkg_grpc_service_proto_rawDescOnce sync.Once
@@ -771,48 +4704,248 @@ func file_pkg_grpc_service_proto_rawDescGZIP() []byte {
 	return file_pkg_grpc_service_proto_rawDescData
 }
 
-var file_pkg_grpc_service_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_pkg_grpc_service_proto_msgTypes = make([]protoimpl.MessageInfo, 68)
 var file_pkg_grpc_service_proto_goTypes = []any{
-	(*MetricRequest)(nil),         // 0: MetricRequest
-	(*ConfigRequest)(nil),         // 1: ConfigRequest
-	(*PostMetricsRequest)(nil),    // 2: PostMetricsRequest
-	(*UpdateConfigRequest)(nil),   // 3: UpdateConfigRequest
-	(*DeviceRequest)(nil),         // 4: DeviceRequest
-	(*Alert)(nil),                 // 5: Alert
-	(*AlertList)(nil),             // 6: AlertList
-	(*PostMetricsResponse)(nil),   // 7: PostMetricsResponse
-	(*UpdateConfigResponse)(nil),  // 8: UpdateConfigResponse
-	(*GetAlertsResponse)(nil),     // 9: GetAlertsResponse
-	(*StatusResponse)(nil),        // 10: StatusResponse
-	(*PostLimiterRequest)(nil),    // 11: PostLimiterRequest
-	(*PostLimiterResponse)(nil),   // 12: PostLimiterResponse
-	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
+	(*MetricValue)(nil),                // 0: MetricValue
+	(*MetricRequest)(nil),              // 1: MetricRequest
+	(*ConfigRequest)(nil),              // 2: ConfigRequest
+	(*PostMetricsRequest)(nil),         // 3: PostMetricsRequest
+	(*PostMetricsBatchRequest)(nil),    // 4: PostMetricsBatchRequest
+	(*UpdateConfigRequest)(nil),        // 5: UpdateConfigRequest
+	(*DeviceRequest)(nil),              // 6: DeviceRequest
+	(*Alert)(nil),                      // 7: Alert
+	(*GetAlertsRequest)(nil),           // 8: GetAlertsRequest
+	(*ListAlertsRequest)(nil),          // 9: ListAlertsRequest
+	(*AlertSummary)(nil),               // 10: AlertSummary
+	(*ListAlertsResponse)(nil),         // 11: ListAlertsResponse
+	(*AlertActionRequest)(nil),         // 12: AlertActionRequest
+	(*AlertResponse)(nil),              // 13: AlertResponse
+	(*WatchAlertsRequest)(nil),         // 14: WatchAlertsRequest
+	(*AlertList)(nil),                  // 15: AlertList
+	(*PostMetricsResponse)(nil),        // 16: PostMetricsResponse
+	(*BatchItemStatus)(nil),            // 17: BatchItemStatus
+	(*PostMetricsBatchResponse)(nil),   // 18: PostMetricsBatchResponse
+	(*StreamMetricsResponse)(nil),      // 19: StreamMetricsResponse
+	(*UpdateConfigResponse)(nil),       // 20: UpdateConfigResponse
+	(*GetAlertsResponse)(nil),          // 21: GetAlertsResponse
+	(*StatusResponse)(nil),             // 22: StatusResponse
+	(*Metric)(nil),                     // 23: Metric
+	(*GetMetricsRequest)(nil),          // 24: GetMetricsRequest
+	(*GetMetricsResponse)(nil),         // 25: GetMetricsResponse
+	(*AggregateMetricsRequest)(nil),    // 26: AggregateMetricsRequest
+	(*MetricStats)(nil),                // 27: MetricStats
+	(*MetricBucket)(nil),               // 28: MetricBucket
+	(*AggregateMetricsResponse)(nil),   // 29: AggregateMetricsResponse
+	(*AlertRule)(nil),                  // 30: AlertRule
+	(*RuleRequest)(nil),                // 31: RuleRequest
+	(*RuleIdRequest)(nil),              // 32: RuleIdRequest
+	(*RuleResponse)(nil),               // 33: RuleResponse
+	(*ListRulesResponse)(nil),          // 34: ListRulesResponse
+	(*DeleteRuleResponse)(nil),         // 35: DeleteRuleResponse
+	(*PostLimiterRequest)(nil),         // 36: PostLimiterRequest
+	(*PostLimiterResponse)(nil),        // 37: PostLimiterResponse
+	(*Device)(nil),                     // 38: Device
+	(*DeviceInfoRequest)(nil),          // 39: DeviceInfoRequest
+	(*DeviceResponse)(nil),             // 40: DeviceResponse
+	(*ListDevicesRequest)(nil),         // 41: ListDevicesRequest
+	(*ListDevicesResponse)(nil),        // 42: ListDevicesResponse
+	(*DeleteDeviceResponse)(nil),       // 43: DeleteDeviceResponse
+	(*ConfigPatch)(nil),                // 44: ConfigPatch
+	(*GroupConfig)(nil),                // 45: GroupConfig
+	(*GroupConfigRequest)(nil),         // 46: GroupConfigRequest
+	(*GroupRequest)(nil),               // 47: GroupRequest
+	(*GroupConfigResponse)(nil),        // 48: GroupConfigResponse
+	(*ListGroupConfigsRequest)(nil),    // 49: ListGroupConfigsRequest
+	(*ListGroupConfigsResponse)(nil),   // 50: ListGroupConfigsResponse
+	(*DeleteGroupConfigResponse)(nil),  // 51: DeleteGroupConfigResponse
+	(*UpdateConfigsByTagRequest)(nil),  // 52: UpdateConfigsByTagRequest
+	(*UpdateConfigsByTagResponse)(nil), // 53: UpdateConfigsByTagResponse
+	(*DeviceConfig)(nil),               // 54: DeviceConfig
+	(*ConfigResponse)(nil),             // 55: ConfigResponse
+	(*DeleteConfigResponse)(nil),       // 56: DeleteConfigResponse
+	(*ConfigValues)(nil),               // 57: ConfigValues
+	(*ConfigChange)(nil),               // 58: ConfigChange
+	(*GetConfigHistoryRequest)(nil),    // 59: GetConfigHistoryRequest
+	(*GetConfigHistoryResponse)(nil),   // 60: GetConfigHistoryResponse
+	(*ConfigSources)(nil),              // 61: ConfigSources
+	(*ResolvedConfigResponse)(nil),     // 62: ResolvedConfigResponse
+	(*GetRetentionRequest)(nil),        // 63: GetRetentionRequest
+	(*RetentionPolicy)(nil),            // 64: RetentionPolicy
+	(*RetentionResponse)(nil),          // 65: RetentionResponse
+	nil,                                // 66: RetentionResponse.PrunedEntry
+	nil,                                // 67: RetentionResponse.LastPrunedEntry
+	(*timestamppb.Timestamp)(nil),      // 68: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),        // 69: google.protobuf.Duration
 }
 var file_pkg_grpc_service_proto_depIdxs = []int32{
-	13, // 0: MetricRequest.timestamp:type_name -> google.protobuf.Timestamp
-	0,  // 1: PostMetricsRequest.metric:type_name -> MetricRequest
-	1,  // 2: UpdateConfigRequest.config:type_name -> ConfigRequest
-	13, // 3: Alert.timestamp:type_name -> google.protobuf.Timestamp
-	10, // 4: AlertList.status:type_name -> StatusResponse
-	5,  // 5: AlertList.alerts:type_name -> Alert
-	10, // 6: PostMetricsResponse.status:type_name -> StatusResponse
-	10, // 7: UpdateConfigResponse.status:type_name -> StatusResponse
-	10, // 8: GetAlertsResponse.status:type_name -> StatusResponse
-	5,  // 9: GetAlertsResponse.alerts:type_name -> Alert
-	10, // 10: PostLimiterResponse.status:type_name -> StatusResponse
-	2,  // 11: IOTService.PostMetrics:input_type -> PostMetricsRequest
-	3,  // 12: IOTService.UpdateConfig:input_type -> UpdateConfigRequest
-	4,  // 13: IOTService.GetAlerts:input_type -> DeviceRequest
-	11, // 14: IOTService.PostLimiter:input_type -> PostLimiterRequest
-	7,  // 15: IOTService.PostMetrics:output_type -> PostMetricsResponse
-	8,  // 16: IOTService.UpdateConfig:output_type -> UpdateConfigResponse
-	9,  // 17: IOTService.GetAlerts:output_type -> GetAlertsResponse
-	12, // 18: IOTService.PostLimiter:output_type -> PostLimiterResponse
-	15, // [15:19] is the sub-list for method output_type
-	11, // [11:15] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	68,  // 0: MetricRequest.timestamp:type_name -> google.protobuf.Timestamp
+	0,   // 1: MetricRequest.values:type_name -> MetricValue
+	69,  // 2: ConfigRequest.heartbeat_interval:type_name -> google.protobuf.Duration
+	1,   // 3: PostMetricsRequest.metric:type_name -> MetricRequest
+	1,   // 4: PostMetricsBatchRequest.metrics:type_name -> MetricRequest
+	2,   // 5: UpdateConfigRequest.config:type_name -> ConfigRequest
+	44,  // 6: UpdateConfigRequest.patch:type_name -> ConfigPatch
+	68,  // 7: Alert.timestamp:type_name -> google.protobuf.Timestamp
+	68,  // 8: Alert.last_seen:type_name -> google.protobuf.Timestamp
+	68,  // 9: Alert.resolved_at:type_name -> google.protobuf.Timestamp
+	68,  // 10: Alert.acknowledged_at:type_name -> google.protobuf.Timestamp
+	68,  // 11: GetAlertsRequest.from:type_name -> google.protobuf.Timestamp
+	68,  // 12: GetAlertsRequest.to:type_name -> google.protobuf.Timestamp
+	68,  // 13: ListAlertsRequest.from:type_name -> google.protobuf.Timestamp
+	68,  // 14: ListAlertsRequest.to:type_name -> google.protobuf.Timestamp
+	68,  // 15: AlertSummary.last_alert_at:type_name -> google.protobuf.Timestamp
+	22,  // 16: ListAlertsResponse.status:type_name -> StatusResponse
+	7,   // 17: ListAlertsResponse.alerts:type_name -> Alert
+	10,  // 18: ListAlertsResponse.summary:type_name -> AlertSummary
+	22,  // 19: AlertResponse.status:type_name -> StatusResponse
+	7,   // 20: AlertResponse.alert:type_name -> Alert
+	22,  // 21: AlertList.status:type_name -> StatusResponse
+	7,   // 22: AlertList.alerts:type_name -> Alert
+	22,  // 23: PostMetricsResponse.status:type_name -> StatusResponse
+	22,  // 24: PostMetricsBatchResponse.status:type_name -> StatusResponse
+	17,  // 25: PostMetricsBatchResponse.results:type_name -> BatchItemStatus
+	22,  // 26: StreamMetricsResponse.status:type_name -> StatusResponse
+	22,  // 27: UpdateConfigResponse.status:type_name -> StatusResponse
+	54,  // 28: UpdateConfigResponse.config:type_name -> DeviceConfig
+	22,  // 29: GetAlertsResponse.status:type_name -> StatusResponse
+	7,   // 30: GetAlertsResponse.alerts:type_name -> Alert
+	68,  // 31: Metric.timestamp:type_name -> google.protobuf.Timestamp
+	0,   // 32: Metric.values:type_name -> MetricValue
+	68,  // 33: GetMetricsRequest.from:type_name -> google.protobuf.Timestamp
+	68,  // 34: GetMetricsRequest.to:type_name -> google.protobuf.Timestamp
+	22,  // 35: GetMetricsResponse.status:type_name -> StatusResponse
+	23,  // 36: GetMetricsResponse.metrics:type_name -> Metric
+	68,  // 37: AggregateMetricsRequest.from:type_name -> google.protobuf.Timestamp
+	68,  // 38: AggregateMetricsRequest.to:type_name -> google.protobuf.Timestamp
+	69,  // 39: AggregateMetricsRequest.interval:type_name -> google.protobuf.Duration
+	68,  // 40: MetricBucket.start:type_name -> google.protobuf.Timestamp
+	27,  // 41: MetricBucket.temperature:type_name -> MetricStats
+	27,  // 42: MetricBucket.battery:type_name -> MetricStats
+	22,  // 43: AggregateMetricsResponse.status:type_name -> StatusResponse
+	28,  // 44: AggregateMetricsResponse.buckets:type_name -> MetricBucket
+	69,  // 45: AlertRule.hold_for:type_name -> google.protobuf.Duration
+	30,  // 46: RuleRequest.rule:type_name -> AlertRule
+	22,  // 47: RuleResponse.status:type_name -> StatusResponse
+	30,  // 48: RuleResponse.rule:type_name -> AlertRule
+	22,  // 49: ListRulesResponse.status:type_name -> StatusResponse
+	30,  // 50: ListRulesResponse.rules:type_name -> AlertRule
+	22,  // 51: DeleteRuleResponse.status:type_name -> StatusResponse
+	22,  // 52: PostLimiterResponse.status:type_name -> StatusResponse
+	68,  // 53: Device.created_at:type_name -> google.protobuf.Timestamp
+	68,  // 54: Device.updated_at:type_name -> google.protobuf.Timestamp
+	68,  // 55: Device.last_seen_at:type_name -> google.protobuf.Timestamp
+	38,  // 56: DeviceInfoRequest.device:type_name -> Device
+	22,  // 57: DeviceResponse.status:type_name -> StatusResponse
+	38,  // 58: DeviceResponse.device:type_name -> Device
+	22,  // 59: ListDevicesResponse.status:type_name -> StatusResponse
+	38,  // 60: ListDevicesResponse.devices:type_name -> Device
+	22,  // 61: DeleteDeviceResponse.status:type_name -> StatusResponse
+	69,  // 62: ConfigPatch.heartbeat_interval:type_name -> google.protobuf.Duration
+	44,  // 63: GroupConfig.defaults:type_name -> ConfigPatch
+	68,  // 64: GroupConfig.updated_at:type_name -> google.protobuf.Timestamp
+	69,  // 65: GroupConfig.metric_retention:type_name -> google.protobuf.Duration
+	69,  // 66: GroupConfig.alert_retention:type_name -> google.protobuf.Duration
+	45,  // 67: GroupConfigRequest.group:type_name -> GroupConfig
+	22,  // 68: GroupConfigResponse.status:type_name -> StatusResponse
+	45,  // 69: GroupConfigResponse.group:type_name -> GroupConfig
+	22,  // 70: ListGroupConfigsResponse.status:type_name -> StatusResponse
+	45,  // 71: ListGroupConfigsResponse.groups:type_name -> GroupConfig
+	22,  // 72: DeleteGroupConfigResponse.status:type_name -> StatusResponse
+	44,  // 73: UpdateConfigsByTagRequest.patch:type_name -> ConfigPatch
+	22,  // 74: UpdateConfigsByTagResponse.status:type_name -> StatusResponse
+	69,  // 75: DeviceConfig.heartbeat_interval:type_name -> google.protobuf.Duration
+	68,  // 76: DeviceConfig.last_seen_at:type_name -> google.protobuf.Timestamp
+	22,  // 77: ConfigResponse.status:type_name -> StatusResponse
+	54,  // 78: ConfigResponse.config:type_name -> DeviceConfig
+	22,  // 79: DeleteConfigResponse.status:type_name -> StatusResponse
+	69,  // 80: ConfigValues.heartbeat_interval:type_name -> google.protobuf.Duration
+	68,  // 81: ConfigChange.timestamp:type_name -> google.protobuf.Timestamp
+	57,  // 82: ConfigChange.old:type_name -> ConfigValues
+	57,  // 83: ConfigChange.new:type_name -> ConfigValues
+	68,  // 84: GetConfigHistoryRequest.from:type_name -> google.protobuf.Timestamp
+	68,  // 85: GetConfigHistoryRequest.to:type_name -> google.protobuf.Timestamp
+	22,  // 86: GetConfigHistoryResponse.status:type_name -> StatusResponse
+	58,  // 87: GetConfigHistoryResponse.changes:type_name -> ConfigChange
+	22,  // 88: ResolvedConfigResponse.status:type_name -> StatusResponse
+	54,  // 89: ResolvedConfigResponse.config:type_name -> DeviceConfig
+	61,  // 90: ResolvedConfigResponse.sources:type_name -> ConfigSources
+	69,  // 91: RetentionPolicy.interval:type_name -> google.protobuf.Duration
+	69,  // 92: RetentionPolicy.metric_max_age:type_name -> google.protobuf.Duration
+	69,  // 93: RetentionPolicy.alert_max_age:type_name -> google.protobuf.Duration
+	22,  // 94: RetentionResponse.status:type_name -> StatusResponse
+	64,  // 95: RetentionResponse.policy:type_name -> RetentionPolicy
+	68,  // 96: RetentionResponse.last_run_at:type_name -> google.protobuf.Timestamp
+	66,  // 97: RetentionResponse.pruned:type_name -> RetentionResponse.PrunedEntry
+	67,  // 98: RetentionResponse.last_pruned:type_name -> RetentionResponse.LastPrunedEntry
+	3,   // 99: IOTService.PostMetrics:input_type -> PostMetricsRequest
+	4,   // 100: IOTService.PostMetricsBatch:input_type -> PostMetricsBatchRequest
+	3,   // 101: IOTService.StreamMetrics:input_type -> PostMetricsRequest
+	5,   // 102: IOTService.UpdateConfig:input_type -> UpdateConfigRequest
+	6,   // 103: IOTService.GetConfig:input_type -> DeviceRequest
+	6,   // 104: IOTService.DeleteConfig:input_type -> DeviceRequest
+	59,  // 105: IOTService.GetConfigHistory:input_type -> GetConfigHistoryRequest
+	8,   // 106: IOTService.GetAlerts:input_type -> GetAlertsRequest
+	9,   // 107: IOTService.ListAlerts:input_type -> ListAlertsRequest
+	12,  // 108: IOTService.AcknowledgeAlert:input_type -> AlertActionRequest
+	12,  // 109: IOTService.ResolveAlert:input_type -> AlertActionRequest
+	14,  // 110: IOTService.WatchAlerts:input_type -> WatchAlertsRequest
+	36,  // 111: IOTService.PostLimiter:input_type -> PostLimiterRequest
+	24,  // 112: IOTService.GetMetrics:input_type -> GetMetricsRequest
+	26,  // 113: IOTService.AggregateMetrics:input_type -> AggregateMetricsRequest
+	31,  // 114: IOTService.CreateRule:input_type -> RuleRequest
+	6,   // 115: IOTService.ListRules:input_type -> DeviceRequest
+	32,  // 116: IOTService.GetRule:input_type -> RuleIdRequest
+	31,  // 117: IOTService.UpdateRule:input_type -> RuleRequest
+	32,  // 118: IOTService.DeleteRule:input_type -> RuleIdRequest
+	39,  // 119: IOTService.CreateDevice:input_type -> DeviceInfoRequest
+	41,  // 120: IOTService.ListDevices:input_type -> ListDevicesRequest
+	6,   // 121: IOTService.GetDevice:input_type -> DeviceRequest
+	39,  // 122: IOTService.UpdateDevice:input_type -> DeviceInfoRequest
+	6,   // 123: IOTService.DeleteDevice:input_type -> DeviceRequest
+	46,  // 124: IOTService.UpsertGroupConfig:input_type -> GroupConfigRequest
+	47,  // 125: IOTService.GetGroupConfig:input_type -> GroupRequest
+	49,  // 126: IOTService.ListGroupConfigs:input_type -> ListGroupConfigsRequest
+	47,  // 127: IOTService.DeleteGroupConfig:input_type -> GroupRequest
+	52,  // 128: IOTService.UpdateConfigsByTag:input_type -> UpdateConfigsByTagRequest
+	6,   // 129: IOTService.ResolveConfig:input_type -> DeviceRequest
+	63,  // 130: IOTService.GetRetention:input_type -> GetRetentionRequest
+	16,  // 131: IOTService.PostMetrics:output_type -> PostMetricsResponse
+	18,  // 132: IOTService.PostMetricsBatch:output_type -> PostMetricsBatchResponse
+	19,  // 133: IOTService.StreamMetrics:output_type -> StreamMetricsResponse
+	20,  // 134: IOTService.UpdateConfig:output_type -> UpdateConfigResponse
+	55,  // 135: IOTService.GetConfig:output_type -> ConfigResponse
+	56,  // 136: IOTService.DeleteConfig:output_type -> DeleteConfigResponse
+	60,  // 137: IOTService.GetConfigHistory:output_type -> GetConfigHistoryResponse
+	21,  // 138: IOTService.GetAlerts:output_type -> GetAlertsResponse
+	11,  // 139: IOTService.ListAlerts:output_type -> ListAlertsResponse
+	13,  // 140: IOTService.AcknowledgeAlert:output_type -> AlertResponse
+	13,  // 141: IOTService.ResolveAlert:output_type -> AlertResponse
+	7,   // 142: IOTService.WatchAlerts:output_type -> Alert
+	37,  // 143: IOTService.PostLimiter:output_type -> PostLimiterResponse
+	25,  // 144: IOTService.GetMetrics:output_type -> GetMetricsResponse
+	29,  // 145: IOTService.AggregateMetrics:output_type -> AggregateMetricsResponse
+	33,  // 146: IOTService.CreateRule:output_type -> RuleResponse
+	34,  // 147: IOTService.ListRules:output_type -> ListRulesResponse
+	33,  // 148: IOTService.GetRule:output_type -> RuleResponse
+	33,  // 149: IOTService.UpdateRule:output_type -> RuleResponse
+	35,  // 150: IOTService.DeleteRule:output_type -> DeleteRuleResponse
+	40,  // 151: IOTService.CreateDevice:output_type -> DeviceResponse
+	42,  // 152: IOTService.ListDevices:output_type -> ListDevicesResponse
+	40,  // 153: IOTService.GetDevice:output_type -> DeviceResponse
+	40,  // 154: IOTService.UpdateDevice:output_type -> DeviceResponse
+	43,  // 155: IOTService.DeleteDevice:output_type -> DeleteDeviceResponse
+	48,  // 156: IOTService.UpsertGroupConfig:output_type -> GroupConfigResponse
+	48,  // 157: IOTService.GetGroupConfig:output_type -> GroupConfigResponse
+	50,  // 158: IOTService.ListGroupConfigs:output_type -> ListGroupConfigsResponse
+	51,  // 159: IOTService.DeleteGroupConfig:output_type -> DeleteGroupConfigResponse
+	53,  // 160: IOTService.UpdateConfigsByTag:output_type -> UpdateConfigsByTagResponse
+	62,  // 161: IOTService.ResolveConfig:output_type -> ResolvedConfigResponse
+	65,  // 162: IOTService.GetRetention:output_type -> RetentionResponse
+	131, // [131:163] is the sub-list for method output_type
+	99,  // [99:131] is the sub-list for method input_type
+	99,  // [99:99] is the sub-list for extension type_name
+	99,  // [99:99] is the sub-list for extension extendee
+	0,   // [0:99] is the sub-list for field type_name
 }
 
 func init() { file_pkg_grpc_service_proto_init() }
@@ -820,13 +4953,16 @@ func file_pkg_grpc_service_proto_init() {
 	if File_pkg_grpc_service_proto != nil {
 		return
 	}
+	file_pkg_grpc_service_proto_msgTypes[38].OneofWrappers = []any{}
+	file_pkg_grpc_service_proto_msgTypes[41].OneofWrappers = []any{}
+	file_pkg_grpc_service_proto_msgTypes[44].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_grpc_service_proto_rawDesc), len(file_pkg_grpc_service_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   13,
+			NumMessages:   68,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,10 +19,38 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	IOTService_PostMetrics_FullMethodName  = "/IOTService/PostMetrics"
-	IOTService_UpdateConfig_FullMethodName = "/IOTService/UpdateConfig"
-	IOTService_GetAlerts_FullMethodName    = "/IOTService/GetAlerts"
-	IOTService_PostLimiter_FullMethodName  = "/IOTService/PostLimiter"
+	IOTService_PostMetrics_FullMethodName        = "/IOTService/PostMetrics"
+	IOTService_PostMetricsBatch_FullMethodName   = "/IOTService/PostMetricsBatch"
+	IOTService_StreamMetrics_FullMethodName      = "/IOTService/StreamMetrics"
+	IOTService_UpdateConfig_FullMethodName       = "/IOTService/UpdateConfig"
+	IOTService_GetConfig_FullMethodName          = "/IOTService/GetConfig"
+	IOTService_DeleteConfig_FullMethodName       = "/IOTService/DeleteConfig"
+	IOTService_GetConfigHistory_FullMethodName   = "/IOTService/GetConfigHistory"
+	IOTService_GetAlerts_FullMethodName          = "/IOTService/GetAlerts"
+	IOTService_ListAlerts_FullMethodName         = "/IOTService/ListAlerts"
+	IOTService_AcknowledgeAlert_FullMethodName   = "/IOTService/AcknowledgeAlert"
+	IOTService_ResolveAlert_FullMethodName       = "/IOTService/ResolveAlert"
+	IOTService_WatchAlerts_FullMethodName        = "/IOTService/WatchAlerts"
+	IOTService_PostLimiter_FullMethodName        = "/IOTService/PostLimiter"
+	IOTService_GetMetrics_FullMethodName         = "/IOTService/GetMetrics"
+	IOTService_AggregateMetrics_FullMethodName   = "/IOTService/AggregateMetrics"
+	IOTService_CreateRule_FullMethodName         = "/IOTService/CreateRule"
+	IOTService_ListRules_FullMethodName          = "/IOTService/ListRules"
+	IOTService_GetRule_FullMethodName            = "/IOTService/GetRule"
+	IOTService_UpdateRule_FullMethodName         = "/IOTService/UpdateRule"
+	IOTService_DeleteRule_FullMethodName         = "/IOTService/DeleteRule"
+	IOTService_CreateDevice_FullMethodName       = "/IOTService/CreateDevice"
+	IOTService_ListDevices_FullMethodName        = "/IOTService/ListDevices"
+	IOTService_GetDevice_FullMethodName          = "/IOTService/GetDevice"
+	IOTService_UpdateDevice_FullMethodName       = "/IOTService/UpdateDevice"
+	IOTService_DeleteDevice_FullMethodName       = "/IOTService/DeleteDevice"
+	IOTService_UpsertGroupConfig_FullMethodName  = "/IOTService/UpsertGroupConfig"
+	IOTService_GetGroupConfig_FullMethodName     = "/IOTService/GetGroupConfig"
+	IOTService_ListGroupConfigs_FullMethodName   = "/IOTService/ListGroupConfigs"
+	IOTService_DeleteGroupConfig_FullMethodName  = "/IOTService/DeleteGroupConfig"
+	IOTService_UpdateConfigsByTag_FullMethodName = "/IOTService/UpdateConfigsByTag"
+	IOTService_ResolveConfig_FullMethodName      = "/IOTService/ResolveConfig"
+	IOTService_GetRetention_FullMethodName       = "/IOTService/GetRetention"
 )
 
 // IOTServiceClient is the client API for IOTService service.
@@ -30,9 +58,37 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type IOTServiceClient interface {
 	PostMetrics(ctx context.Context, in *PostMetricsRequest, opts ...grpc.CallOption) (*PostMetricsResponse, error)
+	PostMetricsBatch(ctx context.Context, in *PostMetricsBatchRequest, opts ...grpc.CallOption) (*PostMetricsBatchResponse, error)
+	StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PostMetricsRequest, StreamMetricsResponse], error)
 	UpdateConfig(ctx context.Context, in *UpdateConfigRequest, opts ...grpc.CallOption) (*UpdateConfigResponse, error)
-	GetAlerts(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*GetAlertsResponse, error)
+	GetConfig(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*ConfigResponse, error)
+	DeleteConfig(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeleteConfigResponse, error)
+	GetConfigHistory(ctx context.Context, in *GetConfigHistoryRequest, opts ...grpc.CallOption) (*GetConfigHistoryResponse, error)
+	GetAlerts(ctx context.Context, in *GetAlertsRequest, opts ...grpc.CallOption) (*GetAlertsResponse, error)
+	ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error)
+	AcknowledgeAlert(ctx context.Context, in *AlertActionRequest, opts ...grpc.CallOption) (*AlertResponse, error)
+	ResolveAlert(ctx context.Context, in *AlertActionRequest, opts ...grpc.CallOption) (*AlertResponse, error)
+	WatchAlerts(ctx context.Context, in *WatchAlertsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Alert], error)
 	PostLimiter(ctx context.Context, in *PostLimiterRequest, opts ...grpc.CallOption) (*PostLimiterResponse, error)
+	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error)
+	AggregateMetrics(ctx context.Context, in *AggregateMetricsRequest, opts ...grpc.CallOption) (*AggregateMetricsResponse, error)
+	CreateRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleResponse, error)
+	ListRules(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*ListRulesResponse, error)
+	GetRule(ctx context.Context, in *RuleIdRequest, opts ...grpc.CallOption) (*RuleResponse, error)
+	UpdateRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleResponse, error)
+	DeleteRule(ctx context.Context, in *RuleIdRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error)
+	CreateDevice(ctx context.Context, in *DeviceInfoRequest, opts ...grpc.CallOption) (*DeviceResponse, error)
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	GetDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeviceResponse, error)
+	UpdateDevice(ctx context.Context, in *DeviceInfoRequest, opts ...grpc.CallOption) (*DeviceResponse, error)
+	DeleteDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeleteDeviceResponse, error)
+	UpsertGroupConfig(ctx context.Context, in *GroupConfigRequest, opts ...grpc.CallOption) (*GroupConfigResponse, error)
+	GetGroupConfig(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*GroupConfigResponse, error)
+	ListGroupConfigs(ctx context.Context, in *ListGroupConfigsRequest, opts ...grpc.CallOption) (*ListGroupConfigsResponse, error)
+	DeleteGroupConfig(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*DeleteGroupConfigResponse, error)
+	UpdateConfigsByTag(ctx context.Context, in *UpdateConfigsByTagRequest, opts ...grpc.CallOption) (*UpdateConfigsByTagResponse, error)
+	ResolveConfig(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*ResolvedConfigResponse, error)
+	GetRetention(ctx context.Context, in *GetRetentionRequest, opts ...grpc.CallOption) (*RetentionResponse, error)
 }
 
 type iOTServiceClient struct {
@@ -53,6 +109,29 @@ func (c *iOTServiceClient) PostMetrics(ctx context.Context, in *PostMetricsReque
 	return out, nil
 }
 
+func (c *iOTServiceClient) PostMetricsBatch(ctx context.Context, in *PostMetricsBatchRequest, opts ...grpc.CallOption) (*PostMetricsBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PostMetricsBatchResponse)
+	err := c.cc.Invoke(ctx, IOTService_PostMetricsBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PostMetricsRequest, StreamMetricsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IOTService_ServiceDesc.Streams[0], IOTService_StreamMetrics_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PostMetricsRequest, StreamMetricsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IOTService_StreamMetricsClient = grpc.ClientStreamingClient[PostMetricsRequest, StreamMetricsResponse]
+
 func (c *iOTServiceClient) UpdateConfig(ctx context.Context, in *UpdateConfigRequest, opts ...grpc.CallOption) (*UpdateConfigResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(UpdateConfigResponse)
@@ -63,7 +142,37 @@ func (c *iOTServiceClient) UpdateConfig(ctx context.Context, in *UpdateConfigReq
 	return out, nil
 }
 
-func (c *iOTServiceClient) GetAlerts(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*GetAlertsResponse, error) {
+func (c *iOTServiceClient) GetConfig(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*ConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfigResponse)
+	err := c.cc.Invoke(ctx, IOTService_GetConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) DeleteConfig(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeleteConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteConfigResponse)
+	err := c.cc.Invoke(ctx, IOTService_DeleteConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) GetConfigHistory(ctx context.Context, in *GetConfigHistoryRequest, opts ...grpc.CallOption) (*GetConfigHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetConfigHistoryResponse)
+	err := c.cc.Invoke(ctx, IOTService_GetConfigHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) GetAlerts(ctx context.Context, in *GetAlertsRequest, opts ...grpc.CallOption) (*GetAlertsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetAlertsResponse)
 	err := c.cc.Invoke(ctx, IOTService_GetAlerts_FullMethodName, in, out, cOpts...)
@@ -73,6 +182,55 @@ func (c *iOTServiceClient) GetAlerts(ctx context.Context, in *DeviceRequest, opt
 	return out, nil
 }
 
+func (c *iOTServiceClient) ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAlertsResponse)
+	err := c.cc.Invoke(ctx, IOTService_ListAlerts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) AcknowledgeAlert(ctx context.Context, in *AlertActionRequest, opts ...grpc.CallOption) (*AlertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AlertResponse)
+	err := c.cc.Invoke(ctx, IOTService_AcknowledgeAlert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) ResolveAlert(ctx context.Context, in *AlertActionRequest, opts ...grpc.CallOption) (*AlertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AlertResponse)
+	err := c.cc.Invoke(ctx, IOTService_ResolveAlert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) WatchAlerts(ctx context.Context, in *WatchAlertsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Alert], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IOTService_ServiceDesc.Streams[1], IOTService_WatchAlerts_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchAlertsRequest, Alert]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IOTService_WatchAlertsClient = grpc.ServerStreamingClient[Alert]
+
 func (c *iOTServiceClient) PostLimiter(ctx context.Context, in *PostLimiterRequest, opts ...grpc.CallOption) (*PostLimiterResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(PostLimiterResponse)
@@ -83,14 +241,232 @@ func (c *iOTServiceClient) PostLimiter(ctx context.Context, in *PostLimiterReque
 	return out, nil
 }
 
+func (c *iOTServiceClient) GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetMetricsResponse)
+	err := c.cc.Invoke(ctx, IOTService_GetMetrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) AggregateMetrics(ctx context.Context, in *AggregateMetricsRequest, opts ...grpc.CallOption) (*AggregateMetricsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AggregateMetricsResponse)
+	err := c.cc.Invoke(ctx, IOTService_AggregateMetrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) CreateRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RuleResponse)
+	err := c.cc.Invoke(ctx, IOTService_CreateRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) ListRules(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*ListRulesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRulesResponse)
+	err := c.cc.Invoke(ctx, IOTService_ListRules_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) GetRule(ctx context.Context, in *RuleIdRequest, opts ...grpc.CallOption) (*RuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RuleResponse)
+	err := c.cc.Invoke(ctx, IOTService_GetRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) UpdateRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RuleResponse)
+	err := c.cc.Invoke(ctx, IOTService_UpdateRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) DeleteRule(ctx context.Context, in *RuleIdRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteRuleResponse)
+	err := c.cc.Invoke(ctx, IOTService_DeleteRule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) CreateDevice(ctx context.Context, in *DeviceInfoRequest, opts ...grpc.CallOption) (*DeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeviceResponse)
+	err := c.cc.Invoke(ctx, IOTService_CreateDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, IOTService_ListDevices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) GetDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeviceResponse)
+	err := c.cc.Invoke(ctx, IOTService_GetDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) UpdateDevice(ctx context.Context, in *DeviceInfoRequest, opts ...grpc.CallOption) (*DeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeviceResponse)
+	err := c.cc.Invoke(ctx, IOTService_UpdateDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) DeleteDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*DeleteDeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteDeviceResponse)
+	err := c.cc.Invoke(ctx, IOTService_DeleteDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) UpsertGroupConfig(ctx context.Context, in *GroupConfigRequest, opts ...grpc.CallOption) (*GroupConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GroupConfigResponse)
+	err := c.cc.Invoke(ctx, IOTService_UpsertGroupConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) GetGroupConfig(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*GroupConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GroupConfigResponse)
+	err := c.cc.Invoke(ctx, IOTService_GetGroupConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) ListGroupConfigs(ctx context.Context, in *ListGroupConfigsRequest, opts ...grpc.CallOption) (*ListGroupConfigsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListGroupConfigsResponse)
+	err := c.cc.Invoke(ctx, IOTService_ListGroupConfigs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) DeleteGroupConfig(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*DeleteGroupConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteGroupConfigResponse)
+	err := c.cc.Invoke(ctx, IOTService_DeleteGroupConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) UpdateConfigsByTag(ctx context.Context, in *UpdateConfigsByTagRequest, opts ...grpc.CallOption) (*UpdateConfigsByTagResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateConfigsByTagResponse)
+	err := c.cc.Invoke(ctx, IOTService_UpdateConfigsByTag_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) ResolveConfig(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*ResolvedConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResolvedConfigResponse)
+	err := c.cc.Invoke(ctx, IOTService_ResolveConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iOTServiceClient) GetRetention(ctx context.Context, in *GetRetentionRequest, opts ...grpc.CallOption) (*RetentionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RetentionResponse)
+	err := c.cc.Invoke(ctx, IOTService_GetRetention_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IOTServiceServer is the server API for IOTService service.
 // All implementations must embed UnimplementedIOTServiceServer
 // for forward compatibility.
 type IOTServiceServer interface {
 	PostMetrics(context.Context, *PostMetricsRequest) (*PostMetricsResponse, error)
+	PostMetricsBatch(context.Context, *PostMetricsBatchRequest) (*PostMetricsBatchResponse, error)
+	StreamMetrics(grpc.ClientStreamingServer[PostMetricsRequest, StreamMetricsResponse]) error
 	UpdateConfig(context.Context, *UpdateConfigRequest) (*UpdateConfigResponse, error)
-	GetAlerts(context.Context, *DeviceRequest) (*GetAlertsResponse, error)
+	GetConfig(context.Context, *DeviceRequest) (*ConfigResponse, error)
+	DeleteConfig(context.Context, *DeviceRequest) (*DeleteConfigResponse, error)
+	GetConfigHistory(context.Context, *GetConfigHistoryRequest) (*GetConfigHistoryResponse, error)
+	GetAlerts(context.Context, *GetAlertsRequest) (*GetAlertsResponse, error)
+	ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error)
+	AcknowledgeAlert(context.Context, *AlertActionRequest) (*AlertResponse, error)
+	ResolveAlert(context.Context, *AlertActionRequest) (*AlertResponse, error)
+	WatchAlerts(*WatchAlertsRequest, grpc.ServerStreamingServer[Alert]) error
 	PostLimiter(context.Context, *PostLimiterRequest) (*PostLimiterResponse, error)
+	GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error)
+	AggregateMetrics(context.Context, *AggregateMetricsRequest) (*AggregateMetricsResponse, error)
+	CreateRule(context.Context, *RuleRequest) (*RuleResponse, error)
+	ListRules(context.Context, *DeviceRequest) (*ListRulesResponse, error)
+	GetRule(context.Context, *RuleIdRequest) (*RuleResponse, error)
+	UpdateRule(context.Context, *RuleRequest) (*RuleResponse, error)
+	DeleteRule(context.Context, *RuleIdRequest) (*DeleteRuleResponse, error)
+	CreateDevice(context.Context, *DeviceInfoRequest) (*DeviceResponse, error)
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	GetDevice(context.Context, *DeviceRequest) (*DeviceResponse, error)
+	UpdateDevice(context.Context, *DeviceInfoRequest) (*DeviceResponse, error)
+	DeleteDevice(context.Context, *DeviceRequest) (*DeleteDeviceResponse, error)
+	UpsertGroupConfig(context.Context, *GroupConfigRequest) (*GroupConfigResponse, error)
+	GetGroupConfig(context.Context, *GroupRequest) (*GroupConfigResponse, error)
+	ListGroupConfigs(context.Context, *ListGroupConfigsRequest) (*ListGroupConfigsResponse, error)
+	DeleteGroupConfig(context.Context, *GroupRequest) (*DeleteGroupConfigResponse, error)
+	UpdateConfigsByTag(context.Context, *UpdateConfigsByTagRequest) (*UpdateConfigsByTagResponse, error)
+	ResolveConfig(context.Context, *DeviceRequest) (*ResolvedConfigResponse, error)
+	GetRetention(context.Context, *GetRetentionRequest) (*RetentionResponse, error)
 	mustEmbedUnimplementedIOTServiceServer()
 }
 
@@ -104,15 +480,99 @@ type UnimplementedIOTServiceServer struct{}
 func (UnimplementedIOTServiceServer) PostMetrics(context.Context, *PostMetricsRequest) (*PostMetricsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PostMetrics not implemented")
 }
+func (UnimplementedIOTServiceServer) PostMetricsBatch(context.Context, *PostMetricsBatchRequest) (*PostMetricsBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PostMetricsBatch not implemented")
+}
+func (UnimplementedIOTServiceServer) StreamMetrics(grpc.ClientStreamingServer[PostMetricsRequest, StreamMetricsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMetrics not implemented")
+}
 func (UnimplementedIOTServiceServer) UpdateConfig(context.Context, *UpdateConfigRequest) (*UpdateConfigResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateConfig not implemented")
 }
-func (UnimplementedIOTServiceServer) GetAlerts(context.Context, *DeviceRequest) (*GetAlertsResponse, error) {
+func (UnimplementedIOTServiceServer) GetConfig(context.Context, *DeviceRequest) (*ConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedIOTServiceServer) DeleteConfig(context.Context, *DeviceRequest) (*DeleteConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteConfig not implemented")
+}
+func (UnimplementedIOTServiceServer) GetConfigHistory(context.Context, *GetConfigHistoryRequest) (*GetConfigHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfigHistory not implemented")
+}
+func (UnimplementedIOTServiceServer) GetAlerts(context.Context, *GetAlertsRequest) (*GetAlertsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAlerts not implemented")
 }
+func (UnimplementedIOTServiceServer) ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAlerts not implemented")
+}
+func (UnimplementedIOTServiceServer) AcknowledgeAlert(context.Context, *AlertActionRequest) (*AlertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcknowledgeAlert not implemented")
+}
+func (UnimplementedIOTServiceServer) ResolveAlert(context.Context, *AlertActionRequest) (*AlertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveAlert not implemented")
+}
+func (UnimplementedIOTServiceServer) WatchAlerts(*WatchAlertsRequest, grpc.ServerStreamingServer[Alert]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchAlerts not implemented")
+}
 func (UnimplementedIOTServiceServer) PostLimiter(context.Context, *PostLimiterRequest) (*PostLimiterResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PostLimiter not implemented")
 }
+func (UnimplementedIOTServiceServer) GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
+}
+func (UnimplementedIOTServiceServer) AggregateMetrics(context.Context, *AggregateMetricsRequest) (*AggregateMetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AggregateMetrics not implemented")
+}
+func (UnimplementedIOTServiceServer) CreateRule(context.Context, *RuleRequest) (*RuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRule not implemented")
+}
+func (UnimplementedIOTServiceServer) ListRules(context.Context, *DeviceRequest) (*ListRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRules not implemented")
+}
+func (UnimplementedIOTServiceServer) GetRule(context.Context, *RuleIdRequest) (*RuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRule not implemented")
+}
+func (UnimplementedIOTServiceServer) UpdateRule(context.Context, *RuleRequest) (*RuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRule not implemented")
+}
+func (UnimplementedIOTServiceServer) DeleteRule(context.Context, *RuleIdRequest) (*DeleteRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRule not implemented")
+}
+func (UnimplementedIOTServiceServer) CreateDevice(context.Context, *DeviceInfoRequest) (*DeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDevice not implemented")
+}
+func (UnimplementedIOTServiceServer) ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedIOTServiceServer) GetDevice(context.Context, *DeviceRequest) (*DeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDevice not implemented")
+}
+func (UnimplementedIOTServiceServer) UpdateDevice(context.Context, *DeviceInfoRequest) (*DeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateDevice not implemented")
+}
+func (UnimplementedIOTServiceServer) DeleteDevice(context.Context, *DeviceRequest) (*DeleteDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDevice not implemented")
+}
+func (UnimplementedIOTServiceServer) UpsertGroupConfig(context.Context, *GroupConfigRequest) (*GroupConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertGroupConfig not implemented")
+}
+func (UnimplementedIOTServiceServer) GetGroupConfig(context.Context, *GroupRequest) (*GroupConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroupConfig not implemented")
+}
+func (UnimplementedIOTServiceServer) ListGroupConfigs(context.Context, *ListGroupConfigsRequest) (*ListGroupConfigsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGroupConfigs not implemented")
+}
+func (UnimplementedIOTServiceServer) DeleteGroupConfig(context.Context, *GroupRequest) (*DeleteGroupConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGroupConfig not implemented")
+}
+func (UnimplementedIOTServiceServer) UpdateConfigsByTag(context.Context, *UpdateConfigsByTagRequest) (*UpdateConfigsByTagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateConfigsByTag not implemented")
+}
+func (UnimplementedIOTServiceServer) ResolveConfig(context.Context, *DeviceRequest) (*ResolvedConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveConfig not implemented")
+}
+func (UnimplementedIOTServiceServer) GetRetention(context.Context, *GetRetentionRequest) (*RetentionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRetention not implemented")
+}
 func (UnimplementedIOTServiceServer) mustEmbedUnimplementedIOTServiceServer() {}
 func (UnimplementedIOTServiceServer) testEmbeddedByValue()                    {}
 
@@ -152,6 +612,31 @@ func _IOTService_PostMetrics_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _IOTService_PostMetricsBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PostMetricsBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).PostMetricsBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_PostMetricsBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).PostMetricsBatch(ctx, req.(*PostMetricsBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IOTServiceServer).StreamMetrics(&grpc.GenericServerStream[PostMetricsRequest, StreamMetricsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IOTService_StreamMetricsServer = grpc.ClientStreamingServer[PostMetricsRequest, StreamMetricsResponse]
+
 func _IOTService_UpdateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateConfigRequest)
 	if err := dec(in); err != nil {
@@ -170,45 +655,506 @@ func _IOTService_UpdateConfig_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
-func _IOTService_GetAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _IOTService_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeviceRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(IOTServiceServer).GetAlerts(ctx, in)
+		return srv.(IOTServiceServer).GetConfig(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: IOTService_GetAlerts_FullMethodName,
+		FullMethod: IOTService_GetConfig_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(IOTServiceServer).GetAlerts(ctx, req.(*DeviceRequest))
+		return srv.(IOTServiceServer).GetConfig(ctx, req.(*DeviceRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _IOTService_PostLimiter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PostLimiterRequest)
+func _IOTService_DeleteConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(IOTServiceServer).PostLimiter(ctx, in)
+		return srv.(IOTServiceServer).DeleteConfig(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: IOTService_PostLimiter_FullMethodName,
+		FullMethod: IOTService_DeleteConfig_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(IOTServiceServer).PostLimiter(ctx, req.(*PostLimiterRequest))
+		return srv.(IOTServiceServer).DeleteConfig(ctx, req.(*DeviceRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-// IOTService_ServiceDesc is the grpc.ServiceDesc for IOTService service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
+func _IOTService_GetConfigHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).GetConfigHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_GetConfigHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).GetConfigHistory(ctx, req.(*GetConfigHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_GetAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAlertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).GetAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_GetAlerts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).GetAlerts(ctx, req.(*GetAlertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_ListAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAlertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).ListAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_ListAlerts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).ListAlerts(ctx, req.(*ListAlertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_AcknowledgeAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlertActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).AcknowledgeAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_AcknowledgeAlert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).AcknowledgeAlert(ctx, req.(*AlertActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_ResolveAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlertActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).ResolveAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_ResolveAlert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).ResolveAlert(ctx, req.(*AlertActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_WatchAlerts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchAlertsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IOTServiceServer).WatchAlerts(m, &grpc.GenericServerStream[WatchAlertsRequest, Alert]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IOTService_WatchAlertsServer = grpc.ServerStreamingServer[Alert]
+
+func _IOTService_PostLimiter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PostLimiterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).PostLimiter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_PostLimiter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).PostLimiter(ctx, req.(*PostLimiterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_GetMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).GetMetrics(ctx, req.(*GetMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_AggregateMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AggregateMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).AggregateMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_AggregateMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).AggregateMetrics(ctx, req.(*AggregateMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_CreateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).CreateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_CreateRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).CreateRule(ctx, req.(*RuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_ListRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).ListRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_ListRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).ListRules(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_GetRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).GetRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_GetRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).GetRule(ctx, req.(*RuleIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_UpdateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).UpdateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_UpdateRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).UpdateRule(ctx, req.(*RuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_DeleteRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).DeleteRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_DeleteRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).DeleteRule(ctx, req.(*RuleIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_CreateDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).CreateDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_CreateDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).CreateDevice(ctx, req.(*DeviceInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_GetDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).GetDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_GetDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).GetDevice(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_UpdateDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).UpdateDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_UpdateDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).UpdateDevice(ctx, req.(*DeviceInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_DeleteDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).DeleteDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_DeleteDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).DeleteDevice(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_UpsertGroupConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).UpsertGroupConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_UpsertGroupConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).UpsertGroupConfig(ctx, req.(*GroupConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_GetGroupConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).GetGroupConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_GetGroupConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).GetGroupConfig(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_ListGroupConfigs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGroupConfigsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).ListGroupConfigs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_ListGroupConfigs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).ListGroupConfigs(ctx, req.(*ListGroupConfigsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_DeleteGroupConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).DeleteGroupConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_DeleteGroupConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).DeleteGroupConfig(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_UpdateConfigsByTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateConfigsByTagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).UpdateConfigsByTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_UpdateConfigsByTag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).UpdateConfigsByTag(ctx, req.(*UpdateConfigsByTagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_ResolveConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).ResolveConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_ResolveConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).ResolveConfig(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IOTService_GetRetention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRetentionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IOTServiceServer).GetRetention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IOTService_GetRetention_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IOTServiceServer).GetRetention(ctx, req.(*GetRetentionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// IOTService_ServiceDesc is the grpc.ServiceDesc for IOTService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
 var IOTService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "IOTService",
 	HandlerType: (*IOTServiceServer)(nil),
@@ -217,19 +1163,134 @@ var IOTService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "PostMetrics",
 			Handler:    _IOTService_PostMetrics_Handler,
 		},
+		{
+			MethodName: "PostMetricsBatch",
+			Handler:    _IOTService_PostMetricsBatch_Handler,
+		},
 		{
 			MethodName: "UpdateConfig",
 			Handler:    _IOTService_UpdateConfig_Handler,
 		},
+		{
+			MethodName: "GetConfig",
+			Handler:    _IOTService_GetConfig_Handler,
+		},
+		{
+			MethodName: "DeleteConfig",
+			Handler:    _IOTService_DeleteConfig_Handler,
+		},
+		{
+			MethodName: "GetConfigHistory",
+			Handler:    _IOTService_GetConfigHistory_Handler,
+		},
 		{
 			MethodName: "GetAlerts",
 			Handler:    _IOTService_GetAlerts_Handler,
 		},
+		{
+			MethodName: "ListAlerts",
+			Handler:    _IOTService_ListAlerts_Handler,
+		},
+		{
+			MethodName: "AcknowledgeAlert",
+			Handler:    _IOTService_AcknowledgeAlert_Handler,
+		},
+		{
+			MethodName: "ResolveAlert",
+			Handler:    _IOTService_ResolveAlert_Handler,
+		},
 		{
 			MethodName: "PostLimiter",
 			Handler:    _IOTService_PostLimiter_Handler,
 		},
+		{
+			MethodName: "GetMetrics",
+			Handler:    _IOTService_GetMetrics_Handler,
+		},
+		{
+			MethodName: "AggregateMetrics",
+			Handler:    _IOTService_AggregateMetrics_Handler,
+		},
+		{
+			MethodName: "CreateRule",
+			Handler:    _IOTService_CreateRule_Handler,
+		},
+		{
+			MethodName: "ListRules",
+			Handler:    _IOTService_ListRules_Handler,
+		},
+		{
+			MethodName: "GetRule",
+			Handler:    _IOTService_GetRule_Handler,
+		},
+		{
+			MethodName: "UpdateRule",
+			Handler:    _IOTService_UpdateRule_Handler,
+		},
+		{
+			MethodName: "DeleteRule",
+			Handler:    _IOTService_DeleteRule_Handler,
+		},
+		{
+			MethodName: "CreateDevice",
+			Handler:    _IOTService_CreateDevice_Handler,
+		},
+		{
+			MethodName: "ListDevices",
+			Handler:    _IOTService_ListDevices_Handler,
+		},
+		{
+			MethodName: "GetDevice",
+			Handler:    _IOTService_GetDevice_Handler,
+		},
+		{
+			MethodName: "UpdateDevice",
+			Handler:    _IOTService_UpdateDevice_Handler,
+		},
+		{
+			MethodName: "DeleteDevice",
+			Handler:    _IOTService_DeleteDevice_Handler,
+		},
+		{
+			MethodName: "UpsertGroupConfig",
+			Handler:    _IOTService_UpsertGroupConfig_Handler,
+		},
+		{
+			MethodName: "GetGroupConfig",
+			Handler:    _IOTService_GetGroupConfig_Handler,
+		},
+		{
+			MethodName: "ListGroupConfigs",
+			Handler:    _IOTService_ListGroupConfigs_Handler,
+		},
+		{
+			MethodName: "DeleteGroupConfig",
+			Handler:    _IOTService_DeleteGroupConfig_Handler,
+		},
+		{
+			MethodName: "UpdateConfigsByTag",
+			Handler:    _IOTService_UpdateConfigsByTag_Handler,
+		},
+		{
+			MethodName: "ResolveConfig",
+			Handler:    _IOTService_ResolveConfig_Handler,
+		},
+		{
+			MethodName: "GetRetention",
+			Handler:    _IOTService_GetRetention_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _IOTService_StreamMetrics_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchAlerts",
+			Handler:       _IOTService_WatchAlerts_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/grpc/service.proto",
 }
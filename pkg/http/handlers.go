@@ -1,29 +1,62 @@
 package http
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/iot"
 	"liyu1981.xyz/iot-metrics-service/pkg/models"
 
 	"github.com/gin-gonic/gin"
 
 	z "github.com/Oudwins/zog"
+	"github.com/Oudwins/zog/parsers/zjson"
 	"github.com/Oudwins/zog/zhttp"
 )
 
+type MetricValueRequest struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
 type MetricRequest struct {
-	Timestamp   time.Time `json:"timestamp"`
-	Temperature float64   `json:"temperature"`
-	Battery     float64   `json:"battery"`
+	Timestamp   time.Time            `json:"timestamp"`
+	Temperature float64              `json:"temperature"`
+	Battery     float64              `json:"battery"`
+	Values      []MetricValueRequest `json:"values"`
 }
 
 var metricRequestSchema = z.Struct(z.Shape{
 	"Timestamp":   z.Time().Required(),
 	"Temperature": z.Float64().Required(),
 	"Battery":     z.Float64().Required(),
+	"values": z.Slice(z.Struct(z.Shape{
+		"name":  z.String().Required(),
+		"value": z.Float64(),
+		"unit":  z.String().Optional(),
+	})).Optional(),
 })
 
+func (req *MetricRequest) toMetric() models.Metric {
+	return models.Metric{
+		Timestamp:   req.Timestamp,
+		Temperature: req.Temperature,
+		Battery:     req.Battery,
+		Values: common.Mapper(req.Values, func(v MetricValueRequest) models.MetricValue {
+			return models.MetricValue{Name: v.Name, Value: v.Value, Unit: v.Unit}
+		}),
+	}
+}
+
 func (rs *RestfulServer) PostMetrics(c *gin.Context) {
 	deviceID := c.Param("device_id")
 
@@ -39,26 +72,241 @@ func (rs *RestfulServer) PostMetrics(c *gin.Context) {
 		return
 	}
 
-	if err := rs.Iot.Metric.UpsertMetric(deviceID, &models.Metric{
-		Timestamp:   req.Timestamp,
-		Temperature: req.Temperature,
-		Battery:     req.Battery,
-	}); err != nil {
+	metric := req.toMetric()
+	if err := rs.Iot.Metric.UpsertMetric(deviceID, &metric); err != nil {
+		if code := metricErrorStatus(c, err); code != http.StatusInternalServerError {
+			c.JSON(code, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, err)
 		return
 	}
 
-	c.Status(http.StatusOK)
+	c.Status(rs.metricWrittenStatus())
+}
+
+// metricErrorStatus is the response status of a failed metric write, a full
+// ingest queue asks the client to retry later
+func metricErrorStatus(c *gin.Context, err error) int {
+	switch {
+	case errors.Is(err, iot.ErrInvalidMetric):
+		return http.StatusBadRequest
+	case errors.Is(err, iot.ErrDeviceNotRegistered), errors.Is(err, iot.ErrDeviceDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, iot.ErrIngestQueueFull):
+		c.Header("Retry-After", "1")
+		return http.StatusTooManyRequests
+	case errors.Is(err, iot.ErrIngestClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// metricWrittenStatus is the response status of metrics taken, they are only
+// accepted yet when written behind by the ingester
+func (rs *RestfulServer) metricWrittenStatus() int {
+	if rs.Iot.Ingester != nil {
+		return http.StatusAccepted
+	}
+	return http.StatusOK
+}
+
+type MetricBatchRequest struct {
+	Metrics []json.RawMessage `json:"metrics"`
+}
+
+type MetricBatchItemResult struct {
+	Index   int    `json:"index"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PostMetricsVerb serves custom methods on metrics, i.e. /metrics:<verb>
+func (rs *RestfulServer) PostMetricsVerb(c *gin.Context) {
+	switch c.Param("verb") {
+	case ":batch":
+		rs.PostMetricsBatch(c)
+	default:
+		c.Status(http.StatusNotFound)
+	}
+}
+
+func (rs *RestfulServer) PostMetricsBatch(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	var req MetricBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Metrics) == 0 || len(req.Metrics) > iot.MaxMetricBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("batch must contain 1 to %d metrics", iot.MaxMetricBatchSize)})
+		return
+	}
+
+	// every entry is validated on its own so one bad reading does not reject
+	// the whole batch
+	results := make([]MetricBatchItemResult, len(req.Metrics))
+	var valid []models.Metric
+	var validIndexes []int
+	for idx, raw := range req.Metrics {
+		var item MetricRequest
+		if err := metricRequestSchema.Parse(zjson.Decode(bytes.NewReader(raw)), &item); err != nil {
+			results[idx] = MetricBatchItemResult{Index: idx, Success: false, Error: fmt.Sprintf("validation error: %v", err)}
+			continue
+		}
+		metric := item.toMetric()
+		if err := iot.ValidateMetricValues(metric.Values); err != nil {
+			results[idx] = MetricBatchItemResult{Index: idx, Success: false, Error: fmt.Sprintf("validation error: %v", err)}
+			continue
+		}
+		valid = append(valid, metric)
+		validIndexes = append(validIndexes, idx)
+	}
+
+	if len(valid) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no valid metrics in batch", "results": results})
+		return
+	}
+
+	err := rs.Iot.Metric.UpsertMetrics(deviceID, valid)
+	for _, idx := range validIndexes {
+		if err != nil {
+			results[idx] = MetricBatchItemResult{Index: idx, Success: false, Error: err.Error()}
+		} else {
+			results[idx] = MetricBatchItemResult{Index: idx, Success: true}
+		}
+	}
+
+	if err != nil {
+		c.JSON(metricErrorStatus(c, err), gin.H{"error": err.Error(), "results": results})
+		return
+	}
+
+	c.JSON(rs.metricWrittenStatus(), gin.H{"results": results})
+}
+
+type MetricQueryRequest struct {
+	From   *time.Time `query:"from"`
+	To     *time.Time `query:"to"`
+	Limit  int        `query:"limit"`
+	Cursor string     `query:"cursor"`
+	Order  string     `query:"order"`
+}
+
+var metricQueryRequestSchema = z.Struct(z.Shape{
+	"From":   z.Ptr(z.Time()),
+	"To":     z.Ptr(z.Time()),
+	"Limit":  z.Int().GTE(0).Optional(),
+	"Cursor": z.String().Optional(),
+	"Order":  z.String().OneOf([]string{string(models.SortOrderAsc), string(models.SortOrderDesc)}).Optional(),
+})
+
+func (rs *RestfulServer) GetMetrics(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	var req MetricQueryRequest
+	if err := metricQueryRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	page, err := rs.Iot.Metric.GetDeviceMetrics(deviceID, &models.MetricQuery{
+		From:   req.From,
+		To:     req.To,
+		Limit:  req.Limit,
+		Cursor: req.Cursor,
+		Order:  models.SortOrder(req.Order),
+	})
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metrics": page.Metrics, "next_cursor": page.NextCursor})
+}
+
+type MetricAggregateRequest struct {
+	From     *time.Time `query:"from"`
+	To       *time.Time `query:"to"`
+	Interval string     `query:"interval"`
+}
+
+var metricAggregateRequestSchema = z.Struct(z.Shape{
+	"From":     z.Ptr(z.Time()),
+	"To":       z.Ptr(z.Time()),
+	"Interval": z.String().Required(),
+})
+
+func (rs *RestfulServer) GetMetricsAggregate(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	var req MetricAggregateRequest
+	if err := metricAggregateRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	// interval uses go duration syntax, e.g. 15m, 1h or 24h
+	interval, err := time.ParseDuration(req.Interval)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	buckets, err := rs.Iot.Metric.AggregateDeviceMetrics(deviceID, &models.MetricAggregateQuery{
+		From:     req.From,
+		To:       req.To,
+		Interval: interval,
+	})
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidInterval) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"buckets": buckets})
 }
 
 type ConfigRequest struct {
-	TemperatureThreshold float64 `json:"temperature_threshold"`
-	BatteryThreshold     float64 `json:"battery_threshold"`
+	TemperatureThreshold  float64 `json:"temperature_threshold"`
+	BatteryThreshold      float64 `json:"battery_threshold"`
+	TemperatureHysteresis float64 `json:"temperature_hysteresis"`
+	BatteryHysteresis     float64 `json:"battery_hysteresis"`
+	// HeartbeatInterval uses go duration syntax, e.g. 5m, empty disables the
+	// offline check
+	HeartbeatInterval string `json:"heartbeat_interval"`
 }
 
 var configRequestSchema = z.Struct(z.Shape{
-	"TemperatureThreshold": z.Float64().Required(),
-	"BatteryThreshold":     z.Float64().Required(),
+	"TemperatureThreshold":  z.Float64().Required(),
+	"BatteryThreshold":      z.Float64().Required(),
+	"TemperatureHysteresis": z.Float64().GTE(0).Optional(),
+	"BatteryHysteresis":     z.Float64().GTE(0).Optional(),
+	"HeartbeatInterval":     z.String().Optional(),
 })
 
 func (rs *RestfulServer) UpdateConfig(c *gin.Context) {
@@ -75,20 +323,247 @@ func (rs *RestfulServer) UpdateConfig(c *gin.Context) {
 		return
 	}
 
+	// an update sets every field, the ones left out of the request to zero
+	var heartbeat time.Duration
+	if req.HeartbeatInterval != "" {
+		var err error
+		if heartbeat, err = time.ParseDuration(req.HeartbeatInterval); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	config := models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: req.TemperatureThreshold,
-		BatteryThreshold:     req.BatteryThreshold,
+		TemperatureThreshold: &req.TemperatureThreshold,
+		BatteryThreshold:     &req.BatteryThreshold,
+
+		TemperatureHysteresis: &req.TemperatureHysteresis,
+		BatteryHysteresis:     &req.BatteryHysteresis,
+		HeartbeatInterval:     &heartbeat,
 	}
 
-	if err := rs.Iot.Config.UpsertConfig(deviceID, &config); err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+	expectedVersion, err := ifMatchVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a conditional update replaces every field through a full patch
+	if expectedVersion != 0 {
+		stored, err := rs.Iot.Config.PatchConfig(deviceID, &models.ConfigPatch{
+			TemperatureThreshold:  config.TemperatureThreshold,
+			BatteryThreshold:      config.BatteryThreshold,
+			TemperatureHysteresis: config.TemperatureHysteresis,
+			BatteryHysteresis:     config.BatteryHysteresis,
+			HeartbeatInterval:     config.HeartbeatInterval,
+		}, expectedVersion, changeOrigin(c))
+		if err != nil {
+			configError(c, err)
+			return
+		}
+		c.Header("ETag", configETag(stored.Version))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	if err := rs.Iot.Config.UpsertConfig(deviceID, &config, changeOrigin(c)); err != nil {
+		configError(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
+func (rs *RestfulServer) PatchConfig(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	var req ConfigPatchRequest
+	if err := configPatchRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	patch, err := req.toConfigPatch()
+	if err != nil {
+		configError(c, err)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config, err := rs.Iot.Config.PatchConfig(deviceID, &patch, expectedVersion, changeOrigin(c))
+	if err != nil {
+		configError(c, err)
+		return
+	}
+
+	c.Header("ETag", configETag(config.Version))
+	c.JSON(http.StatusOK, config)
+}
+
+type ConfigHistoryQueryRequest struct {
+	From   *time.Time `query:"from"`
+	To     *time.Time `query:"to"`
+	Limit  int        `query:"limit"`
+	Cursor string     `query:"cursor"`
+}
+
+var configHistoryQueryRequestSchema = z.Struct(z.Shape{
+	"From":   z.Ptr(z.Time()),
+	"To":     z.Ptr(z.Time()),
+	"Limit":  z.Int().GTE(0).Optional(),
+	"Cursor": z.String().Optional(),
+})
+
+func (rs *RestfulServer) GetConfigHistory(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	var req ConfigHistoryQueryRequest
+	if err := configHistoryQueryRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	page, err := rs.Iot.Config.GetConfigHistory(deviceID, &models.ConfigChangeQuery{
+		From:   req.From,
+		To:     req.To,
+		Limit:  req.Limit,
+		Cursor: req.Cursor,
+	})
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": page.Changes, "next_cursor": page.NextCursor})
+}
+
+// changeOrigin identifies the client changing a config by the optional
+// X-Actor header
+func changeOrigin(c *gin.Context) models.ChangeOrigin {
+	return models.ChangeOrigin{Actor: c.GetHeader("X-Actor"), Source: models.ChangeSourceHTTP}
+}
+
+// configETag is the entity tag of a config at version
+func configETag(version uint64) string {
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// ifMatchVersion returns the config version required by the If-Match header,
+// 0 when there is no header or it matches any version
+func ifMatchVersion(c *gin.Context) (uint64, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, fmt.Errorf("If-Match must be a single strong entity tag, got %s", header)
+	}
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("If-Match must be a config version, got %s", header)
+	}
+	return version, nil
+}
+
+// configError answers the error of a config operation, an unknown config is
+// 404 and a version not matching If-Match 412
+func configError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, iot.ErrConfigConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, iot.ErrInvalidConfig):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, iot.ErrConfigNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, err)
+	}
+}
+
+func (rs *RestfulServer) GetConfig(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	config, err := rs.Iot.Config.GetDeviceConfig(deviceID)
+	if err != nil {
+		configError(c, err)
+		return
+	}
+
+	c.Header("ETag", configETag(config.Version))
+	c.JSON(http.StatusOK, config)
+}
+
+func (rs *RestfulServer) DeleteConfig(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	if err := rs.Iot.Config.DeleteConfig(deviceID, changeOrigin(c)); err != nil {
+		configError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type AlertQueryRequest struct {
+	Status string     `query:"status"`
+	State  string     `query:"state"`
+	From   *time.Time `query:"from"`
+	To     *time.Time `query:"to"`
+	Limit  int        `query:"limit"`
+	Cursor string     `query:"cursor"`
+}
+
+var alertStatuses = []string{
+	string(models.AlertStatusOpen),
+	string(models.AlertStatusAcknowledged),
+	string(models.AlertStatusResolved),
+}
+
+var alertStates = []string{
+	string(models.AlertStateFiring),
+	string(models.AlertStateResolved),
+}
+
+var alertQueryRequestSchema = z.Struct(z.Shape{
+	"Status": z.String().OneOf(alertStatuses).Optional(),
+	"State":  z.String().OneOf(alertStates).Optional(),
+	"From":   z.Ptr(z.Time()),
+	"To":     z.Ptr(z.Time()),
+	"Limit":  z.Int().GTE(0).Optional(),
+	"Cursor": z.String().Optional(),
+})
+
 func (rs *RestfulServer) GetAlerts(c *gin.Context) {
 	deviceID := c.Param("device_id")
 
@@ -97,38 +572,783 @@ func (rs *RestfulServer) GetAlerts(c *gin.Context) {
 		return
 	}
 
-	var alerts []models.Alert
-	var err error
-	if alerts, err = rs.Iot.Alert.GetDeviceAlerts(deviceID); err != nil {
+	var req AlertQueryRequest
+	if err := alertQueryRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	page, err := rs.Iot.Alert.GetDeviceAlerts(deviceID, req.toQuery(c))
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, alerts)
+	c.JSON(http.StatusOK, gin.H{"alerts": page.Alerts, "next_cursor": page.NextCursor})
 }
 
-type LimiterRequest struct {
-	Rate  float64 `json:"rate"`
-	Burst int     `json:"burst"`
+// toQuery also takes the alert types, which repeat as "type" in the query string
+func (req *AlertQueryRequest) toQuery(c *gin.Context) *models.AlertQuery {
+	return &models.AlertQuery{
+		Status: models.AlertStatus(req.Status),
+		State:  models.AlertState(req.State),
+		Types:  common.Mapper(c.QueryArray("type"), func(t string) models.AlertType { return models.AlertType(t) }),
+		From:   req.From,
+		To:     req.To,
+		Limit:  req.Limit,
+		Cursor: req.Cursor,
+	}
 }
 
-var limiterRequestSchema = z.Struct(z.Shape{
-	"rate":  z.Float64().Required(),
-	"burst": z.Int().Required(),
+type FleetAlertQueryRequest struct {
+	AlertQueryRequest
+	DevicePrefix string `query:"device_prefix"`
+	Summary      bool   `query:"summary"`
+}
+
+var fleetAlertQueryRequestSchema = alertQueryRequestSchema.Extend(z.Shape{
+	"DevicePrefix": z.String().Optional(),
+	"Summary":      z.Bool().Optional(),
 })
 
-func (rs *RestfulServer) PostLimiter(c *gin.Context) {
+// ListAlerts queries alerts across all devices. It is not rate limited, as the
+// limiters are per device.
+func (rs *RestfulServer) ListAlerts(c *gin.Context) {
+	var req FleetAlertQueryRequest
+	if err := fleetAlertQueryRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	query := &models.FleetAlertQuery{
+		AlertQuery:   *req.toQuery(c),
+		DevicePrefix: req.DevicePrefix,
+	}
+
+	if req.Summary {
+		summaries, err := rs.Iot.Alert.SummarizeAlerts(query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"summary": summaries})
+		return
+	}
+
+	page, err := rs.Iot.Alert.ListAlerts(query)
+	if err != nil {
+		if errors.Is(err, iot.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": page.Alerts, "next_cursor": page.NextCursor})
+}
+
+type AlertActionRequest struct {
+	By   string `json:"by"`
+	Note string `json:"note"`
+}
+
+var alertActionRequestSchema = z.Struct(z.Shape{
+	"By":   z.String().Min(1).Required(),
+	"Note": z.String().Optional(),
+})
+
+// alertActionError writes the response of a failed acknowledge or resolve
+func alertActionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, iot.ErrAlertNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, iot.ErrAlertStatusConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, err)
+	}
+}
+
+func (rs *RestfulServer) alertAction(c *gin.Context, action func(deviceID string, id uint, by string, note string) (*models.Alert, error)) {
 	deviceID := c.Param("device_id")
 
-	var req LimiterRequest
-	if err := limiterRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	id, ok := pathID(c, "alert")
+	if !ok {
+		return
+	}
+
+	var req AlertActionRequest
+	if err := alertActionRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err})
 		return
 	}
 
-	rs.SetLimiter(deviceID, req.Rate, req.Burst)
+	alert, err := action(deviceID, id, req.By, req.Note)
+	if err != nil {
+		alertActionError(c, err)
+		return
+	}
 
-	c.Status(http.StatusOK)
+	c.JSON(http.StatusOK, alert)
+}
+
+func (rs *RestfulServer) AcknowledgeAlert(c *gin.Context) {
+	rs.alertAction(c, rs.Iot.Alert.AcknowledgeAlert)
+}
+
+func (rs *RestfulServer) ResolveAlert(c *gin.Context) {
+	rs.alertAction(c, rs.Iot.Alert.ResolveAlert)
+}
+
+// sseKeepAliveInterval is how often an idle alert stream sends a ping event,
+// so proxies do not close the connection
+var sseKeepAliveInterval = 15 * time.Second
+
+func (rs *RestfulServer) StreamAlerts(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	if rs.Iot.Broker == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "alert broker is not used"})
+		return
+	}
+
+	sub := rs.Iot.Broker.Subscribe(iot.AlertFilter{
+		DeviceID: deviceID,
+		Types:    common.Mapper(c.QueryArray("type"), func(t string) models.AlertType { return models.AlertType(t) }),
+	})
+	defer sub.Close()
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+
+	// send headers right away, so clients know the stream is open before any
+	// alert arrives
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-ticker.C:
+			c.SSEvent("ping", time.Now().UTC())
+			return true
+		case alert, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.SSEvent("alert", alert)
+			return true
+		}
+	})
+}
+
+type RuleRequest struct {
+	Metric         string  `json:"metric"`
+	Operator       string  `json:"operator"`
+	Threshold      float64 `json:"threshold"`
+	UpperThreshold float64 `json:"upper_threshold"`
+	Severity       string  `json:"severity"`
+	Hysteresis     float64 `json:"hysteresis"`
+	// HoldFor uses go duration syntax, e.g. 5m
+	HoldFor     string `json:"hold_for"`
+	HoldSamples int    `json:"hold_samples"`
+}
+
+var ruleRequestSchema = z.Struct(z.Shape{
+	"Metric":         z.String().Required(),
+	"Operator":       z.String().Required(),
+	"Threshold":      z.Float64().Required(),
+	"UpperThreshold": z.Float64().Optional(),
+	"Severity":       z.String().Optional(),
+	"Hysteresis":     z.Float64().Optional(),
+	"HoldFor":        z.String().Optional(),
+	"HoldSamples":    z.Int().Optional(),
+})
+
+func (req *RuleRequest) toRule() (models.AlertRule, error) {
+	rule := models.AlertRule{
+		Metric:         req.Metric,
+		Operator:       models.RuleOperator(req.Operator),
+		Threshold:      req.Threshold,
+		UpperThreshold: req.UpperThreshold,
+		Severity:       models.AlertSeverity(req.Severity),
+		Hysteresis:     req.Hysteresis,
+		HoldSamples:    req.HoldSamples,
+	}
+
+	if req.HoldFor != "" {
+		holdFor, err := time.ParseDuration(req.HoldFor)
+		if err != nil {
+			return rule, fmt.Errorf("%w: %v", iot.ErrInvalidRule, err)
+		}
+		rule.HoldFor = holdFor
+	}
+
+	return rule, nil
+}
+
+// ruleError writes the response of a failed rule operation
+func ruleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, iot.ErrInvalidRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, iot.ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, err)
+	}
+}
+
+// pathID parses the :id path param, it writes 400 and returns false when the
+// param is not a valid id of what
+func pathID(c *gin.Context, what string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + what + " id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (rs *RestfulServer) GetRules(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	rules, err := rs.Iot.Rule.GetDeviceRules(deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func (rs *RestfulServer) GetRule(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	id, ok := pathID(c, "rule")
+	if !ok {
+		return
+	}
+
+	rule, err := rs.Iot.Rule.GetDeviceRule(deviceID, id)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (rs *RestfulServer) CreateRule(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	var req RuleRequest
+	if err := ruleRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	input, err := req.toRule()
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	rule, err := rs.Iot.Rule.CreateRule(deviceID, &input)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (rs *RestfulServer) UpdateRule(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	id, ok := pathID(c, "rule")
+	if !ok {
+		return
+	}
+
+	var req RuleRequest
+	if err := ruleRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	input, err := req.toRule()
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	rule, err := rs.Iot.Rule.UpdateRule(deviceID, id, &input)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (rs *RestfulServer) DeleteRule(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	id, ok := pathID(c, "rule")
+	if !ok {
+		return
+	}
+
+	if err := rs.Iot.Rule.DeleteRule(deviceID, id); err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type ConfigPatchRequest struct {
+	TemperatureThreshold  *float64 `json:"temperature_threshold"`
+	BatteryThreshold      *float64 `json:"battery_threshold"`
+	TemperatureHysteresis *float64 `json:"temperature_hysteresis"`
+	BatteryHysteresis     *float64 `json:"battery_hysteresis"`
+	// HeartbeatInterval uses go duration syntax, e.g. 5m
+	HeartbeatInterval *string `json:"heartbeat_interval"`
+}
+
+var configPatchRequestSchema = z.Struct(z.Shape{
+	"TemperatureThreshold":  z.Ptr(z.Float64()),
+	"BatteryThreshold":      z.Ptr(z.Float64()),
+	"TemperatureHysteresis": z.Ptr(z.Float64()),
+	"BatteryHysteresis":     z.Ptr(z.Float64()),
+	"HeartbeatInterval":     z.Ptr(z.String()),
+})
+
+func (req *ConfigPatchRequest) toConfigPatch() (models.ConfigPatch, error) {
+	patch := models.ConfigPatch{
+		TemperatureThreshold:  req.TemperatureThreshold,
+		BatteryThreshold:      req.BatteryThreshold,
+		TemperatureHysteresis: req.TemperatureHysteresis,
+		BatteryHysteresis:     req.BatteryHysteresis,
+	}
+	if req.HeartbeatInterval != nil {
+		heartbeat, err := time.ParseDuration(*req.HeartbeatInterval)
+		if err != nil {
+			return patch, fmt.Errorf("%w: %v", iot.ErrInvalidConfig, err)
+		}
+		patch.HeartbeatInterval = &heartbeat
+	}
+	return patch, nil
+}
+
+type GroupConfigRequest struct {
+	ConfigPatchRequest
+	Priority int `json:"priority"`
+	// MetricRetention and AlertRetention use go duration syntax, e.g. 720h,
+	// 0 keeps the rows of the devices forever
+	MetricRetention *string `json:"metric_retention"`
+	AlertRetention  *string `json:"alert_retention"`
+}
+
+var groupConfigRequestSchema = configPatchRequestSchema.Extend(z.Shape{
+	"Priority":        z.Int().Optional(),
+	"MetricRetention": z.Ptr(z.String()),
+	"AlertRetention":  z.Ptr(z.String()),
+})
+
+func (req *GroupConfigRequest) toGroupConfig() (*models.GroupConfig, error) {
+	patch, err := req.toConfigPatch()
+	if err != nil {
+		return nil, err
+	}
+	group := &models.GroupConfig{Priority: req.Priority, ConfigPatch: patch}
+	for _, field := range []struct {
+		value  *string
+		target **time.Duration
+	}{
+		{req.MetricRetention, &group.MetricRetention},
+		{req.AlertRetention, &group.AlertRetention},
+	} {
+		if field.value == nil {
+			continue
+		}
+		retention, err := time.ParseDuration(*field.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", iot.ErrInvalidConfig, err)
+		}
+		*field.target = &retention
+	}
+	return group, nil
+}
+
+// groupError writes the response of a failed group or resolved config
+// operation
+func groupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, iot.ErrInvalidConfig):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, iot.ErrGroupNotFound), errors.Is(err, iot.ErrConfigNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, err)
+	}
+}
+
+// the group handlers are not rate limited, as the limiters are per device
+
+func (rs *RestfulServer) ListGroupConfigs(c *gin.Context) {
+	groups, err := rs.Iot.Group.ListGroupConfigs()
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+func (rs *RestfulServer) GetGroupConfig(c *gin.Context) {
+	group, err := rs.Iot.Group.GetGroupConfig(c.Param("tag"))
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+func (rs *RestfulServer) PutGroupConfig(c *gin.Context) {
+	var req GroupConfigRequest
+	if err := groupConfigRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	input, err := req.toGroupConfig()
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+
+	group, err := rs.Iot.Group.UpsertGroupConfig(c.Param("tag"), input)
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+func (rs *RestfulServer) DeleteGroupConfig(c *gin.Context) {
+	if err := rs.Iot.Group.DeleteGroupConfig(c.Param("tag")); err != nil {
+		groupError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UpdateGroupDeviceConfigs writes the given fields into the configs of all
+// devices of the group
+func (rs *RestfulServer) UpdateGroupDeviceConfigs(c *gin.Context) {
+	var req ConfigPatchRequest
+	if err := configPatchRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	patch, err := req.toConfigPatch()
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+
+	count, err := rs.Iot.Group.UpdateConfigsByTag(c.Param("tag"), &patch, changeOrigin(c))
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": count})
+}
+
+func (rs *RestfulServer) GetResolvedConfig(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	resolved, err := rs.Iot.Config.ResolveConfig(deviceID)
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resolved)
+}
+
+type DeviceRequest struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Model    string   `json:"model"`
+	Firmware string   `json:"firmware"`
+	Location string   `json:"location"`
+	Tags     []string `json:"tags"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+var deviceRequestSchema = z.Struct(z.Shape{
+	"Name":     z.String().Optional(),
+	"Model":    z.String().Optional(),
+	"Firmware": z.String().Optional(),
+	"Location": z.String().Optional(),
+	"Tags":     z.Slice(z.String()).Optional(),
+	"Enabled":  z.Ptr(z.Bool()),
+})
+
+// the device id is in the body when creating, and in the path otherwise
+var createDeviceRequestSchema = deviceRequestSchema.Extend(z.Shape{
+	"ID": z.String().Min(1).Required(),
+})
+
+func (req *DeviceRequest) toDevice() models.Device {
+	device := models.Device{
+		ID:       req.ID,
+		Name:     req.Name,
+		Model:    req.Model,
+		Firmware: req.Firmware,
+		Location: req.Location,
+		Tags:     req.Tags,
+		Enabled:  true,
+	}
+	if req.Enabled != nil {
+		device.Enabled = *req.Enabled
+	}
+	return device
+}
+
+// deviceError writes the response of a failed device operation
+func deviceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, iot.ErrInvalidDevice), errors.Is(err, iot.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, iot.ErrDeviceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, iot.ErrDeviceExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, err)
+	}
+}
+
+type DeviceQueryRequest struct {
+	Prefix  string `query:"prefix"`
+	Tag     string `query:"tag"`
+	Enabled *bool  `query:"enabled"`
+	Limit   int    `query:"limit"`
+	Cursor  string `query:"cursor"`
+}
+
+var deviceQueryRequestSchema = z.Struct(z.Shape{
+	"Prefix":  z.String().Optional(),
+	"Tag":     z.String().Optional(),
+	"Enabled": z.Ptr(z.Bool()),
+	"Limit":   z.Int().GTE(0).Optional(),
+	"Cursor":  z.String().Optional(),
+})
+
+// ListDevices and CreateDevice are not rate limited, as the limiters are per
+// device
+func (rs *RestfulServer) ListDevices(c *gin.Context) {
+	var req DeviceQueryRequest
+	if err := deviceQueryRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	page, err := rs.Iot.Device.ListDevices(&models.DeviceQuery{
+		Prefix:  req.Prefix,
+		Tag:     req.Tag,
+		Enabled: req.Enabled,
+		Limit:   req.Limit,
+		Cursor:  req.Cursor,
+	})
+	if err != nil {
+		deviceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"devices": page.Devices, "next_cursor": page.NextCursor})
+}
+
+func (rs *RestfulServer) CreateDevice(c *gin.Context) {
+	var req DeviceRequest
+	if err := createDeviceRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	input := req.toDevice()
+	device, err := rs.Iot.Device.CreateDevice(&input, changeOrigin(c))
+	if err != nil {
+		deviceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, device)
+}
+
+func (rs *RestfulServer) GetDevice(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	device, err := rs.Iot.Device.GetDevice(deviceID)
+	if err != nil {
+		deviceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func (rs *RestfulServer) UpdateDevice(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	var req DeviceRequest
+	if err := deviceRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	input := req.toDevice()
+	device, err := rs.Iot.Device.UpdateDevice(deviceID, &input, changeOrigin(c))
+	if err != nil {
+		deviceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func (rs *RestfulServer) DeleteDevice(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	if !rs.CheckDeviceLimiter(deviceID) {
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+
+	if err := rs.Iot.Device.DeleteDevice(deviceID); err != nil {
+		deviceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type LimiterRequest struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+var limiterRequestSchema = z.Struct(z.Shape{
+	"rate":  z.Float64().Required(),
+	"burst": z.Int().Required(),
+})
+
+func (rs *RestfulServer) PostLimiter(c *gin.Context) {
+	deviceID := c.Param("device_id")
+
+	var req LimiterRequest
+	if err := limiterRequestSchema.Parse(zhttp.Request(c.Request), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+
+	rs.SetLimiter(deviceID, req.Rate, req.Burst)
+
+	c.Status(http.StatusOK)
+}
+
+// GetRetention returns the retention policy and how many rows were pruned
+// so far per table
+func (rs *RestfulServer) GetRetention(c *gin.Context) {
+	if rs.Iot.Retention == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "retention is not used"})
+		return
+	}
+
+	opts := rs.Iot.Retention.Options()
+	stats := rs.Iot.Retention.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"policy": gin.H{
+			"interval":       opts.Interval.String(),
+			"metric_max_age": opts.MetricMaxAge.String(),
+			"alert_max_age":  opts.AlertMaxAge.String(),
+			"batch_size":     opts.BatchSize,
+		},
+		"runs":        stats.Runs,
+		"last_run_at": stats.LastRunAt,
+		"last_error":  stats.LastError,
+		"pruned":      stats.Pruned,
+		"last_pruned": stats.LastPruned,
+	})
 }
 
 func (rs *RestfulServer) HealthCheck(c *gin.Context) {
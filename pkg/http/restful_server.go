@@ -37,12 +37,46 @@ func (rs *RestfulServer) SetLimiter(deviceID string, deviceRate float64, deviceB
 
 func (rs *RestfulServer) Setup() {
 	rs.Server.GET("/healthz", rs.HealthCheck)
+	rs.Server.GET("/alerts", rs.ListAlerts)
+	rs.Server.GET("/devices", rs.ListDevices)
+	rs.Server.POST("/devices", rs.CreateDevice)
+	rs.Server.GET("/groups", rs.ListGroupConfigs)
+	rs.Server.GET("/retention", rs.GetRetention)
+
+	groups := rs.Server.Group("/groups/:tag")
+	{
+		groups.GET("/config", rs.GetGroupConfig)
+		groups.PUT("/config", rs.PutGroupConfig)
+		groups.DELETE("/config", rs.DeleteGroupConfig)
+		groups.POST("/devices/config", rs.UpdateGroupDeviceConfigs)
+	}
 
 	devices := rs.Server.Group("/devices/:device_id")
 	{
+		devices.GET("", rs.GetDevice)
+		devices.PUT("", rs.UpdateDevice)
+		devices.DELETE("", rs.DeleteDevice)
 		devices.POST("/metrics", rs.PostMetrics)
+		// gin can not route a literal ':', so "/metrics:batch" and friends are
+		// matched as a param right after "metrics", e.g. verb=":batch"
+		devices.POST("/metrics:verb", rs.PostMetricsVerb)
+		devices.GET("/metrics", rs.GetMetrics)
+		devices.GET("/metrics/aggregate", rs.GetMetricsAggregate)
+		devices.GET("/config", rs.GetConfig)
 		devices.POST("/config", rs.UpdateConfig)
+		devices.PATCH("/config", rs.PatchConfig)
+		devices.DELETE("/config", rs.DeleteConfig)
+		devices.GET("/config/resolved", rs.GetResolvedConfig)
+		devices.GET("/config/history", rs.GetConfigHistory)
 		devices.GET("/alerts", rs.GetAlerts)
+		devices.GET("/alerts/stream", rs.StreamAlerts)
+		devices.POST("/alerts/:id/ack", rs.AcknowledgeAlert)
+		devices.POST("/alerts/:id/resolve", rs.ResolveAlert)
+		devices.GET("/rules", rs.GetRules)
+		devices.POST("/rules", rs.CreateRule)
+		devices.GET("/rules/:id", rs.GetRule)
+		devices.PUT("/rules/:id", rs.UpdateRule)
+		devices.DELETE("/rules/:id", rs.DeleteRule)
 		devices.POST("/limiter", rs.PostLimiter)
 	}
 }
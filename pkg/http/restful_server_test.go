@@ -1,11 +1,15 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,15 +28,24 @@ import (
 	"liyu1981.xyz/iot-metrics-service/pkg/models"
 )
 
+func ptr[T any](v T) *T {
+	return &v
+}
+
 func setupTestServer() *RestfulServer {
 	iotObj := iot.IOT{
 		Db: *db.GetInstance(db.UseMemorySqliteDialector()),
 	}
-	iotObj.WithServices(iot.ServiceOpts{
+	if _, err := iotObj.WithServices(iot.ServiceOpts{
 		Metric: iotObj.GetIMetric(),
 		Alert:  iotObj.GetIAlert(),
 		Config: iotObj.GetIConfig(),
-	})
+		Rule:   iotObj.GetIRule(),
+		Device: iotObj.GetIDevice(),
+		Group:  iotObj.GetIGroup(),
+	}); err != nil {
+		panic(err)
+	}
 
 	rs := &RestfulServer{
 		Server: gin.Default(),
@@ -54,218 +67,1576 @@ type RequestBodyData struct {
 func TestHealthCheck(t *testing.T) {
 	rs := setupTestServer()
 
-	req := httptest.NewRequest("GET", "/healthz", nil)
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+
+	rs.Server.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+type alertsResponse struct {
+	Alerts     []models.Alert `json:"alerts"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+func TestPostMetricsAndGetAlerts(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	// Insert config first (required to trigger alerts)
+	config := &models.Config{
+		DeviceID:             deviceID,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
+	}
+	err := rs.Iot.Db.Conn.Create(config).Error
+	assert.NoError(t, err)
+
+	// Send a metric that triggers both alerts
+	metricReq := MetricRequest{
+		Timestamp:   time.Now(),
+		Temperature: 45.5,
+		Battery:     20.0,
+	}
+	body, _ := json.Marshal(metricReq)
+
+	req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	alertReq := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts", nil)
+	alertW := httptest.NewRecorder()
+	rs.Server.ServeHTTP(alertW, alertReq)
+
+	assert.Equal(t, http.StatusOK, alertW.Code)
+
+	var page alertsResponse
+	err = json.Unmarshal(alertW.Body.Bytes(), &page)
+	alerts := page.Alerts
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 2)
+
+	alertTypes := map[string]bool{}
+	for _, alert := range alerts {
+		alertTypes[string(alert.Type)] = true
+	}
+	assert.True(t, alertTypes[string(models.AlertTypeTemperature)])
+	assert.True(t, alertTypes[string(models.AlertTypeBattery)])
+}
+
+func TestGetAlerts_Query(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	err := rs.Iot.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// raises a temperature and a battery alert
+	err = rs.Iot.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: base, Temperature: 40.0, Battery: 10.0})
+	require.NoError(t, err)
+
+	getAlerts := func(query string) alertsResponse {
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts?"+query, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var page alertsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+
+	first := getAlerts("limit=1")
+	require.Len(t, first.Alerts, 1)
+	require.NotEmpty(t, first.NextCursor)
+
+	second := getAlerts("limit=1&cursor=" + first.NextCursor)
+	require.Len(t, second.Alerts, 1)
+	assert.NotEqual(t, first.Alerts[0].Type, second.Alerts[0].Type)
+	assert.Empty(t, second.NextCursor)
+
+	page := getAlerts("type=battery")
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertTypeBattery, page.Alerts[0].Type)
+
+	assert.Len(t, getAlerts("type=battery&type=temperature").Alerts, 2)
+	// alerts are stamped when raised, not with the metric timestamp
+	now := time.Now()
+	assert.Len(t, getAlerts("from="+url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339))).Alerts, 2)
+	assert.Len(t, getAlerts("from="+url.QueryEscape(now.Add(time.Hour).Format(time.RFC3339))).Alerts, 0)
+	assert.Len(t, getAlerts("to="+url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339))).Alerts, 0)
+
+	for _, query := range []string{"limit=-1", "cursor=bogus", "from=yesterday"} {
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts?"+query, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestListAlerts(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	prefix := "fleet_" + uuid.NewString() + "/"
+	deviceA, deviceB := prefix+"a", prefix+"b"
+	for _, deviceID := range []string{deviceA, deviceB} {
+		err := rs.Iot.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+		require.NoError(t, err)
+	}
+
+	err := rs.Iot.Metric.UpsertMetric(deviceA, &models.Metric{Timestamp: time.Now(), Temperature: 40.0, Battery: 10.0})
+	require.NoError(t, err)
+	err = rs.Iot.Metric.UpsertMetric(deviceB, &models.Metric{Timestamp: time.Now(), Temperature: 40.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/alerts?device_prefix="+url.QueryEscape(prefix)+"&"+query, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	{
+		w := get("")
+		require.Equal(t, http.StatusOK, w.Code)
+		var page alertsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Len(t, page.Alerts, 3)
+	}
+
+	{
+		w := get("type=battery&state=firing")
+		require.Equal(t, http.StatusOK, w.Code)
+		var page alertsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Alerts, 1)
+		assert.Equal(t, deviceA, page.Alerts[0].DeviceID)
+	}
+
+	{
+		w := get("limit=2")
+		require.Equal(t, http.StatusOK, w.Code)
+		var page alertsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Len(t, page.Alerts, 2)
+		assert.NotEmpty(t, page.NextCursor)
+	}
+
+	{
+		w := get("summary=true")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Summary []models.AlertSummary `json:"summary"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Summary, 2)
+		assert.Equal(t, deviceA, resp.Summary[0].DeviceID)
+		assert.Equal(t, 2, resp.Summary[0].Count)
+		assert.Equal(t, deviceB, resp.Summary[1].DeviceID)
+		assert.Equal(t, 1, resp.Summary[1].Count)
+	}
+
+	for _, query := range []string{"limit=-1", "cursor=bogus", "status=closed", "state=pending", "summary=maybe"} {
+		assert.Equal(t, http.StatusBadRequest, get(query).Code, query)
+	}
+}
+
+func TestListAlerts_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockIAlert := mocks.NewMockIAlert(ctrl)
+	rs.Iot.Alert = mockIAlert
+
+	mockIAlert.EXPECT().ListAlerts(gomock.Any()).Return(nil, fmt.Errorf("just causing error")).Times(1)
+	mockIAlert.EXPECT().SummarizeAlerts(gomock.Any()).Return(nil, fmt.Errorf("just causing error")).Times(1)
+
+	for _, path := range []string{"/alerts", "/alerts?summary=true"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code, path)
+	}
+}
+
+func TestPostMetricsAndGetAlerts_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		// empty payload should be rejected
+		payload := []byte("{}")
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	{
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		// Send a metric without valid deviceID should cause internal error
+		metricReq := MetricRequest{
+			Timestamp:   time.Now(),
+			Temperature: 45.5,
+			Battery:     20.0,
+		}
+		body, _ := json.Marshal(metricReq)
+
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+
+	{
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		// This will not work too
+		payload := "{\"timestamp\": \"-1\", \"temperature\": 45.5, \"battery\": 20.0}"
+
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	{
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockIAlert := mocks.NewMockIAlert(ctrl)
+		rs.Iot.Alert = mockIAlert
+		mockIAlert.EXPECT().
+			GetDeviceAlerts(gomock.Eq(deviceID), gomock.Any()).
+			Return(nil, fmt.Errorf("just causing error")).
+			Times(1)
+
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts", nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestPostMetricsWithValues(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	config := &models.Config{
+		DeviceID:             deviceID,
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
+	}
+	err := rs.Iot.Db.Conn.Create(config).Error
+	assert.NoError(t, err)
+
+	{
+		payload := `{"timestamp": "2025-01-01T00:00:00Z", "temperature": 20.0, "battery": 80.0,
+			"values": [{"name": "humidity", "value": 40.5, "unit": "%"}, {"name": "voltage", "value": 3.3, "unit": "V"}]}`
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	{
+		// temperature and battery have their own fields
+		payload := `{"timestamp": "2025-01-01T00:00:00Z", "temperature": 20.0, "battery": 80.0,
+			"values": [{"name": "temperature", "value": 40.5}]}`
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	{
+		// value entry without name
+		payload := `{"timestamp": "2025-01-01T00:00:00Z", "temperature": 20.0, "battery": 80.0,
+			"values": [{"value": 40.5}]}`
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics", nil)
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Metrics []models.Metric `json:"metrics"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	require.Len(t, resp.Metrics, 1)
+	voltage, ok := resp.Metrics[0].Value("voltage")
+	assert.True(t, ok)
+	assert.Equal(t, 3.3, voltage)
+}
+
+func TestPostMetricsBatch(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	config := &models.Config{
+		DeviceID:             deviceID,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
+	}
+	err := rs.Iot.Db.Conn.Create(config).Error
+	assert.NoError(t, err)
+
+	payload := `{"metrics": [
+		{"timestamp": "2025-01-01T00:00:00Z", "temperature": 20.0, "battery": 80.0},
+		{"timestamp": "-1", "temperature": 20.0, "battery": 80.0},
+		{"timestamp": "2025-01-01T00:01:00Z", "temperature": 45.0, "battery": 80.0},
+		{"timestamp": "2025-01-01T00:02:00Z", "temperature": 20.0, "battery": 80.0, "values": [{"name": "battery", "value": 1}]}
+	]}`
+
+	req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics:batch", bytes.NewReader([]byte(payload)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Results []MetricBatchItemResult `json:"results"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Results, 4)
+	assert.True(t, resp.Results[0].Success)
+	assert.False(t, resp.Results[1].Success)
+	assert.Contains(t, resp.Results[1].Error, "validation error")
+	assert.True(t, resp.Results[2].Success)
+	assert.False(t, resp.Results[3].Success)
+	assert.Contains(t, resp.Results[3].Error, "validation error")
+
+	var count int64
+	err = rs.Iot.Db.Conn.Model(&models.Metric{}).Where("device_id = ?", deviceID).Count(&count).Error
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// the hot reading in the batch raised an alert
+	page, err := rs.Iot.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	alerts := page.Alerts
+	assert.Len(t, alerts, 1)
+}
+
+func TestPostMetricsBatch_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+	deviceID := uuid.NewString()
+
+	{
+		// unknown verb
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics:import", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	}
+
+	for _, payload := range []string{`{}`, `{"metrics": []}`, `not json`} {
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics:batch", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "payload %s should be rejected", payload)
+	}
+
+	{
+		// nothing in the batch is valid
+		payload := `{"metrics": [{"timestamp": "-1", "temperature": 20.0, "battery": 80.0}, {"temperature": 20.0}]}`
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics:batch", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var resp struct {
+			Results []MetricBatchItemResult `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Results, 2)
+		for _, result := range resp.Results {
+			assert.False(t, result.Success)
+			assert.Contains(t, result.Error, "validation error")
+		}
+	}
+
+	{
+		// without config the whole transaction fails
+		payload := `{"metrics": [{"timestamp": "2025-01-01T00:00:00Z", "temperature": 20.0, "battery": 80.0}]}`
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics:batch", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	config := &models.Config{
+		DeviceID:             deviceID,
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
+	}
+	err := rs.Iot.Db.Conn.Create(config).Error
+	assert.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		err = rs.Iot.Metric.UpsertMetric(deviceID, &models.Metric{
+			Timestamp:   base.Add(time.Duration(i) * time.Minute),
+			Temperature: float64(20 + i),
+			Battery:     50.0,
+		})
+		assert.NoError(t, err)
+	}
+
+	type metricsResponse struct {
+		Metrics    []models.Metric `json:"metrics"`
+		NextCursor string          `json:"next_cursor"`
+	}
+
+	var first metricsResponse
+	{
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics?limit=2&order=asc", nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		err = json.Unmarshal(w.Body.Bytes(), &first)
+		assert.NoError(t, err)
+		assert.Len(t, first.Metrics, 2)
+		assert.Equal(t, 20.0, first.Metrics[0].Temperature)
+		assert.NotEmpty(t, first.NextCursor)
+	}
+
+	{
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics?limit=2&order=asc&cursor="+first.NextCursor, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var second metricsResponse
+		err = json.Unmarshal(w.Body.Bytes(), &second)
+		assert.NoError(t, err)
+		assert.Len(t, second.Metrics, 1)
+		assert.Equal(t, 22.0, second.Metrics[0].Temperature)
+		assert.Empty(t, second.NextCursor)
+	}
+
+	{
+		from := base.Add(1 * time.Minute).Format(time.RFC3339)
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics?from="+from, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp metricsResponse
+		err = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Metrics, 2)
+		assert.Equal(t, 22.0, resp.Metrics[0].Temperature)
+	}
+}
+
+func TestGetMetrics_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+	deviceID := uuid.NewString()
+
+	for _, query := range []string{"order=sideways", "limit=-1", "from=yesterday", "cursor=bogus"} {
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics?"+query, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "query %s should be rejected", query)
+	}
+
+	{
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockIMetric := mocks.NewMockIMetric(ctrl)
+		rs.Iot.Metric = mockIMetric
+		mockIMetric.EXPECT().
+			GetDeviceMetrics(gomock.Eq(deviceID), gomock.Any()).
+			Return(nil, fmt.Errorf("just causing error")).
+			Times(1)
+
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics", nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestGetMetricsAggregate(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	config := &models.Config{
+		DeviceID:             deviceID,
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
+	}
+	err := rs.Iot.Db.Conn.Create(config).Error
+	assert.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		err = rs.Iot.Metric.UpsertMetric(deviceID, &models.Metric{
+			Timestamp:   base.Add(time.Duration(i) * 30 * time.Minute),
+			Temperature: float64(20 + i),
+			Battery:     50.0,
+		})
+		assert.NoError(t, err)
+	}
+
+	req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics/aggregate?interval=1h", nil)
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Buckets []models.MetricBucket `json:"buckets"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Buckets, 2)
+	assert.Equal(t, 2, resp.Buckets[0].Count)
+	assert.Equal(t, 20.5, resp.Buckets[0].Temperature.Avg)
+	assert.Equal(t, 23.0, resp.Buckets[1].Temperature.Last)
+}
+
+func TestGetMetricsAggregate_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+	deviceID := uuid.NewString()
+
+	for _, query := range []string{"", "interval=hourly", "interval=1s", "interval=1h&from=yesterday"} {
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics/aggregate?"+query, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "query %s should be rejected", query)
+	}
+
+	{
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockIMetric := mocks.NewMockIMetric(ctrl)
+		rs.Iot.Metric = mockIMetric
+		mockIMetric.EXPECT().
+			AggregateDeviceMetrics(gomock.Eq(deviceID), gomock.Any()).
+			Return(nil, fmt.Errorf("just causing error")).
+			Times(1)
+
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/metrics/aggregate?interval=1h", nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestStreamAlerts(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+	rs.Iot.Broker = iot.NewAlertBroker(8)
+
+	server := httptest.NewServer(rs.Server)
+	defer server.Close()
+
+	deviceID := uuid.NewString()
+
+	config := &models.Config{
+		DeviceID:             deviceID,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
+	}
+	err := rs.Iot.Db.Conn.Create(config).Error
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/devices/"+deviceID+"/alerts/stream?type=battery", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.Eventually(t, func() bool { return rs.Iot.Broker.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// triggers both alerts, only the battery one passes the filter
+	err = rs.Iot.Metric.UpsertMetric(deviceID, &models.Metric{
+		Timestamp:   time.Now(),
+		Temperature: 45.5,
+		Battery:     20.0,
+	})
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(resp.Body)
+	event, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event:alert\n", event)
+
+	data, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	var alert models.Alert
+	err = json.Unmarshal([]byte(strings.TrimPrefix(data, "data:")), &alert)
+	require.NoError(t, err)
+	assert.Equal(t, deviceID, alert.DeviceID)
+	assert.Equal(t, models.AlertTypeBattery, alert.Type)
+
+	cancel()
+	require.Eventually(t, func() bool { return rs.Iot.Broker.SubscriberCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestStreamAlerts_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+	deviceID := uuid.NewString()
+
+	// no broker, no stream
+	req := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts/stream", nil)
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestUpdateConfig(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	configReq := ConfigRequest{
+		TemperatureThreshold: 100.0,
+		BatteryThreshold:     20.0,
+	}
+	body, _ := json.Marshal(configReq)
+	req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Verify in DB
+	var config models.Config
+	err := rs.Iot.Db.Conn.
+		Where("device_id = ?", deviceID).
+		First(&config).Error
+	assert.NoError(t, err)
+	assert.Equal(t, ptr(100.0), config.TemperatureThreshold)
+	assert.Equal(t, ptr(time.Duration(0)), config.HeartbeatInterval)
+
+	payload := []byte(`{"temperature_threshold": 100.0, "battery_threshold": 20.0, "heartbeat_interval": "5m"}`)
+	req = httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	err = rs.Iot.Db.Conn.
+		Where("device_id = ?", deviceID).
+		First(&config).Error
+	assert.NoError(t, err)
+	assert.Equal(t, ptr(5*time.Minute), config.HeartbeatInterval)
+}
+
+func TestGetAndDeleteConfig(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	deviceID := uuid.NewString()
+	assert.Equal(t, http.StatusNotFound, do("GET", "/devices/"+deviceID+"/config", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/devices/"+deviceID+"/config", "").Code)
+
+	w := do("POST", "/devices/"+deviceID+"/config", `{"temperature_threshold": 100.0, "battery_threshold": 20.0, "heartbeat_interval": "5m"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", "/devices/"+deviceID+"/config", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var config models.Config
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &config))
+	assert.Equal(t, deviceID, config.DeviceID)
+	assert.Equal(t, ptr(100.0), config.TemperatureThreshold)
+	assert.Equal(t, ptr(5*time.Minute), config.HeartbeatInterval)
+
+	// deleting resets the config, the metrics of the device are kept
+	metric := fmt.Sprintf(`{"timestamp": %q, "temperature": 20.0, "battery": 50.0}`, time.Now().Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, do("POST", "/devices/"+deviceID+"/metrics", metric).Code)
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/devices/"+deviceID+"/config", "").Code)
+
+	w = do("GET", "/devices/"+deviceID+"/config", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	config = models.Config{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &config))
+	assert.Equal(t, uint64(2), config.Version)
+	assert.Nil(t, config.TemperatureThreshold)
+	assert.Nil(t, config.HeartbeatInterval)
+	assert.NotNil(t, config.LastSeenAt)
+
+	w = do("GET", "/devices/"+deviceID+"/metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), deviceID)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockIConfig := mocks.NewMockIConfig(ctrl)
+	rs.Iot.Config = mockIConfig
+	mockIConfig.EXPECT().GetDeviceConfig(gomock.Eq(deviceID)).Return(nil, fmt.Errorf("just causing error")).Times(1)
+	assert.Equal(t, http.StatusInternalServerError, do("GET", "/devices/"+deviceID+"/config", "").Code)
+}
+
+func TestPatchConfig(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	do := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	deviceID := uuid.NewString()
+	path := "/devices/" + deviceID + "/config"
+
+	w := do("POST", path, `{"temperature_threshold": 100.0, "battery_threshold": 20.0}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do("GET", path, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	// only the battery threshold changes
+	w = do("PATCH", path, `{"battery_threshold": 15.0}`, `"1"`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	var config models.Config
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &config))
+	assert.Equal(t, ptr(100.0), config.TemperatureThreshold)
+	assert.Equal(t, ptr(15.0), config.BatteryThreshold)
+	assert.Equal(t, uint64(2), config.Version)
+
+	// a stale version loses
+	assert.Equal(t, http.StatusPreconditionFailed, do("PATCH", path, `{"battery_threshold": 10.0}`, `"1"`).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, do("POST", path, `{"temperature_threshold": 90.0, "battery_threshold": 10.0}`, `"1"`).Code)
+
+	w = do("POST", path, `{"temperature_threshold": 90.0, "battery_threshold": 10.0}`, `"2"`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	// without If-Match the last writer wins
+	w = do("PATCH", path, `{"heartbeat_interval": "5m"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &config))
+	assert.Equal(t, ptr(90.0), config.TemperatureThreshold)
+	assert.Equal(t, ptr(5*time.Minute), config.HeartbeatInterval)
+
+	// patching a device without config creates it
+	w = do("PATCH", "/devices/"+uuid.NewString()+"/config", `{"battery_threshold": 15.0}`, "*")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, do("PATCH", "/devices/"+uuid.NewString()+"/config", `{"battery_threshold": 15.0}`, `"1"`).Code)
+}
+
+func TestPatchConfig_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+	for _, tc := range []struct{ body, ifMatch string }{
+		{`{}`, ""},
+		{`{"battery_threshold": "low"}`, ""},
+		{`{"battery_hysteresis": -1.0}`, ""},
+		{`{"heartbeat_interval": "soon"}`, ""},
+		{`{"battery_threshold": 15.0}`, "1"},
+		{`{"battery_threshold": 15.0}`, `"one"`},
+		{`{"battery_threshold": 15.0}`, `W/"1"`},
+	} {
+		req := httptest.NewRequest("PATCH", "/devices/"+deviceID+"/config", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s %s", tc.body, tc.ifMatch)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockIConfig := mocks.NewMockIConfig(ctrl)
+	rs.Iot.Config = mockIConfig
+	mockIConfig.EXPECT().PatchConfig(gomock.Eq(deviceID), gomock.Any(), gomock.Eq(uint64(0)), gomock.Any()).Return(nil, fmt.Errorf("just causing error")).Times(1)
+
+	req := httptest.NewRequest("PATCH", "/devices/"+deviceID+"/config", strings.NewReader(`{"battery_threshold": 15.0}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetConfigHistory(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "alice")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	deviceID := uuid.NewString()
+	path := "/devices/" + deviceID + "/config"
+	require.Equal(t, http.StatusOK, do("POST", path, `{"temperature_threshold": 100.0, "battery_threshold": 20.0}`).Code)
+	require.Equal(t, http.StatusOK, do("PATCH", path, `{"battery_threshold": 15.0}`).Code)
+
+	w := do("GET", path+"/history?limit=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Changes    []models.ConfigChange `json:"changes"`
+		NextCursor string                `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Changes, 1)
+	assert.Equal(t, ptr(20.0), resp.Changes[0].Old.BatteryThreshold)
+	assert.Equal(t, ptr(15.0), resp.Changes[0].New.BatteryThreshold)
+	assert.Equal(t, "alice", resp.Changes[0].Actor)
+	assert.Equal(t, models.ChangeSourceHTTP, resp.Changes[0].Source)
+	require.NotEmpty(t, resp.NextCursor)
+
+	w = do("GET", path+"/history?cursor="+resp.NextCursor, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	resp.Changes = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Changes, 1)
+	assert.Nil(t, resp.Changes[0].Old)
+	assert.Empty(t, resp.NextCursor)
+
+	for _, query := range []string{"limit=-1", "from=yesterday", "cursor=!"} {
+		assert.Equal(t, http.StatusBadRequest, do("GET", path+"/history?"+query, "").Code, query)
+	}
+}
+
+func TestUpdateConfig_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	{
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		// empty payload should be rejected
+		payload := []byte("{}")
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	{
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockIConfig := mocks.NewMockIConfig(ctrl)
+		rs.Iot.Config = mockIConfig
+		mockIConfig.EXPECT().
+			UpsertConfig(gomock.Eq(deviceID), gomock.Any(), gomock.Any()).
+			Return(fmt.Errorf("just causing error")).
+			Times(1)
+
+		// Send a config without valid deviceID should cause internal error
+		configReq := ConfigRequest{
+			TemperatureThreshold: 100.0,
+			BatteryThreshold:     20.0,
+		}
+		body, _ := json.Marshal(configReq)
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+
+	{
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		// hysteresis bands can not be negative
+		payload := []byte(`{"temperature_threshold": 30.0, "battery_threshold": 20.0, "temperature_hysteresis": -1.0}`)
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	for _, heartbeat := range []string{"soon", "-1m"} {
+		rs := setupTestServer()
+		deviceID := uuid.NewString()
+		payload := []byte(`{"temperature_threshold": 30.0, "battery_threshold": 20.0, "heartbeat_interval": "` + heartbeat + `"}`)
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, heartbeat)
+	}
+}
+
+func setupTestServerWithLimiter(limiter *iot.RateLimiterStore) *RestfulServer {
+	iotObj := iot.IOT{
+		Db: *db.GetInstance(db.UseMemorySqliteDialector()),
+	}
+	if _, err := iotObj.WithServices(iot.ServiceOpts{
+		Metric: iotObj.GetIMetric(),
+		Alert:  iotObj.GetIAlert(),
+		Config: iotObj.GetIConfig(),
+		Rule:   iotObj.GetIRule(),
+		Device: iotObj.GetIDevice(),
+		Group:  iotObj.GetIGroup(),
+	}); err != nil {
+		panic(err)
+	}
+
+	rs := &RestfulServer{
+		Server:           gin.Default(),
+		Iot:              &iotObj,
+		RateLimiterStore: limiter,
+	}
+
+	rs.Setup()
+
+	return rs
+}
+
+func TestAlertActions(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	config := &models.Config{
+		DeviceID:             deviceID,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(20.0),
+	}
+	err := rs.Iot.Db.Conn.Create(config).Error
+	assert.NoError(t, err)
+
+	err = rs.Iot.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 40.0, Battery: 50.0})
+	assert.NoError(t, err)
+
+	getAlerts := func(status string) []models.Alert {
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts?status="+status, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var page alertsResponse
+		err := json.Unmarshal(w.Body.Bytes(), &page)
+		alerts := page.Alerts
+		assert.NoError(t, err)
+		return alerts
+	}
+
+	post := func(path string, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/alerts/"+path, bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	alerts := getAlerts("open")
+	require.Len(t, alerts, 1)
+	id := fmt.Sprint(alerts[0].ID)
+
+	w := post(id+"/ack", `{"by": "alice", "note": "on it"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var alert models.Alert
+	err = json.Unmarshal(w.Body.Bytes(), &alert)
+	assert.NoError(t, err)
+	assert.Equal(t, models.AlertStatusAcknowledged, alert.Status)
+	assert.Equal(t, "alice", alert.AcknowledgedBy)
+
+	assert.Len(t, getAlerts("open"), 0)
+	assert.Len(t, getAlerts("acknowledged"), 1)
+
+	assert.Equal(t, http.StatusConflict, post(id+"/ack", `{"by": "alice"}`).Code)
+	assert.Equal(t, http.StatusOK, post(id+"/resolve", `{"by": "alice", "note": "fan replaced"}`).Code)
+	assert.Equal(t, http.StatusConflict, post(id+"/resolve", `{"by": "alice"}`).Code)
+
+	alerts = getAlerts("resolved")
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "fan replaced", alerts[0].Note)
+}
+
+func TestAlertActions_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	for _, c := range []struct {
+		path    string
+		payload string
+		code    int
+	}{
+		{"1/ack", `{}`, http.StatusBadRequest},
+		{"1/ack", `{"by": ""}`, http.StatusBadRequest},
+		{"abc/ack", `{"by": "alice"}`, http.StatusBadRequest},
+		{"999999/ack", `{"by": "alice"}`, http.StatusNotFound},
+		{"999999/resolve", `{"by": "alice"}`, http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/alerts/"+c.path, bytes.NewReader([]byte(c.payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, c.code, w.Code, c.path+" "+c.payload)
+	}
+
+	req := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts?status=closed", nil)
 	w := httptest.NewRecorder()
-
 	rs.Server.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestPostMetricsAndGetAlerts(t *testing.T) {
+func TestRules(t *testing.T) {
 	common.SetTestLoggerNop()
 
 	rs := setupTestServer()
 
 	deviceID := uuid.NewString()
 
-	// Insert config first (required to trigger alerts)
 	config := &models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: 30.0,
-		BatteryThreshold:     50.0,
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
 	}
 	err := rs.Iot.Db.Conn.Create(config).Error
 	assert.NoError(t, err)
 
-	// Send a metric that triggers both alerts
-	metricReq := MetricRequest{
-		Timestamp:   time.Now(),
-		Temperature: 45.5,
-		Battery:     20.0,
+	ruleReq := RuleRequest{
+		Metric:    "humidity",
+		Operator:  ">",
+		Threshold: 80.0,
+		Severity:  "critical",
+		HoldFor:   "10m",
 	}
-	body, _ := json.Marshal(metricReq)
-
-	req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader(body))
+	body, _ := json.Marshal(ruleReq)
+	req := httptest.NewRequest("POST", "/devices/"+deviceID+"/rules", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	rs.Server.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusCreated, w.Code)
 
-	alertReq := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts", nil)
-	alertW := httptest.NewRecorder()
-	rs.Server.ServeHTTP(alertW, alertReq)
+	var rule models.AlertRule
+	err = json.Unmarshal(w.Body.Bytes(), &rule)
+	assert.NoError(t, err)
+	assert.NotZero(t, rule.ID)
+	assert.Equal(t, 10*time.Minute, rule.HoldFor)
+	ruleURL := fmt.Sprintf("/devices/%s/rules/%d", deviceID, rule.ID)
 
-	assert.Equal(t, http.StatusOK, alertW.Code)
+	{
+		payload := `{"metric": "humidity", "operator": ">=", "threshold": 70.0, "severity": "critical"}`
+		req := httptest.NewRequest("PUT", ruleURL, bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
 
-	var alerts []models.Alert
-	err = json.Unmarshal(alertW.Body.Bytes(), &alerts)
-	assert.NoError(t, err)
-	assert.Len(t, alerts, 2)
+	{
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/rules", nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
 
-	alertTypes := map[string]bool{}
-	for _, alert := range alerts {
-		alertTypes[string(alert.Type)] = true
+		var rules []models.AlertRule
+		err = json.Unmarshal(w.Body.Bytes(), &rules)
+		assert.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, models.RuleOperatorGTE, rules[0].Operator)
+		assert.Equal(t, 70.0, rules[0].Threshold)
+	}
+
+	{
+		payload := `{"timestamp": "2025-01-01T00:00:00Z", "temperature": 20.0, "battery": 80.0,
+			"values": [{"name": "humidity", "value": 70}]}`
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req = httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts", nil)
+		w = httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var page alertsResponse
+		err = json.Unmarshal(w.Body.Bytes(), &page)
+		alerts := page.Alerts
+		assert.NoError(t, err)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, models.AlertType("humidity"), alerts[0].Type)
+		assert.Equal(t, models.AlertSeverityCritical, alerts[0].Severity)
+	}
+
+	{
+		req := httptest.NewRequest("DELETE", ruleURL, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("GET", ruleURL, nil)
+		w = httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	}
-	assert.True(t, alertTypes[string(models.AlertTypeTemperature)])
-	assert.True(t, alertTypes[string(models.AlertTypeBattery)])
 }
 
-func TestPostMetricsAndGetAlerts_EdgeCases(t *testing.T) {
+func TestRules_EdgeCases(t *testing.T) {
 	common.SetTestLoggerNop()
 
-	{
-		rs := setupTestServer()
-		deviceID := uuid.NewString()
-		// empty payload should be rejected
-		payload := []byte("{}")
-		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader(payload))
+	rs := setupTestServer()
+
+	deviceID := uuid.NewString()
+
+	for _, payload := range []string{
+		`{"operator": ">", "threshold": 1}`,
+		`{"metric": "humidity", "operator": "!=", "threshold": 1}`,
+		`{"metric": "humidity", "operator": ">", "threshold": 1, "severity": "fatal"}`,
+		`{"metric": "humidity", "operator": "outside", "threshold": 60, "upper_threshold": 20}`,
+		`{"metric": "humidity", "operator": ">", "threshold": 1, "hold_for": "soon"}`,
+		`{"metric": "humidity", "operator": ">", "threshold": 1, "hold_samples": -1}`,
+	} {
+		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/rules", bytes.NewReader([]byte(payload)))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		rs.Server.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusBadRequest, w.Code, payload)
 	}
 
 	{
-		rs := setupTestServer()
-		deviceID := uuid.NewString()
-		// Send a metric without valid deviceID should cause internal error
-		metricReq := MetricRequest{
-			Timestamp:   time.Now(),
-			Temperature: 45.5,
-			Battery:     20.0,
-		}
-		body, _ := json.Marshal(metricReq)
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/rules/abc", nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
 
-		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader(body))
+	{
+		payload := `{"metric": "humidity", "operator": ">", "threshold": 1}`
+		req := httptest.NewRequest("PUT", "/devices/"+deviceID+"/rules/999999", bytes.NewReader([]byte(payload)))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		rs.Server.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	}
 
 	{
-		rs := setupTestServer()
-		deviceID := uuid.NewString()
-		// This will not work too
-		payload := "{\"timestamp\": \"-1\", \"temperature\": 45.5, \"battery\": 20.0}"
+		req := httptest.NewRequest("DELETE", "/devices/"+deviceID+"/rules/999999", nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	}
+}
 
-		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/metrics", bytes.NewReader([]byte(payload)))
+type devicesResponse struct {
+	Devices    []models.Device `json:"devices"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+func TestDevices(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		rs.Server.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		return w
 	}
 
+	prefix := "fleet_" + uuid.NewString() + "-"
+	deviceID := prefix + "1"
+
 	{
-		rs := setupTestServer()
-		deviceID := uuid.NewString()
-		ctrl := gomock.NewController(t)
-		defer ctrl.Finish()
-		mockIAlert := mocks.NewMockIAlert(ctrl)
-		rs.Iot.Alert = mockIAlert
-		mockIAlert.EXPECT().
-			GetDeviceAlerts(gomock.Eq(deviceID)).
-			Return(nil, fmt.Errorf("just causing error")).
-			Times(1)
+		w := do("POST", "/devices", `{"id":"`+deviceID+`","name":"Boiler room","model":"TH-100","tags":["heating","building-1"]}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var device models.Device
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &device))
+		assert.Equal(t, deviceID, device.ID)
+		assert.Equal(t, []string{"building-1", "heating"}, device.Tags)
+		// enabled unless told otherwise
+		assert.True(t, device.Enabled)
+	}
 
-		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/alerts", nil)
+	assert.Equal(t, http.StatusConflict, do("POST", "/devices", `{"id":"`+deviceID+`"}`).Code)
+	assert.Equal(t, http.StatusCreated, do("POST", "/devices", `{"id":"`+prefix+`2","enabled":false,"tags":["heating"]}`).Code)
+
+	{
+		w := do("GET", "/devices/"+deviceID, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var device models.Device
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &device))
+		assert.Equal(t, "Boiler room", device.Name)
+	}
+
+	{
+		w := do("PUT", "/devices/"+deviceID, `{"name":"Boiler room","firmware":"1.3.0","enabled":false}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var device models.Device
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &device))
+		assert.Equal(t, "1.3.0", device.Firmware)
+		assert.Empty(t, device.Model)
+		assert.Empty(t, device.Tags)
+		assert.False(t, device.Enabled)
+	}
+
+	{
+		w := do("GET", "/devices?prefix="+url.QueryEscape(prefix)+"&limit=1", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var page devicesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Devices, 1)
+		assert.Equal(t, deviceID, page.Devices[0].ID)
+		assert.NotEmpty(t, page.NextCursor)
+	}
+
+	{
+		w := do("GET", "/devices?prefix="+url.QueryEscape(prefix)+"&tag=heating&enabled=false", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var page devicesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Devices, 1)
+		assert.Equal(t, prefix+"2", page.Devices[0].ID)
+	}
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/devices/"+deviceID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/devices/"+deviceID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("PUT", "/devices/"+deviceID, `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/devices/"+deviceID, "").Code)
+}
+
+func TestDevices_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	for _, tc := range []struct{ method, path, body string }{
+		{"POST", "/devices", `{"name":"no id"}`},
+		{"POST", "/devices", `{"id":"` + uuid.NewString() + `","tags":["bad tag"]}`},
+		{"POST", "/devices", `{"id":"` + uuid.NewString() + `","enabled":"yes"}`},
+		{"PUT", "/devices/" + uuid.NewString(), `{"tags":["bad tag"]}`},
+		{"GET", "/devices?limit=-1", ""},
+		{"GET", "/devices?cursor=!", ""},
+		{"GET", "/devices?enabled=maybe", ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		rs.Server.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s %s %s", tc.method, tc.path, tc.body)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockIDevice := mocks.NewMockIDevice(ctrl)
+	rs.Iot.Device = mockIDevice
+
+	mockIDevice.EXPECT().ListDevices(gomock.Any()).Return(nil, fmt.Errorf("just causing error")).Times(1)
+	mockIDevice.EXPECT().GetDevice(gomock.Any()).Return(nil, fmt.Errorf("just causing error")).Times(1)
+
+	for _, path := range []string{"/devices", "/devices/" + uuid.NewString()} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code, path)
 	}
 }
 
-func TestUpdateConfig(t *testing.T) {
+func TestPostMetrics_RequireRegisteredDevices(t *testing.T) {
 	common.SetTestLoggerNop()
 
 	rs := setupTestServer()
+	rs.Iot.RequireRegisteredDevices = true
+	defer func() { rs.Iot.RequireRegisteredDevices = false }()
 
 	deviceID := uuid.NewString()
+	err := rs.Iot.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
 
-	configReq := ConfigRequest{
-		TemperatureThreshold: 100.0,
-		BatteryThreshold:     20.0,
+	post := func(path, body string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w.Code
 	}
-	body, _ := json.Marshal(configReq)
-	req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-	rs.Server.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
 
-	// Verify in DB
-	var config models.Config
-	err := rs.Iot.Db.Conn.
-		Where("device_id = ?", deviceID).
-		First(&config).Error
-	assert.NoError(t, err)
-	assert.Equal(t, 100.0, config.TemperatureThreshold)
+	metric := `{"timestamp":"` + time.Now().Format(time.RFC3339) + `","temperature":20.0,"battery":50.0}`
+	assert.Equal(t, http.StatusForbidden, post("/devices/"+deviceID+"/metrics", metric))
+	assert.Equal(t, http.StatusForbidden, post("/devices/"+deviceID+"/metrics:batch", `{"metrics":[`+metric+`]}`))
+
+	_, err = rs.Iot.Device.CreateDevice(&models.Device{ID: deviceID, Enabled: false}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, post("/devices/"+deviceID+"/metrics", metric))
+
+	_, err = rs.Iot.Device.UpdateDevice(deviceID, &models.Device{Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, post("/devices/"+deviceID+"/metrics", metric))
+
+	// a device registered without tags or config gets one to store metrics against
+	untagged := uuid.NewString()
+	assert.Equal(t, http.StatusCreated, post("/devices", `{"id":"`+untagged+`"}`))
+	assert.Equal(t, http.StatusOK, post("/devices/"+untagged+"/metrics", metric))
+	config, err := rs.Iot.Config.GetDeviceConfig(untagged)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), config.Version)
+	assert.Nil(t, config.TemperatureThreshold)
 }
 
-func TestUpdateConfig_EdgeCases(t *testing.T) {
+func TestGroups(t *testing.T) {
 	common.SetTestLoggerNop()
 
-	{
-		rs := setupTestServer()
-		deviceID := uuid.NewString()
-		// empty payload should be rejected
-		payload := []byte("{}")
-		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(payload))
+	rs := setupTestServer()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		rs.Server.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		return w
 	}
 
+	tag := "g-" + uuid.NewString()
+	deviceID := uuid.NewString()
+	_, err := rs.Iot.Device.CreateDevice(&models.Device{ID: deviceID, Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
 	{
-		rs := setupTestServer()
-		deviceID := uuid.NewString()
-		ctrl := gomock.NewController(t)
-		defer ctrl.Finish()
-		mockIConfig := mocks.NewMockIConfig(ctrl)
-		rs.Iot.Config = mockIConfig
-		mockIConfig.EXPECT().
-			UpsertConfig(gomock.Eq(deviceID), gomock.Any()).
-			Return(fmt.Errorf("just causing error")).
-			Times(1)
+		w := do("PUT", "/groups/"+tag+"/config", `{"priority": 2, "battery_threshold": 15.0, "heartbeat_interval": "5m"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var group models.GroupConfig
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &group))
+		assert.Equal(t, tag, group.Tag)
+		assert.Equal(t, 2, group.Priority)
+		assert.Equal(t, 15.0, *group.BatteryThreshold)
+		assert.Equal(t, 5*time.Minute, *group.HeartbeatInterval)
+		assert.Nil(t, group.TemperatureThreshold)
+	}
 
-		// Send a config without valid deviceID should cause internal error
-		configReq := ConfigRequest{
-			TemperatureThreshold: 100.0,
-			BatteryThreshold:     20.0,
+	{
+		w := do("GET", "/groups/"+tag+"/config", "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	{
+		w := do("GET", "/groups", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Groups []models.GroupConfig `json:"groups"`
 		}
-		body, _ := json.Marshal(configReq)
-		req := httptest.NewRequest("POST", "/devices/"+deviceID+"/config", bytes.NewReader(body))
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Contains(t, common.Mapper(resp.Groups, func(g models.GroupConfig) string { return g.Tag }), tag)
+	}
+
+	{
+		w := do("POST", "/groups/"+tag+"/devices/config", `{"temperature_threshold": 40.0}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"updated": 1}`, w.Body.String())
+	}
+
+	{
+		w := do("GET", "/devices/"+deviceID+"/config/resolved", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resolved models.ResolvedConfig
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resolved))
+		assert.Equal(t, ptr(40.0), resolved.Config.TemperatureThreshold)
+		assert.Equal(t, models.ConfigSourceDevice, resolved.Sources.TemperatureThreshold)
+		assert.Equal(t, ptr(15.0), resolved.Config.BatteryThreshold)
+		assert.Equal(t, models.ConfigSourceGroupPrefix+tag, resolved.Sources.BatteryThreshold)
+		assert.Equal(t, models.ConfigSourceDefault, resolved.Sources.TemperatureHysteresis)
+	}
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/groups/"+tag+"/config", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/groups/"+tag+"/config", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/groups/"+tag+"/config", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/devices/"+uuid.NewString()+"/config/resolved", "").Code)
+}
+
+func TestGroups_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+
+	tag := "g-" + uuid.NewString()
+	for _, tc := range []struct{ method, path, body string }{
+		{"PUT", "/groups/" + tag + "/config", `{"heartbeat_interval": "soon"}`},
+		{"PUT", "/groups/" + tag + "/config", `{"battery_hysteresis": -1}`},
+		{"PUT", "/groups/" + tag + "/config", `{"priority": "high"}`},
+		{"PUT", "/groups/" + tag + "/config", `{"metric_retention": "forever"}`},
+		{"PUT", "/groups/" + tag + "/config", `{"alert_retention": "-1h"}`},
+		{"PUT", "/groups/bad%20tag/config", `{}`},
+		{"POST", "/groups/" + tag + "/devices/config", `{}`},
+		{"POST", "/groups/" + tag + "/devices/config", `{"battery_threshold": "low"}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		rs.Server.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s %s %s", tc.method, tc.path, tc.body)
 	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockIGroup := mocks.NewMockIGroup(ctrl)
+	rs.Iot.Group = mockIGroup
+
+	mockIGroup.EXPECT().ListGroupConfigs().Return(nil, fmt.Errorf("just causing error")).Times(1)
+
+	req := httptest.NewRequest("GET", "/groups", nil)
+	w := httptest.NewRecorder()
+	rs.Server.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
-func setupTestServerWithLimiter(limiter *iot.RateLimiterStore) *RestfulServer {
-	iotObj := iot.IOT{
-		Db: *db.GetInstance(db.UseMemorySqliteDialector()),
-	}
-	iotObj.WithServices(iot.ServiceOpts{
-		Metric: iotObj.GetIMetric(),
-		Alert:  iotObj.GetIAlert(),
-		Config: iotObj.GetIConfig(),
-	})
+func TestRetention(t *testing.T) {
+	common.SetTestLoggerNop()
 
-	rs := &RestfulServer{
-		Server:           gin.Default(),
-		Iot:              &iotObj,
-		RateLimiterStore: limiter,
+	rs := setupTestServer()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w
 	}
 
-	rs.Setup()
+	assert.Equal(t, http.StatusServiceUnavailable, do("GET", "/retention", "").Code)
 
-	return rs
+	{
+		tag := "g-" + uuid.NewString()
+		w := do("PUT", "/groups/"+tag+"/config", `{"metric_retention": "720h", "alert_retention": "0s"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var group models.GroupConfig
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &group))
+		assert.Equal(t, 720*time.Hour, *group.MetricRetention)
+		assert.Zero(t, *group.AlertRetention)
+	}
+
+	rs.Iot.Retention = iot.NewRetentionPruner(rs.Iot.Db.Conn, iot.RetentionOptions{
+		MetricMaxAge: 24 * time.Hour,
+		Interval:     10 * time.Millisecond,
+	})
+	defer rs.Iot.Retention.Close()
+
+	require.Eventually(t, func() bool { return rs.Iot.Retention.Stats().Runs > 0 }, time.Second, 10*time.Millisecond)
+
+	w := do("GET", "/retention", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Policy struct {
+			Interval     string `json:"interval"`
+			MetricMaxAge string `json:"metric_max_age"`
+			AlertMaxAge  string `json:"alert_max_age"`
+			BatchSize    int    `json:"batch_size"`
+		} `json:"policy"`
+		Runs      int              `json:"runs"`
+		LastRunAt *time.Time       `json:"last_run_at"`
+		Pruned    map[string]int64 `json:"pruned"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "10ms", resp.Policy.Interval)
+	assert.Equal(t, "24h0m0s", resp.Policy.MetricMaxAge)
+	assert.Equal(t, "0s", resp.Policy.AlertMaxAge)
+	assert.Equal(t, 1000, resp.Policy.BatchSize)
+	assert.Positive(t, resp.Runs)
+	assert.NotNil(t, resp.LastRunAt)
+	assert.Contains(t, resp.Pruned, iot.RetentionTableMetrics)
+	assert.Contains(t, resp.Pruned, iot.RetentionTableAlerts)
 }
 
 func TestPostMetricsWithLimiter(t *testing.T) {
@@ -278,8 +1649,8 @@ func TestPostMetricsWithLimiter(t *testing.T) {
 	// Insert config first (required to trigger alerts)
 	config := &models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: 30.0,
-		BatteryThreshold:     50.0,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
 	}
 	err := rs.Iot.Db.Conn.Create(config).Error
 	assert.NoError(t, err)
@@ -416,3 +1787,38 @@ func TestSetLimiter_EdgeCases(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	}
 }
+
+func TestPostMetrics_Ingester(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	rs := setupTestServer()
+	// the first metric waits for a batch to fill, the queue is then full
+	rs.Iot.Ingester = iot.NewMetricIngester(rs.Iot, iot.IngestOptions{QueueSize: 1, Workers: 1, BatchSize: 2, FlushInterval: time.Hour})
+	defer func() { rs.Iot.Ingester = nil }()
+
+	deviceID := uuid.NewString()
+	err := rs.Iot.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		rs.Server.ServeHTTP(w, req)
+		return w
+	}
+
+	metric := `{"timestamp":"` + time.Now().Format(time.RFC3339) + `","temperature":20.0,"battery":50.0}`
+	assert.Equal(t, http.StatusAccepted, post("/devices/"+deviceID+"/metrics", metric).Code)
+
+	w := post("/devices/"+deviceID+"/metrics:batch", `{"metrics":[`+metric+`]}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	rs.Iot.Ingester.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, post("/devices/"+deviceID+"/metrics", metric).Code)
+
+	page, err := rs.Iot.Metric.GetDeviceMetrics(deviceID, &models.MetricQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Metrics, 1)
+}
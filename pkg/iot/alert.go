@@ -1,7 +1,8 @@
 package iot
 
 import (
-	"fmt"
+	"errors"
+	"slices"
 	"time"
 
 	"go.uber.org/zap"
@@ -10,76 +11,322 @@ import (
 )
 
 func (i *IOT) checkAlerts(deviceID string, metric *models.Metric, upsertAlertFn func(alert *models.Alert) error) error {
-	var err error
-	var config *models.Config
-	if config, err = i.Config.GetDeviceConfig(deviceID); err != nil {
-		// no config, then no need to calcualte alerts
-		return nil
-	}
-
 	logger := common.GetLoggerWith(
 		common.LoggerNameIOTCore,
 		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTAlert),
 	)
 
-	now := time.Now()
+	// a device without a config is still checked against its stored rules
+	var rules []models.AlertRule
+	resolved, err := i.Config.ResolveConfig(deviceID)
+	switch {
+	case errors.Is(err, ErrConfigNotFound):
+	case err != nil:
+		return err
+	default:
+		rules = configRules(&resolved.Config)
+	}
 
-	if metric.Temperature > config.TemperatureThreshold {
-		alert := models.Alert{
-			DeviceID:  deviceID,
-			Timestamp: now,
-			Type:      models.AlertTypeTemperature,
-			Message:   fmt.Sprintf("Temperature %.2f exceeded threshold %.2f", metric.Temperature, config.TemperatureThreshold),
+	if i.Rule != nil {
+		var stored []models.AlertRule
+		if stored, err = i.Rule.GetDeviceRules(deviceID); err != nil {
+			return err
 		}
+		rules = append(rules, stored...)
+	}
+
+	var firing []models.Alert
+	if firing, err = i.getFiringAlerts(deviceID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	for _, rule := range rules {
+		value, ok := metric.Value(rule.Metric)
+		if !ok {
+			continue
+		}
+
+		current := findRuleAlert(firing, &rule)
+
+		switch {
+		case matchRule(&rule, value) && current != nil:
+			if err = i.Store.RepeatAlert(current.ID, now); err != nil {
+				return err
+			}
+
+			logger.Debug("Alert repeated", zap.Uint("alert_id", current.ID))
 
-		logger.Info("Alert found", zap.Reflect("alert", alert))
+		case matchRule(&rule, value):
+			var held bool
+			if held, err = i.ruleHeld(deviceID, metric, &rule); err != nil {
+				return err
+			}
+			if !held {
+				logger.Debug("Alert pending", zap.String("device_id", deviceID), zap.Reflect("rule", rule))
+				continue
+			}
+
+			alert := models.Alert{
+				DeviceID:  deviceID,
+				Timestamp: now,
+				Type:      models.AlertType(rule.Metric),
+				Severity:  rule.Severity,
+				Message:   ruleMessage(&rule, value),
+				State:     models.AlertStateFiring,
+				LastSeen:  now,
+				Count:     1,
+				Status:    models.AlertStatusOpen,
+			}
+			if rule.ID != 0 {
+				alert.RuleID = &rule.ID
+			}
+
+			logger.Info("Alert found", zap.Reflect("alert", alert))
+
+			if err = upsertAlertFn(&alert); err != nil {
+				return err
+			}
+
+			logger.Info("Alert saved", zap.Reflect("alert", alert))
+
+			i.publishAlert(&alert)
+
+		case current != nil && clearsRule(&rule, value):
+			var resolved *models.Alert
+			if resolved, err = i.clearAlert(current, now); err != nil {
+				return err
+			}
+			if resolved == nil {
+				continue
+			}
+
+			logger.Info("Alert resolved", zap.Reflect("alert", resolved))
+
+			i.publishAlert(resolved)
+		}
+	}
+
+	// an alert whose rule was deleted, or whose threshold was unset, has
+	// nothing left to clear it
+	for idx := range firing {
+		alert := &firing[idx]
+		if alert.Type == models.AlertTypeOffline || slices.ContainsFunc(rules, func(rule models.AlertRule) bool { return raisedBy(alert, &rule) }) {
+			continue
+		}
 
-		if err = upsertAlertFn(&alert); err != nil {
+		var resolved *models.Alert
+		if resolved, err = i.clearAlert(alert, now); err != nil {
 			return err
 		}
+		if resolved == nil {
+			continue
+		}
+
+		logger.Info("Alert resolved without its rule", zap.Reflect("alert", resolved))
 
-		logger.Info("Alert saved", zap.Reflect("alert", alert))
+		i.publishAlert(resolved)
 	}
 
-	if metric.Battery < config.BatteryThreshold {
-		alert := models.Alert{
-			DeviceID:  deviceID,
-			Timestamp: now,
-			Type:      models.AlertTypeBattery,
-			Message:   fmt.Sprintf("Battery %.2f below threshold %.2f", metric.Battery, config.BatteryThreshold),
+	return nil
+}
+
+// ruleHeld tells whether a breach of rule by metric has lasted for the hold
+// duration and hold samples of the rule, judged by the stored metric history
+// of the device
+func (i *IOT) ruleHeld(deviceID string, metric *models.Metric, rule *models.AlertRule) (bool, error) {
+	if rule.HoldSamples > 1 {
+		history, err := i.Store.FindMetricHistory(deviceID, metric, &MetricHistoryQuery{Limit: rule.HoldSamples - 1})
+		if err != nil {
+			return false, err
+		}
+		if len(history) < rule.HoldSamples-1 || !breachesAll(rule, history) {
+			return false, nil
 		}
+	}
 
-		logger.Info("Alert found", zap.Reflect("alert", alert))
+	if rule.HoldFor > 0 {
+		since := metric.Timestamp.UTC().Add(-rule.HoldFor)
 
-		if err = upsertAlertFn(&alert); err != nil {
-			return err
+		window, err := i.Store.FindMetricHistory(deviceID, metric, &MetricHistoryQuery{After: &since})
+		if err != nil {
+			return false, err
+		}
+		if !breachesAll(rule, window) {
+			return false, nil
+		}
+
+		// the breach must already be there at the start of the duration
+		start, err := i.Store.FindMetricHistory(deviceID, metric, &MetricHistoryQuery{Until: &since, Limit: 1})
+		if err != nil {
+			return false, err
+		}
+		if len(start) == 0 || !breachesAll(rule, start) {
+			return false, nil
 		}
+	}
+
+	return true, nil
+}
+
+func breachesAll(rule *models.AlertRule, metrics []models.Metric) bool {
+	for _, m := range metrics {
+		value, ok := m.Value(rule.Metric)
+		if !ok || !matchRule(rule, value) {
+			return false
+		}
+	}
+	return true
+}
 
-		logger.Info("Alert saved", zap.Reflect("alert", alert))
+// raisedBy tells whether alert was raised by rule, config threshold rules are
+// told apart by their metric
+func raisedBy(alert *models.Alert, rule *models.AlertRule) bool {
+	if string(alert.Type) != rule.Metric {
+		return false
 	}
+	return (rule.ID == 0 && alert.RuleID == nil) || (alert.RuleID != nil && *alert.RuleID == rule.ID)
+}
 
+// findRuleAlert returns the alert raised by rule
+func findRuleAlert(alerts []models.Alert, rule *models.AlertRule) *models.Alert {
+	for idx := range alerts {
+		if raisedBy(&alerts[idx], rule) {
+			return &alerts[idx]
+		}
+	}
 	return nil
 }
 
+// clearAlert resolves a firing alert once its breach is over. It returns nil
+// when the alert was resolved meanwhile.
+func (i *IOT) clearAlert(alert *models.Alert, at time.Time) (*models.Alert, error) {
+	resolved, err := i.Store.TransitAlert(alert.DeviceID, alert.ID, &AlertTransition{
+		From:   []models.AlertStatus{models.AlertStatusOpen, models.AlertStatusAcknowledged},
+		Status: models.AlertStatusResolved,
+		At:     at,
+	})
+	if errors.Is(err, ErrAlertNotFound) || errors.Is(err, ErrAlertStatusConflict) {
+		return nil, nil
+	}
+	return resolved, err
+}
+
+func (i *IOT) getFiringAlerts(deviceID string) ([]models.Alert, error) {
+	return i.Store.FindFiringAlerts(deviceID)
+}
+
+func (i *IOT) publishAlert(alert *models.Alert) {
+	if i.Broker != nil {
+		i.Broker.Publish(*alert)
+	}
+	if i.Notifier != nil {
+		i.Notifier.Notify(*alert)
+	}
+}
+
+// upsertAlert inserts a new alert, or updates it when ID is set
 func (i *IOT) upsertAlert(data *models.Alert) error {
-	return i.Db.Conn.Create(data).Error
+	return i.Store.SaveAlert(data)
+}
+
+func (i *IOT) getDeviceAlerts(deviceID string, query *models.AlertQuery) (*models.AlertPage, error) {
+	if query == nil {
+		query = &models.AlertQuery{}
+	}
+	return i.Store.FindAlerts(deviceID, &models.FleetAlertQuery{AlertQuery: *query})
+}
+
+func (i *IOT) listAlerts(query *models.FleetAlertQuery) (*models.AlertPage, error) {
+	if query == nil {
+		query = &models.FleetAlertQuery{}
+	}
+	return i.Store.FindAlerts("", query)
+}
+
+// summarizeAlerts counts the alerts matching query per device, ordered by
+// device id. Limit and cursor of the query are not used.
+func (i *IOT) summarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error) {
+	if query == nil {
+		query = &models.FleetAlertQuery{}
+	}
+
+	return i.Store.SummarizeAlerts(query)
+}
+
+func (i *IOT) acknowledgeAlert(deviceID string, id uint, by string, note string) (*models.Alert, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTAlert),
+	)
+
+	alert, err := i.Store.TransitAlert(deviceID, id, &AlertTransition{
+		From:   []models.AlertStatus{models.AlertStatusOpen},
+		Status: models.AlertStatusAcknowledged,
+		By:     by,
+		At:     time.Now().UTC(),
+		Note:   note,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Alert acknowledged", zap.Reflect("alert", alert))
+
+	i.publishAlert(alert)
+
+	return alert, nil
 }
 
-func (i *IOT) getDeviceAlerts(deviceID string) ([]models.Alert, error) {
-	var alerts []models.Alert
-	err := i.Db.Conn.
-		Where("device_id = ?", deviceID).
-		Order("timestamp desc").
-		Find(&alerts).Error
-	return alerts, err
+// resolveAlert closes the alert by hand, if the breach goes on the next
+// metric raises a new alert
+func (i *IOT) resolveAlert(deviceID string, id uint, by string, note string) (*models.Alert, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTAlert),
+	)
+
+	alert, err := i.Store.TransitAlert(deviceID, id, &AlertTransition{
+		From:   []models.AlertStatus{models.AlertStatusOpen, models.AlertStatusAcknowledged},
+		Status: models.AlertStatusResolved,
+		By:     by,
+		At:     time.Now().UTC(),
+		Note:   note,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Alert resolved by hand", zap.Reflect("alert", alert))
+
+	i.publishAlert(alert)
+
+	return alert, nil
 }
 
 type IAlertImpl struct {
 	iot *IOT
 }
 
-func (ia *IAlertImpl) GetDeviceAlerts(deviceID string) ([]models.Alert, error) {
-	return ia.iot.getDeviceAlerts(deviceID)
+func (ia *IAlertImpl) GetDeviceAlerts(deviceID string, query *models.AlertQuery) (*models.AlertPage, error) {
+	return ia.iot.getDeviceAlerts(deviceID, query)
+}
+
+func (ia *IAlertImpl) ListAlerts(query *models.FleetAlertQuery) (*models.AlertPage, error) {
+	return ia.iot.listAlerts(query)
+}
+
+func (ia *IAlertImpl) SummarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error) {
+	return ia.iot.summarizeAlerts(query)
+}
+
+func (ia *IAlertImpl) AcknowledgeAlert(deviceID string, id uint, by string, note string) (*models.Alert, error) {
+	return ia.iot.acknowledgeAlert(deviceID, id, by, note)
+}
+
+func (ia *IAlertImpl) ResolveAlert(deviceID string, id uint, by string, note string) (*models.Alert, error) {
+	return ia.iot.resolveAlert(deviceID, id, by, note)
 }
 
 func (ia *IAlertImpl) CheckAndStoreAlerts(deviceID string, metric *models.Metric) error {
@@ -29,8 +29,8 @@ func TestCheckAndStoreAlerts(t *testing.T) {
 	// Seed config
 	config := models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: 30.0,
-		BatteryThreshold:     20.0,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(20.0),
 	}
 	err := iotObj.Db.Conn.Create(&config).Error
 	assert.NoError(t, err)
@@ -46,8 +46,9 @@ func TestCheckAndStoreAlerts(t *testing.T) {
 	iotObj.Alert.CheckAndStoreAlerts(deviceID, metric)
 
 	// Check that 2 alerts were stored
-	alerts, err := iotObj.Alert.GetDeviceAlerts(deviceID)
-	assert.NoError(t, err)
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	alerts := page.Alerts
 	assert.Len(t, alerts, 2)
 
 	// Assert alert types
@@ -78,8 +79,9 @@ func TestCheckAndStoreAlertsNoConfig(t *testing.T) {
 	// No config exists, so alerts shouldn't be stored
 	iotObj.Alert.CheckAndStoreAlerts(deviceID, metric)
 
-	alerts, err := iotObj.Alert.GetDeviceAlerts(deviceID)
-	assert.NoError(t, err)
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	alerts := page.Alerts
 	assert.Len(t, alerts, 0)
 }
 
@@ -94,9 +96,9 @@ func TestUpsertAlert(t *testing.T) {
 	{
 		err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
 			DeviceID:             deviceID,
-			TemperatureThreshold: 30.0,
-			BatteryThreshold:     20.0,
-		})
+			TemperatureThreshold: ptr(30.0),
+			BatteryThreshold:     ptr(20.0),
+		}, models.ChangeOrigin{})
 
 		assert.NoError(t, err)
 	}
@@ -128,8 +130,24 @@ func (ia *IAlertFallbackMock) UpsertAlert(data *models.Alert) error {
 	return ia.mockIAlert.UpsertAlert(data)
 }
 
-func (ia *IAlertFallbackMock) GetDeviceAlerts(deviceID string) ([]models.Alert, error) {
-	return ia.iotObj.Alert.GetDeviceAlerts(deviceID)
+func (ia *IAlertFallbackMock) GetDeviceAlerts(deviceID string, query *models.AlertQuery) (*models.AlertPage, error) {
+	return ia.iotObj.Alert.GetDeviceAlerts(deviceID, query)
+}
+
+func (ia *IAlertFallbackMock) ListAlerts(query *models.FleetAlertQuery) (*models.AlertPage, error) {
+	return ia.iotObj.Alert.ListAlerts(query)
+}
+
+func (ia *IAlertFallbackMock) SummarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error) {
+	return ia.iotObj.Alert.SummarizeAlerts(query)
+}
+
+func (ia *IAlertFallbackMock) AcknowledgeAlert(deviceID string, id uint, by string, note string) (*models.Alert, error) {
+	return ia.mockIAlert.AcknowledgeAlert(deviceID, id, by, note)
+}
+
+func (ia *IAlertFallbackMock) ResolveAlert(deviceID string, id uint, by string, note string) (*models.Alert, error) {
+	return ia.mockIAlert.ResolveAlert(deviceID, id, by, note)
 }
 
 func TestCheckAndStoreAlerts_EdgeCases(t *testing.T) {
@@ -149,9 +167,9 @@ func TestCheckAndStoreAlerts_EdgeCases(t *testing.T) {
 	{
 		err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
 			DeviceID:             deviceID,
-			TemperatureThreshold: 30.0,
-			BatteryThreshold:     50.0,
-		})
+			TemperatureThreshold: ptr(30.0),
+			BatteryThreshold:     ptr(50.0),
+		}, models.ChangeOrigin{})
 		assert.NoError(t, err)
 	}
 
@@ -216,8 +234,8 @@ func TestCheckAndStoreAlerts_WithLog(t *testing.T) {
 	// Seed config
 	config := models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: 30.0,
-		BatteryThreshold:     20.0,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(20.0),
 	}
 	err := iotObj.Db.Conn.Create(&config).Error
 	assert.NoError(t, err)
@@ -233,8 +251,9 @@ func TestCheckAndStoreAlerts_WithLog(t *testing.T) {
 	iotObj.Alert.CheckAndStoreAlerts(deviceID, metric)
 
 	// Check that 2 alerts were stored
-	alerts, err := iotObj.Alert.GetDeviceAlerts(deviceID)
-	assert.NoError(t, err)
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	alerts := page.Alerts
 	assert.Len(t, alerts, 2)
 
 	// Assert alert types
@@ -312,3 +331,495 @@ func TestCheckAndStoreAlerts_WithLog(t *testing.T) {
 		assert.True(t, found)
 	}
 }
+
+func TestCheckAndStoreAlerts_Hysteresis(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	broker := NewAlertBroker(8)
+	iotObj.Broker = broker
+	defer func() { iotObj.Broker = nil }()
+
+	deviceID := uuid.NewString()
+	sub := broker.Subscribe(AlertFilter{DeviceID: deviceID})
+	defer sub.Close()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold:  ptr(30.0),
+		BatteryThreshold:      ptr(20.0),
+		TemperatureHysteresis: ptr(2.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	check := func(temperature float64) []models.Alert {
+		err := iotObj.Alert.CheckAndStoreAlerts(deviceID, &models.Metric{
+			DeviceID:    deviceID,
+			Timestamp:   time.Now(),
+			Temperature: temperature,
+			Battery:     50.0,
+		})
+		require.NoError(t, err)
+
+		page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+		require.NoError(t, err)
+		alerts := page.Alerts
+		return alerts
+	}
+
+	alerts := check(35.0)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, models.AlertStateFiring, alerts[0].State)
+	assert.Equal(t, 1, alerts[0].Count)
+
+	// repeated breaches only bump the firing alert
+	alerts = check(36.0)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 2, alerts[0].Count)
+	assert.True(t, !alerts[0].LastSeen.Before(alerts[0].Timestamp))
+
+	// back below threshold, but still inside the hysteresis band
+	alerts = check(29.0)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, models.AlertStateFiring, alerts[0].State)
+	assert.Nil(t, alerts[0].ResolvedAt)
+
+	alerts = check(28.0)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, models.AlertStateResolved, alerts[0].State)
+	assert.NotNil(t, alerts[0].ResolvedAt)
+	assert.Equal(t, 2, alerts[0].Count)
+
+	// a new breach after resolution raises a new alert
+	alerts = check(31.0)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, models.AlertStateFiring, alerts[0].State)
+	assert.Equal(t, 1, alerts[0].Count)
+
+	// only transitions are published: firing, resolved, firing
+	var states []models.AlertState
+	for range 3 {
+		select {
+		case alert := <-sub.C:
+			states = append(states, alert.State)
+		case <-time.After(time.Second):
+			t.Fatal("expected alert to be published")
+		}
+	}
+	assert.Equal(t, []models.AlertState{models.AlertStateFiring, models.AlertStateResolved, models.AlertStateFiring}, states)
+
+	select {
+	case alert := <-sub.C:
+		t.Fatalf("unexpected alert published: %+v", alert)
+	default:
+	}
+}
+
+func TestCheckAndStoreAlerts_Hold(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	setup := func(rule models.AlertRule) string {
+		deviceID := uuid.NewString()
+		err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+			TemperatureThreshold: ptr(100.0),
+			BatteryThreshold:     ptr(0.0),
+		}, models.ChangeOrigin{})
+		require.NoError(t, err)
+		_, err = iotObj.Rule.CreateRule(deviceID, &rule)
+		require.NoError(t, err)
+		return deviceID
+	}
+
+	post := func(deviceID string, ts time.Time, temperature, humidity float64) []models.Alert {
+		err := iotObj.Metric.UpsertMetric(deviceID, &models.Metric{
+			Timestamp:   ts,
+			Temperature: temperature,
+			Battery:     50,
+			Values:      []models.MetricValue{{Name: "humidity", Value: humidity}},
+		})
+		require.NoError(t, err)
+
+		page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+		require.NoError(t, err)
+		alerts := page.Alerts
+		return alerts
+	}
+
+	base := time.Now().Add(-time.Hour)
+
+	{
+		deviceID := setup(models.AlertRule{Metric: "humidity", Operator: models.RuleOperatorGT, Threshold: 80, HoldSamples: 3})
+
+		assert.Len(t, post(deviceID, base, 20, 85), 0)
+		assert.Len(t, post(deviceID, base.Add(time.Minute), 20, 85), 0)
+		// the streak is broken
+		assert.Len(t, post(deviceID, base.Add(2*time.Minute), 20, 70), 0)
+		assert.Len(t, post(deviceID, base.Add(3*time.Minute), 20, 85), 0)
+		assert.Len(t, post(deviceID, base.Add(4*time.Minute), 20, 85), 0)
+
+		alerts := post(deviceID, base.Add(5*time.Minute), 20, 86)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, "Humidity 86.00 exceeded threshold 80.00 in 3 consecutive samples", alerts[0].Message)
+	}
+
+	{
+		deviceID := setup(models.AlertRule{Metric: "temperature", Operator: models.RuleOperatorGTE, Threshold: 50, HoldFor: 5 * time.Minute})
+
+		assert.Len(t, post(deviceID, base, 55, 0), 0)
+		assert.Len(t, post(deviceID, base.Add(3*time.Minute), 56, 0), 0)
+
+		alerts := post(deviceID, base.Add(5*time.Minute), 57, 0)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, "Temperature 57.00 reached threshold 50.00 for 5m0s", alerts[0].Message)
+	}
+
+	{
+		deviceID := setup(models.AlertRule{Metric: "temperature", Operator: models.RuleOperatorGTE, Threshold: 50, HoldFor: 5 * time.Minute})
+
+		assert.Len(t, post(deviceID, base, 55, 0), 0)
+		// a dip inside the duration restarts it
+		assert.Len(t, post(deviceID, base.Add(2*time.Minute), 45, 0), 0)
+		assert.Len(t, post(deviceID, base.Add(3*time.Minute), 56, 0), 0)
+		assert.Len(t, post(deviceID, base.Add(6*time.Minute), 57, 0), 0)
+		assert.Len(t, post(deviceID, base.Add(8*time.Minute), 57, 0), 1)
+	}
+}
+
+func TestAlertLifecycle(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(20.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	breach := func() {
+		err := iotObj.Alert.CheckAndStoreAlerts(deviceID, &models.Metric{
+			DeviceID:    deviceID,
+			Timestamp:   time.Now(),
+			Temperature: 35.0,
+			Battery:     50.0,
+		})
+		require.NoError(t, err)
+	}
+
+	breach()
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Status: models.AlertStatusOpen})
+	require.NoError(t, err)
+	alerts := page.Alerts
+	require.Len(t, alerts, 1)
+	id := alerts[0].ID
+
+	acked, err := iotObj.Alert.AcknowledgeAlert(deviceID, id, "alice", "looking into it")
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusAcknowledged, acked.Status)
+	assert.Equal(t, "alice", acked.AcknowledgedBy)
+	assert.NotNil(t, acked.AcknowledgedAt)
+	assert.Equal(t, "looking into it", acked.Note)
+
+	_, err = iotObj.Alert.AcknowledgeAlert(deviceID, id, "bob", "")
+	assert.ErrorIs(t, err, ErrAlertStatusConflict)
+
+	// alerts belong to their device
+	_, err = iotObj.Alert.AcknowledgeAlert(uuid.NewString(), id, "bob", "")
+	assert.ErrorIs(t, err, ErrAlertNotFound)
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Status: models.AlertStatusOpen})
+	require.NoError(t, err)
+	alerts = page.Alerts
+	assert.Len(t, alerts, 0)
+
+	// an acknowledged alert still counts the breaches
+	breach()
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Status: models.AlertStatusAcknowledged})
+	require.NoError(t, err)
+	alerts = page.Alerts
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 2, alerts[0].Count)
+
+	resolved, err := iotObj.Alert.ResolveAlert(deviceID, id, "bob", "")
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusResolved, resolved.Status)
+	assert.Equal(t, models.AlertStateResolved, resolved.State)
+	assert.Equal(t, "bob", resolved.ResolvedBy)
+	assert.NotNil(t, resolved.ResolvedAt)
+	assert.Equal(t, "looking into it", resolved.Note)
+
+	_, err = iotObj.Alert.ResolveAlert(deviceID, id, "bob", "")
+	assert.ErrorIs(t, err, ErrAlertStatusConflict)
+
+	_, err = iotObj.Alert.ResolveAlert(deviceID, 0, "bob", "")
+	assert.ErrorIs(t, err, ErrAlertNotFound)
+
+	// the breach goes on after resolving by hand, so a new alert is raised
+	breach()
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Status: models.AlertStatusOpen})
+	require.NoError(t, err)
+	alerts = page.Alerts
+	require.Len(t, alerts, 1)
+	assert.NotEqual(t, id, alerts[0].ID)
+
+	// resolving by itself leaves ResolvedBy empty
+	err = iotObj.Alert.CheckAndStoreAlerts(deviceID, &models.Metric{
+		DeviceID:    deviceID,
+		Timestamp:   time.Now(),
+		Temperature: 25.0,
+		Battery:     50.0,
+	})
+	require.NoError(t, err)
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Status: models.AlertStatusResolved})
+	require.NoError(t, err)
+	alerts = page.Alerts
+	require.Len(t, alerts, 2)
+	assert.Empty(t, alerts[0].ResolvedBy)
+}
+
+func TestGetDeviceAlerts_Query(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(20.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	types := []models.AlertType{models.AlertTypeTemperature, models.AlertTypeBattery, "humidity", models.AlertTypeTemperature}
+	for i, alertType := range types {
+		err := iotObj.Alert.UpsertAlert(&models.Alert{
+			DeviceID:  deviceID,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Type:      alertType,
+			Message:   fmt.Sprintf("alert %d", i),
+		})
+		require.NoError(t, err)
+	}
+
+	// newest first, paged by limit and cursor
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 3)
+	assert.Equal(t, "alert 3", page.Alerts[0].Message)
+	assert.Equal(t, "alert 1", page.Alerts[2].Message)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Limit: 3, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, "alert 0", page.Alerts[0].Message)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{
+		Types: []models.AlertType{models.AlertTypeTemperature, "humidity"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Alerts, 3)
+
+	from, to := base.Add(time.Minute), base.Add(2*time.Minute)
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{From: &from, To: &to})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 2)
+	assert.Equal(t, "alert 2", page.Alerts[0].Message)
+	assert.Equal(t, "alert 1", page.Alerts[1].Message)
+
+	_, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Cursor: "bogus"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestListAlerts(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	// the database is shared with other tests, so the fleet is kept apart by
+	// a prefix of its own
+	prefix := "fleet_" + uuid.NewString() + "/"
+	deviceA, deviceB := prefix+"a", prefix+"b"
+
+	for _, deviceID := range []string{deviceA, deviceB} {
+		err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+			TemperatureThreshold: ptr(30.0),
+			BatteryThreshold:     ptr(20.0),
+		}, models.ChangeOrigin{})
+		require.NoError(t, err)
+	}
+
+	// a gets a temperature and a battery alert, b a battery alert which is
+	// resolved right away
+	err := iotObj.Alert.CheckAndStoreAlerts(deviceA, &models.Metric{Timestamp: time.Now(), Temperature: 40.0, Battery: 10.0})
+	require.NoError(t, err)
+	err = iotObj.Alert.CheckAndStoreAlerts(deviceB, &models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 10.0})
+	require.NoError(t, err)
+	err = iotObj.Alert.CheckAndStoreAlerts(deviceB, &models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	page, err := iotObj.Alert.ListAlerts(&models.FleetAlertQuery{DevicePrefix: prefix})
+	require.NoError(t, err)
+	assert.Len(t, page.Alerts, 3)
+
+	page, err = iotObj.Alert.ListAlerts(&models.FleetAlertQuery{DevicePrefix: prefix, AlertQuery: models.AlertQuery{Limit: 2}})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 2)
+	page, err = iotObj.Alert.ListAlerts(&models.FleetAlertQuery{DevicePrefix: prefix, AlertQuery: models.AlertQuery{Limit: 2, Cursor: page.NextCursor}})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = iotObj.Alert.ListAlerts(&models.FleetAlertQuery{
+		DevicePrefix: prefix,
+		AlertQuery:   models.AlertQuery{Types: []models.AlertType{models.AlertTypeBattery}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Alerts, 2)
+
+	page, err = iotObj.Alert.ListAlerts(&models.FleetAlertQuery{
+		DevicePrefix: prefix,
+		AlertQuery:   models.AlertQuery{State: models.AlertStateFiring},
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 2)
+	for _, alert := range page.Alerts {
+		assert.Equal(t, deviceA, alert.DeviceID)
+	}
+
+	// wildcards in the prefix are matched literally
+	page, err = iotObj.Alert.ListAlerts(&models.FleetAlertQuery{DevicePrefix: "fleet%"})
+	require.NoError(t, err)
+	assert.Len(t, page.Alerts, 0)
+
+	summaries, err := iotObj.Alert.SummarizeAlerts(&models.FleetAlertQuery{DevicePrefix: prefix})
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, deviceA, summaries[0].DeviceID)
+	assert.Equal(t, 2, summaries[0].Count)
+	assert.Equal(t, 2, summaries[0].Firing)
+	assert.Equal(t, 2, summaries[0].Open)
+	assert.WithinDuration(t, time.Now(), summaries[0].LastAlertAt, time.Minute)
+	assert.Equal(t, deviceB, summaries[1].DeviceID)
+	assert.Equal(t, 1, summaries[1].Count)
+	assert.Equal(t, 0, summaries[1].Firing)
+	assert.Equal(t, 0, summaries[1].Open)
+
+	// which devices are alerting right now
+	summaries, err = iotObj.Alert.SummarizeAlerts(&models.FleetAlertQuery{
+		DevicePrefix: prefix,
+		AlertQuery:   models.AlertQuery{State: models.AlertStateFiring},
+	})
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, deviceA, summaries[0].DeviceID)
+}
+
+// pinLocalTimezone runs the test with time.Local ahead of UTC, sqlite
+// compares timestamps as text so any of them kept in local time misorders
+func pinLocalTimezone(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*60*60)
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestAlerts_LocalTimezone(t *testing.T) {
+	common.SetTestLoggerNop()
+	pinLocalTimezone(t)
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	// raises a temperature and a battery alert
+	err = iotObj.Alert.CheckAndStoreAlerts(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 40.0, Battery: 10.0})
+	require.NoError(t, err)
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, time.UTC, page.Alerts[0].Timestamp.Location())
+	require.NotEmpty(t, page.NextCursor)
+	next, err := iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, next.Alerts, 1)
+	assert.NotEqual(t, page.Alerts[0].Type, next.Alerts[0].Type)
+
+	// bounds in local time select the same alerts
+	now := time.Now()
+	from, to := now.Add(-time.Hour), now.Add(time.Hour)
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Len(t, page.Alerts, 2)
+	to = now.Add(-time.Hour)
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{To: &to})
+	require.NoError(t, err)
+	assert.Empty(t, page.Alerts)
+
+	acked, err := iotObj.Alert.AcknowledgeAlert(deviceID, next.Alerts[0].ID, "alice", "")
+	require.NoError(t, err)
+	require.NotNil(t, acked.AcknowledgedAt)
+	assert.Equal(t, time.UTC, acked.AcknowledgedAt.Location())
+
+	// the fleet pages by the same cursor
+	fleet := &models.FleetAlertQuery{DevicePrefix: deviceID, AlertQuery: models.AlertQuery{Limit: 1}}
+	page, err = iotObj.Alert.ListAlerts(fleet)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	require.NotEmpty(t, page.NextCursor)
+	fleet.Cursor = page.NextCursor
+	next, err = iotObj.Alert.ListAlerts(fleet)
+	require.NoError(t, err)
+	require.Len(t, next.Alerts, 1)
+	assert.NotEqual(t, page.Alerts[0].ID, next.Alerts[0].ID)
+	assert.Empty(t, next.NextCursor)
+
+	summaries, err := iotObj.Alert.SummarizeAlerts(&models.FleetAlertQuery{DevicePrefix: deviceID})
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, 2, summaries[0].Count)
+	assert.Equal(t, time.UTC, summaries[0].LastAlertAt.Location())
+	assert.WithinDuration(t, now, summaries[0].LastAlertAt, time.Minute)
+}
+
+func TestScanTime(t *testing.T) {
+	want := time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+	for _, value := range []any{
+		want,
+		"2025-01-02 03:04:05.000006+00:00",
+		[]byte("2025-01-02T05:04:05.000006+02:00"),
+		"2025-01-02 03:04:05.000006",
+	} {
+		got, err := scanTime(value)
+		require.NoError(t, err, "%v", value)
+		assert.True(t, want.Equal(got), "%v", value)
+	}
+
+	got, err := scanTime(nil)
+	require.NoError(t, err)
+	assert.True(t, got.IsZero())
+
+	_, err = scanTime("yesterday")
+	assert.Error(t, err)
+	_, err = scanTime(42)
+	assert.Error(t, err)
+}
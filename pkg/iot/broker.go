@@ -0,0 +1,90 @@
+package iot
+
+import (
+	"slices"
+	"sync"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+// AlertFilter selects which alerts a subscriber receives, empty fields match all
+type AlertFilter struct {
+	DeviceID string
+	Types    []models.AlertType
+}
+
+func (f *AlertFilter) Match(alert *models.Alert) bool {
+	if f.DeviceID != "" && f.DeviceID != alert.DeviceID {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, alert.Type) {
+		return false
+	}
+	return true
+}
+
+type AlertSubscription struct {
+	C <-chan models.Alert
+
+	ch     chan models.Alert
+	filter AlertFilter
+	broker *AlertBroker
+}
+
+func (s *AlertSubscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// AlertBroker is an in-process pub/sub of stored alerts. Publish never blocks,
+// a subscriber whose buffer is full misses the alert.
+type AlertBroker struct {
+	subscribers map[*AlertSubscription]struct{}
+	mu          sync.RWMutex
+	bufferSize  int
+}
+
+func NewAlertBroker(bufferSize int) *AlertBroker {
+	return &AlertBroker{
+		subscribers: make(map[*AlertSubscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (b *AlertBroker) Subscribe(filter AlertFilter) *AlertSubscription {
+	ch := make(chan models.Alert, b.bufferSize)
+	sub := &AlertSubscription{C: ch, ch: ch, filter: filter, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (b *AlertBroker) unsubscribe(sub *AlertSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+func (b *AlertBroker) Publish(alert models.Alert) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if !sub.filter.Match(&alert) {
+			continue
+		}
+		select {
+		case sub.ch <- alert:
+		default:
+		}
+	}
+}
+
+func (b *AlertBroker) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
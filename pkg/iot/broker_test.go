@@ -0,0 +1,88 @@
+package iot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+func TestAlertFilter_Match(t *testing.T) {
+	alert := &models.Alert{DeviceID: "device1", Type: models.AlertTypeBattery}
+
+	assert.True(t, (&AlertFilter{}).Match(alert))
+	assert.True(t, (&AlertFilter{DeviceID: "device1"}).Match(alert))
+	assert.False(t, (&AlertFilter{DeviceID: "device2"}).Match(alert))
+	assert.True(t, (&AlertFilter{Types: []models.AlertType{models.AlertTypeTemperature, models.AlertTypeBattery}}).Match(alert))
+	assert.False(t, (&AlertFilter{DeviceID: "device1", Types: []models.AlertType{models.AlertTypeTemperature}}).Match(alert))
+}
+
+func TestAlertBroker_PublishSubscribe(t *testing.T) {
+	broker := NewAlertBroker(1)
+
+	all := broker.Subscribe(AlertFilter{})
+	batteryOnly := broker.Subscribe(AlertFilter{Types: []models.AlertType{models.AlertTypeBattery}})
+	assert.Equal(t, 2, broker.SubscriberCount())
+
+	broker.Publish(models.Alert{DeviceID: "device1", Type: models.AlertTypeTemperature})
+	// buffer of "all" is full now, this one is dropped for it instead of blocking
+	broker.Publish(models.Alert{DeviceID: "device1", Type: models.AlertTypeBattery})
+
+	assert.Equal(t, models.AlertTypeTemperature, (<-all.C).Type)
+	assert.Equal(t, models.AlertTypeBattery, (<-batteryOnly.C).Type)
+	assert.Len(t, all.C, 0)
+
+	all.Close()
+	all.Close() // closing twice is fine
+	_, ok := <-all.C
+	assert.False(t, ok, "expected channel to be closed")
+	assert.Equal(t, 1, broker.SubscriberCount())
+
+	batteryOnly.Close()
+	assert.Equal(t, 0, broker.SubscriberCount())
+}
+
+func TestCheckAndStoreAlerts_PublishToBroker(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	iotObj.Broker = NewAlertBroker(8)
+
+	deviceID := uuid.NewString()
+	sub := iotObj.Broker.Subscribe(AlertFilter{DeviceID: deviceID, Types: []models.AlertType{models.AlertTypeBattery}})
+	defer sub.Close()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(20.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	err = iotObj.Alert.CheckAndStoreAlerts(deviceID, &models.Metric{
+		DeviceID:    deviceID,
+		Timestamp:   time.Now(),
+		Temperature: 35.0,
+		Battery:     15.0,
+	})
+	require.NoError(t, err)
+
+	select {
+	case alert := <-sub.C:
+		assert.Equal(t, deviceID, alert.DeviceID)
+		assert.Equal(t, models.AlertTypeBattery, alert.Type)
+		assert.NotZero(t, alert.ID, "expected published alert to be the stored one")
+	case <-time.After(time.Second):
+		t.Fatal("expected alert to be published")
+	}
+
+	// temperature alert is filtered out
+	assert.Len(t, sub.C, 0)
+}
@@ -23,8 +23,12 @@ func GetMockIOTWithMemorySqliteDialector(t *testing.T, useMockIMetric, useMockIA
 	mockIMetric := mocks.NewMockIMetric(ctrl)
 	mockIAlter := mocks.NewMockIAlert(ctrl)
 	mockIConfig := mocks.NewMockIConfig(ctrl)
-	dialector := db.UseMemorySqliteDialector()
-	dbInstance := db.GetInstance(dialector) // ensure migrations
+	// every test gets a database of its own
+	dbInstance, err := db.New(db.UseMemorySqliteDialector(), db.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = dbInstance.Close() })
 	iotInstance := (&IOT{Db: *dbInstance})
 
 	metricService := iotInstance.GetIMetric()
@@ -42,11 +46,16 @@ func GetMockIOTWithMemorySqliteDialector(t *testing.T, useMockIMetric, useMockIA
 		alertService = mockIAlter
 	}
 
-	iotInstance.WithServices(ServiceOpts{
+	if _, err := iotInstance.WithServices(ServiceOpts{
 		Metric: metricService,
 		Alert:  alertService,
 		Config: configService,
-	})
+		Rule:   iotInstance.GetIRule(),
+		Device: iotInstance.GetIDevice(),
+		Group:  iotInstance.GetIGroup(),
+	}); err != nil {
+		t.Fatalf("Failed to set up services: %v", err)
+	}
 
 	return ctrl, iotInstance, mockIMetric, mockIAlter, mockIConfig
 }
@@ -1,30 +1,52 @@
 package iot
 
 import (
+	"errors"
+	"fmt"
+
 	"go.uber.org/zap"
-	"gorm.io/gorm/clause"
 	"liyu1981.xyz/iot-metrics-service/pkg/common"
 	"liyu1981.xyz/iot-metrics-service/pkg/models"
 )
 
-func (i *IOT) upsertConfig(deviceID string, input *models.Config) error {
+// configUpsertAttempts bounds the retries of an upsert losing against other
+// writers of the same config
+const configUpsertAttempts = 3
+
+func (i *IOT) upsertConfig(deviceID string, input *models.Config, origin models.ChangeOrigin) error {
 	logger := common.GetLoggerWith(
 		common.LoggerNameIOTCore,
 		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTConfig),
 	)
 
+	if input.HeartbeatInterval != nil && *input.HeartbeatInterval < 0 {
+		return fmt.Errorf("%w: heartbeat interval can not be negative", ErrInvalidConfig)
+	}
+
 	config := models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: input.TemperatureThreshold,
-		BatteryThreshold:     input.BatteryThreshold,
+		TemperatureThreshold: common.ClonePtr(input.TemperatureThreshold),
+		BatteryThreshold:     common.ClonePtr(input.BatteryThreshold),
+
+		TemperatureHysteresis: common.ClonePtr(input.TemperatureHysteresis),
+		BatteryHysteresis:     common.ClonePtr(input.BatteryHysteresis),
+		HeartbeatInterval:     common.ClonePtr(input.HeartbeatInterval),
 	}
 
 	logger.Info("Received config for device", zap.Reflect("config", config))
 
-	err := i.Db.Conn.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "device_id"}},
-		UpdateAll: true,
-	}).Create(&config).Error
+	// an upsert replaces whatever config there is, so a conflict only means
+	// trying again
+	var err error
+	for range configUpsertAttempts {
+		_, err = i.Store.UpdateConfigs([]string{deviceID}, origin, func(deviceID string, current *models.Config) (*models.Config, error) {
+			updated := config
+			return &updated, nil
+		})
+		if !errors.Is(err, ErrConfigConflict) {
+			break
+		}
+	}
 
 	if err == nil {
 		logger.Info("Upserted config for device", zap.Reflect("config", config))
@@ -33,24 +55,128 @@ func (i *IOT) upsertConfig(deviceID string, input *models.Config) error {
 	return err
 }
 
+// patchConfig changes the set fields of patch in the config of the device,
+// creating the config when missing, with the fields patch leaves out unset.
+// With a non-zero expectedVersion the config must still be at that version,
+// otherwise ErrConfigConflict is returned.
+func (i *IOT) patchConfig(deviceID string, patch *models.ConfigPatch, expectedVersion uint64, origin models.ChangeOrigin) (*models.Config, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTConfig),
+	)
+
+	if isEmptyConfigPatch(patch) {
+		return nil, fmt.Errorf("%w: no config field to update", ErrInvalidConfig)
+	}
+	if err := ValidateConfigPatch(patch); err != nil {
+		return nil, err
+	}
+
+	updated, err := i.Store.UpdateConfigs([]string{deviceID}, origin, func(deviceID string, current *models.Config) (*models.Config, error) {
+		if current == nil {
+			if expectedVersion != 0 {
+				return nil, fmt.Errorf("%w: device %s has no config", ErrConfigConflict, deviceID)
+			}
+			current = &models.Config{DeviceID: deviceID}
+		} else if expectedVersion != 0 && current.Version != expectedVersion {
+			return nil, fmt.Errorf("%w: config is at version %d, not %d", ErrConfigConflict, current.Version, expectedVersion)
+		}
+		applyConfigPatch(current, patch)
+		return current, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	config := &updated[0]
+
+	logger.Info("Patched config for device", zap.Reflect("patch", patch), zap.Reflect("config", config))
+
+	return config, nil
+}
+
+// errConfigExists stops ensureConfig from changing a config there already is
+var errConfigExists = errors.New("config exists")
+
+// ensureConfig creates a config with every field unset for the device, unless
+// it has one
+func (i *IOT) ensureConfig(deviceID string, origin models.ChangeOrigin) error {
+	_, err := i.Store.UpdateConfigs([]string{deviceID}, origin, func(deviceID string, current *models.Config) (*models.Config, error) {
+		if current != nil {
+			return nil, errConfigExists
+		}
+		return &models.Config{DeviceID: deviceID}, nil
+	})
+	// a conflict is a config created meanwhile
+	if errors.Is(err, errConfigExists) || errors.Is(err, ErrConfigConflict) {
+		return nil
+	}
+	return err
+}
+
 func (i *IOT) getDeviceConfig(deviceID string) (*models.Config, error) {
-	var config models.Config
-	err := i.Db.Conn.First(&config, "device_id = ?", deviceID).Error
-	return &config, err
+	return i.Store.GetConfig(deviceID)
+}
+
+// deleteConfig resets the config of the device, every field becomes unset and
+// is taken from the groups of the device again. The config itself is kept,
+// the metrics, alerts and registry entry of the device reference it.
+func (i *IOT) deleteConfig(deviceID string, origin models.ChangeOrigin) error {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTConfig),
+	)
+
+	// a reset replaces whatever config there is, so a conflict only means
+	// trying again
+	var err error
+	for range configUpsertAttempts {
+		_, err = i.Store.UpdateConfigs([]string{deviceID}, origin, func(deviceID string, current *models.Config) (*models.Config, error) {
+			if current == nil {
+				return nil, ErrConfigNotFound
+			}
+			return &models.Config{DeviceID: deviceID}, nil
+		})
+		if !errors.Is(err, ErrConfigConflict) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Reset config for device", zap.String("device_id", deviceID))
+
+	return nil
 }
 
 type IConfigImpl struct {
 	iot *IOT
 }
 
-func (ic *IConfigImpl) UpsertConfig(deviceID string, input *models.Config) error {
-	return ic.iot.upsertConfig(deviceID, input)
+func (ic *IConfigImpl) UpsertConfig(deviceID string, input *models.Config, origin models.ChangeOrigin) error {
+	return ic.iot.upsertConfig(deviceID, input, origin)
+}
+
+func (ic *IConfigImpl) PatchConfig(deviceID string, patch *models.ConfigPatch, expectedVersion uint64, origin models.ChangeOrigin) (*models.Config, error) {
+	return ic.iot.patchConfig(deviceID, patch, expectedVersion, origin)
 }
 
 func (ic *IConfigImpl) GetDeviceConfig(deviceID string) (*models.Config, error) {
 	return ic.iot.getDeviceConfig(deviceID)
 }
 
+func (ic *IConfigImpl) DeleteConfig(deviceID string, origin models.ChangeOrigin) error {
+	return ic.iot.deleteConfig(deviceID, origin)
+}
+
+func (ic *IConfigImpl) GetConfigHistory(deviceID string, query *models.ConfigChangeQuery) (*models.ConfigChangePage, error) {
+	return ic.iot.getConfigHistory(deviceID, query)
+}
+
+func (ic *IConfigImpl) ResolveConfig(deviceID string) (*models.ResolvedConfig, error) {
+	return ic.iot.resolveDeviceConfig(deviceID)
+}
+
 func (i *IOT) GetIConfig() IConfig {
 	return &IConfigImpl{iot: i}
 }
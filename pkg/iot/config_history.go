@@ -0,0 +1,45 @@
+package iot
+
+import (
+	"time"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+func configValues(config *models.Config) *models.ConfigValues {
+	if config == nil {
+		return nil
+	}
+	return &models.ConfigValues{
+		TemperatureThreshold:  common.ClonePtr(config.TemperatureThreshold),
+		BatteryThreshold:      common.ClonePtr(config.BatteryThreshold),
+		TemperatureHysteresis: common.ClonePtr(config.TemperatureHysteresis),
+		BatteryHysteresis:     common.ClonePtr(config.BatteryHysteresis),
+		HeartbeatInterval:     common.ClonePtr(config.HeartbeatInterval),
+	}
+}
+
+// newConfigChange is the history entry of a change of the config of the
+// device, old is nil for a created config and updated nil for a deleted one.
+// Stores keep it along with the change, so both are stored or neither.
+func newConfigChange(deviceID string, old *models.Config, updated *models.Config, origin models.ChangeOrigin) models.ConfigChange {
+	change := models.ConfigChange{
+		DeviceID:     deviceID,
+		Timestamp:    time.Now().UTC(),
+		Old:          configValues(old),
+		New:          configValues(updated),
+		ChangeOrigin: origin,
+	}
+	if updated != nil {
+		change.Version = updated.Version
+	}
+	return change
+}
+
+func (i *IOT) getConfigHistory(deviceID string, query *models.ConfigChangeQuery) (*models.ConfigChangePage, error) {
+	if query == nil {
+		query = &models.ConfigChangeQuery{}
+	}
+	return i.Store.FindConfigChanges(deviceID, query)
+}
@@ -3,9 +3,11 @@ package iot
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zapcore"
 
 	"liyu1981.xyz/iot-metrics-service/pkg/common" // generated mock folder
@@ -22,12 +24,12 @@ func TestUpsertConfig(t *testing.T) {
 	deviceID := uuid.NewString()
 
 	input := &models.Config{
-		TemperatureThreshold: 30.0,
-		BatteryThreshold:     50.0,
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
 	}
 
 	// Call UpsertConfig and verify no error
-	err := iotObj.Config.UpsertConfig(deviceID, input)
+	err := iotObj.Config.UpsertConfig(deviceID, input, models.ChangeOrigin{})
 	assert.NoError(t, err)
 
 	// Verify the configuration was inserted into the database
@@ -39,10 +41,10 @@ func TestUpsertConfig(t *testing.T) {
 
 	// Update the configuration with new values
 	updatedInput := &models.Config{
-		TemperatureThreshold: 35.0,
-		BatteryThreshold:     60.0,
+		TemperatureThreshold: ptr(35.0),
+		BatteryThreshold:     ptr(60.0),
 	}
-	err = iotObj.Config.UpsertConfig(deviceID, updatedInput)
+	err = iotObj.Config.UpsertConfig(deviceID, updatedInput, models.ChangeOrigin{})
 	assert.NoError(t, err)
 
 	// Verify the updated configuration
@@ -64,11 +66,11 @@ func TestUpsertConfig_WithLog(t *testing.T) {
 
 	{
 		input := &models.Config{
-			TemperatureThreshold: 30.0,
-			BatteryThreshold:     50.0,
+			TemperatureThreshold: ptr(30.0),
+			BatteryThreshold:     ptr(50.0),
 		}
 
-		err := iotObj.Config.UpsertConfig(deviceID, input)
+		err := iotObj.Config.UpsertConfig(deviceID, input, models.ChangeOrigin{})
 		assert.NoError(t, err)
 	}
 
@@ -107,3 +109,202 @@ func TestUpsertConfig_WithLog(t *testing.T) {
 	}
 
 }
+
+func TestGetAndDeleteConfig(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID, postedID := uuid.NewString(), uuid.NewString()
+	for _, id := range []string{deviceID, postedID} {
+		err := iotObj.Config.UpsertConfig(id, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(50.0)}, models.ChangeOrigin{})
+		require.NoError(t, err)
+	}
+
+	config, err := iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, ptr(30.0), config.TemperatureThreshold)
+
+	require.NoError(t, iotObj.Config.DeleteConfig(deviceID, models.ChangeOrigin{}))
+	config, err = iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), config.Version)
+	assert.Nil(t, config.TemperatureThreshold)
+	assert.Nil(t, config.BatteryThreshold)
+	assert.ErrorIs(t, iotObj.Config.DeleteConfig(uuid.NewString(), models.ChangeOrigin{}), ErrConfigNotFound)
+
+	// a device with metrics and alerts has its config reset, the alerts of
+	// the unset thresholds resolve with the next metrics
+	err = iotObj.Metric.UpsertMetric(postedID, &models.Metric{Timestamp: time.Now(), Temperature: 40.0, Battery: 60.0})
+	require.NoError(t, err)
+	require.NoError(t, iotObj.Config.DeleteConfig(postedID, models.ChangeOrigin{}))
+	config, err = iotObj.Config.GetDeviceConfig(postedID)
+	require.NoError(t, err)
+	assert.Nil(t, config.TemperatureThreshold)
+	assert.NotNil(t, config.LastSeenAt)
+
+	err = iotObj.Metric.UpsertMetric(postedID, &models.Metric{Timestamp: time.Now(), Temperature: 40.0, Battery: 60.0})
+	require.NoError(t, err)
+	page, err := iotObj.Alert.GetDeviceAlerts(postedID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertStateResolved, page.Alerts[0].State)
+}
+
+func TestPatchConfig(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	battery := 15.0
+
+	// a missing config is created from the patch
+	config, err := iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{BatteryThreshold: &battery}, 0, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), config.Version)
+	assert.Equal(t, ptr(15.0), config.BatteryThreshold)
+	assert.Nil(t, config.TemperatureThreshold)
+
+	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	config, err = iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), config.Version)
+
+	// only the set fields change
+	config, err = iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{BatteryThreshold: &battery}, 2, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), config.Version)
+	assert.Equal(t, ptr(30.0), config.TemperatureThreshold)
+	assert.Equal(t, ptr(15.0), config.BatteryThreshold)
+
+	_, err = iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{BatteryThreshold: &battery}, 2, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrConfigConflict)
+	_, err = iotObj.Config.PatchConfig(uuid.NewString(), &models.ConfigPatch{BatteryThreshold: &battery}, 1, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrConfigConflict)
+
+	_, err = iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{}, 0, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	negative := -1.0
+	_, err = iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{BatteryHysteresis: &negative}, 0, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	// bulk updates by tag count as a change too
+	tag := "g-" + uuid.NewString()
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	_, err = iotObj.Group.UpdateConfigsByTag(tag, &models.ConfigPatch{BatteryThreshold: &battery}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	config, err = iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), config.Version)
+}
+
+func TestPatchConfig_CreatesWithoutThresholds(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	battery := 15.0
+	_, err := iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{BatteryThreshold: &battery}, 0, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	// the temperature threshold the patch left out raises no alerts
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 5.0, Battery: 50.0})
+	require.NoError(t, err)
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Alerts)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 5.0, Battery: 10.0})
+	require.NoError(t, err)
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertTypeBattery, page.Alerts[0].Type)
+
+	resolved, err := iotObj.Config.ResolveConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ConfigSourceDefault, resolved.Sources.TemperatureThreshold)
+	assert.Equal(t, models.ConfigSourceDevice, resolved.Sources.BatteryThreshold)
+}
+
+func TestGetConfigHistory(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	operator := models.ChangeOrigin{Actor: "alice", Source: models.ChangeSourceHTTP}
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, operator)
+	require.NoError(t, err)
+	battery := 15.0
+	_, err = iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{BatteryThreshold: &battery}, 0, models.ChangeOrigin{Actor: "bob", Source: models.ChangeSourceGRPC})
+	require.NoError(t, err)
+
+	tag := "g-" + uuid.NewString()
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	_, err = iotObj.Group.UpdateConfigsByTag(tag, &models.ConfigPatch{BatteryThreshold: ptr(10.0)}, operator)
+	require.NoError(t, err)
+	require.NoError(t, iotObj.Config.DeleteConfig(deviceID, operator))
+
+	page, err := iotObj.Config.GetConfigHistory(deviceID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 4)
+	assert.Empty(t, page.NextCursor)
+
+	// newest first
+	reset, bulk, patched, created := page.Changes[0], page.Changes[1], page.Changes[2], page.Changes[3]
+	assert.Nil(t, created.Old)
+	assert.Equal(t, ptr(30.0), created.New.TemperatureThreshold)
+	assert.Equal(t, uint64(1), created.Version)
+	assert.Equal(t, "alice", created.Actor)
+	assert.Equal(t, models.ChangeSourceHTTP, created.Source)
+
+	assert.Equal(t, ptr(20.0), patched.Old.BatteryThreshold)
+	assert.Equal(t, ptr(15.0), patched.New.BatteryThreshold)
+	assert.Equal(t, ptr(30.0), patched.New.TemperatureThreshold)
+	assert.Equal(t, "bob", patched.Actor)
+	assert.Equal(t, models.ChangeSourceGRPC, patched.Source)
+
+	assert.Equal(t, ptr(15.0), bulk.Old.BatteryThreshold)
+	assert.Equal(t, ptr(10.0), bulk.New.BatteryThreshold)
+	assert.Equal(t, uint64(3), bulk.Version)
+
+	assert.Equal(t, ptr(10.0), reset.Old.BatteryThreshold)
+	assert.Nil(t, reset.New.BatteryThreshold)
+	assert.Equal(t, uint64(4), reset.Version)
+
+	page, err = iotObj.Config.GetConfigHistory(deviceID, &models.ConfigChangeQuery{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 3)
+	require.NotEmpty(t, page.NextCursor)
+	page, err = iotObj.Config.GetConfigHistory(deviceID, &models.ConfigChangeQuery{Limit: 3, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, created.ID, page.Changes[0].ID)
+
+	future := time.Now().Add(time.Hour)
+	page, err = iotObj.Config.GetConfigHistory(deviceID, &models.ConfigChangeQuery{From: &future})
+	require.NoError(t, err)
+	assert.Empty(t, page.Changes)
+
+	// a failed change leaves no history
+	otherID := uuid.NewString()
+	err = iotObj.Config.UpsertConfig(otherID, &models.Config{HeartbeatInterval: ptr(-time.Second)}, operator)
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	page, err = iotObj.Config.GetConfigHistory(otherID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Changes)
+
+	_, err = iotObj.Config.GetConfigHistory(deviceID, &models.ConfigChangeQuery{Cursor: "!"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
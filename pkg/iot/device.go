@@ -0,0 +1,333 @@
+package iot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+const (
+	MaxDeviceIDLength = 128
+	MaxDeviceTags     = 32
+)
+
+var deviceTagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:/-]{0,63}$`)
+
+// ValidateDevice checks the id and tags of a registry entry, tags must be
+// unique per device
+func ValidateDevice(device *models.Device) error {
+	if device.ID == "" || len(device.ID) > MaxDeviceIDLength {
+		return fmt.Errorf("%w: device id must be 1 to %d characters", ErrInvalidDevice, MaxDeviceIDLength)
+	}
+	if len(device.Tags) > MaxDeviceTags {
+		return fmt.Errorf("%w: more than %d tags", ErrInvalidDevice, MaxDeviceTags)
+	}
+	for idx, tag := range device.Tags {
+		if !deviceTagPattern.MatchString(tag) {
+			return fmt.Errorf("%w: tag %q must match %s", ErrInvalidDevice, tag, deviceTagPattern)
+		}
+		if slices.Contains(device.Tags[:idx], tag) {
+			return fmt.Errorf("%w: duplicated tag %q", ErrInvalidDevice, tag)
+		}
+	}
+	return nil
+}
+
+func copyDevice(deviceID string, input *models.Device) models.Device {
+	return models.Device{
+		ID:       deviceID,
+		Name:     input.Name,
+		Model:    input.Model,
+		Firmware: input.Firmware,
+		Location: input.Location,
+		Tags:     slices.Clone(input.Tags),
+		Enabled:  input.Enabled,
+	}
+}
+
+func deviceTags(device *models.Device) []models.DeviceTag {
+	return common.Mapper(device.Tags, func(tag string) models.DeviceTag {
+		return models.DeviceTag{DeviceID: device.ID, Tag: tag}
+	})
+}
+
+// loadDeviceTags fills the tags of devices from their DeviceTag rows
+func loadDeviceTags(tx *gorm.DB, devices []models.Device) error {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	var tags []models.DeviceTag
+	ids := common.Mapper(devices, func(d models.Device) string { return d.ID })
+	if err := tx.Where("device_id IN ?", ids).Order("tag").Find(&tags).Error; err != nil {
+		return err
+	}
+
+	byDevice := map[string][]string{}
+	for _, tag := range tags {
+		byDevice[tag.DeviceID] = append(byDevice[tag.DeviceID], tag.Tag)
+	}
+	for idx := range devices {
+		devices[idx].Tags = byDevice[devices[idx].ID]
+		if devices[idx].Tags == nil {
+			devices[idx].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+func createDeviceTags(tx *gorm.DB, device *models.Device) error {
+	tags := deviceTags(device)
+	if len(tags) == 0 {
+		return nil
+	}
+	return tx.Create(&tags).Error
+}
+
+// createDevice registers the device along with an empty config, which the
+// metrics and alerts of the device reference. The config is recorded in the
+// history with origin.
+func (i *IOT) createDevice(input *models.Device, origin models.ChangeOrigin) (*models.Device, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTDevice),
+	)
+
+	if err := ValidateDevice(input); err != nil {
+		return nil, err
+	}
+
+	if err := i.ensureConfig(input.ID, origin); err != nil {
+		return nil, err
+	}
+
+	device := copyDevice(input.ID, input)
+	err := i.Db.Conn.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Device{}).Where("id = ?", device.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", ErrDeviceExists, device.ID)
+		}
+		if err := tx.Create(&device).Error; err != nil {
+			return err
+		}
+		return createDeviceTags(tx, &device)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Registered device", zap.Reflect("device", device))
+
+	return i.getDevice(device.ID)
+}
+
+func (i *IOT) getDevice(deviceID string) (*models.Device, error) {
+	var device models.Device
+	err := i.Db.Conn.First(&device, "id = ?", deviceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []models.Device{device}
+	if err := loadDeviceTags(i.Db.Conn, devices); err != nil {
+		return nil, err
+	}
+	return &devices[0], nil
+}
+
+func (i *IOT) listDevices(query *models.DeviceQuery) (*models.DevicePage, error) {
+	if query == nil {
+		query = &models.DeviceQuery{}
+	}
+	limit := normalizePageLimit(query.Limit)
+
+	tx := i.Db.Conn.Model(&models.Device{})
+	if query.Prefix != "" {
+		tx = tx.Where(`id LIKE ? ESCAPE '\'`, likeEscaper.Replace(query.Prefix)+"%")
+	}
+	if query.Tag != "" {
+		tx = tx.Where("id IN (?)", i.Db.Conn.Model(&models.DeviceTag{}).Select("device_id").Where("tag = ?", query.Tag))
+	}
+	if query.Enabled != nil {
+		tx = tx.Where("enabled = ?", *query.Enabled)
+	}
+	if query.Cursor != "" {
+		after, err := decodeKeyCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("id > ?", after)
+	}
+
+	devices, next, err := findPage(tx.Order("id"), limit, func(last *models.Device) string {
+		return encodeKeyCursor(last.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.DevicePage{Devices: devices, NextCursor: next}
+
+	if err := loadDeviceTags(i.Db.Conn, page.Devices); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// updateDevice replaces the metadata, tags and enabled flag of a registered
+// device, the timestamps are kept. A device registered without a config gets
+// an empty one, recorded with origin.
+func (i *IOT) updateDevice(deviceID string, input *models.Device, origin models.ChangeOrigin) (*models.Device, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTDevice),
+	)
+
+	device := copyDevice(deviceID, input)
+	if err := ValidateDevice(&device); err != nil {
+		return nil, err
+	}
+
+	err := i.Db.Conn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Device{}).Where("id = ?", deviceID).Updates(map[string]any{
+			"name":     device.Name,
+			"model":    device.Model,
+			"firmware": device.Firmware,
+			"location": device.Location,
+			"enabled":  device.Enabled,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeviceNotFound
+		}
+
+		if err := tx.Where("device_id = ?", deviceID).Delete(&models.DeviceTag{}).Error; err != nil {
+			return err
+		}
+		return createDeviceTags(tx, &device)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.ensureConfig(deviceID, origin); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Updated device", zap.Reflect("device", device))
+
+	return i.getDevice(deviceID)
+}
+
+// deleteDevice removes the registry entry only, config, metrics and alerts of
+// the device are kept
+func (i *IOT) deleteDevice(deviceID string) error {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTDevice),
+	)
+
+	err := i.Db.Conn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", deviceID).Delete(&models.Device{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeviceNotFound
+		}
+		return tx.Where("device_id = ?", deviceID).Delete(&models.DeviceTag{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Deleted device", zap.String("device_id", deviceID))
+
+	return nil
+}
+
+// checkDevice tells whether the device may post metrics when registration is
+// required
+func (i *IOT) checkDevice(deviceID string) error {
+	var device models.Device
+	err := i.Db.Conn.Select("id", "enabled").First(&device, "id = ?", deviceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", ErrDeviceNotRegistered, deviceID)
+	}
+	if err != nil {
+		return err
+	}
+	if !device.Enabled {
+		return fmt.Errorf("%w: %s", ErrDeviceDisabled, deviceID)
+	}
+	return nil
+}
+
+// markDeviceSeen records in the registry entry of the device, if any, that
+// metrics of it were received at seen
+func (i *IOT) markDeviceSeen(deviceID string, seen time.Time) error {
+	// UpdateColumn leaves UpdatedAt of the registry entry alone
+	return i.Db.Conn.Model(&models.Device{}).Where("id = ?", deviceID).UpdateColumn("last_seen_at", seen).Error
+}
+
+// admitMetrics is checked before metrics of the device are stored
+func (i *IOT) admitMetrics(deviceID string) error {
+	if !i.RequireRegisteredDevices {
+		return nil
+	}
+	if i.Device == nil {
+		return fmt.Errorf("device service not available")
+	}
+	return i.Device.CheckDevice(deviceID)
+}
+
+type IDeviceImpl struct {
+	iot *IOT
+}
+
+func (id *IDeviceImpl) CreateDevice(input *models.Device, origin models.ChangeOrigin) (*models.Device, error) {
+	return id.iot.createDevice(input, origin)
+}
+
+func (id *IDeviceImpl) GetDevice(deviceID string) (*models.Device, error) {
+	return id.iot.getDevice(deviceID)
+}
+
+func (id *IDeviceImpl) ListDevices(query *models.DeviceQuery) (*models.DevicePage, error) {
+	return id.iot.listDevices(query)
+}
+
+func (id *IDeviceImpl) UpdateDevice(deviceID string, input *models.Device, origin models.ChangeOrigin) (*models.Device, error) {
+	return id.iot.updateDevice(deviceID, input, origin)
+}
+
+func (id *IDeviceImpl) DeleteDevice(deviceID string) error {
+	return id.iot.deleteDevice(deviceID)
+}
+
+func (id *IDeviceImpl) CheckDevice(deviceID string) error {
+	return id.iot.checkDevice(deviceID)
+}
+
+func (id *IDeviceImpl) MarkDeviceSeen(deviceID string, seen time.Time) error {
+	return id.iot.markDeviceSeen(deviceID, seen)
+}
+
+func (i *IOT) GetIDevice() IDevice {
+	return &IDeviceImpl{iot: i}
+}
@@ -0,0 +1,209 @@
+package iot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+func TestValidateDevice(t *testing.T) {
+	assert.NoError(t, ValidateDevice(&models.Device{ID: "device-1"}))
+	assert.NoError(t, ValidateDevice(&models.Device{ID: "device-1", Tags: []string{"building-1", "floor:3", "Zone_A"}}))
+
+	for _, device := range []models.Device{
+		{ID: ""},
+		{ID: strings.Repeat("d", MaxDeviceIDLength+1)},
+		{ID: "device-1", Tags: []string{""}},
+		{ID: "device-1", Tags: []string{"-lead"}},
+		{ID: "device-1", Tags: []string{"with space"}},
+		{ID: "device-1", Tags: []string{"a", "b", "a"}},
+		{ID: "device-1", Tags: make([]string, MaxDeviceTags+1)},
+	} {
+		assert.ErrorIs(t, ValidateDevice(&device), ErrInvalidDevice, "expected %+v to be invalid", device)
+	}
+}
+
+func TestDeviceCRUD(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	device, err := iotObj.Device.CreateDevice(&models.Device{
+		ID:       deviceID,
+		Name:     "Boiler room",
+		Model:    "TH-100",
+		Firmware: "1.2.0",
+		Location: "building-1",
+		Tags:     []string{"heating", "building-1"},
+		Enabled:  true,
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, deviceID, device.ID)
+	assert.Equal(t, "TH-100", device.Model)
+	assert.Equal(t, []string{"building-1", "heating"}, device.Tags)
+	assert.True(t, device.Enabled)
+	assert.False(t, device.CreatedAt.IsZero())
+	assert.Nil(t, device.LastSeenAt)
+
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID}, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrDeviceExists)
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: uuid.NewString(), Tags: []string{"bad tag"}}, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrInvalidDevice)
+
+	updated, err := iotObj.Device.UpdateDevice(deviceID, &models.Device{
+		Name:     "Boiler room",
+		Model:    "TH-100",
+		Firmware: "1.3.0",
+		Tags:     []string{"heating"},
+		Enabled:  false,
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, "1.3.0", updated.Firmware)
+	assert.Empty(t, updated.Location)
+	assert.Equal(t, []string{"heating"}, updated.Tags)
+	assert.False(t, updated.Enabled)
+	assert.True(t, device.CreatedAt.Equal(updated.CreatedAt))
+
+	got, err := iotObj.Device.GetDevice(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, updated.Tags, got.Tags)
+	assert.False(t, got.Enabled)
+
+	_, err = iotObj.Device.GetDevice(uuid.NewString())
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	_, err = iotObj.Device.UpdateDevice(uuid.NewString(), &models.Device{}, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+
+	require.NoError(t, iotObj.Device.DeleteDevice(deviceID))
+	assert.ErrorIs(t, iotObj.Device.DeleteDevice(deviceID), ErrDeviceNotFound)
+	_, err = iotObj.Device.GetDevice(deviceID)
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+}
+
+func TestCreateDevice_Config(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	origin := models.ChangeOrigin{Actor: "alice", Source: models.ChangeSourceHTTP}
+
+	// registering creates the config through the history, tagged or not
+	deviceID, taggedID := uuid.NewString(), uuid.NewString()
+	_, err := iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Enabled: true}, origin)
+	require.NoError(t, err)
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: taggedID, Tags: []string{"heating"}, Enabled: true}, origin)
+	require.NoError(t, err)
+
+	for _, id := range []string{deviceID, taggedID} {
+		config, err := iotObj.Config.GetDeviceConfig(id)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), config.Version)
+		assert.Nil(t, config.TemperatureThreshold)
+
+		history, err := iotObj.Config.GetConfigHistory(id, nil)
+		require.NoError(t, err)
+		require.Len(t, history.Changes, 1)
+		assert.Nil(t, history.Changes[0].Old)
+		assert.Equal(t, origin, history.Changes[0].ChangeOrigin)
+	}
+
+	// a config there already is stays as it was
+	configuredID := uuid.NewString()
+	err = iotObj.Config.UpsertConfig(configuredID, &models.Config{TemperatureThreshold: ptr(30.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: configuredID, Enabled: true}, origin)
+	require.NoError(t, err)
+	config, err := iotObj.Config.GetDeviceConfig(configuredID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), config.Version)
+	assert.Equal(t, ptr(30.0), config.TemperatureThreshold)
+}
+
+func TestListDevices(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	prefix := "fleet_" + uuid.NewString() + "/"
+	for idx, tags := range [][]string{{"a"}, {"a", "b"}, {"b"}} {
+		_, err := iotObj.Device.CreateDevice(&models.Device{
+			ID:      prefix + string(rune('1'+idx)),
+			Tags:    tags,
+			Enabled: idx != 2,
+		}, models.ChangeOrigin{})
+		require.NoError(t, err)
+	}
+
+	page, err := iotObj.Device.ListDevices(&models.DeviceQuery{Prefix: prefix, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Devices, 2)
+	assert.Equal(t, prefix+"1", page.Devices[0].ID)
+	assert.Equal(t, []string{"a", "b"}, page.Devices[1].Tags)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = iotObj.Device.ListDevices(&models.DeviceQuery{Prefix: prefix, Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Devices, 1)
+	assert.Equal(t, prefix+"3", page.Devices[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = iotObj.Device.ListDevices(&models.DeviceQuery{Prefix: prefix, Tag: "b"})
+	require.NoError(t, err)
+	assert.Len(t, page.Devices, 2)
+
+	enabled := false
+	page, err = iotObj.Device.ListDevices(&models.DeviceQuery{Prefix: prefix, Enabled: &enabled})
+	require.NoError(t, err)
+	require.Len(t, page.Devices, 1)
+	assert.Equal(t, prefix+"3", page.Devices[0].ID)
+
+	_, err = iotObj.Device.ListDevices(&models.DeviceQuery{Cursor: "!"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestUpsertMetric_RequireRegisteredDevices(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	iotObj.RequireRegisteredDevices = true
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	metric := models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0}
+
+	assert.ErrorIs(t, iotObj.Metric.UpsertMetric(deviceID, &metric), ErrDeviceNotRegistered)
+	assert.ErrorIs(t, iotObj.Metric.UpsertMetrics(deviceID, []models.Metric{metric}), ErrDeviceNotRegistered)
+
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Enabled: false}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, iotObj.Metric.UpsertMetric(deviceID, &metric), ErrDeviceDisabled)
+
+	_, err = iotObj.Device.UpdateDevice(deviceID, &models.Device{Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.NoError(t, iotObj.Metric.UpsertMetric(deviceID, &metric))
+	assert.NoError(t, iotObj.Metric.UpsertMetrics(deviceID, []models.Metric{metric}))
+
+	// without the option unregistered devices post as before
+	iotObj.RequireRegisteredDevices = false
+	otherID := uuid.NewString()
+	err = iotObj.Config.UpsertConfig(otherID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.NoError(t, iotObj.Metric.UpsertMetric(otherID, &metric))
+}
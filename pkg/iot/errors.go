@@ -0,0 +1,29 @@
+package iot
+
+import "errors"
+
+var (
+	ErrInvalidConfig   = errors.New("invalid config")
+	ErrConfigNotFound  = errors.New("config not found")
+	ErrConfigInUse     = errors.New("config in use")
+	ErrConfigConflict  = errors.New("config version conflict")
+	ErrGroupNotFound   = errors.New("group not found")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrInvalidInterval = errors.New("invalid interval")
+	ErrInvalidMetric   = errors.New("invalid metric")
+	ErrIngestQueueFull = errors.New("ingest queue full")
+	ErrIngestClosed    = errors.New("ingest closed")
+	ErrInvalidRule     = errors.New("invalid rule")
+	ErrRuleNotFound    = errors.New("rule not found")
+
+	ErrStoreUnsupported = errors.New("store unsupported")
+
+	ErrAlertNotFound       = errors.New("alert not found")
+	ErrAlertStatusConflict = errors.New("alert status conflict")
+
+	ErrInvalidDevice       = errors.New("invalid device")
+	ErrDeviceNotFound      = errors.New("device not found")
+	ErrDeviceExists        = errors.New("device already exists")
+	ErrDeviceNotRegistered = errors.New("device not registered")
+	ErrDeviceDisabled      = errors.New("device disabled")
+)
@@ -0,0 +1,348 @@
+package iot
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+// ValidateConfigPatch checks the set fields of patch, hysteresis bands and the
+// heartbeat interval can not be negative
+func ValidateConfigPatch(patch *models.ConfigPatch) error {
+	if (patch.TemperatureHysteresis != nil && *patch.TemperatureHysteresis < 0) ||
+		(patch.BatteryHysteresis != nil && *patch.BatteryHysteresis < 0) {
+		return fmt.Errorf("%w: hysteresis can not be negative", ErrInvalidConfig)
+	}
+	if patch.HeartbeatInterval != nil && *patch.HeartbeatInterval < 0 {
+		return fmt.Errorf("%w: heartbeat interval can not be negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+func isEmptyConfigPatch(patch *models.ConfigPatch) bool {
+	return patch.TemperatureThreshold == nil && patch.BatteryThreshold == nil &&
+		patch.TemperatureHysteresis == nil && patch.BatteryHysteresis == nil &&
+		patch.HeartbeatInterval == nil
+}
+
+// configPatchColumns returns the config columns set by patch
+func configPatchColumns(patch *models.ConfigPatch) map[string]any {
+	columns := map[string]any{}
+	if patch.TemperatureThreshold != nil {
+		columns["temperature_threshold"] = *patch.TemperatureThreshold
+	}
+	if patch.BatteryThreshold != nil {
+		columns["battery_threshold"] = *patch.BatteryThreshold
+	}
+	if patch.TemperatureHysteresis != nil {
+		columns["temperature_hysteresis"] = *patch.TemperatureHysteresis
+	}
+	if patch.BatteryHysteresis != nil {
+		columns["battery_hysteresis"] = *patch.BatteryHysteresis
+	}
+	if patch.HeartbeatInterval != nil {
+		columns["heartbeat_interval"] = *patch.HeartbeatInterval
+	}
+	return columns
+}
+
+func applyConfigPatch(config *models.Config, patch *models.ConfigPatch) {
+	if patch.TemperatureThreshold != nil {
+		config.TemperatureThreshold = common.ClonePtr(patch.TemperatureThreshold)
+	}
+	if patch.BatteryThreshold != nil {
+		config.BatteryThreshold = common.ClonePtr(patch.BatteryThreshold)
+	}
+	if patch.TemperatureHysteresis != nil {
+		config.TemperatureHysteresis = common.ClonePtr(patch.TemperatureHysteresis)
+	}
+	if patch.BatteryHysteresis != nil {
+		config.BatteryHysteresis = common.ClonePtr(patch.BatteryHysteresis)
+	}
+	if patch.HeartbeatInterval != nil {
+		config.HeartbeatInterval = common.ClonePtr(patch.HeartbeatInterval)
+	}
+}
+
+func (i *IOT) upsertGroupConfig(tag string, input *models.GroupConfig) (*models.GroupConfig, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTConfig),
+	)
+
+	if !deviceTagPattern.MatchString(tag) {
+		return nil, fmt.Errorf("%w: tag %q must match %s", ErrInvalidConfig, tag, deviceTagPattern)
+	}
+	if err := ValidateConfigPatch(&input.ConfigPatch); err != nil {
+		return nil, err
+	}
+	for _, retention := range []*time.Duration{input.MetricRetention, input.AlertRetention} {
+		if retention != nil && *retention < 0 {
+			return nil, fmt.Errorf("%w: retention must not be negative", ErrInvalidConfig)
+		}
+	}
+
+	group := models.GroupConfig{
+		Tag:             tag,
+		Priority:        input.Priority,
+		ConfigPatch:     input.ConfigPatch,
+		MetricRetention: input.MetricRetention,
+		AlertRetention:  input.AlertRetention,
+	}
+
+	err := i.Db.Conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tag"}},
+		UpdateAll: true,
+	}).Create(&group).Error
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Upserted group config", zap.Reflect("group", group))
+
+	return &group, nil
+}
+
+func (i *IOT) getGroupConfig(tag string) (*models.GroupConfig, error) {
+	var group models.GroupConfig
+	err := i.Db.Conn.First(&group, "tag = ?", tag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (i *IOT) listGroupConfigs() ([]models.GroupConfig, error) {
+	groups := []models.GroupConfig{}
+	err := i.Db.Conn.Order("tag").Find(&groups).Error
+	return groups, err
+}
+
+// deleteGroupConfig removes the defaults of the group, the tags of the devices
+// are kept
+func (i *IOT) deleteGroupConfig(tag string) error {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTConfig),
+	)
+
+	result := i.Db.Conn.Where("tag = ?", tag).Delete(&models.GroupConfig{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGroupNotFound
+	}
+
+	logger.Info("Deleted group config", zap.String("tag", tag))
+
+	return nil
+}
+
+// updateConfigsByTag writes the set fields of patch into the config of every
+// device tagged with tag, devices without a config get one. It returns the
+// number of devices updated.
+func (i *IOT) updateConfigsByTag(tag string, patch *models.ConfigPatch, origin models.ChangeOrigin) (int, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTConfig),
+	)
+
+	if isEmptyConfigPatch(patch) {
+		return 0, fmt.Errorf("%w: no config field to update", ErrInvalidConfig)
+	}
+	if err := ValidateConfigPatch(patch); err != nil {
+		return 0, err
+	}
+
+	var deviceIDs []string
+	if err := i.Db.Conn.Model(&models.DeviceTag{}).Where("tag = ?", tag).Order("device_id").Pluck("device_id", &deviceIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(deviceIDs) == 0 {
+		return 0, nil
+	}
+
+	// devices without a config get one with the patch
+	_, err := i.Store.UpdateConfigs(deviceIDs, origin, func(deviceID string, current *models.Config) (*models.Config, error) {
+		if current == nil {
+			current = &models.Config{DeviceID: deviceID}
+		}
+		applyConfigPatch(current, patch)
+		return current, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Info("Updated configs by tag", zap.String("tag", tag), zap.Reflect("patch", patch), zap.Int("count", len(deviceIDs)))
+
+	return len(deviceIDs), nil
+}
+
+// resolveConfigs fills the unset fields of configs with the defaults of the
+// groups of each device, and tells where every value came from
+func (i *IOT) resolveConfigs(configs []models.Config) ([]models.ResolvedConfig, error) {
+	resolved := common.Mapper(configs, func(config models.Config) models.ResolvedConfig {
+		return models.ResolvedConfig{Config: config}
+	})
+	if len(configs) == 0 {
+		return resolved, nil
+	}
+
+	var byDevice map[string][]models.GroupConfig
+	if i.Group != nil {
+		ids := common.Mapper(configs, func(c models.Config) string { return c.DeviceID })
+		var err error
+		if byDevice, err = i.Group.GetDeviceGroups(ids); err != nil {
+			return nil, err
+		}
+	}
+
+	for idx := range resolved {
+		resolveConfig(&resolved[idx], byDevice[resolved[idx].Config.DeviceID])
+	}
+
+	return resolved, nil
+}
+
+// getDeviceGroups returns the groups of each device which have a config, in
+// the order they apply: the higher priority first, then by tag
+func (i *IOT) getDeviceGroups(deviceIDs []string) (map[string][]models.GroupConfig, error) {
+	byDevice := map[string][]models.GroupConfig{}
+	if len(deviceIDs) == 0 {
+		return byDevice, nil
+	}
+
+	var tags []models.DeviceTag
+	if err := i.Db.Conn.Where("device_id IN ?", deviceIDs).Order("tag").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	groups := map[string]models.GroupConfig{}
+	if len(tags) > 0 {
+		var found []models.GroupConfig
+		names := common.Mapper(tags, func(t models.DeviceTag) string { return t.Tag })
+		if err := i.Db.Conn.Where("tag IN ?", names).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for _, group := range found {
+			groups[group.Tag] = group
+		}
+	}
+
+	for _, tag := range tags {
+		if group, ok := groups[tag.Tag]; ok {
+			byDevice[tag.DeviceID] = append(byDevice[tag.DeviceID], group)
+		}
+	}
+	for _, deviceGroups := range byDevice {
+		// stable, so groups of the same priority stay in tag order
+		slices.SortStableFunc(deviceGroups, func(a, b models.GroupConfig) int { return b.Priority - a.Priority })
+	}
+
+	return byDevice, nil
+}
+
+// resolveConfig fills the unset fields of resolved.Config from groups, the
+// first group setting a field wins. Fields no group sets stay unset.
+func resolveConfig(resolved *models.ResolvedConfig, groups []models.GroupConfig) {
+	config := &resolved.Config
+	sources := &resolved.Sources
+
+	resolveField(&config.TemperatureThreshold, &sources.TemperatureThreshold, groups,
+		func(p *models.ConfigPatch) *float64 { return p.TemperatureThreshold })
+	resolveField(&config.BatteryThreshold, &sources.BatteryThreshold, groups,
+		func(p *models.ConfigPatch) *float64 { return p.BatteryThreshold })
+	resolveField(&config.TemperatureHysteresis, &sources.TemperatureHysteresis, groups,
+		func(p *models.ConfigPatch) *float64 { return p.TemperatureHysteresis })
+	resolveField(&config.BatteryHysteresis, &sources.BatteryHysteresis, groups,
+		func(p *models.ConfigPatch) *float64 { return p.BatteryHysteresis })
+	resolveField(&config.HeartbeatInterval, &sources.HeartbeatInterval, groups,
+		func(p *models.ConfigPatch) *time.Duration { return p.HeartbeatInterval })
+}
+
+func resolveField[T float64 | time.Duration](value **T, source *string, groups []models.GroupConfig, field func(*models.ConfigPatch) *T) {
+	if *value != nil {
+		*source = models.ConfigSourceDevice
+		return
+	}
+	for _, group := range groups {
+		if v := field(&group.ConfigPatch); v != nil {
+			*value = common.ClonePtr(v)
+			*source = models.ConfigSourceGroupPrefix + group.Tag
+			return
+		}
+	}
+	*source = models.ConfigSourceDefault
+}
+
+// resolveDeviceConfig resolves the config of one device. A registered device
+// without a config of its own still gets the defaults of its groups.
+func (i *IOT) resolveDeviceConfig(deviceID string) (*models.ResolvedConfig, error) {
+	config := models.Config{DeviceID: deviceID}
+	stored, err := i.Store.GetConfig(deviceID)
+	switch {
+	case errors.Is(err, ErrConfigNotFound):
+		if i.Device == nil {
+			return nil, err
+		}
+		if _, err := i.Device.GetDevice(deviceID); errors.Is(err, ErrDeviceNotFound) {
+			return nil, ErrConfigNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		config = *stored
+	}
+
+	resolved, err := i.resolveConfigs([]models.Config{config})
+	if err != nil {
+		return nil, err
+	}
+	return &resolved[0], nil
+}
+
+type IGroupImpl struct {
+	iot *IOT
+}
+
+func (ig *IGroupImpl) UpsertGroupConfig(tag string, input *models.GroupConfig) (*models.GroupConfig, error) {
+	return ig.iot.upsertGroupConfig(tag, input)
+}
+
+func (ig *IGroupImpl) GetGroupConfig(tag string) (*models.GroupConfig, error) {
+	return ig.iot.getGroupConfig(tag)
+}
+
+func (ig *IGroupImpl) ListGroupConfigs() ([]models.GroupConfig, error) {
+	return ig.iot.listGroupConfigs()
+}
+
+func (ig *IGroupImpl) DeleteGroupConfig(tag string) error {
+	return ig.iot.deleteGroupConfig(tag)
+}
+
+func (ig *IGroupImpl) GetDeviceGroups(deviceIDs []string) (map[string][]models.GroupConfig, error) {
+	return ig.iot.getDeviceGroups(deviceIDs)
+}
+
+func (ig *IGroupImpl) UpdateConfigsByTag(tag string, patch *models.ConfigPatch, origin models.ChangeOrigin) (int, error) {
+	return ig.iot.updateConfigsByTag(tag, patch, origin)
+}
+
+func (i *IOT) GetIGroup() IGroup {
+	return &IGroupImpl{iot: i}
+}
@@ -0,0 +1,240 @@
+package iot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestGroupConfigCRUD(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	tag := "g-" + uuid.NewString()
+
+	group, err := iotObj.Group.UpsertGroupConfig(tag, &models.GroupConfig{
+		Priority:    1,
+		ConfigPatch: models.ConfigPatch{BatteryThreshold: ptr(15.0)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tag, group.Tag)
+
+	group, err = iotObj.Group.UpsertGroupConfig(tag, &models.GroupConfig{
+		ConfigPatch: models.ConfigPatch{HeartbeatInterval: ptr(time.Minute)},
+	})
+	require.NoError(t, err)
+
+	got, err := iotObj.Group.GetGroupConfig(tag)
+	require.NoError(t, err)
+	// an upsert replaces all defaults of the group
+	assert.Nil(t, got.BatteryThreshold)
+	assert.Equal(t, time.Minute, *got.HeartbeatInterval)
+	assert.Zero(t, got.Priority)
+
+	groups, err := iotObj.Group.ListGroupConfigs()
+	require.NoError(t, err)
+	assert.Contains(t, common.Mapper(groups, func(g models.GroupConfig) string { return g.Tag }), tag)
+
+	require.NoError(t, iotObj.Group.DeleteGroupConfig(tag))
+	assert.ErrorIs(t, iotObj.Group.DeleteGroupConfig(tag), ErrGroupNotFound)
+	_, err = iotObj.Group.GetGroupConfig(tag)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+
+	_, err = iotObj.Group.UpsertGroupConfig("bad tag", &models.GroupConfig{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = iotObj.Group.UpsertGroupConfig(tag, &models.GroupConfig{ConfigPatch: models.ConfigPatch{BatteryHysteresis: ptr(-1.0)}})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestResolveConfig(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	tagA, tagB := "a-"+uuid.NewString(), "b-"+uuid.NewString()
+	_, err := iotObj.Group.UpsertGroupConfig(tagA, &models.GroupConfig{
+		ConfigPatch: models.ConfigPatch{
+			TemperatureThreshold:  ptr(50.0),
+			BatteryThreshold:      ptr(15.0),
+			TemperatureHysteresis: ptr(1.0),
+		},
+	})
+	require.NoError(t, err)
+	_, err = iotObj.Group.UpsertGroupConfig(tagB, &models.GroupConfig{
+		Priority: 5,
+		ConfigPatch: models.ConfigPatch{
+			BatteryThreshold:  ptr(10.0),
+			HeartbeatInterval: ptr(5 * time.Minute),
+		},
+	})
+	require.NoError(t, err)
+
+	deviceID := uuid.NewString()
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Tags: []string{tagA, tagB}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	// registered without a config of its own, all from the groups
+	resolved, err := iotObj.Config.ResolveConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, ptr(50.0), resolved.Config.TemperatureThreshold)
+	assert.Equal(t, models.ConfigSourceGroupPrefix+tagA, resolved.Sources.TemperatureThreshold)
+
+	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	resolved, err = iotObj.Config.ResolveConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, deviceID, resolved.Config.DeviceID)
+	assert.Equal(t, ptr(30.0), resolved.Config.TemperatureThreshold)
+	assert.Equal(t, models.ConfigSourceDevice, resolved.Sources.TemperatureThreshold)
+	// the higher priority wins
+	assert.Equal(t, ptr(10.0), resolved.Config.BatteryThreshold)
+	assert.Equal(t, models.ConfigSourceGroupPrefix+tagB, resolved.Sources.BatteryThreshold)
+	assert.Equal(t, ptr(1.0), resolved.Config.TemperatureHysteresis)
+	assert.Equal(t, models.ConfigSourceGroupPrefix+tagA, resolved.Sources.TemperatureHysteresis)
+	assert.Nil(t, resolved.Config.BatteryHysteresis)
+	assert.Equal(t, models.ConfigSourceDefault, resolved.Sources.BatteryHysteresis)
+	assert.Equal(t, ptr(5*time.Minute), resolved.Config.HeartbeatInterval)
+	assert.Equal(t, models.ConfigSourceGroupPrefix+tagB, resolved.Sources.HeartbeatInterval)
+
+	_, err = iotObj.Config.ResolveConfig(uuid.NewString())
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+}
+
+func TestUpdateConfigsByTag(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	tag := "g-" + uuid.NewString()
+	configured, unconfigured := uuid.NewString(), uuid.NewString()
+	for _, id := range []string{configured, unconfigured} {
+		_, err := iotObj.Device.CreateDevice(&models.Device{ID: id, Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+		require.NoError(t, err)
+	}
+	err := iotObj.Config.UpsertConfig(configured, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	count, err := iotObj.Group.UpdateConfigsByTag(tag, &models.ConfigPatch{BatteryThreshold: ptr(12.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	config, err := iotObj.Config.GetDeviceConfig(configured)
+	require.NoError(t, err)
+	assert.Equal(t, ptr(30.0), config.TemperatureThreshold)
+	assert.Equal(t, ptr(12.0), config.BatteryThreshold)
+
+	config, err = iotObj.Config.GetDeviceConfig(unconfigured)
+	require.NoError(t, err)
+	assert.Nil(t, config.TemperatureThreshold)
+	assert.Equal(t, ptr(12.0), config.BatteryThreshold)
+
+	count, err = iotObj.Group.UpdateConfigsByTag("g-"+uuid.NewString(), &models.ConfigPatch{BatteryThreshold: ptr(12.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	_, err = iotObj.Group.UpdateConfigsByTag(tag, &models.ConfigPatch{}, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = iotObj.Group.UpdateConfigsByTag(tag, &models.ConfigPatch{HeartbeatInterval: ptr(-time.Second)}, models.ChangeOrigin{})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestCheckAndStoreAlerts_GroupDefaults(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	tag := "g-" + uuid.NewString()
+	_, err := iotObj.Group.UpsertGroupConfig(tag, &models.GroupConfig{
+		ConfigPatch: models.ConfigPatch{BatteryThreshold: ptr(20.0)},
+	})
+	require.NoError(t, err)
+
+	// neither device sets a field of its config, one is tagged when
+	// registered and the other later
+	deviceID, laterID := uuid.NewString(), uuid.NewString()
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: laterID, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	_, err = iotObj.Device.UpdateDevice(laterID, &models.Device{Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	for _, id := range []string{deviceID, laterID} {
+		resolved, err := iotObj.Config.ResolveConfig(id)
+		require.NoError(t, err)
+		assert.Equal(t, models.ConfigSourceGroupPrefix+tag, resolved.Sources.BatteryThreshold)
+		assert.Equal(t, models.ConfigSourceDefault, resolved.Sources.TemperatureThreshold)
+
+		err = iotObj.Metric.UpsertMetric(id, &models.Metric{Timestamp: time.Now(), Temperature: 25.0, Battery: 10.0})
+		require.NoError(t, err)
+
+		// the temperature threshold is set by no one, so it raises nothing
+		page, err := iotObj.Alert.GetDeviceAlerts(id, nil)
+		require.NoError(t, err)
+		require.Len(t, page.Alerts, 1)
+		assert.Equal(t, models.AlertTypeBattery, page.Alerts[0].Type)
+	}
+
+	// a threshold of the device itself still wins over the group
+	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(20.0), BatteryThreshold: ptr(5.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 25.0, Battery: 50.0})
+	require.NoError(t, err)
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{State: models.AlertStateFiring})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertTypeTemperature, page.Alerts[0].Type)
+}
+
+func TestCheckAndStoreAlerts_ZeroThresholds(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	tag := "g-" + uuid.NewString()
+	_, err := iotObj.Group.UpsertGroupConfig(tag, &models.GroupConfig{
+		ConfigPatch: models.ConfigPatch{TemperatureThreshold: ptr(50.0)},
+	})
+	require.NoError(t, err)
+
+	deviceID := uuid.NewString()
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	// a threshold of zero set by the device overrides the group
+	_, err = iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{TemperatureThreshold: ptr(0.0)}, 0, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	resolved, err := iotObj.Config.ResolveConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, ptr(0.0), resolved.Config.TemperatureThreshold)
+	assert.Equal(t, models.ConfigSourceDevice, resolved.Sources.TemperatureThreshold)
+	assert.Nil(t, resolved.Config.BatteryThreshold)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 5.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertTypeTemperature, page.Alerts[0].Type)
+	assert.Equal(t, "Temperature 5.00 exceeded threshold 0.00", page.Alerts[0].Message)
+}
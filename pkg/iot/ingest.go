@@ -0,0 +1,275 @@
+package iot
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+// IngestOptions size the write-behind pipeline of metrics, zero values take
+// the defaults
+type IngestOptions struct {
+	// QueueSize bounds the metrics accepted but not written yet, metrics
+	// beyond it are refused with ErrIngestQueueFull
+	QueueSize int
+	Workers   int
+	// BatchSize metrics are inserted per statement at most, a worker
+	// flushes what it has every FlushInterval even when the batch is not
+	// full
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+func (o *IngestOptions) withDefaults() IngestOptions {
+	opts := *o
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 200
+	}
+	opts.BatchSize = min(opts.BatchSize, MaxMetricBatchSize)
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 100 * time.Millisecond
+	}
+	return opts
+}
+
+// IngestStats counts the metrics through the pipeline since it started,
+// Queued ones are accepted but not written yet
+type IngestStats struct {
+	Queued   int64
+	Accepted int64
+	Refused  int64
+	Written  int64
+	Failed   int64
+	Batches  int64
+}
+
+// ingestJob is the metrics of one device accepted by one call of Enqueue
+type ingestJob struct {
+	deviceID string
+	metrics  []models.Metric
+}
+
+// MetricIngester writes metrics behind the requests posting them. Accepted
+// metrics wait in a bounded queue, workers insert them in batches and then
+// check them for alerts like a synchronous write does. The metrics of a device
+// always go to the same worker, so they are checked in the order they came.
+type MetricIngester struct {
+	iot    *IOT
+	opts   IngestOptions
+	logger *zap.Logger
+
+	// queues has one queue per worker
+	queues  []chan ingestJob
+	wg      sync.WaitGroup
+	closing sync.Once
+	mu      sync.RWMutex
+	closed  bool
+
+	queued   atomic.Int64
+	accepted atomic.Int64
+	refused  atomic.Int64
+	written  atomic.Int64
+	failed   atomic.Int64
+	batches  atomic.Int64
+}
+
+func NewMetricIngester(i *IOT, opts IngestOptions) *MetricIngester {
+	opts = opts.withDefaults()
+
+	g := &MetricIngester{
+		iot:  i,
+		opts: opts,
+		logger: common.GetLoggerWith(
+			common.LoggerNameIOTCore,
+			zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTIngest),
+		),
+	}
+
+	for range opts.Workers {
+		// sends never block, the queued count keeps the queues below
+		// QueueSize in total
+		queue := make(chan ingestJob, opts.QueueSize)
+		g.queues = append(g.queues, queue)
+		g.wg.Add(1)
+		go g.work(queue)
+	}
+
+	return g
+}
+
+func (g *MetricIngester) Options() IngestOptions {
+	return g.opts
+}
+
+func (g *MetricIngester) Stats() IngestStats {
+	return IngestStats{
+		Queued:   g.queued.Load(),
+		Accepted: g.accepted.Load(),
+		Refused:  g.refused.Load(),
+		Written:  g.written.Load(),
+		Failed:   g.failed.Load(),
+		Batches:  g.batches.Load(),
+	}
+}
+
+// Enqueue accepts the metrics of a device all or none, it never blocks. It
+// returns ErrIngestQueueFull when they do not fit in the queue and
+// ErrIngestClosed after Close.
+func (g *MetricIngester) Enqueue(deviceID string, metrics []models.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.closed {
+		return ErrIngestClosed
+	}
+
+	count := int64(len(metrics))
+	if g.queued.Add(count) > int64(g.opts.QueueSize) {
+		g.queued.Add(-count)
+		g.refused.Add(count)
+		return ErrIngestQueueFull
+	}
+	g.accepted.Add(count)
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(deviceID))
+	g.queues[hash.Sum32()%uint32(len(g.queues))] <- ingestJob{deviceID: deviceID, metrics: metrics}
+	return nil
+}
+
+// Close stops taking metrics and waits until the queued ones are written
+func (g *MetricIngester) Close() {
+	g.closing.Do(func() {
+		g.mu.Lock()
+		g.closed = true
+		for _, queue := range g.queues {
+			close(queue)
+		}
+		g.mu.Unlock()
+
+		g.wg.Wait()
+	})
+}
+
+func (g *MetricIngester) work(queue chan ingestJob) {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(g.opts.FlushInterval)
+	defer ticker.Stop()
+
+	var pending []ingestJob
+	size := 0
+	for {
+		select {
+		case job, ok := <-queue:
+			if !ok {
+				g.flush(pending)
+				return
+			}
+			pending = append(pending, job)
+			size += len(job.metrics)
+			if size < g.opts.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+		}
+		g.flush(pending)
+		pending, size = nil, 0
+	}
+}
+
+// flush writes the metrics of jobs in batches of BatchSize. The metrics are
+// grouped by device first, keeping their order within each device, so the
+// metrics of a device are one run in a batch.
+func (g *MetricIngester) flush(jobs []ingestJob) {
+	var deviceIDs []string
+	byDevice := map[string][]models.Metric{}
+	for _, job := range jobs {
+		if _, ok := byDevice[job.deviceID]; !ok {
+			deviceIDs = append(deviceIDs, job.deviceID)
+		}
+		byDevice[job.deviceID] = append(byDevice[job.deviceID], job.metrics...)
+	}
+
+	var metrics []models.Metric
+	for _, deviceID := range deviceIDs {
+		metrics = append(metrics, byDevice[deviceID]...)
+	}
+	for start := 0; start < len(metrics); start += g.opts.BatchSize {
+		g.write(metrics[start:min(start+g.opts.BatchSize, len(metrics))])
+	}
+}
+
+// deviceRuns splits metrics grouped by device into the runs of each device,
+// the runs share the array of metrics
+func deviceRuns(metrics []models.Metric) [][]models.Metric {
+	var runs [][]models.Metric
+	start := 0
+	for idx := 1; idx <= len(metrics); idx++ {
+		if idx == len(metrics) || metrics[idx].DeviceID != metrics[start].DeviceID {
+			runs = append(runs, metrics[start:idx])
+			start = idx
+		}
+	}
+	return runs
+}
+
+// resetMetricIDs clears the IDs a failed insert may have left behind
+func resetMetricIDs(metrics []models.Metric) {
+	for idx := range metrics {
+		metrics[idx].ID = 0
+		for v := range metrics[idx].Values {
+			metrics[idx].Values[v].ID = 0
+			metrics[idx].Values[v].MetricID = 0
+		}
+	}
+}
+
+func (g *MetricIngester) write(metrics []models.Metric) {
+	defer g.queued.Add(-int64(len(metrics)))
+	g.batches.Add(1)
+
+	runs := deviceRuns(metrics)
+	stored := runs
+	if err := g.iot.Store.CreateMetrics(metrics); err != nil {
+		// write device by device, so one device failing does not lose the
+		// metrics of the others
+		g.logger.Warn("Failed to write metrics batch, writing per device", zap.Int("count", len(metrics)), zap.Error(err))
+		stored = nil
+		for _, run := range runs {
+			resetMetricIDs(run)
+			if err := g.iot.Store.CreateMetrics(run); err != nil {
+				g.logger.Error("Failed to write metrics of device", zap.String("device_id", run[0].DeviceID), zap.Error(err))
+				g.failed.Add(int64(len(run)))
+				continue
+			}
+			g.written.Add(int64(len(run)))
+			stored = append(stored, run)
+		}
+	} else {
+		g.written.Add(int64(len(metrics)))
+	}
+
+	for _, run := range stored {
+		if err := g.iot.checkStoredMetrics(run[0].DeviceID, run); err != nil {
+			g.logger.Error("Failed to check metrics of device", zap.String("device_id", run[0].DeviceID), zap.Error(err))
+		}
+	}
+}
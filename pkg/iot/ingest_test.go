@@ -0,0 +1,217 @@
+package iot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+// ingestTestStore blocks writes until gate is closed, when gate is set, and
+// fails every write holding a metric of failDeviceID
+type ingestTestStore struct {
+	Store
+	gate         chan struct{}
+	failDeviceID string
+}
+
+func (s *ingestTestStore) CreateMetrics(metrics []models.Metric) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	for _, metric := range metrics {
+		if metric.DeviceID == s.failDeviceID {
+			return errors.New("write failed")
+		}
+	}
+	return s.Store.CreateMetrics(metrics)
+}
+
+func countDeviceMetrics(t *testing.T, iotObj *IOT, deviceID string) int {
+	t.Helper()
+	page, err := iotObj.Metric.GetDeviceMetrics(deviceID, &models.MetricQuery{})
+	require.NoError(t, err)
+	return len(page.Metrics)
+}
+
+func TestMetricIngester_Drain(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID, otherID := uuid.NewString(), uuid.NewString()
+	for _, id := range []string{deviceID, otherID} {
+		err := iotObj.Config.UpsertConfig(id, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+		require.NoError(t, err)
+	}
+
+	// nothing is flushed by time, Close has to write what is queued
+	iotObj.Ingester = NewMetricIngester(iotObj, IngestOptions{BatchSize: 100, FlushInterval: time.Hour})
+
+	now := time.Now()
+	err := iotObj.Metric.UpsertMetrics(deviceID, []models.Metric{
+		{Timestamp: now.Add(-time.Minute), Temperature: 20.0, Battery: 50.0},
+		{Timestamp: now, Temperature: 35.0, Battery: 50.0},
+	})
+	require.NoError(t, err)
+	err = iotObj.Metric.UpsertMetric(otherID, &models.Metric{Timestamp: now, Temperature: 20.0, Battery: 10.0})
+	require.NoError(t, err)
+
+	// written behind the requests
+	assert.Equal(t, 0, countDeviceMetrics(t, iotObj, deviceID))
+
+	iotObj.Ingester.Close()
+
+	assert.Equal(t, 2, countDeviceMetrics(t, iotObj, deviceID))
+	assert.Equal(t, 1, countDeviceMetrics(t, iotObj, otherID))
+
+	stats := iotObj.Ingester.Stats()
+	assert.Equal(t, IngestStats{Accepted: 3, Written: 3, Batches: stats.Batches}, stats)
+	assert.NotZero(t, stats.Batches)
+
+	// the queued metrics are checked for alerts and mark the devices seen
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertTypeTemperature, page.Alerts[0].Type)
+	page, err = iotObj.Alert.GetDeviceAlerts(otherID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertTypeBattery, page.Alerts[0].Type)
+
+	config, err := iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	assert.NotNil(t, config.LastSeenAt)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: now, Temperature: 20.0, Battery: 50.0})
+	assert.ErrorIs(t, err, ErrIngestClosed)
+
+	// validation still happens before queueing
+	iotObj.Ingester = NewMetricIngester(iotObj, IngestOptions{})
+	defer iotObj.Ingester.Close()
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: now, Values: []models.MetricValue{{Name: ""}}})
+	assert.ErrorIs(t, err, ErrInvalidMetric)
+	assert.Zero(t, iotObj.Ingester.Stats().Accepted)
+}
+
+func TestMetricIngester_Flush(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	iotObj.Ingester = NewMetricIngester(iotObj, IngestOptions{Workers: 1, BatchSize: 2, FlushInterval: 20 * time.Millisecond})
+	defer iotObj.Ingester.Close()
+
+	now := time.Now()
+	// a full batch is written at once, the rest when the interval passes
+	err = iotObj.Metric.UpsertMetrics(deviceID, []models.Metric{
+		{Timestamp: now.Add(-2 * time.Second), Temperature: 20.0, Battery: 50.0},
+		{Timestamp: now.Add(-time.Second), Temperature: 20.0, Battery: 50.0},
+		{Timestamp: now, Temperature: 20.0, Battery: 50.0},
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return iotObj.Ingester.Stats().Written == 3
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 3, countDeviceMetrics(t, iotObj, deviceID))
+
+	stats := iotObj.Ingester.Stats()
+	assert.Equal(t, int64(2), stats.Batches)
+	assert.Zero(t, stats.Queued)
+}
+
+func TestMetricIngester_QueueFull(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	store := &ingestTestStore{Store: iotObj.Store, gate: make(chan struct{})}
+	iotObj.Store = store
+	iotObj.Ingester = NewMetricIngester(iotObj, IngestOptions{QueueSize: 3, Workers: 1, BatchSize: 1})
+	defer iotObj.Ingester.Close()
+
+	metric := models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0}
+	// the worker holds the first metric in a write until the gate opens, it
+	// still counts against the queue
+	require.NoError(t, iotObj.Metric.UpsertMetric(deviceID, &metric))
+	require.NoError(t, iotObj.Metric.UpsertMetric(deviceID, &metric))
+
+	// all or none, the batch does not fit
+	err = iotObj.Metric.UpsertMetrics(deviceID, []models.Metric{metric, metric})
+	assert.ErrorIs(t, err, ErrIngestQueueFull)
+	require.NoError(t, iotObj.Metric.UpsertMetric(deviceID, &metric))
+	assert.ErrorIs(t, iotObj.Metric.UpsertMetric(deviceID, &metric), ErrIngestQueueFull)
+
+	stats := iotObj.Ingester.Stats()
+	assert.Equal(t, int64(3), stats.Queued)
+	assert.Equal(t, int64(3), stats.Accepted)
+	assert.Equal(t, int64(3), stats.Refused)
+
+	close(store.gate)
+	iotObj.Ingester.Close()
+
+	stats = iotObj.Ingester.Stats()
+	assert.Zero(t, stats.Queued)
+	assert.Equal(t, int64(3), stats.Written)
+	assert.Equal(t, 3, countDeviceMetrics(t, iotObj, deviceID))
+}
+
+func TestMetricIngester_BatchFailure(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID, failingID := uuid.NewString(), uuid.NewString()
+	for _, id := range []string{deviceID, failingID} {
+		err := iotObj.Config.UpsertConfig(id, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+		require.NoError(t, err)
+	}
+
+	iotObj.Store = &ingestTestStore{Store: iotObj.Store, failDeviceID: failingID}
+	iotObj.Ingester = NewMetricIngester(iotObj, IngestOptions{Workers: 1, FlushInterval: time.Hour})
+
+	now := time.Now()
+	metrics := []models.Metric{
+		{Timestamp: now.Add(-time.Second), Temperature: 35.0, Battery: 50.0},
+		{Timestamp: now, Temperature: 35.0, Battery: 50.0},
+	}
+	require.NoError(t, iotObj.Metric.UpsertMetrics(deviceID, metrics))
+	require.NoError(t, iotObj.Metric.UpsertMetrics(failingID, metrics))
+	iotObj.Ingester.Close()
+
+	// the batch holding both devices fails, the device of its own is written
+	stats := iotObj.Ingester.Stats()
+	assert.Equal(t, int64(2), stats.Written)
+	assert.Equal(t, int64(2), stats.Failed)
+	assert.Equal(t, int64(1), stats.Batches)
+	assert.Equal(t, 2, countDeviceMetrics(t, iotObj, deviceID))
+	assert.Equal(t, 0, countDeviceMetrics(t, iotObj, failingID))
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, 2, page.Alerts[0].Count)
+	page, err = iotObj.Alert.GetDeviceAlerts(failingID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Alerts)
+}
@@ -1,39 +1,110 @@
 package iot
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"liyu1981.xyz/iot-metrics-service/pkg/db"
 	"liyu1981.xyz/iot-metrics-service/pkg/models"
 )
 
 type IMetric interface {
 	UpsertMetric(deviceID string, input *models.Metric) error
+	UpsertMetrics(deviceID string, inputs []models.Metric) error
+	GetDeviceMetrics(deviceID string, query *models.MetricQuery) (*models.MetricPage, error)
+	AggregateDeviceMetrics(deviceID string, query *models.MetricAggregateQuery) ([]models.MetricBucket, error)
 }
 
 type IAlert interface {
 	CheckAndStoreAlerts(deviceID string, metric *models.Metric) error
 	UpsertAlert(data *models.Alert) error
-	GetDeviceAlerts(deviceID string) ([]models.Alert, error)
+	GetDeviceAlerts(deviceID string, query *models.AlertQuery) (*models.AlertPage, error)
+	ListAlerts(query *models.FleetAlertQuery) (*models.AlertPage, error)
+	SummarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error)
+	AcknowledgeAlert(deviceID string, id uint, by string, note string) (*models.Alert, error)
+	ResolveAlert(deviceID string, id uint, by string, note string) (*models.Alert, error)
+}
+
+type IDevice interface {
+	CreateDevice(input *models.Device, origin models.ChangeOrigin) (*models.Device, error)
+	GetDevice(deviceID string) (*models.Device, error)
+	ListDevices(query *models.DeviceQuery) (*models.DevicePage, error)
+	UpdateDevice(deviceID string, input *models.Device, origin models.ChangeOrigin) (*models.Device, error)
+	DeleteDevice(deviceID string) error
+	CheckDevice(deviceID string) error
+	MarkDeviceSeen(deviceID string, seen time.Time) error
+}
+
+type IRule interface {
+	CreateRule(deviceID string, input *models.AlertRule) (*models.AlertRule, error)
+	GetDeviceRules(deviceID string) ([]models.AlertRule, error)
+	GetDeviceRule(deviceID string, id uint) (*models.AlertRule, error)
+	UpdateRule(deviceID string, id uint, input *models.AlertRule) (*models.AlertRule, error)
+	DeleteRule(deviceID string, id uint) error
 }
 
 type IConfig interface {
-	UpsertConfig(deviceID string, input *models.Config) error
+	UpsertConfig(deviceID string, input *models.Config, origin models.ChangeOrigin) error
+	PatchConfig(deviceID string, patch *models.ConfigPatch, expectedVersion uint64, origin models.ChangeOrigin) (*models.Config, error)
 	GetDeviceConfig(deviceID string) (*models.Config, error)
+	DeleteConfig(deviceID string, origin models.ChangeOrigin) error
+	GetConfigHistory(deviceID string, query *models.ConfigChangeQuery) (*models.ConfigChangePage, error)
+	ResolveConfig(deviceID string) (*models.ResolvedConfig, error)
+}
+
+type IGroup interface {
+	UpsertGroupConfig(tag string, input *models.GroupConfig) (*models.GroupConfig, error)
+	GetGroupConfig(tag string) (*models.GroupConfig, error)
+	ListGroupConfigs() ([]models.GroupConfig, error)
+	DeleteGroupConfig(tag string) error
+	GetDeviceGroups(deviceIDs []string) (map[string][]models.GroupConfig, error)
+	UpdateConfigsByTag(tag string, patch *models.ConfigPatch, origin models.ChangeOrigin) (int, error)
 }
 
 type IOT struct {
-	Db     db.DB
+	Db db.DB
+	// Store keeps metrics, alerts and configs, WithServices defaults it to
+	// the GormStore of Db
+	Store  Store
 	Metric IMetric
 	Alert  IAlert
 	Config IConfig
+	Rule   IRule
+	Device IDevice
+	Group  IGroup
+
+	// RequireRegisteredDevices rejects metrics of devices which are not in
+	// the registry or are disabled there
+	RequireRegisteredDevices bool
+
+	// Broker is optional, when set every stored alert is published to it
+	Broker *AlertBroker
+	// Notifier is optional, when set every stored alert is sent to its webhooks
+	Notifier *WebhookNotifier
+	// Retention is optional, when set its policy and stats are served
+	Retention *RetentionPruner
+	// Ingester is optional, when set metrics are written behind the requests
+	// posting them
+	Ingester *MetricIngester
 }
 
 type ServiceOpts struct {
 	Metric IMetric
 	Alert  IAlert
 	Config IConfig
+	Rule   IRule
+	Device IDevice
+	Group  IGroup
 }
 
-func (i *IOT) WithServices(opts ServiceOpts) *IOT {
+// WithServices sets the services of opts which are not nil and defaults Store
+// to the GormStore of Db. Only metrics, alerts and configs go through Store,
+// the rule, device and group services of this package, the webhook notifier
+// and the retention pruner work on the tables of Db directly. It returns
+// ErrStoreUnsupported when any of them is set along with another Store, they
+// would not see its data.
+func (i *IOT) WithServices(opts ServiceOpts) (*IOT, error) {
 	if opts.Metric != nil {
 		i.Metric = opts.Metric
 	}
@@ -43,5 +114,50 @@ func (i *IOT) WithServices(opts ServiceOpts) *IOT {
 	if opts.Config != nil {
 		i.Config = opts.Config
 	}
-	return i
+	if opts.Rule != nil {
+		i.Rule = opts.Rule
+	}
+	if opts.Device != nil {
+		i.Device = opts.Device
+	}
+	if opts.Group != nil {
+		i.Group = opts.Group
+	}
+	if i.Store == nil && i.Db.Conn != nil {
+		i.Store = NewGormStore(i.Db.Conn)
+	}
+
+	var direct []string
+	if _, ok := i.Rule.(*IRuleImpl); ok {
+		direct = append(direct, "rule service")
+	}
+	if _, ok := i.Device.(*IDeviceImpl); ok {
+		direct = append(direct, "device service")
+	}
+	if _, ok := i.Group.(*IGroupImpl); ok {
+		direct = append(direct, "group service")
+	}
+	if i.Notifier != nil {
+		direct = append(direct, "webhook notifier")
+	}
+	if i.Retention != nil {
+		direct = append(direct, "retention pruner")
+	}
+	if err := i.requireGormStore(direct...); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// requireGormStore fails when Store keeps its data elsewhere than the tables
+// of Db, which users work on directly
+func (i *IOT) requireGormStore(users ...string) error {
+	if len(users) == 0 || i.Store == nil {
+		return nil
+	}
+	if _, ok := i.Store.(*GormStore); ok {
+		return nil
+	}
+	return fmt.Errorf("%w: %s need a GormStore, not %T", ErrStoreUnsupported, strings.Join(users, ", "), i.Store)
 }
@@ -2,41 +2,228 @@ package iot
 
 import (
 	"fmt"
+	"regexp"
+	"time"
 
 	"go.uber.org/zap"
 	"liyu1981.xyz/iot-metrics-service/pkg/common"
 	"liyu1981.xyz/iot-metrics-service/pkg/models"
 )
 
+var metricValueNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+const maxMetricValueUnitLength = 16
+
+// ValidateMetricValues checks the named series of one metric: names are lower
+// snake case, unique, and can not shadow temperature or battery
+func ValidateMetricValues(values []models.MetricValue) error {
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if !metricValueNamePattern.MatchString(v.Name) {
+			return fmt.Errorf("%w: series name %q must match %s", ErrInvalidMetric, v.Name, metricValueNamePattern)
+		}
+		if v.Name == models.MetricNameTemperature || v.Name == models.MetricNameBattery {
+			return fmt.Errorf("%w: series name %q is reserved", ErrInvalidMetric, v.Name)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("%w: duplicated series name %q", ErrInvalidMetric, v.Name)
+		}
+		if len(v.Unit) > maxMetricValueUnitLength {
+			return fmt.Errorf("%w: unit of series %q is longer than %d", ErrInvalidMetric, v.Name, maxMetricValueUnitLength)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+func copyMetricValues(values []models.MetricValue) []models.MetricValue {
+	return common.Mapper(values, func(v models.MetricValue) models.MetricValue {
+		return models.MetricValue{Name: v.Name, Value: v.Value, Unit: v.Unit}
+	})
+}
+
 func (i *IOT) upsertMetric(deviceID string, input *models.Metric) error {
 	logger := common.GetLoggerWith(
 		common.LoggerNameIOTCore,
 		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTMetric),
 	)
 
+	if err := i.admitMetrics(deviceID); err != nil {
+		return err
+	}
+
+	if err := ValidateMetricValues(input.Values); err != nil {
+		return err
+	}
+
+	// timestamps are compared as text by sqlite, so keep them all in UTC
 	metric := models.Metric{
 		DeviceID:    deviceID,
-		Timestamp:   input.Timestamp,
+		Timestamp:   input.Timestamp.UTC(),
 		Temperature: input.Temperature,
 		Battery:     input.Battery,
+		Values:      copyMetricValues(input.Values),
 	}
 
 	logger.Info("Received metric for device", zap.Reflect("metric", metric))
 
-	if err := i.Db.Conn.Create(&metric).Error; err != nil {
+	metrics := []models.Metric{metric}
+	if i.Ingester != nil {
+		return i.Ingester.Enqueue(deviceID, metrics)
+	}
+
+	if err := i.Store.CreateMetrics(metrics); err != nil {
 		return err
 	}
 
-	logger.Info("Upserted metric for device,", zap.Reflect("metric", metric))
+	logger.Info("Upserted metric for device,", zap.Reflect("metric", metrics[0]))
+
+	return i.checkStoredMetrics(deviceID, metrics)
+}
+
+// checkStoredMetrics marks the device seen and checks its stored metrics for
+// alerts, in order
+func (i *IOT) checkStoredMetrics(deviceID string, metrics []models.Metric) error {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTAlert),
+	)
+
+	if err := i.markSeen(deviceID, time.Now().UTC()); err != nil {
+		return err
+	}
 
 	if i.Alert == nil {
 		return fmt.Errorf("alert service not available")
 	}
 
-	i.Alert.CheckAndStoreAlerts(deviceID, &metric)
+	// the metrics are stored, failing alert checks are only logged
+	for idx := range metrics {
+		if err := i.Alert.CheckAndStoreAlerts(deviceID, &metrics[idx]); err != nil {
+			logger.Error("Failed to check alerts", zap.String("device_id", deviceID), zap.Error(err))
+		}
+	}
 	return nil
 }
 
+// MaxMetricBatchSize caps how many metrics can be inserted in one transaction
+const MaxMetricBatchSize = 500
+
+func (i *IOT) upsertMetrics(deviceID string, inputs []models.Metric) error {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTMetric),
+	)
+
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	if len(inputs) > MaxMetricBatchSize {
+		return fmt.Errorf("batch of %d metrics exceeds max size %d", len(inputs), MaxMetricBatchSize)
+	}
+
+	if err := i.admitMetrics(deviceID); err != nil {
+		return err
+	}
+
+	for idx := range inputs {
+		if err := ValidateMetricValues(inputs[idx].Values); err != nil {
+			return fmt.Errorf("metric %d: %w", idx, err)
+		}
+	}
+
+	metrics := common.Mapper(inputs, func(input models.Metric) models.Metric {
+		return models.Metric{
+			DeviceID:    deviceID,
+			Timestamp:   input.Timestamp.UTC(),
+			Temperature: input.Temperature,
+			Battery:     input.Battery,
+			Values:      copyMetricValues(input.Values),
+		}
+	})
+
+	logger.Info("Received metrics batch for device", zap.String("device_id", deviceID), zap.Int("count", len(metrics)))
+
+	if i.Ingester != nil {
+		return i.Ingester.Enqueue(deviceID, metrics)
+	}
+
+	if err := i.Store.CreateMetrics(metrics); err != nil {
+		return err
+	}
+
+	logger.Info("Upserted metrics batch for device", zap.String("device_id", deviceID), zap.Int("count", len(metrics)))
+
+	return i.checkStoredMetrics(deviceID, metrics)
+}
+
+func (i *IOT) getDeviceMetrics(deviceID string, query *models.MetricQuery) (*models.MetricPage, error) {
+	return i.Store.FindMetrics(deviceID, query)
+}
+
+// MinAggregateInterval keeps a single aggregate query from producing one bucket
+// per raw row
+const MinAggregateInterval = time.Minute
+
+func (i *IOT) aggregateDeviceMetrics(deviceID string, query *models.MetricAggregateQuery) ([]models.MetricBucket, error) {
+	if query.Interval < MinAggregateInterval {
+		return nil, ErrInvalidInterval
+	}
+	return i.Store.AggregateMetrics(deviceID, query)
+}
+
+// bucketMetrics aggregates metrics sorted by time into buckets of interval
+func bucketMetrics(interval time.Duration, next func() (*models.Metric, error)) ([]models.MetricBucket, error) {
+	buckets := []models.MetricBucket{}
+	var current *models.MetricBucket
+	for {
+		metric, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if metric == nil {
+			break
+		}
+
+		start := metric.Timestamp.UTC().Truncate(interval)
+		if current == nil || !current.Start.Equal(start) {
+			if current != nil {
+				buckets = append(buckets, finishBucket(current))
+			}
+			current = &models.MetricBucket{
+				Start:       start,
+				Temperature: models.MetricStats{Min: metric.Temperature, Max: metric.Temperature},
+				Battery:     models.MetricStats{Min: metric.Battery, Max: metric.Battery},
+			}
+		}
+
+		current.Count++
+		accumulateStats(&current.Temperature, metric.Temperature)
+		accumulateStats(&current.Battery, metric.Battery)
+	}
+
+	if current != nil {
+		buckets = append(buckets, finishBucket(current))
+	}
+
+	return buckets, nil
+}
+
+// accumulateStats keeps the running sum in Avg until finishBucket divides it
+func accumulateStats(stats *models.MetricStats, value float64) {
+	stats.Min = min(stats.Min, value)
+	stats.Max = max(stats.Max, value)
+	stats.Avg += value
+	stats.Last = value
+}
+
+func finishBucket(bucket *models.MetricBucket) models.MetricBucket {
+	bucket.Temperature.Avg /= float64(bucket.Count)
+	bucket.Battery.Avg /= float64(bucket.Count)
+	return *bucket
+}
+
 type IMetricImpl struct {
 	iot *IOT
 }
@@ -45,6 +232,18 @@ func (im *IMetricImpl) UpsertMetric(deviceID string, input *models.Metric) error
 	return im.iot.upsertMetric(deviceID, input)
 }
 
+func (im *IMetricImpl) UpsertMetrics(deviceID string, inputs []models.Metric) error {
+	return im.iot.upsertMetrics(deviceID, inputs)
+}
+
+func (im *IMetricImpl) GetDeviceMetrics(deviceID string, query *models.MetricQuery) (*models.MetricPage, error) {
+	return im.iot.getDeviceMetrics(deviceID, query)
+}
+
+func (im *IMetricImpl) AggregateDeviceMetrics(deviceID string, query *models.MetricAggregateQuery) ([]models.MetricBucket, error) {
+	return im.iot.aggregateDeviceMetrics(deviceID, query)
+}
+
 func (i *IOT) GetIMetric() IMetric {
 	return &IMetricImpl{iot: i}
 }
@@ -25,9 +25,9 @@ func TestUpsertMetric(t *testing.T) {
 	var err error
 	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: 30.0,
-		BatteryThreshold:     50.0,
-	})
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
+	}, models.ChangeOrigin{})
 	assert.NoError(t, err)
 
 	// Expect the alert checker to be called with correct args
@@ -71,9 +71,9 @@ func TestUpsertMetric_EdgeCases(t *testing.T) {
 
 	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{
 		DeviceID:             deviceID,
-		TemperatureThreshold: 30.0,
-		BatteryThreshold:     50.0,
-	})
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
+	}, models.ChangeOrigin{})
 	assert.NoError(t, err)
 
 	// force the alert service to be nil to cause alert not avaialable
@@ -82,3 +82,282 @@ func TestUpsertMetric_EdgeCases(t *testing.T) {
 	err = iotObj.Metric.UpsertMetric(deviceID, input)
 	require.Error(t, err, "alert service not available")
 }
+
+func TestGetDeviceMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{
+			Timestamp:   base.Add(time.Duration(i) * time.Minute),
+			Temperature: float64(20 + i),
+			Battery:     50.0,
+		})
+		require.NoError(t, err)
+	}
+
+	{
+		// default order is newest first, walk all pages with limit 2
+		var temperatures []float64
+		cursor := ""
+		pages := 0
+		for {
+			page, err := iotObj.Metric.GetDeviceMetrics(deviceID, &models.MetricQuery{Limit: 2, Cursor: cursor})
+			require.NoError(t, err)
+			pages++
+			for _, m := range page.Metrics {
+				temperatures = append(temperatures, m.Temperature)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		assert.Equal(t, 3, pages)
+		assert.Equal(t, []float64{24, 23, 22, 21, 20}, temperatures)
+	}
+
+	{
+		// ascending order within a time range
+		from := base.Add(1 * time.Minute)
+		to := base.Add(3 * time.Minute)
+		page, err := iotObj.Metric.GetDeviceMetrics(deviceID, &models.MetricQuery{
+			From:  &from,
+			To:    &to,
+			Order: models.SortOrderAsc,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, page.NextCursor)
+		require.Len(t, page.Metrics, 3)
+		assert.Equal(t, 21.0, page.Metrics[0].Temperature)
+		assert.Equal(t, 23.0, page.Metrics[2].Temperature)
+	}
+
+	{
+		// cursor must be produced by a previous page
+		_, err := iotObj.Metric.GetDeviceMetrics(deviceID, &models.MetricQuery{Cursor: "not-a-cursor"})
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	}
+}
+
+func TestAggregateDeviceMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []struct {
+		offset      time.Duration
+		temperature float64
+		battery     float64
+	}{
+		{10 * time.Minute, 20, 90},
+		{20 * time.Minute, 30, 80},
+		{30 * time.Minute, 25, 70},
+		{70 * time.Minute, 40, 60},
+	}
+	for _, s := range samples {
+		err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{
+			Timestamp:   base.Add(s.offset),
+			Temperature: s.temperature,
+			Battery:     s.battery,
+		})
+		require.NoError(t, err)
+	}
+
+	buckets, err := iotObj.Metric.AggregateDeviceMetrics(deviceID, &models.MetricAggregateQuery{Interval: time.Hour})
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+
+	assert.True(t, base.Equal(buckets[0].Start))
+	assert.Equal(t, 3, buckets[0].Count)
+	assert.Equal(t, models.MetricStats{Min: 20, Max: 30, Avg: 25, Last: 25}, buckets[0].Temperature)
+	assert.Equal(t, models.MetricStats{Min: 70, Max: 90, Avg: 80, Last: 70}, buckets[0].Battery)
+
+	assert.True(t, base.Add(time.Hour).Equal(buckets[1].Start))
+	assert.Equal(t, 1, buckets[1].Count)
+	assert.Equal(t, models.MetricStats{Min: 40, Max: 40, Avg: 40, Last: 40}, buckets[1].Temperature)
+
+	{
+		// time range limits the rows before bucketing
+		from := base.Add(15 * time.Minute)
+		to := base.Add(time.Hour)
+		buckets, err := iotObj.Metric.AggregateDeviceMetrics(deviceID, &models.MetricAggregateQuery{
+			From:     &from,
+			To:       &to,
+			Interval: 24 * time.Hour,
+		})
+		require.NoError(t, err)
+		require.Len(t, buckets, 1)
+		assert.Equal(t, 2, buckets[0].Count)
+		assert.Equal(t, 27.5, buckets[0].Temperature.Avg)
+	}
+
+	{
+		buckets, err := iotObj.Metric.AggregateDeviceMetrics(uuid.NewString(), &models.MetricAggregateQuery{Interval: time.Hour})
+		require.NoError(t, err)
+		assert.Empty(t, buckets)
+	}
+
+	{
+		_, err := iotObj.Metric.AggregateDeviceMetrics(deviceID, &models.MetricAggregateQuery{Interval: time.Second})
+		require.ErrorIs(t, err, ErrInvalidInterval)
+	}
+}
+
+func TestUpsertMetrics(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, mockIAlter, _ := GetMockIOTWithMemorySqliteDialector(t, false, true, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(30.0),
+		BatteryThreshold:     ptr(50.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	// alerts are checked once per metric in the batch
+	mockIAlter.
+		EXPECT().
+		CheckAndStoreAlerts(gomock.Eq(deviceID), gomock.Any()).
+		Times(3)
+
+	now := time.Now()
+	inputs := []models.Metric{
+		{Timestamp: now.Add(-2 * time.Minute), Temperature: 20, Battery: 60},
+		{Timestamp: now.Add(-1 * time.Minute), Temperature: 25, Battery: 55},
+		{Timestamp: now, Temperature: 35, Battery: 45},
+	}
+	err = iotObj.Metric.UpsertMetrics(deviceID, inputs)
+	require.NoError(t, err)
+
+	var count int64
+	err = iotObj.Db.Conn.Model(&models.Metric{}).Where("device_id = ?", deviceID).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestUpsertMetrics_EdgeCases(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	// empty batch is a no-op
+	err := iotObj.Metric.UpsertMetrics(deviceID, nil)
+	require.NoError(t, err)
+
+	// too big batch is rejected
+	err = iotObj.Metric.UpsertMetrics(deviceID, make([]models.Metric, MaxMetricBatchSize+1))
+	require.Error(t, err)
+
+	// without config the foreign key fails, and nothing of the batch is kept
+	err = iotObj.Metric.UpsertMetrics(deviceID, []models.Metric{
+		{Timestamp: time.Now(), Temperature: 20, Battery: 60},
+		{Timestamp: time.Now(), Temperature: 25, Battery: 55},
+	})
+	require.Error(t, err, "FOREIGN KEY constraint failed")
+
+	var count int64
+	err = iotObj.Db.Conn.Model(&models.Metric{}).Where("device_id = ?", deviceID).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestUpsertMetric_WithValues(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{
+		Timestamp:   time.Now(),
+		Temperature: 21.5,
+		Battery:     80.0,
+		Values: []models.MetricValue{
+			{Name: "humidity", Value: 45.2, Unit: "%"},
+			{Name: "rssi", Value: -67},
+		},
+	})
+	require.NoError(t, err)
+
+	page, err := iotObj.Metric.GetDeviceMetrics(deviceID, &models.MetricQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Metrics, 1)
+
+	metric := page.Metrics[0]
+	require.Len(t, metric.Values, 2)
+
+	humidity, ok := metric.Value("humidity")
+	assert.True(t, ok)
+	assert.Equal(t, 45.2, humidity)
+
+	temperature, ok := metric.Value(models.MetricNameTemperature)
+	assert.True(t, ok)
+	assert.Equal(t, 21.5, temperature)
+
+	_, ok = metric.Value("pressure")
+	assert.False(t, ok)
+}
+
+func TestValidateMetricValues(t *testing.T) {
+	assert.NoError(t, ValidateMetricValues(nil))
+	assert.NoError(t, ValidateMetricValues([]models.MetricValue{{Name: "humidity"}, {Name: "supply_voltage", Unit: "V"}}))
+
+	for _, values := range [][]models.MetricValue{
+		{{Name: ""}},
+		{{Name: "Humidity"}},
+		{{Name: "temperature"}},
+		{{Name: "humidity"}, {Name: "humidity"}},
+		{{Name: "pressure", Unit: "hectopascal-per-second"}},
+	} {
+		assert.ErrorIs(t, ValidateMetricValues(values), ErrInvalidMetric, "expected %v to be invalid", values)
+	}
+
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	invalid := models.Metric{Timestamp: time.Now(), Values: []models.MetricValue{{Name: "battery", Value: 1}}}
+
+	err := iotObj.Metric.UpsertMetric(deviceID, &invalid)
+	require.ErrorIs(t, err, ErrInvalidMetric)
+
+	err = iotObj.Metric.UpsertMetrics(deviceID, []models.Metric{invalid})
+	require.ErrorIs(t, err, ErrInvalidMetric)
+}
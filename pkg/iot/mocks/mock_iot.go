@@ -11,6 +11,7 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 	models "liyu1981.xyz/iot-metrics-service/pkg/models"
@@ -40,6 +41,36 @@ func (m *MockIMetric) EXPECT() *MockIMetricMockRecorder {
 	return m.recorder
 }
 
+// AggregateDeviceMetrics mocks base method.
+func (m *MockIMetric) AggregateDeviceMetrics(deviceID string, query *models.MetricAggregateQuery) ([]models.MetricBucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AggregateDeviceMetrics", deviceID, query)
+	ret0, _ := ret[0].([]models.MetricBucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AggregateDeviceMetrics indicates an expected call of AggregateDeviceMetrics.
+func (mr *MockIMetricMockRecorder) AggregateDeviceMetrics(deviceID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AggregateDeviceMetrics", reflect.TypeOf((*MockIMetric)(nil).AggregateDeviceMetrics), deviceID, query)
+}
+
+// GetDeviceMetrics mocks base method.
+func (m *MockIMetric) GetDeviceMetrics(deviceID string, query *models.MetricQuery) (*models.MetricPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceMetrics", deviceID, query)
+	ret0, _ := ret[0].(*models.MetricPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceMetrics indicates an expected call of GetDeviceMetrics.
+func (mr *MockIMetricMockRecorder) GetDeviceMetrics(deviceID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceMetrics", reflect.TypeOf((*MockIMetric)(nil).GetDeviceMetrics), deviceID, query)
+}
+
 // UpsertMetric mocks base method.
 func (m *MockIMetric) UpsertMetric(deviceID string, input *models.Metric) error {
 	m.ctrl.T.Helper()
@@ -54,6 +85,20 @@ func (mr *MockIMetricMockRecorder) UpsertMetric(deviceID, input any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertMetric", reflect.TypeOf((*MockIMetric)(nil).UpsertMetric), deviceID, input)
 }
 
+// UpsertMetrics mocks base method.
+func (m *MockIMetric) UpsertMetrics(deviceID string, inputs []models.Metric) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertMetrics", deviceID, inputs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertMetrics indicates an expected call of UpsertMetrics.
+func (mr *MockIMetricMockRecorder) UpsertMetrics(deviceID, inputs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertMetrics", reflect.TypeOf((*MockIMetric)(nil).UpsertMetrics), deviceID, inputs)
+}
+
 // MockIAlert is a mock of IAlert interface.
 type MockIAlert struct {
 	ctrl     *gomock.Controller
@@ -78,6 +123,21 @@ func (m *MockIAlert) EXPECT() *MockIAlertMockRecorder {
 	return m.recorder
 }
 
+// AcknowledgeAlert mocks base method.
+func (m *MockIAlert) AcknowledgeAlert(deviceID string, id uint, by, note string) (*models.Alert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcknowledgeAlert", deviceID, id, by, note)
+	ret0, _ := ret[0].(*models.Alert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcknowledgeAlert indicates an expected call of AcknowledgeAlert.
+func (mr *MockIAlertMockRecorder) AcknowledgeAlert(deviceID, id, by, note any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcknowledgeAlert", reflect.TypeOf((*MockIAlert)(nil).AcknowledgeAlert), deviceID, id, by, note)
+}
+
 // CheckAndStoreAlerts mocks base method.
 func (m *MockIAlert) CheckAndStoreAlerts(deviceID string, metric *models.Metric) error {
 	m.ctrl.T.Helper()
@@ -93,18 +153,63 @@ func (mr *MockIAlertMockRecorder) CheckAndStoreAlerts(deviceID, metric any) *gom
 }
 
 // GetDeviceAlerts mocks base method.
-func (m *MockIAlert) GetDeviceAlerts(deviceID string) ([]models.Alert, error) {
+func (m *MockIAlert) GetDeviceAlerts(deviceID string, query *models.AlertQuery) (*models.AlertPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDeviceAlerts", deviceID)
-	ret0, _ := ret[0].([]models.Alert)
+	ret := m.ctrl.Call(m, "GetDeviceAlerts", deviceID, query)
+	ret0, _ := ret[0].(*models.AlertPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetDeviceAlerts indicates an expected call of GetDeviceAlerts.
-func (mr *MockIAlertMockRecorder) GetDeviceAlerts(deviceID any) *gomock.Call {
+func (mr *MockIAlertMockRecorder) GetDeviceAlerts(deviceID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceAlerts", reflect.TypeOf((*MockIAlert)(nil).GetDeviceAlerts), deviceID, query)
+}
+
+// ListAlerts mocks base method.
+func (m *MockIAlert) ListAlerts(query *models.FleetAlertQuery) (*models.AlertPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAlerts", query)
+	ret0, _ := ret[0].(*models.AlertPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAlerts indicates an expected call of ListAlerts.
+func (mr *MockIAlertMockRecorder) ListAlerts(query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAlerts", reflect.TypeOf((*MockIAlert)(nil).ListAlerts), query)
+}
+
+// ResolveAlert mocks base method.
+func (m *MockIAlert) ResolveAlert(deviceID string, id uint, by, note string) (*models.Alert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveAlert", deviceID, id, by, note)
+	ret0, _ := ret[0].(*models.Alert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveAlert indicates an expected call of ResolveAlert.
+func (mr *MockIAlertMockRecorder) ResolveAlert(deviceID, id, by, note any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveAlert", reflect.TypeOf((*MockIAlert)(nil).ResolveAlert), deviceID, id, by, note)
+}
+
+// SummarizeAlerts mocks base method.
+func (m *MockIAlert) SummarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SummarizeAlerts", query)
+	ret0, _ := ret[0].([]models.AlertSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SummarizeAlerts indicates an expected call of SummarizeAlerts.
+func (mr *MockIAlertMockRecorder) SummarizeAlerts(query any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceAlerts", reflect.TypeOf((*MockIAlert)(nil).GetDeviceAlerts), deviceID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SummarizeAlerts", reflect.TypeOf((*MockIAlert)(nil).SummarizeAlerts), query)
 }
 
 // UpsertAlert mocks base method.
@@ -121,6 +226,230 @@ func (mr *MockIAlertMockRecorder) UpsertAlert(data any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertAlert", reflect.TypeOf((*MockIAlert)(nil).UpsertAlert), data)
 }
 
+// MockIDevice is a mock of IDevice interface.
+type MockIDevice struct {
+	ctrl     *gomock.Controller
+	recorder *MockIDeviceMockRecorder
+	isgomock struct{}
+}
+
+// MockIDeviceMockRecorder is the mock recorder for MockIDevice.
+type MockIDeviceMockRecorder struct {
+	mock *MockIDevice
+}
+
+// NewMockIDevice creates a new mock instance.
+func NewMockIDevice(ctrl *gomock.Controller) *MockIDevice {
+	mock := &MockIDevice{ctrl: ctrl}
+	mock.recorder = &MockIDeviceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIDevice) EXPECT() *MockIDeviceMockRecorder {
+	return m.recorder
+}
+
+// CheckDevice mocks base method.
+func (m *MockIDevice) CheckDevice(deviceID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckDevice", deviceID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckDevice indicates an expected call of CheckDevice.
+func (mr *MockIDeviceMockRecorder) CheckDevice(deviceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckDevice", reflect.TypeOf((*MockIDevice)(nil).CheckDevice), deviceID)
+}
+
+// CreateDevice mocks base method.
+func (m *MockIDevice) CreateDevice(input *models.Device, origin models.ChangeOrigin) (*models.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDevice", input, origin)
+	ret0, _ := ret[0].(*models.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDevice indicates an expected call of CreateDevice.
+func (mr *MockIDeviceMockRecorder) CreateDevice(input, origin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDevice", reflect.TypeOf((*MockIDevice)(nil).CreateDevice), input, origin)
+}
+
+// DeleteDevice mocks base method.
+func (m *MockIDevice) DeleteDevice(deviceID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDevice", deviceID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDevice indicates an expected call of DeleteDevice.
+func (mr *MockIDeviceMockRecorder) DeleteDevice(deviceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDevice", reflect.TypeOf((*MockIDevice)(nil).DeleteDevice), deviceID)
+}
+
+// GetDevice mocks base method.
+func (m *MockIDevice) GetDevice(deviceID string) (*models.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDevice", deviceID)
+	ret0, _ := ret[0].(*models.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDevice indicates an expected call of GetDevice.
+func (mr *MockIDeviceMockRecorder) GetDevice(deviceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDevice", reflect.TypeOf((*MockIDevice)(nil).GetDevice), deviceID)
+}
+
+// ListDevices mocks base method.
+func (m *MockIDevice) ListDevices(query *models.DeviceQuery) (*models.DevicePage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDevices", query)
+	ret0, _ := ret[0].(*models.DevicePage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDevices indicates an expected call of ListDevices.
+func (mr *MockIDeviceMockRecorder) ListDevices(query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDevices", reflect.TypeOf((*MockIDevice)(nil).ListDevices), query)
+}
+
+// MarkDeviceSeen mocks base method.
+func (m *MockIDevice) MarkDeviceSeen(deviceID string, seen time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDeviceSeen", deviceID, seen)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkDeviceSeen indicates an expected call of MarkDeviceSeen.
+func (mr *MockIDeviceMockRecorder) MarkDeviceSeen(deviceID, seen any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDeviceSeen", reflect.TypeOf((*MockIDevice)(nil).MarkDeviceSeen), deviceID, seen)
+}
+
+// UpdateDevice mocks base method.
+func (m *MockIDevice) UpdateDevice(deviceID string, input *models.Device, origin models.ChangeOrigin) (*models.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDevice", deviceID, input, origin)
+	ret0, _ := ret[0].(*models.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateDevice indicates an expected call of UpdateDevice.
+func (mr *MockIDeviceMockRecorder) UpdateDevice(deviceID, input, origin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDevice", reflect.TypeOf((*MockIDevice)(nil).UpdateDevice), deviceID, input, origin)
+}
+
+// MockIRule is a mock of IRule interface.
+type MockIRule struct {
+	ctrl     *gomock.Controller
+	recorder *MockIRuleMockRecorder
+	isgomock struct{}
+}
+
+// MockIRuleMockRecorder is the mock recorder for MockIRule.
+type MockIRuleMockRecorder struct {
+	mock *MockIRule
+}
+
+// NewMockIRule creates a new mock instance.
+func NewMockIRule(ctrl *gomock.Controller) *MockIRule {
+	mock := &MockIRule{ctrl: ctrl}
+	mock.recorder = &MockIRuleMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIRule) EXPECT() *MockIRuleMockRecorder {
+	return m.recorder
+}
+
+// CreateRule mocks base method.
+func (m *MockIRule) CreateRule(deviceID string, input *models.AlertRule) (*models.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRule", deviceID, input)
+	ret0, _ := ret[0].(*models.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRule indicates an expected call of CreateRule.
+func (mr *MockIRuleMockRecorder) CreateRule(deviceID, input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRule", reflect.TypeOf((*MockIRule)(nil).CreateRule), deviceID, input)
+}
+
+// DeleteRule mocks base method.
+func (m *MockIRule) DeleteRule(deviceID string, id uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRule", deviceID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRule indicates an expected call of DeleteRule.
+func (mr *MockIRuleMockRecorder) DeleteRule(deviceID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRule", reflect.TypeOf((*MockIRule)(nil).DeleteRule), deviceID, id)
+}
+
+// GetDeviceRule mocks base method.
+func (m *MockIRule) GetDeviceRule(deviceID string, id uint) (*models.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceRule", deviceID, id)
+	ret0, _ := ret[0].(*models.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceRule indicates an expected call of GetDeviceRule.
+func (mr *MockIRuleMockRecorder) GetDeviceRule(deviceID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceRule", reflect.TypeOf((*MockIRule)(nil).GetDeviceRule), deviceID, id)
+}
+
+// GetDeviceRules mocks base method.
+func (m *MockIRule) GetDeviceRules(deviceID string) ([]models.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceRules", deviceID)
+	ret0, _ := ret[0].([]models.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceRules indicates an expected call of GetDeviceRules.
+func (mr *MockIRuleMockRecorder) GetDeviceRules(deviceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceRules", reflect.TypeOf((*MockIRule)(nil).GetDeviceRules), deviceID)
+}
+
+// UpdateRule mocks base method.
+func (m *MockIRule) UpdateRule(deviceID string, id uint, input *models.AlertRule) (*models.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRule", deviceID, id, input)
+	ret0, _ := ret[0].(*models.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRule indicates an expected call of UpdateRule.
+func (mr *MockIRuleMockRecorder) UpdateRule(deviceID, id, input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRule", reflect.TypeOf((*MockIRule)(nil).UpdateRule), deviceID, id, input)
+}
+
 // MockIConfig is a mock of IConfig interface.
 type MockIConfig struct {
 	ctrl     *gomock.Controller
@@ -145,6 +474,35 @@ func (m *MockIConfig) EXPECT() *MockIConfigMockRecorder {
 	return m.recorder
 }
 
+// DeleteConfig mocks base method.
+func (m *MockIConfig) DeleteConfig(deviceID string, origin models.ChangeOrigin) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConfig", deviceID, origin)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConfig indicates an expected call of DeleteConfig.
+func (mr *MockIConfigMockRecorder) DeleteConfig(deviceID, origin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConfig", reflect.TypeOf((*MockIConfig)(nil).DeleteConfig), deviceID, origin)
+}
+
+// GetConfigHistory mocks base method.
+func (m *MockIConfig) GetConfigHistory(deviceID string, query *models.ConfigChangeQuery) (*models.ConfigChangePage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConfigHistory", deviceID, query)
+	ret0, _ := ret[0].(*models.ConfigChangePage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConfigHistory indicates an expected call of GetConfigHistory.
+func (mr *MockIConfigMockRecorder) GetConfigHistory(deviceID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConfigHistory", reflect.TypeOf((*MockIConfig)(nil).GetConfigHistory), deviceID, query)
+}
+
 // GetDeviceConfig mocks base method.
 func (m *MockIConfig) GetDeviceConfig(deviceID string) (*models.Config, error) {
 	m.ctrl.T.Helper()
@@ -160,16 +518,159 @@ func (mr *MockIConfigMockRecorder) GetDeviceConfig(deviceID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceConfig", reflect.TypeOf((*MockIConfig)(nil).GetDeviceConfig), deviceID)
 }
 
+// PatchConfig mocks base method.
+func (m *MockIConfig) PatchConfig(deviceID string, patch *models.ConfigPatch, expectedVersion uint64, origin models.ChangeOrigin) (*models.Config, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchConfig", deviceID, patch, expectedVersion, origin)
+	ret0, _ := ret[0].(*models.Config)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchConfig indicates an expected call of PatchConfig.
+func (mr *MockIConfigMockRecorder) PatchConfig(deviceID, patch, expectedVersion, origin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchConfig", reflect.TypeOf((*MockIConfig)(nil).PatchConfig), deviceID, patch, expectedVersion, origin)
+}
+
+// ResolveConfig mocks base method.
+func (m *MockIConfig) ResolveConfig(deviceID string) (*models.ResolvedConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveConfig", deviceID)
+	ret0, _ := ret[0].(*models.ResolvedConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveConfig indicates an expected call of ResolveConfig.
+func (mr *MockIConfigMockRecorder) ResolveConfig(deviceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveConfig", reflect.TypeOf((*MockIConfig)(nil).ResolveConfig), deviceID)
+}
+
 // UpsertConfig mocks base method.
-func (m *MockIConfig) UpsertConfig(deviceID string, input *models.Config) error {
+func (m *MockIConfig) UpsertConfig(deviceID string, input *models.Config, origin models.ChangeOrigin) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpsertConfig", deviceID, input)
+	ret := m.ctrl.Call(m, "UpsertConfig", deviceID, input, origin)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpsertConfig indicates an expected call of UpsertConfig.
-func (mr *MockIConfigMockRecorder) UpsertConfig(deviceID, input any) *gomock.Call {
+func (mr *MockIConfigMockRecorder) UpsertConfig(deviceID, input, origin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertConfig", reflect.TypeOf((*MockIConfig)(nil).UpsertConfig), deviceID, input, origin)
+}
+
+// MockIGroup is a mock of IGroup interface.
+type MockIGroup struct {
+	ctrl     *gomock.Controller
+	recorder *MockIGroupMockRecorder
+	isgomock struct{}
+}
+
+// MockIGroupMockRecorder is the mock recorder for MockIGroup.
+type MockIGroupMockRecorder struct {
+	mock *MockIGroup
+}
+
+// NewMockIGroup creates a new mock instance.
+func NewMockIGroup(ctrl *gomock.Controller) *MockIGroup {
+	mock := &MockIGroup{ctrl: ctrl}
+	mock.recorder = &MockIGroupMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIGroup) EXPECT() *MockIGroupMockRecorder {
+	return m.recorder
+}
+
+// DeleteGroupConfig mocks base method.
+func (m *MockIGroup) DeleteGroupConfig(tag string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGroupConfig", tag)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGroupConfig indicates an expected call of DeleteGroupConfig.
+func (mr *MockIGroupMockRecorder) DeleteGroupConfig(tag any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGroupConfig", reflect.TypeOf((*MockIGroup)(nil).DeleteGroupConfig), tag)
+}
+
+// GetDeviceGroups mocks base method.
+func (m *MockIGroup) GetDeviceGroups(deviceIDs []string) (map[string][]models.GroupConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceGroups", deviceIDs)
+	ret0, _ := ret[0].(map[string][]models.GroupConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceGroups indicates an expected call of GetDeviceGroups.
+func (mr *MockIGroupMockRecorder) GetDeviceGroups(deviceIDs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroups", reflect.TypeOf((*MockIGroup)(nil).GetDeviceGroups), deviceIDs)
+}
+
+// GetGroupConfig mocks base method.
+func (m *MockIGroup) GetGroupConfig(tag string) (*models.GroupConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroupConfig", tag)
+	ret0, _ := ret[0].(*models.GroupConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGroupConfig indicates an expected call of GetGroupConfig.
+func (mr *MockIGroupMockRecorder) GetGroupConfig(tag any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroupConfig", reflect.TypeOf((*MockIGroup)(nil).GetGroupConfig), tag)
+}
+
+// ListGroupConfigs mocks base method.
+func (m *MockIGroup) ListGroupConfigs() ([]models.GroupConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGroupConfigs")
+	ret0, _ := ret[0].([]models.GroupConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGroupConfigs indicates an expected call of ListGroupConfigs.
+func (mr *MockIGroupMockRecorder) ListGroupConfigs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGroupConfigs", reflect.TypeOf((*MockIGroup)(nil).ListGroupConfigs))
+}
+
+// UpdateConfigsByTag mocks base method.
+func (m *MockIGroup) UpdateConfigsByTag(tag string, patch *models.ConfigPatch, origin models.ChangeOrigin) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConfigsByTag", tag, patch, origin)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateConfigsByTag indicates an expected call of UpdateConfigsByTag.
+func (mr *MockIGroupMockRecorder) UpdateConfigsByTag(tag, patch, origin any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConfigsByTag", reflect.TypeOf((*MockIGroup)(nil).UpdateConfigsByTag), tag, patch, origin)
+}
+
+// UpsertGroupConfig mocks base method.
+func (m *MockIGroup) UpsertGroupConfig(tag string, input *models.GroupConfig) (*models.GroupConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertGroupConfig", tag, input)
+	ret0, _ := ret[0].(*models.GroupConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertGroupConfig indicates an expected call of UpsertGroupConfig.
+func (mr *MockIGroupMockRecorder) UpsertGroupConfig(tag, input any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertConfig", reflect.TypeOf((*MockIConfig)(nil).UpsertConfig), deviceID, input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertGroupConfig", reflect.TypeOf((*MockIGroup)(nil).UpsertGroupConfig), tag, input)
 }
@@ -0,0 +1,283 @@
+package iot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+const (
+	WebhookHeaderEvent     = "X-IOT-Event"
+	WebhookHeaderDelivery  = "X-IOT-Delivery"
+	WebhookHeaderSignature = "X-IOT-Signature"
+)
+
+const (
+	WebhookEventFiring       = "alert.firing"
+	WebhookEventAcknowledged = "alert.acknowledged"
+	WebhookEventResolved     = "alert.resolved"
+)
+
+type WebhookOptions struct {
+	URLs []string
+	// Secret signs the payloads with HMAC-SHA256, empty sends them unsigned
+	Secret string
+
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+
+	QueueSize int
+	Workers   int
+}
+
+func (o *WebhookOptions) withDefaults() WebhookOptions {
+	opts := *o
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Minute
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 256
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	return opts
+}
+
+type WebhookPayload struct {
+	Event string       `json:"event"`
+	Alert models.Alert `json:"alert"`
+}
+
+// SignWebhookPayload returns the signature header value of body, receivers
+// compute the same with the shared secret to verify a delivery
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookEvent(alert *models.Alert) string {
+	switch alert.Status {
+	case models.AlertStatusResolved:
+		return WebhookEventResolved
+	case models.AlertStatusAcknowledged:
+		return WebhookEventAcknowledged
+	}
+	return WebhookEventFiring
+}
+
+type webhookJob struct {
+	url     string
+	payload WebhookPayload
+}
+
+// WebhookNotifier POSTs changed alerts to the configured URLs. Deliveries run
+// on a worker pool off the ingestion path, failed ones are retried with
+// exponential backoff and every delivery is logged in the database.
+type WebhookNotifier struct {
+	conn   *gorm.DB
+	opts   WebhookOptions
+	client *http.Client
+	logger *zap.Logger
+
+	queue   chan webhookJob
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	closing sync.Once
+	mu      sync.RWMutex
+	closed  bool
+}
+
+func NewWebhookNotifier(conn *gorm.DB, opts WebhookOptions) *WebhookNotifier {
+	opts = opts.withDefaults()
+
+	n := &WebhookNotifier{
+		conn:   conn,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		logger: common.GetLoggerWith(
+			common.LoggerNameIOTCore,
+			zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTNotify),
+		),
+		queue: make(chan webhookJob, opts.QueueSize),
+		stop:  make(chan struct{}),
+	}
+
+	for range opts.Workers {
+		n.wg.Add(1)
+		go n.work()
+	}
+
+	return n
+}
+
+// Notify queues one delivery of alert per URL, it never blocks. When the
+// queue is full the delivery is logged as failed right away.
+func (n *WebhookNotifier) Notify(alert models.Alert) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	payload := WebhookPayload{Event: webhookEvent(&alert), Alert: alert}
+	for _, url := range n.opts.URLs {
+		select {
+		case n.queue <- webhookJob{url: url, payload: payload}:
+		default:
+			n.logger.Warn("Webhook queue is full, delivery dropped", zap.String("url", url), zap.Uint("alert_id", alert.ID))
+			err := n.conn.Create(&models.WebhookDelivery{
+				AlertID: alert.ID,
+				URL:     url,
+				Event:   payload.Event,
+				Error:   "queue full",
+			}).Error
+			if err != nil {
+				n.logger.Error("Failed to log webhook delivery", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Close stops taking alerts and waits for the queued deliveries. Backoff
+// waits are cut short, so a failing receiver does not hold up shutdown.
+func (n *WebhookNotifier) Close() {
+	n.closing.Do(func() {
+		n.mu.Lock()
+		n.closed = true
+		close(n.queue)
+		n.mu.Unlock()
+
+		close(n.stop)
+		n.wg.Wait()
+	})
+}
+
+func (n *WebhookNotifier) GetAlertDeliveries(alertID uint) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := n.conn.
+		Where("alert_id = ?", alertID).
+		Order("id").
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (n *WebhookNotifier) work() {
+	defer n.wg.Done()
+	for job := range n.queue {
+		n.deliver(job)
+	}
+}
+
+func (n *WebhookNotifier) backoff(attempt int) time.Duration {
+	d := n.opts.InitialBackoff << (attempt - 1)
+	if d <= 0 || d > n.opts.MaxBackoff {
+		return n.opts.MaxBackoff
+	}
+	return d
+}
+
+func (n *WebhookNotifier) deliver(job webhookJob) {
+	logger := n.logger
+
+	body, err := json.Marshal(job.payload)
+	if err != nil {
+		logger.Error("Failed to encode webhook payload", zap.Error(err))
+		return
+	}
+
+	delivery := models.WebhookDelivery{
+		AlertID: job.payload.Alert.ID,
+		URL:     job.url,
+		Event:   job.payload.Event,
+	}
+	if err := n.conn.Create(&delivery).Error; err != nil {
+		logger.Error("Failed to log webhook delivery", zap.Error(err))
+	}
+
+	for attempt := 1; attempt <= n.opts.MaxAttempts; attempt++ {
+		var retry bool
+		delivery.Attempts = attempt
+		delivery.StatusCode, retry, err = n.post(job.url, delivery.ID, job.payload.Event, body)
+
+		if err == nil {
+			delivery.Delivered = true
+			delivery.Error = ""
+		} else {
+			delivery.Error = err.Error()
+		}
+		if err := n.conn.Save(&delivery).Error; err != nil {
+			logger.Error("Failed to log webhook delivery", zap.Error(err))
+		}
+
+		if delivery.Delivered {
+			logger.Info("Webhook delivered", zap.Reflect("delivery", delivery))
+			return
+		}
+		if !retry || attempt == n.opts.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(n.backoff(attempt)):
+		case <-n.stop:
+			logger.Warn("Webhook retries stopped by close", zap.Reflect("delivery", delivery))
+			return
+		}
+	}
+
+	logger.Warn("Webhook delivery failed", zap.Reflect("delivery", delivery))
+}
+
+// post sends one attempt, it tells whether a failed attempt is worth retrying:
+// network errors, 429 and 5xx are, other responses are not
+func (n *WebhookNotifier) post(url string, deliveryID uint, event string, body []byte) (int, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEvent, event)
+	req.Header.Set(WebhookHeaderDelivery, strconv.FormatUint(uint64(deliveryID), 10))
+	if n.opts.Secret != "" {
+		req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(n.opts.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return resp.StatusCode, retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
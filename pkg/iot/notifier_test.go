@@ -0,0 +1,215 @@
+package iot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/db"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+func newTestNotifier(urls ...string) *WebhookNotifier {
+	return NewWebhookNotifier(db.GetInstance(db.UseMemorySqliteDialector()).Conn, WebhookOptions{
+		URLs:           urls,
+		Secret:         "s3cret",
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		Workers:        1,
+	})
+}
+
+// the delivery log is shared by all tests, so every alert gets its own ID
+func newTestAlert() models.Alert {
+	return models.Alert{
+		ID:       uint(uuid.New().ID()),
+		DeviceID: uuid.NewString(),
+		Type:     models.AlertTypeBattery,
+		State:    models.AlertStateFiring,
+		Status:   models.AlertStatusOpen,
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// echo -n '{"event":"alert.firing"}' | openssl dgst -sha256 -hmac s3cret
+	assert.Equal(t,
+		"sha256=6c9c11b89b665fa238f562b53b1a5ddc07256dfaeab09be26383c57f58c7e416",
+		SignWebhookPayload("s3cret", []byte(`{"event":"alert.firing"}`)),
+	)
+}
+
+func TestWebhookNotifier_Deliver(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	var received []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := newTestNotifier(server.URL)
+	alert := newTestAlert()
+	notifier.Notify(alert)
+	notifier.Close()
+
+	require.Len(t, received, 1)
+	assert.Equal(t, http.MethodPost, received[0].Method)
+	assert.Equal(t, "application/json", received[0].Header.Get("Content-Type"))
+	assert.Equal(t, WebhookEventFiring, received[0].Header.Get(WebhookHeaderEvent))
+	assert.NotEmpty(t, received[0].Header.Get(WebhookHeaderDelivery))
+	assert.Equal(t, SignWebhookPayload("s3cret", bodies[0]), received[0].Header.Get(WebhookHeaderSignature))
+
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(bodies[0], &payload))
+	assert.Equal(t, WebhookEventFiring, payload.Event)
+	assert.Equal(t, alert.ID, payload.Alert.ID)
+	assert.Equal(t, alert.DeviceID, payload.Alert.DeviceID)
+
+	deliveries, err := notifier.GetAlertDeliveries(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Delivered)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].StatusCode)
+	assert.Equal(t, server.URL, deliveries[0].URL)
+	assert.Empty(t, deliveries[0].Error)
+}
+
+func TestWebhookNotifier_Retry(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer flaky.Close()
+
+	notifier := newTestNotifier(flaky.URL)
+	alert := newTestAlert()
+	notifier.Notify(alert)
+	// close cuts retries short, so wait for them first
+	require.Eventually(t, func() bool { return calls.Load() == 3 }, time.Second, 5*time.Millisecond)
+	notifier.Close()
+
+	deliveries, err := notifier.GetAlertDeliveries(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Delivered)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+}
+
+func TestWebhookNotifier_Failures(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	var badCalls, downCalls atomic.Int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badCalls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downCalls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	notifier := newTestNotifier(bad.URL, down.URL)
+	alert := newTestAlert()
+	alert.Status = models.AlertStatusResolved
+	notifier.Notify(alert)
+	require.Eventually(t, func() bool { return downCalls.Load() == 3 }, time.Second, 5*time.Millisecond)
+	notifier.Close()
+
+	// 4xx is not retried, 5xx is retried until attempts run out
+	assert.Equal(t, int32(1), badCalls.Load())
+	assert.Equal(t, int32(3), downCalls.Load())
+
+	deliveries, err := notifier.GetAlertDeliveries(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+
+	byURL := map[string]models.WebhookDelivery{}
+	for _, delivery := range deliveries {
+		assert.False(t, delivery.Delivered)
+		assert.Equal(t, WebhookEventResolved, delivery.Event)
+		byURL[delivery.URL] = delivery
+	}
+	assert.Equal(t, 1, byURL[bad.URL].Attempts)
+	assert.Equal(t, http.StatusBadRequest, byURL[bad.URL].StatusCode)
+	assert.Equal(t, 3, byURL[down.URL].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, byURL[down.URL].StatusCode)
+	assert.Contains(t, byURL[down.URL].Error, "500")
+
+	// nothing is sent after close
+	notifier.Notify(newTestAlert())
+	assert.Equal(t, int32(1), badCalls.Load())
+}
+
+func TestCheckAndStoreAlerts_NotifyWebhook(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	events := make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events <- r.Header.Get(WebhookHeaderEvent)
+	}))
+	defer server.Close()
+
+	iotObj.Notifier = newTestNotifier(server.URL)
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(20.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	for _, battery := range []float64{10, 5, 50} {
+		err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{
+			Timestamp:   time.Now(),
+			Temperature: 30,
+			Battery:     battery,
+		})
+		require.NoError(t, err)
+	}
+	iotObj.Notifier.Close()
+	close(events)
+
+	// the repeated breach does not notify again
+	var got []string
+	for event := range events {
+		got = append(got, event)
+	}
+	assert.Equal(t, []string{WebhookEventFiring, WebhookEventResolved}, got)
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	alerts := page.Alerts
+	require.Len(t, alerts, 1)
+
+	deliveries, err := iotObj.Notifier.GetAlertDeliveries(alerts[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+}
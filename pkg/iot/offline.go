@@ -0,0 +1,206 @@
+package iot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+const DefaultOfflineCheckInterval = 30 * time.Second
+
+// markSeen records that metrics of the device were received at seen, in its
+// config and registry entry, and resolves its firing offline alert
+func (i *IOT) markSeen(deviceID string, seen time.Time) error {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTOffline),
+	)
+
+	if err := i.Store.MarkConfigSeen(deviceID, seen); err != nil {
+		return err
+	}
+	if i.Device != nil {
+		if err := i.Device.MarkDeviceSeen(deviceID, seen); err != nil {
+			return err
+		}
+	}
+
+	alerts, err := i.Store.FindFiringAlerts(deviceID)
+	if err != nil {
+		return err
+	}
+
+	for idx := range alerts {
+		if alerts[idx].Type != models.AlertTypeOffline {
+			continue
+		}
+
+		alert, err := i.clearAlert(&alerts[idx], seen)
+		if err != nil {
+			return err
+		}
+		if alert == nil {
+			continue
+		}
+
+		logger.Info("Device back online", zap.Reflect("alert", alert))
+
+		i.publishAlert(alert)
+	}
+
+	return nil
+}
+
+func offlineMessage(config *models.Config) string {
+	return fmt.Sprintf("No metrics within heartbeat interval %s, last seen at %s",
+		*config.HeartbeatInterval, config.LastSeenAt.Format(time.RFC3339))
+}
+
+// checkOffline raises an offline alert for every device whose last metrics are
+// older than its heartbeat interval at now, and returns them. The interval may
+// be inherited from a group. Devices which never posted metrics, are disabled
+// in the registry or are already offline are skipped.
+func (i *IOT) checkOffline(now time.Time) ([]models.Alert, error) {
+	var configs []models.Config
+	err := i.Db.Conn.
+		Select("device_id", "heartbeat_interval", "last_seen_at").
+		Where("last_seen_at IS NOT NULL").
+		Where(i.Db.Conn.Where("heartbeat_interval > 0").
+			Or("device_id IN (?)", i.Db.Conn.Model(&models.DeviceTag{}).Select("device_id").
+				Where("tag IN (?)", i.Db.Conn.Model(&models.GroupConfig{}).Select("tag").Where("heartbeat_interval > 0")))).
+		Where("device_id NOT IN (?)", i.Db.Conn.Model(&models.Device{}).Select("id").Where("enabled = ?", false)).
+		Where("device_id NOT IN (?)", i.Db.Conn.Model(&models.Alert{}).Select("device_id").
+			Where("type = ? AND state = ?", models.AlertTypeOffline, models.AlertStateFiring)).
+		Order("device_id").
+		Find(&configs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := i.resolveConfigs(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	var raised []models.Alert
+	for _, rc := range resolved {
+		config := rc.Config
+		if config.HeartbeatInterval == nil || *config.HeartbeatInterval <= 0 ||
+			now.Sub(*config.LastSeenAt) <= *config.HeartbeatInterval {
+			continue
+		}
+
+		alert := models.Alert{
+			DeviceID:  config.DeviceID,
+			Timestamp: now,
+			Type:      models.AlertTypeOffline,
+			Severity:  models.AlertSeverityWarning,
+			Message:   offlineMessage(&config),
+			State:     models.AlertStateFiring,
+			LastSeen:  now,
+			Count:     1,
+			Status:    models.AlertStatusOpen,
+		}
+
+		var stale bool
+		err := i.Db.Conn.Transaction(func(tx *gorm.DB) error {
+			// metrics may have come in since the configs were read
+			var current models.Config
+			if err := tx.Select("last_seen_at").First(&current, "device_id = ?", config.DeviceID).Error; err != nil {
+				return err
+			}
+			if current.LastSeenAt == nil || !current.LastSeenAt.Equal(*config.LastSeenAt) {
+				stale = true
+				return nil
+			}
+			return tx.Create(&alert).Error
+		})
+		if err != nil {
+			return raised, err
+		}
+		if stale {
+			continue
+		}
+
+		i.publishAlert(&alert)
+		raised = append(raised, alert)
+	}
+
+	return raised, nil
+}
+
+// OfflineWatcher checks the heartbeats of all devices in the background every
+// interval, see checkOffline. Offline alerts are resolved by the next metrics
+// of the device.
+type OfflineWatcher struct {
+	iot      *IOT
+	interval time.Duration
+	logger   *zap.Logger
+
+	stop    chan struct{}
+	done    chan struct{}
+	closing sync.Once
+}
+
+// NewOfflineWatcher checks the devices of i every interval. It returns
+// ErrStoreUnsupported when the Store of i is not a GormStore, the check reads
+// the tables of Db directly.
+func NewOfflineWatcher(i *IOT, interval time.Duration) (*OfflineWatcher, error) {
+	if err := i.requireGormStore("offline watcher"); err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		interval = DefaultOfflineCheckInterval
+	}
+
+	w := &OfflineWatcher{
+		iot:      i,
+		interval: interval,
+		logger: common.GetLoggerWith(
+			common.LoggerNameIOTCore,
+			zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTOffline),
+		),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// Close stops the watcher and waits for a running check to finish
+func (w *OfflineWatcher) Close() {
+	w.closing.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+}
+
+func (w *OfflineWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-ticker.C:
+			alerts, err := w.iot.checkOffline(now.UTC())
+			for idx := range alerts {
+				w.logger.Info("Device offline", zap.Reflect("alert", alerts[idx]))
+			}
+			if err != nil {
+				w.logger.Error("Failed to check device heartbeats", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package iot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+func deviceOfflineAlerts(t *testing.T, alerts []models.Alert, deviceID string) []models.Alert {
+	t.Helper()
+	var found []models.Alert
+	for _, alert := range alerts {
+		if alert.DeviceID == deviceID {
+			found = append(found, alert)
+		}
+	}
+	return found
+}
+
+func TestUpsertMetric_MarkSeen(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	before := time.Now()
+	// an old reading still counts as the device being seen now
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: before.Add(-time.Hour), Temperature: 20.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	config, err := iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	require.NotNil(t, config.LastSeenAt)
+	assert.False(t, config.LastSeenAt.Before(before.Truncate(time.Second)))
+
+	device, err := iotObj.Device.GetDevice(deviceID)
+	require.NoError(t, err)
+	require.NotNil(t, device.LastSeenAt)
+	assert.True(t, config.LastSeenAt.Equal(*device.LastSeenAt))
+
+	// updating the config keeps the last seen time
+	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(35.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	updated, err := iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	require.NotNil(t, updated.LastSeenAt)
+	assert.True(t, config.LastSeenAt.Equal(*updated.LastSeenAt))
+
+	assert.ErrorIs(t, iotObj.Config.UpsertConfig(deviceID, &models.Config{HeartbeatInterval: ptr(-time.Second)}, models.ChangeOrigin{}), ErrInvalidConfig)
+}
+
+func TestCheckOffline(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	broker := NewAlertBroker(8)
+	iotObj.Broker = broker
+
+	deviceID, disabledID, unwatchedID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	for _, id := range []string{deviceID, disabledID, unwatchedID} {
+		heartbeat := time.Minute
+		if id == unwatchedID {
+			heartbeat = 0
+		}
+		err := iotObj.Config.UpsertConfig(id, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0), HeartbeatInterval: ptr(heartbeat)}, models.ChangeOrigin{})
+		require.NoError(t, err)
+		err = iotObj.Metric.UpsertMetric(id, &models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0})
+		require.NoError(t, err)
+	}
+	_, err := iotObj.Device.CreateDevice(&models.Device{ID: disabledID, Enabled: false}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	sub := broker.Subscribe(AlertFilter{DeviceID: deviceID})
+	defer sub.Close()
+
+	alerts, err := iotObj.checkOffline(time.Now().UTC())
+	require.NoError(t, err)
+	assert.Empty(t, deviceOfflineAlerts(t, alerts, deviceID))
+
+	later := time.Now().UTC().Add(2 * time.Minute)
+	alerts, err = iotObj.checkOffline(later)
+	require.NoError(t, err)
+	require.Len(t, deviceOfflineAlerts(t, alerts, deviceID), 1)
+	assert.Empty(t, deviceOfflineAlerts(t, alerts, disabledID))
+	assert.Empty(t, deviceOfflineAlerts(t, alerts, unwatchedID))
+
+	offline := deviceOfflineAlerts(t, alerts, deviceID)[0]
+	assert.Equal(t, models.AlertTypeOffline, offline.Type)
+	assert.Equal(t, models.AlertStateFiring, offline.State)
+	assert.Contains(t, offline.Message, "heartbeat interval 1m0s")
+	assert.Equal(t, offline.ID, (<-sub.C).ID)
+
+	// a device is only alerted once while offline
+	alerts, err = iotObj.checkOffline(later.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, deviceOfflineAlerts(t, alerts, deviceID))
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	resolved := <-sub.C
+	assert.Equal(t, offline.ID, resolved.ID)
+	assert.Equal(t, models.AlertStateResolved, resolved.State)
+	assert.Equal(t, models.AlertStatusResolved, resolved.Status)
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{Types: []models.AlertType{models.AlertTypeOffline}})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertStateResolved, page.Alerts[0].State)
+	assert.NotNil(t, page.Alerts[0].ResolvedAt)
+}
+
+func TestOfflineWatcher(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0), HeartbeatInterval: ptr(time.Millisecond)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	watcher, err := NewOfflineWatcher(iotObj, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	offline := &models.AlertQuery{Types: []models.AlertType{models.AlertTypeOffline}, State: models.AlertStateFiring}
+	require.Eventually(t, func() bool {
+		page, err := iotObj.Alert.GetDeviceAlerts(deviceID, offline)
+		return err == nil && len(page.Alerts) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	watcher.Close()
+	// closing twice is fine
+	watcher.Close()
+}
+
+func TestCheckOffline_GroupHeartbeat(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	tag := "g-" + uuid.NewString()
+	_, err := iotObj.Group.UpsertGroupConfig(tag, &models.GroupConfig{
+		ConfigPatch: models.ConfigPatch{HeartbeatInterval: ptr(time.Minute)},
+	})
+	require.NoError(t, err)
+
+	deviceID := uuid.NewString()
+	_, err = iotObj.Device.CreateDevice(&models.Device{ID: deviceID, Tags: []string{tag}, Enabled: true}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	alerts, err := iotObj.checkOffline(time.Now().UTC().Add(2 * time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, deviceOfflineAlerts(t, alerts, deviceID), 1)
+}
@@ -0,0 +1,98 @@
+package iot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+func normalizePageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return limit
+}
+
+// cursors are opaque to clients, internally they point at the (timestamp, id)
+// of the last row returned so that the next page can continue after it
+func encodeCursor(ts time.Time, id uint) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d", ts.UnixNano(), id))
+}
+
+func decodeCursor(cursor string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	var nanos int64
+	var id uint
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &nanos, &id); err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	return time.Unix(0, nanos).UTC(), id, nil
+}
+
+// key cursors point at the primary key of the last row returned, for tables
+// ordered by a string key
+func encodeKeyCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeKeyCursor(cursor string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(raw), nil
+}
+
+// applyTimeCursor restricts tx to rows after the cursor position, for tables
+// ordered by (timestamp, id)
+func applyTimeCursor(tx *gorm.DB, cursor string, desc bool) (*gorm.DB, error) {
+	if cursor == "" {
+		return tx, nil
+	}
+
+	ts, id, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if desc {
+		return tx.Where("(timestamp < ? OR (timestamp = ? AND id < ?))", ts, ts, id), nil
+	}
+	return tx.Where("(timestamp > ? OR (timestamp = ? AND id > ?))", ts, ts, id), nil
+}
+
+func orderByTime(tx *gorm.DB, desc bool) *gorm.DB {
+	if desc {
+		return tx.Order("timestamp desc").Order("id desc")
+	}
+	return tx.Order("timestamp asc").Order("id asc")
+}
+
+// findPage finds up to limit rows of tx, fetching one extra row to know
+// whether there is a next page, and returns the cursor of the last row then
+func findPage[T any](tx *gorm.DB, limit int, cursor func(last *T) string) ([]T, string, error) {
+	var rows []T
+	if err := tx.Limit(limit + 1).Find(&rows).Error; err != nil {
+		return nil, "", err
+	}
+	if len(rows) <= limit {
+		return rows, "", nil
+	}
+	rows = rows[:limit]
+	return rows, cursor(&rows[limit-1]), nil
+}
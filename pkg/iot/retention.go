@@ -0,0 +1,352 @@
+package iot
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+const (
+	RetentionTableMetrics = "metrics"
+	RetentionTableAlerts  = "alerts"
+
+	DefaultRetentionInterval = time.Hour
+)
+
+// RetentionOptions sets how long rows are kept. A zero max age keeps the rows
+// of a table forever, groups may still override it for their devices, see
+// models.GroupConfig.
+type RetentionOptions struct {
+	// MetricMaxAge is measured from the metric timestamp
+	MetricMaxAge time.Duration
+	// AlertMaxAge is measured from when the alert resolved, firing alerts
+	// are never pruned
+	AlertMaxAge time.Duration
+
+	Interval time.Duration
+	// BatchSize rows are deleted per statement, with Pause in between so
+	// that ingestion gets the database in the meantime, a negative Pause
+	// goes without
+	BatchSize int
+	Pause     time.Duration
+}
+
+func (o *RetentionOptions) withDefaults() RetentionOptions {
+	opts := *o
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultRetentionInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.Pause == 0 {
+		opts.Pause = 10 * time.Millisecond
+	}
+	return opts
+}
+
+// RetentionStats counts the rows pruned per table since the pruner started
+type RetentionStats struct {
+	Runs       int
+	LastRunAt  *time.Time
+	LastError  string
+	Pruned     map[string]int64
+	LastPruned map[string]int64
+}
+
+// retentionTable describes how the rows of a table expire
+type retentionTable struct {
+	name  string
+	model any
+	// column is the time the age of a row is measured from
+	column string
+	// where limits the rows which may expire at all
+	where string
+	// maxAge of the table and of a group
+	maxAge      func(opts *RetentionOptions) time.Duration
+	groupMaxAge func(group *models.GroupConfig) *time.Duration
+	// deleteRows deletes the rows with ids, and whatever depends on them
+	deleteRows func(tx *gorm.DB, ids []uint) error
+}
+
+var retentionTables = []retentionTable{
+	{
+		name:        RetentionTableMetrics,
+		model:       &models.Metric{},
+		column:      "timestamp",
+		maxAge:      func(opts *RetentionOptions) time.Duration { return opts.MetricMaxAge },
+		groupMaxAge: func(group *models.GroupConfig) *time.Duration { return group.MetricRetention },
+		// the values are deleted first rather than left to the cascade of
+		// their foreign key, which a connection may not enforce
+		deleteRows: func(tx *gorm.DB, ids []uint) error {
+			if err := tx.Where("metric_id IN ?", ids).Delete(&models.MetricValue{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.Metric{}, ids).Error
+		},
+	},
+	{
+		name:        RetentionTableAlerts,
+		model:       &models.Alert{},
+		column:      "resolved_at",
+		where:       fmt.Sprintf("state = '%s'", models.AlertStateResolved),
+		maxAge:      func(opts *RetentionOptions) time.Duration { return opts.AlertMaxAge },
+		groupMaxAge: func(group *models.GroupConfig) *time.Duration { return group.AlertRetention },
+		deleteRows: func(tx *gorm.DB, ids []uint) error {
+			if err := tx.Where("alert_id IN ?", ids).Delete(&models.WebhookDelivery{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.Alert{}, ids).Error
+		},
+	},
+}
+
+// RetentionPruner deletes expired metrics and alerts in the background every
+// interval. Rows go in small batches, each in its own transaction, so writes
+// of ingestion are never held up for long.
+type RetentionPruner struct {
+	conn   *gorm.DB
+	opts   RetentionOptions
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	stats RetentionStats
+
+	stop    chan struct{}
+	done    chan struct{}
+	closing sync.Once
+}
+
+func NewRetentionPruner(conn *gorm.DB, opts RetentionOptions) *RetentionPruner {
+	opts = opts.withDefaults()
+
+	p := &RetentionPruner{
+		conn: conn,
+		opts: opts,
+		logger: common.GetLoggerWith(
+			common.LoggerNameIOTCore,
+			zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTRetention),
+		),
+		stats: RetentionStats{Pruned: map[string]int64{}, LastPruned: map[string]int64{}},
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+// Options returns the retention the pruner was created with
+func (p *RetentionPruner) Options() RetentionOptions {
+	return p.opts
+}
+
+// Stats returns a copy of the counters of the pruner
+func (p *RetentionPruner) Stats() RetentionStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Pruned = make(map[string]int64, len(p.stats.Pruned))
+	for table, count := range p.stats.Pruned {
+		stats.Pruned[table] = count
+	}
+	stats.LastPruned = make(map[string]int64, len(p.stats.LastPruned))
+	for table, count := range p.stats.LastPruned {
+		stats.LastPruned[table] = count
+	}
+	return stats
+}
+
+// Close stops the pruner, a running batch is finished but the rest of the
+// run is left to the next start
+func (p *RetentionPruner) Close() {
+	p.closing.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+}
+
+func (p *RetentionPruner) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			pruned, err := p.prune(now.UTC())
+			p.record(now.UTC(), pruned, err)
+			if err != nil {
+				p.logger.Error("Failed to prune expired rows", zap.Error(err), zap.Reflect("pruned", pruned))
+			} else {
+				p.logger.Info("Pruned expired rows", zap.Reflect("pruned", pruned))
+			}
+		}
+	}
+}
+
+func (p *RetentionPruner) record(now time.Time, pruned map[string]int64, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Runs++
+	p.stats.LastRunAt = &now
+	p.stats.LastError = ""
+	if err != nil {
+		p.stats.LastError = err.Error()
+	}
+	p.stats.LastPruned = pruned
+	for table, count := range pruned {
+		p.stats.Pruned[table] += count
+	}
+}
+
+// prune deletes the rows of every table expired at now and returns how many
+// went per table
+func (p *RetentionPruner) prune(now time.Time) (map[string]int64, error) {
+	var groups []models.GroupConfig
+	if err := p.conn.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	var tags []models.DeviceTag
+	if err := p.conn.Order("tag").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	pruned := map[string]int64{}
+	for idx := range retentionTables {
+		table := &retentionTables[idx]
+		count, err := p.pruneTable(table, now, groups, tags)
+		pruned[table.name] = count
+		if err != nil {
+			return pruned, fmt.Errorf("pruning %s: %w", table.name, err)
+		}
+	}
+	return pruned, nil
+}
+
+// groupMaxAges returns the max age of every device with a group overriding
+// the one of table, the group of the highest priority wins
+func groupMaxAges(table *retentionTable, groups []models.GroupConfig, tags []models.DeviceTag) map[string]time.Duration {
+	overriding := map[string]models.GroupConfig{}
+	for _, group := range groups {
+		if table.groupMaxAge(&group) != nil {
+			overriding[group.Tag] = group
+		}
+	}
+
+	byDevice := map[string][]models.GroupConfig{}
+	for _, tag := range tags {
+		if group, ok := overriding[tag.Tag]; ok {
+			byDevice[tag.DeviceID] = append(byDevice[tag.DeviceID], group)
+		}
+	}
+
+	maxAges := map[string]time.Duration{}
+	for deviceID, deviceGroups := range byDevice {
+		// stable, so groups of the same priority stay in tag order, the same
+		// as when resolving configs
+		slices.SortStableFunc(deviceGroups, func(a, b models.GroupConfig) int { return b.Priority - a.Priority })
+		maxAges[deviceID] = *table.groupMaxAge(&deviceGroups[0])
+	}
+	return maxAges
+}
+
+func (p *RetentionPruner) pruneTable(table *retentionTable, now time.Time, groups []models.GroupConfig, tags []models.DeviceTag) (int64, error) {
+	maxAges := groupMaxAges(table, groups, tags)
+
+	var total int64
+
+	// devices of the same group max age go together
+	devicesByAge := map[time.Duration][]string{}
+	for deviceID, maxAge := range maxAges {
+		devicesByAge[maxAge] = append(devicesByAge[maxAge], deviceID)
+	}
+	for maxAge, deviceIDs := range devicesByAge {
+		if maxAge <= 0 {
+			continue
+		}
+		slices.Sort(deviceIDs)
+		count, err := p.pruneBatches(table, now.Add(-maxAge), func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("device_id IN ?", deviceIDs)
+		})
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if maxAge := table.maxAge(&p.opts); maxAge > 0 {
+		overridden := make([]string, 0, len(maxAges))
+		for deviceID := range maxAges {
+			overridden = append(overridden, deviceID)
+		}
+		count, err := p.pruneBatches(table, now.Add(-maxAge), func(tx *gorm.DB) *gorm.DB {
+			if len(overridden) == 0 {
+				return tx
+			}
+			return tx.Where("device_id NOT IN ?", overridden)
+		})
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// pruneBatches deletes the rows of table older than cutoff and matching scope
+// batch by batch, until none is left or the pruner is closed
+func (p *RetentionPruner) pruneBatches(table *retentionTable, cutoff time.Time, scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	var total int64
+	for {
+		select {
+		case <-p.stop:
+			return total, nil
+		default:
+		}
+
+		var ids []uint
+		err := p.conn.Transaction(func(tx *gorm.DB) error {
+			query := scope(tx.Model(table.model)).Where(table.column+" < ?", cutoff)
+			if table.where != "" {
+				query = query.Where(table.where)
+			}
+			if err := query.Order("id").Limit(p.opts.BatchSize).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			return table.deleteRows(tx, ids)
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += int64(len(ids))
+		if len(ids) < p.opts.BatchSize {
+			return total, nil
+		}
+
+		if p.opts.Pause > 0 {
+			select {
+			case <-p.stop:
+				return total, nil
+			case <-time.After(p.opts.Pause):
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package iot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+func TestRetentionPruner_Prune(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	shortTag, keepTag := "g-"+uuid.NewString(), "g-"+uuid.NewString()
+	_, err := iotObj.Group.UpsertGroupConfig(shortTag, &models.GroupConfig{MetricRetention: ptr(2 * time.Hour)})
+	require.NoError(t, err)
+	// the higher priority keeps the metrics of a device in both groups
+	_, err = iotObj.Group.UpsertGroupConfig(keepTag, &models.GroupConfig{Priority: 1, MetricRetention: ptr(time.Duration(0))})
+	require.NoError(t, err)
+
+	defaultID, shortID, keepID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	deviceTags := map[string][]string{defaultID: nil, shortID: {shortTag}, keepID: {shortTag, keepTag}}
+	now := time.Now().UTC()
+	for id, tags := range deviceTags {
+		_, err := iotObj.Device.CreateDevice(&models.Device{ID: id, Tags: tags, Enabled: true}, models.ChangeOrigin{})
+		require.NoError(t, err)
+		err = iotObj.Config.UpsertConfig(id, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+		require.NoError(t, err)
+		for _, age := range []time.Duration{48 * time.Hour, 3 * time.Hour, 0} {
+			err = iotObj.Metric.UpsertMetric(id, &models.Metric{
+				Timestamp:   now.Add(-age),
+				Temperature: 20.0,
+				Battery:     50.0,
+				Values:      []models.MetricValue{{Name: "humidity", Value: 40.0}},
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	resolvedAt := now.Add(-48 * time.Hour)
+	expired := models.Alert{DeviceID: defaultID, Timestamp: resolvedAt, Type: models.AlertTypeBattery,
+		State: models.AlertStateResolved, Status: models.AlertStatusResolved, ResolvedAt: &resolvedAt}
+	firing := models.Alert{DeviceID: defaultID, Timestamp: resolvedAt, Type: models.AlertTypeTemperature,
+		State: models.AlertStateFiring, Status: models.AlertStatusOpen}
+	require.NoError(t, iotObj.Db.Conn.Create(&expired).Error)
+	require.NoError(t, iotObj.Db.Conn.Create(&firing).Error)
+	require.NoError(t, iotObj.Db.Conn.Create(&models.WebhookDelivery{AlertID: expired.ID, URL: "http://localhost"}).Error)
+
+	// prune through connections not enforcing foreign keys, the values of
+	// pruned metrics must not be left to the cascade
+	dialector := iotObj.Db.Conn.Dialector.(*sqlite.Dialector)
+	conn, err := gorm.Open(sqlite.Open(strings.Replace(dialector.DSN, "_foreign_keys=1", "_foreign_keys=0", 1)), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	pruner := NewRetentionPruner(conn, RetentionOptions{
+		MetricMaxAge: 24 * time.Hour,
+		AlertMaxAge:  24 * time.Hour,
+		BatchSize:    2,
+		Pause:        -1,
+	})
+	defer pruner.Close()
+
+	pruned, err := pruner.prune(now)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, pruned[RetentionTableMetrics], int64(3))
+	assert.GreaterOrEqual(t, pruned[RetentionTableAlerts], int64(1))
+
+	countMetrics := func(deviceID string) int64 {
+		var count int64
+		require.NoError(t, iotObj.Db.Conn.Model(&models.Metric{}).Where("device_id = ?", deviceID).Count(&count).Error)
+		return count
+	}
+	assert.Equal(t, int64(2), countMetrics(defaultID))
+	assert.Equal(t, int64(1), countMetrics(shortID))
+	assert.Equal(t, int64(3), countMetrics(keepID))
+
+	// the values of pruned metrics went along
+	var orphans int64
+	err = iotObj.Db.Conn.Model(&models.MetricValue{}).
+		Where("metric_id NOT IN (?)", iotObj.Db.Conn.Model(&models.Metric{}).Select("id")).
+		Count(&orphans).Error
+	require.NoError(t, err)
+	assert.Zero(t, orphans)
+
+	var alertIDs []uint
+	require.NoError(t, iotObj.Db.Conn.Model(&models.Alert{}).Where("device_id = ?", defaultID).Pluck("id", &alertIDs).Error)
+	assert.Equal(t, []uint{firing.ID}, alertIDs)
+	var deliveries int64
+	require.NoError(t, iotObj.Db.Conn.Model(&models.WebhookDelivery{}).Where("alert_id = ?", expired.ID).Count(&deliveries).Error)
+	assert.Zero(t, deliveries)
+
+	// nothing is left to prune
+	pruned, err = pruner.prune(now)
+	require.NoError(t, err)
+	assert.Zero(t, pruned[RetentionTableMetrics])
+	assert.Zero(t, pruned[RetentionTableAlerts])
+
+	_, err = iotObj.Group.UpsertGroupConfig(shortTag, &models.GroupConfig{AlertRetention: ptr(-time.Hour)})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestRetentionPruner_Run(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0), BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now().Add(-1000 * time.Hour), Temperature: 20.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	pruner := NewRetentionPruner(iotObj.Db.Conn, RetentionOptions{MetricMaxAge: 999 * time.Hour, Interval: 10 * time.Millisecond})
+	defer pruner.Close()
+
+	require.Eventually(t, func() bool {
+		stats := pruner.Stats()
+		return stats.Runs > 0 && stats.Pruned[RetentionTableMetrics] > 0
+	}, time.Second, 10*time.Millisecond)
+
+	stats := pruner.Stats()
+	assert.Empty(t, stats.LastError)
+	assert.NotNil(t, stats.LastRunAt)
+	assert.Equal(t, 1000, pruner.Options().BatchSize)
+
+	pruner.Close()
+	// closing twice is fine
+	pruner.Close()
+}
@@ -0,0 +1,316 @@
+package iot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+var ruleOperators = []models.RuleOperator{
+	models.RuleOperatorGT,
+	models.RuleOperatorGTE,
+	models.RuleOperatorLT,
+	models.RuleOperatorLTE,
+	models.RuleOperatorEQ,
+	models.RuleOperatorOutside,
+}
+
+var alertSeverities = []models.AlertSeverity{
+	models.AlertSeverityInfo,
+	models.AlertSeverityWarning,
+	models.AlertSeverityCritical,
+}
+
+// MaxRuleHoldSamples bounds the metric history read for one rule
+const MaxRuleHoldSamples = 100
+
+// ValidateRule checks the metric name, operator, severity and range of a
+// rule. An empty severity is valid and stored as warning.
+func ValidateRule(rule *models.AlertRule) error {
+	if !metricValueNamePattern.MatchString(rule.Metric) {
+		return fmt.Errorf("%w: metric name %q must match %s", ErrInvalidRule, rule.Metric, metricValueNamePattern)
+	}
+	if !slices.Contains(ruleOperators, rule.Operator) {
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidRule, rule.Operator)
+	}
+	if rule.Severity != "" && !slices.Contains(alertSeverities, rule.Severity) {
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidRule, rule.Severity)
+	}
+	if rule.Hysteresis < 0 {
+		return fmt.Errorf("%w: hysteresis %.2f is negative", ErrInvalidRule, rule.Hysteresis)
+	}
+	if rule.HoldFor < 0 || rule.HoldSamples < 0 {
+		return fmt.Errorf("%w: hold duration and samples can not be negative", ErrInvalidRule)
+	}
+	if rule.HoldSamples > MaxRuleHoldSamples {
+		return fmt.Errorf("%w: hold samples %d is more than %d", ErrInvalidRule, rule.HoldSamples, MaxRuleHoldSamples)
+	}
+	if rule.Operator == models.RuleOperatorOutside && rule.UpperThreshold < rule.Threshold {
+		return fmt.Errorf("%w: upper threshold %.2f is below threshold %.2f", ErrInvalidRule, rule.UpperThreshold, rule.Threshold)
+	}
+	return nil
+}
+
+// matchRule tells whether value breaks the rule
+func matchRule(rule *models.AlertRule, value float64) bool {
+	switch rule.Operator {
+	case models.RuleOperatorGT:
+		return value > rule.Threshold
+	case models.RuleOperatorGTE:
+		return value >= rule.Threshold
+	case models.RuleOperatorLT:
+		return value < rule.Threshold
+	case models.RuleOperatorLTE:
+		return value <= rule.Threshold
+	case models.RuleOperatorEQ:
+		return value == rule.Threshold
+	case models.RuleOperatorOutside:
+		return value < rule.Threshold || value > rule.UpperThreshold
+	}
+	return false
+}
+
+// clearsRule tells whether value is back inside the rule by at least the
+// hysteresis band, which resolves a firing alert of the rule
+func clearsRule(rule *models.AlertRule, value float64) bool {
+	h := rule.Hysteresis
+	switch rule.Operator {
+	case models.RuleOperatorGT:
+		return value <= rule.Threshold-h
+	case models.RuleOperatorGTE:
+		return value < rule.Threshold-h
+	case models.RuleOperatorLT:
+		return value >= rule.Threshold+h
+	case models.RuleOperatorLTE:
+		return value > rule.Threshold+h
+	case models.RuleOperatorEQ:
+		return math.Abs(value-rule.Threshold) > h
+	case models.RuleOperatorOutside:
+		return value >= rule.Threshold+h && value <= rule.UpperThreshold-h
+	}
+	return true
+}
+
+// ruleMessage describes the breach, e.g. "Temperature 35.00 exceeded threshold 30.00",
+// suffixed with the hold condition of the rule if any
+func ruleMessage(rule *models.AlertRule, value float64) string {
+	message := ruleBreachMessage(rule, value)
+	if rule.HoldFor > 0 {
+		message += fmt.Sprintf(" for %s", rule.HoldFor)
+	}
+	if rule.HoldSamples > 1 {
+		message += fmt.Sprintf(" in %d consecutive samples", rule.HoldSamples)
+	}
+	return message
+}
+
+func ruleBreachMessage(rule *models.AlertRule, value float64) string {
+	name := strings.ToUpper(rule.Metric[:1]) + rule.Metric[1:]
+	switch rule.Operator {
+	case models.RuleOperatorGT:
+		return fmt.Sprintf("%s %.2f exceeded threshold %.2f", name, value, rule.Threshold)
+	case models.RuleOperatorGTE:
+		return fmt.Sprintf("%s %.2f reached threshold %.2f", name, value, rule.Threshold)
+	case models.RuleOperatorLT:
+		return fmt.Sprintf("%s %.2f below threshold %.2f", name, value, rule.Threshold)
+	case models.RuleOperatorLTE:
+		return fmt.Sprintf("%s %.2f at or below threshold %.2f", name, value, rule.Threshold)
+	case models.RuleOperatorEQ:
+		return fmt.Sprintf("%s %.2f equals threshold %.2f", name, value, rule.Threshold)
+	case models.RuleOperatorOutside:
+		return fmt.Sprintf("%s %.2f outside range [%.2f, %.2f]", name, value, rule.Threshold, rule.UpperThreshold)
+	}
+	return fmt.Sprintf("%s %.2f", name, value)
+}
+
+// configRules are the built-in rules expressed by the thresholds of a device
+// config, they are evaluated before the stored rules of the device. An unset
+// threshold has no rule.
+func configRules(config *models.Config) []models.AlertRule {
+	var rules []models.AlertRule
+	if config.TemperatureThreshold != nil {
+		rules = append(rules, models.AlertRule{
+			DeviceID:   config.DeviceID,
+			Metric:     models.MetricNameTemperature,
+			Operator:   models.RuleOperatorGT,
+			Threshold:  *config.TemperatureThreshold,
+			Severity:   models.AlertSeverityWarning,
+			Hysteresis: common.ValueOrZero(config.TemperatureHysteresis),
+		})
+	}
+	if config.BatteryThreshold != nil {
+		rules = append(rules, models.AlertRule{
+			DeviceID:   config.DeviceID,
+			Metric:     models.MetricNameBattery,
+			Operator:   models.RuleOperatorLT,
+			Threshold:  *config.BatteryThreshold,
+			Severity:   models.AlertSeverityWarning,
+			Hysteresis: common.ValueOrZero(config.BatteryHysteresis),
+		})
+	}
+	return rules
+}
+
+func copyRule(deviceID string, input *models.AlertRule) models.AlertRule {
+	rule := models.AlertRule{
+		DeviceID:       deviceID,
+		Metric:         input.Metric,
+		Operator:       input.Operator,
+		Threshold:      input.Threshold,
+		UpperThreshold: input.UpperThreshold,
+		Severity:       input.Severity,
+		Hysteresis:     input.Hysteresis,
+		HoldFor:        input.HoldFor,
+		HoldSamples:    input.HoldSamples,
+	}
+	if rule.Severity == "" {
+		rule.Severity = models.AlertSeverityWarning
+	}
+	return rule
+}
+
+func (i *IOT) createRule(deviceID string, input *models.AlertRule) (*models.AlertRule, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTAlert),
+	)
+
+	if err := ValidateRule(input); err != nil {
+		return nil, err
+	}
+
+	rule := copyRule(deviceID, input)
+	if err := i.Db.Conn.Create(&rule).Error; err != nil {
+		return nil, err
+	}
+
+	logger.Info("Created alert rule", zap.Reflect("rule", rule))
+
+	return &rule, nil
+}
+
+func (i *IOT) getDeviceRules(deviceID string) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	err := i.Db.Conn.
+		Where("device_id = ?", deviceID).
+		Order("id").
+		Find(&rules).Error
+	return rules, err
+}
+
+func (i *IOT) getDeviceRule(deviceID string, id uint) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := i.Db.Conn.First(&rule, "device_id = ? AND id = ?", deviceID, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (i *IOT) updateRule(deviceID string, id uint, input *models.AlertRule) (*models.AlertRule, error) {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTAlert),
+	)
+
+	if err := ValidateRule(input); err != nil {
+		return nil, err
+	}
+
+	if _, err := i.getDeviceRule(deviceID, id); err != nil {
+		return nil, err
+	}
+
+	rule := copyRule(deviceID, input)
+	rule.ID = id
+	if err := i.Db.Conn.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+
+	logger.Info("Updated alert rule", zap.Reflect("rule", rule))
+
+	return &rule, nil
+}
+
+// deleteRule removes the rule and resolves the alerts it fired
+func (i *IOT) deleteRule(deviceID string, id uint) error {
+	logger := common.GetLoggerWith(
+		common.LoggerNameIOTCore,
+		zap.String(common.LoggerFieldIOTCategory, common.LoggerCategoryIOTAlert),
+	)
+
+	result := i.Db.Conn.Where("device_id = ? AND id = ?", deviceID, id).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+
+	logger.Info("Deleted alert rule", zap.String("device_id", deviceID), zap.Uint("id", id))
+
+	// the alerts of the rule can not clear anymore
+	firing, err := i.getFiringAlerts(deviceID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for idx := range firing {
+		if firing[idx].RuleID == nil || *firing[idx].RuleID != id {
+			continue
+		}
+
+		alert, err := i.clearAlert(&firing[idx], now)
+		if err != nil {
+			return err
+		}
+		if alert == nil {
+			continue
+		}
+
+		logger.Info("Alert resolved with its rule", zap.Reflect("alert", alert))
+
+		i.publishAlert(alert)
+	}
+
+	return nil
+}
+
+type IRuleImpl struct {
+	iot *IOT
+}
+
+func (ir *IRuleImpl) CreateRule(deviceID string, input *models.AlertRule) (*models.AlertRule, error) {
+	return ir.iot.createRule(deviceID, input)
+}
+
+func (ir *IRuleImpl) GetDeviceRules(deviceID string) ([]models.AlertRule, error) {
+	return ir.iot.getDeviceRules(deviceID)
+}
+
+func (ir *IRuleImpl) GetDeviceRule(deviceID string, id uint) (*models.AlertRule, error) {
+	return ir.iot.getDeviceRule(deviceID, id)
+}
+
+func (ir *IRuleImpl) UpdateRule(deviceID string, id uint, input *models.AlertRule) (*models.AlertRule, error) {
+	return ir.iot.updateRule(deviceID, id, input)
+}
+
+func (ir *IRuleImpl) DeleteRule(deviceID string, id uint) error {
+	return ir.iot.deleteRule(deviceID, id)
+}
+
+func (i *IOT) GetIRule() IRule {
+	return &IRuleImpl{iot: i}
+}
@@ -0,0 +1,253 @@
+package iot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+func TestMatchRule(t *testing.T) {
+	cases := []struct {
+		rule  models.AlertRule
+		value float64
+		match bool
+	}{
+		{models.AlertRule{Operator: models.RuleOperatorGT, Threshold: 10}, 10, false},
+		{models.AlertRule{Operator: models.RuleOperatorGT, Threshold: 10}, 10.1, true},
+		{models.AlertRule{Operator: models.RuleOperatorGTE, Threshold: 10}, 10, true},
+		{models.AlertRule{Operator: models.RuleOperatorLT, Threshold: 10}, 10, false},
+		{models.AlertRule{Operator: models.RuleOperatorLT, Threshold: 10}, 9.9, true},
+		{models.AlertRule{Operator: models.RuleOperatorLTE, Threshold: 10}, 10, true},
+		{models.AlertRule{Operator: models.RuleOperatorEQ, Threshold: 0}, 0, true},
+		{models.AlertRule{Operator: models.RuleOperatorEQ, Threshold: 0}, 1, false},
+		{models.AlertRule{Operator: models.RuleOperatorOutside, Threshold: 20, UpperThreshold: 60}, 20, false},
+		{models.AlertRule{Operator: models.RuleOperatorOutside, Threshold: 20, UpperThreshold: 60}, 60, false},
+		{models.AlertRule{Operator: models.RuleOperatorOutside, Threshold: 20, UpperThreshold: 60}, 19, true},
+		{models.AlertRule{Operator: models.RuleOperatorOutside, Threshold: 20, UpperThreshold: 60}, 61, true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, matchRule(&c.rule, c.value), "%s %v against %v", c.rule.Operator, c.rule.Threshold, c.value)
+	}
+}
+
+func TestClearsRule(t *testing.T) {
+	cases := []struct {
+		rule  models.AlertRule
+		value float64
+		clear bool
+	}{
+		{models.AlertRule{Operator: models.RuleOperatorGT, Threshold: 10}, 10, true},
+		{models.AlertRule{Operator: models.RuleOperatorGT, Threshold: 10, Hysteresis: 1}, 9.5, false},
+		{models.AlertRule{Operator: models.RuleOperatorGT, Threshold: 10, Hysteresis: 1}, 9, true},
+		{models.AlertRule{Operator: models.RuleOperatorGTE, Threshold: 10}, 10, false},
+		{models.AlertRule{Operator: models.RuleOperatorLT, Threshold: 10, Hysteresis: 1}, 10.5, false},
+		{models.AlertRule{Operator: models.RuleOperatorLT, Threshold: 10, Hysteresis: 1}, 11, true},
+		{models.AlertRule{Operator: models.RuleOperatorLTE, Threshold: 10}, 10.1, true},
+		{models.AlertRule{Operator: models.RuleOperatorEQ, Threshold: 0, Hysteresis: 0.5}, 0.5, false},
+		{models.AlertRule{Operator: models.RuleOperatorEQ, Threshold: 0, Hysteresis: 0.5}, 0.6, true},
+		{models.AlertRule{Operator: models.RuleOperatorOutside, Threshold: 20, UpperThreshold: 60, Hysteresis: 5}, 22, false},
+		{models.AlertRule{Operator: models.RuleOperatorOutside, Threshold: 20, UpperThreshold: 60, Hysteresis: 5}, 58, false},
+		{models.AlertRule{Operator: models.RuleOperatorOutside, Threshold: 20, UpperThreshold: 60, Hysteresis: 5}, 40, true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.clear, clearsRule(&c.rule, c.value), "%s %v ±%v against %v", c.rule.Operator, c.rule.Threshold, c.rule.Hysteresis, c.value)
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	assert.NoError(t, ValidateRule(&models.AlertRule{Metric: "humidity", Operator: models.RuleOperatorGT}))
+	assert.NoError(t, ValidateRule(&models.AlertRule{Metric: "temperature", Operator: models.RuleOperatorGTE, Severity: models.AlertSeverityCritical}))
+
+	for _, rule := range []models.AlertRule{
+		{Metric: "", Operator: models.RuleOperatorGT},
+		{Metric: "Humidity", Operator: models.RuleOperatorGT},
+		{Metric: "humidity", Operator: "!="},
+		{Metric: "humidity", Operator: models.RuleOperatorGT, Severity: "fatal"},
+		{Metric: "humidity", Operator: models.RuleOperatorGT, Hysteresis: -1},
+		{Metric: "humidity", Operator: models.RuleOperatorGT, HoldFor: -time.Minute},
+		{Metric: "humidity", Operator: models.RuleOperatorGT, HoldSamples: MaxRuleHoldSamples + 1},
+		{Metric: "humidity", Operator: models.RuleOperatorOutside, Threshold: 60, UpperThreshold: 20},
+	} {
+		assert.ErrorIs(t, ValidateRule(&rule), ErrInvalidRule, "expected %+v to be invalid", rule)
+	}
+}
+
+func TestRuleCRUD(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	rule, err := iotObj.Rule.CreateRule(deviceID, &models.AlertRule{
+		Metric:    "humidity",
+		Operator:  models.RuleOperatorGT,
+		Threshold: 80,
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, rule.ID)
+	assert.Equal(t, deviceID, rule.DeviceID)
+	assert.Equal(t, models.AlertSeverityWarning, rule.Severity)
+
+	_, err = iotObj.Rule.CreateRule(deviceID, &models.AlertRule{Metric: "humidity", Operator: "~"})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+
+	rules, err := iotObj.Rule.GetDeviceRules(deviceID)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	updated, err := iotObj.Rule.UpdateRule(deviceID, rule.ID, &models.AlertRule{
+		Metric:         "humidity",
+		Operator:       models.RuleOperatorOutside,
+		Threshold:      20,
+		UpperThreshold: 60,
+		Severity:       models.AlertSeverityCritical,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rule.ID, updated.ID)
+
+	got, err := iotObj.Rule.GetDeviceRule(deviceID, rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RuleOperatorOutside, got.Operator)
+	assert.Equal(t, 60.0, got.UpperThreshold)
+	assert.Equal(t, models.AlertSeverityCritical, got.Severity)
+
+	// rules are scoped by device
+	_, err = iotObj.Rule.GetDeviceRule(uuid.NewString(), rule.ID)
+	assert.ErrorIs(t, err, ErrRuleNotFound)
+	_, err = iotObj.Rule.UpdateRule(uuid.NewString(), rule.ID, got)
+	assert.ErrorIs(t, err, ErrRuleNotFound)
+
+	require.NoError(t, iotObj.Rule.DeleteRule(deviceID, rule.ID))
+	assert.ErrorIs(t, iotObj.Rule.DeleteRule(deviceID, rule.ID), ErrRuleNotFound)
+
+	rules, err = iotObj.Rule.GetDeviceRules(deviceID)
+	require.NoError(t, err)
+	assert.Len(t, rules, 0)
+}
+
+func TestCheckAndStoreAlerts_Rules(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+
+	// config thresholds are never breached here, so only the rules fire
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{
+		TemperatureThreshold: ptr(100.0),
+		BatteryThreshold:     ptr(0.0),
+	}, models.ChangeOrigin{})
+	require.NoError(t, err)
+
+	humidityRule, err := iotObj.Rule.CreateRule(deviceID, &models.AlertRule{
+		Metric:         "humidity",
+		Operator:       models.RuleOperatorOutside,
+		Threshold:      20,
+		UpperThreshold: 60,
+		Severity:       models.AlertSeverityCritical,
+	})
+	require.NoError(t, err)
+
+	_, err = iotObj.Rule.CreateRule(deviceID, &models.AlertRule{
+		Metric:    "temperature",
+		Operator:  models.RuleOperatorGTE,
+		Threshold: 40,
+		Severity:  models.AlertSeverityInfo,
+	})
+	require.NoError(t, err)
+
+	// rssi is not reported, so its rule is skipped
+	_, err = iotObj.Rule.CreateRule(deviceID, &models.AlertRule{
+		Metric:    "rssi",
+		Operator:  models.RuleOperatorLT,
+		Threshold: -90,
+	})
+	require.NoError(t, err)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{
+		Timestamp:   time.Now(),
+		Temperature: 30,
+		Battery:     50,
+		Values:      []models.MetricValue{{Name: "humidity", Value: 45}},
+	})
+	require.NoError(t, err)
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	alerts := page.Alerts
+	assert.Len(t, alerts, 0)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{
+		Timestamp:   time.Now(),
+		Temperature: 40,
+		Battery:     50,
+		Values:      []models.MetricValue{{Name: "humidity", Value: 75}},
+	})
+	require.NoError(t, err)
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	alerts = page.Alerts
+	require.Len(t, alerts, 2)
+
+	byType := map[models.AlertType]models.Alert{}
+	for _, alert := range alerts {
+		byType[alert.Type] = alert
+	}
+
+	humidity := byType["humidity"]
+	assert.Equal(t, models.AlertSeverityCritical, humidity.Severity)
+	require.NotNil(t, humidity.RuleID)
+	assert.Equal(t, humidityRule.ID, *humidity.RuleID)
+	assert.Equal(t, "Humidity 75.00 outside range [20.00, 60.00]", humidity.Message)
+
+	temperature := byType[models.AlertTypeTemperature]
+	assert.Equal(t, models.AlertSeverityInfo, temperature.Severity)
+	assert.Equal(t, "Temperature 40.00 reached threshold 40.00", temperature.Message)
+}
+
+func TestCheckAndStoreAlerts_RuleRemoved(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl, iotObj, _, _, _ := GetMockIOTWithMemorySqliteDialector(t, false, false, false)
+	defer ctrl.Finish()
+
+	deviceID := uuid.NewString()
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	rule, err := iotObj.Rule.CreateRule(deviceID, &models.AlertRule{Metric: "humidity", Operator: models.RuleOperatorGT, Threshold: 60})
+	require.NoError(t, err)
+
+	metric := models.Metric{Timestamp: time.Now(), Temperature: 35, Battery: 50, Values: []models.MetricValue{{Name: "humidity", Value: 75}}}
+	require.NoError(t, iotObj.Metric.UpsertMetric(deviceID, &metric))
+
+	firing := func() []models.AlertType {
+		page, err := iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{State: models.AlertStateFiring})
+		require.NoError(t, err)
+		return common.Mapper(page.Alerts, func(a models.Alert) models.AlertType { return a.Type })
+	}
+	assert.ElementsMatch(t, []models.AlertType{"humidity", models.AlertTypeTemperature}, firing())
+
+	// deleting the rule resolves its alert right away
+	require.NoError(t, iotObj.Rule.DeleteRule(deviceID, rule.ID))
+	assert.Equal(t, []models.AlertType{models.AlertTypeTemperature}, firing())
+
+	// an unset threshold resolves its alert with the next metrics
+	err = iotObj.Config.UpsertConfig(deviceID, &models.Config{BatteryThreshold: ptr(20.0)}, models.ChangeOrigin{})
+	require.NoError(t, err)
+	metric.Timestamp = time.Now()
+	require.NoError(t, iotObj.Metric.UpsertMetric(deviceID, &metric))
+	assert.Empty(t, firing())
+}
@@ -0,0 +1,96 @@
+package iot
+
+import (
+	"time"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+// Store keeps the metrics, alerts and configs of the devices. GormStore keeps
+// them in the database of IOT.Db, MemoryStore in memory for tests.
+//
+// The registry, groups and rules are read through the IDevice, IGroup and
+// IRule services, so the metric, alert and config services run on any Store.
+// The rule, device and group services of this package, the offline check, the
+// retention pruner and the webhook notifier work on the database tables
+// directly, WithServices and NewOfflineWatcher refuse them with another Store.
+type Store interface {
+	MetricStore
+	AlertStore
+	ConfigStore
+}
+
+type MetricStore interface {
+	// CreateMetrics stores metrics with their values all or none, and sets
+	// their IDs
+	CreateMetrics(metrics []models.Metric) error
+	// FindMetrics pages the metrics of the device with their values
+	FindMetrics(deviceID string, query *models.MetricQuery) (*models.MetricPage, error)
+	// FindMetricHistory returns the metrics of the device stored before
+	// metric, newest first, with their values
+	FindMetricHistory(deviceID string, metric *models.Metric, query *MetricHistoryQuery) ([]models.Metric, error)
+	// AggregateMetrics buckets the metrics of the device by query.Interval,
+	// oldest first
+	AggregateMetrics(deviceID string, query *models.MetricAggregateQuery) ([]models.MetricBucket, error)
+}
+
+// MetricHistoryQuery narrows the history of a metric, all fields are optional
+type MetricHistoryQuery struct {
+	// After is exclusive, Until inclusive
+	After *time.Time
+	Until *time.Time
+	Limit int
+}
+
+type AlertStore interface {
+	// SaveAlert inserts a new alert, or updates it when ID is set
+	SaveAlert(alert *models.Alert) error
+	// RepeatAlert counts another breach of a firing alert, last seen at seen.
+	// An alert no longer firing is left as it is.
+	RepeatAlert(id uint, seen time.Time) error
+	// FindFiringAlerts returns the firing alerts of the device, newest first
+	FindFiringAlerts(deviceID string) ([]models.Alert, error)
+	// FindAlerts pages the alerts matching query newest first, only the ones
+	// of the device unless deviceID is empty
+	FindAlerts(deviceID string, query *models.FleetAlertQuery) (*models.AlertPage, error)
+	// SummarizeAlerts counts the alerts matching query per device, ordered
+	// by device id. Limit and cursor of the query are not used.
+	SummarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error)
+	// TransitAlert changes the status of an alert of the device, if it is
+	// still in one of the statuses the transition is from. It returns
+	// ErrAlertNotFound or ErrAlertStatusConflict otherwise.
+	TransitAlert(deviceID string, id uint, transition *AlertTransition) (*models.Alert, error)
+}
+
+// AlertTransition is a status change of an alert by an operator, a transition
+// to resolved also resolves the state of the alert
+type AlertTransition struct {
+	From   []models.AlertStatus
+	Status models.AlertStatus
+	By     string
+	At     time.Time
+	// Note replaces the note of the alert unless empty
+	Note string
+}
+
+// ConfigUpdate returns the new config of the device from its current one, nil
+// when the device has no config. Returning nil deletes the config.
+type ConfigUpdate func(deviceID string, current *models.Config) (*models.Config, error)
+
+type ConfigStore interface {
+	// GetConfig returns the config of the device or ErrConfigNotFound
+	GetConfig(deviceID string) (*models.Config, error)
+	// UpdateConfigs changes the configs of the devices by update all or
+	// none, and records every change in the history with origin. The store
+	// numbers the versions and keeps LastSeenAt, a config changed by someone
+	// else meanwhile fails with ErrConfigConflict and a config still having
+	// metrics or alerts can not be deleted, see ErrConfigInUse. It returns
+	// the configs after the update, deleted ones left out.
+	UpdateConfigs(deviceIDs []string, origin models.ChangeOrigin, update ConfigUpdate) ([]models.Config, error)
+	// MarkConfigSeen sets LastSeenAt of the config of the device, if any,
+	// without changing its version
+	MarkConfigSeen(deviceID string, seen time.Time) error
+	// FindConfigChanges pages the history of the config of the device, newest
+	// first
+	FindConfigChanges(deviceID string, query *models.ConfigChangeQuery) (*models.ConfigChangePage, error)
+}
@@ -0,0 +1,482 @@
+package iot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/db"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+// GormStore keeps metrics, alerts and configs in the database of conn
+type GormStore struct {
+	conn *gorm.DB
+	// timeBucket aggregates metrics in the database with the time_bucket
+	// function of TimescaleDB, instead of scanning them
+	timeBucket bool
+}
+
+func NewGormStore(conn *gorm.DB) *GormStore {
+	timescale, err := db.HasTimescale(conn)
+	if err != nil {
+		common.GetLogger().Warn("Failed to detect TimescaleDB, metrics are aggregated without it", zap.Error(err))
+	}
+	return &GormStore{conn: conn, timeBucket: timescale}
+}
+
+func (s *GormStore) CreateMetrics(metrics []models.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	return s.conn.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&metrics).Error
+	})
+}
+
+func (s *GormStore) FindMetrics(deviceID string, query *models.MetricQuery) (*models.MetricPage, error) {
+	limit := normalizePageLimit(query.Limit)
+	desc := query.Order != models.SortOrderAsc
+
+	tx := s.conn.Where("device_id = ?", deviceID)
+	if query.From != nil {
+		tx = tx.Where("timestamp >= ?", query.From.UTC())
+	}
+	if query.To != nil {
+		tx = tx.Where("timestamp <= ?", query.To.UTC())
+	}
+
+	tx, err := applyTimeCursor(tx, query.Cursor, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, next, err := findPage(orderByTime(tx, desc).Preload("Values"), limit, func(last *models.Metric) string {
+		return encodeCursor(last.Timestamp, last.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MetricPage{Metrics: metrics, NextCursor: next}, nil
+}
+
+func (s *GormStore) FindMetricHistory(deviceID string, metric *models.Metric, query *MetricHistoryQuery) ([]models.Metric, error) {
+	tx := s.conn.
+		Preload("Values").
+		Where("device_id = ? AND timestamp <= ? AND id <> ?", deviceID, metric.Timestamp.UTC(), metric.ID)
+	if query.After != nil {
+		tx = tx.Where("timestamp > ?", query.After.UTC())
+	}
+	if query.Until != nil {
+		tx = tx.Where("timestamp <= ?", query.Until.UTC())
+	}
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+
+	var metrics []models.Metric
+	err := orderByTime(tx, true).Find(&metrics).Error
+	return metrics, err
+}
+
+func (s *GormStore) AggregateMetrics(deviceID string, query *models.MetricAggregateQuery) ([]models.MetricBucket, error) {
+	tx := s.conn.Model(&models.Metric{}).Where("device_id = ?", deviceID)
+	if query.From != nil {
+		tx = tx.Where("timestamp >= ?", query.From.UTC())
+	}
+	if query.To != nil {
+		tx = tx.Where("timestamp <= ?", query.To.UTC())
+	}
+
+	if s.timeBucket {
+		return s.timeBucketMetrics(tx, query.Interval)
+	}
+
+	rows, err := orderByTime(tx, false).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return bucketMetrics(query.Interval, func() (*models.Metric, error) {
+		if !rows.Next() {
+			return nil, rows.Err()
+		}
+		var metric models.Metric
+		if err := s.conn.ScanRows(rows, &metric); err != nil {
+			return nil, err
+		}
+		return &metric, nil
+	})
+}
+
+// timeBucketMetrics aggregates the metrics of tx in the database. The buckets
+// start from an origin aligned like time.Truncate, so they are the same as the
+// ones of bucketMetrics.
+func (s *GormStore) timeBucketMetrics(tx *gorm.DB, interval time.Duration) ([]models.MetricBucket, error) {
+	width := fmt.Sprintf("%d microseconds", interval.Microseconds())
+	origin := time.Unix(0, 0).UTC().Truncate(interval)
+
+	rows, err := tx.
+		Select("time_bucket(CAST(? AS interval), timestamp, CAST(? AS timestamptz)) AS bucket, COUNT(*), "+
+			"MIN(temperature), MAX(temperature), AVG(temperature), "+
+			"(ARRAY_AGG(temperature ORDER BY timestamp DESC, id DESC))[1], "+
+			"MIN(battery), MAX(battery), AVG(battery), "+
+			"(ARRAY_AGG(battery ORDER BY timestamp DESC, id DESC))[1]",
+			width, origin).
+		Group("bucket").
+		Order("bucket").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []models.MetricBucket{}
+	for rows.Next() {
+		var bucket models.MetricBucket
+		err := rows.Scan(&bucket.Start, &bucket.Count,
+			&bucket.Temperature.Min, &bucket.Temperature.Max, &bucket.Temperature.Avg, &bucket.Temperature.Last,
+			&bucket.Battery.Min, &bucket.Battery.Max, &bucket.Battery.Avg, &bucket.Battery.Last)
+		if err != nil {
+			return nil, err
+		}
+		bucket.Start = bucket.Start.UTC()
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+func (s *GormStore) SaveAlert(alert *models.Alert) error {
+	return s.conn.Save(alert).Error
+}
+
+// RepeatAlert updates conditionally and only the repeat columns, so an alert
+// acknowledged or resolved meanwhile keeps its status
+func (s *GormStore) RepeatAlert(id uint, seen time.Time) error {
+	return s.conn.
+		Model(&models.Alert{}).
+		Where("id = ? AND state = ?", id, models.AlertStateFiring).
+		Updates(map[string]any{"last_seen": seen, "count": gorm.Expr("count + 1")}).Error
+}
+
+func (s *GormStore) FindFiringAlerts(deviceID string) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := s.conn.
+		Where("device_id = ? AND state = ?", deviceID, models.AlertStateFiring).
+		Order("timestamp desc").
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// filterAlerts applies the filters of query except paging
+func filterAlerts(tx *gorm.DB, query *models.FleetAlertQuery) *gorm.DB {
+	if query.DevicePrefix != "" {
+		tx = tx.Where(`device_id LIKE ? ESCAPE '\'`, likeEscaper.Replace(query.DevicePrefix)+"%")
+	}
+	if query.Status != "" {
+		tx = tx.Where("status = ?", query.Status)
+	}
+	if query.State != "" {
+		tx = tx.Where("state = ?", query.State)
+	}
+	if len(query.Types) > 0 {
+		tx = tx.Where("type IN ?", query.Types)
+	}
+	if query.From != nil {
+		tx = tx.Where("timestamp >= ?", query.From.UTC())
+	}
+	if query.To != nil {
+		tx = tx.Where("timestamp <= ?", query.To.UTC())
+	}
+	return tx
+}
+
+// likeEscaper escapes the LIKE wildcards of a device prefix, with \ as the
+// escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *GormStore) FindAlerts(deviceID string, query *models.FleetAlertQuery) (*models.AlertPage, error) {
+	limit := normalizePageLimit(query.Limit)
+
+	tx := s.conn
+	if deviceID != "" {
+		tx = tx.Where("device_id = ?", deviceID)
+	}
+	tx, err := applyTimeCursor(filterAlerts(tx, query), query.Cursor, true)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, next, err := findPage(orderByTime(tx, true), limit, func(last *models.Alert) string {
+		return encodeCursor(last.Timestamp, last.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AlertPage{Alerts: alerts, NextCursor: next}, nil
+}
+
+func (s *GormStore) SummarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error) {
+	rows, err := filterAlerts(s.conn.Model(&models.Alert{}), query).
+		Select("device_id, COUNT(*), "+
+			"SUM(CASE WHEN state = ? THEN 1 ELSE 0 END), "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), "+
+			"MAX(timestamp)",
+			models.AlertStateFiring, models.AlertStatusOpen).
+		Group("device_id").
+		Order("device_id").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []models.AlertSummary{}
+	for rows.Next() {
+		var summary models.AlertSummary
+		var last any
+		if err := rows.Scan(&summary.DeviceID, &summary.Count, &summary.Firing, &summary.Open, &last); err != nil {
+			return nil, err
+		}
+		if summary.LastAlertAt, err = scanTime(last); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+// sqlite has no time type and returns aggregates of time columns as text in
+// the layout the driver stored them with
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// scanTime converts a time column read without a destination type
+func scanTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case []byte:
+		return scanTime(string(v))
+	case string:
+		for _, layout := range sqliteTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unknown time format %q", v)
+	case nil:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("unknown time value %T", value)
+}
+
+// TransitAlert updates conditionally, so concurrent operators can not both
+// acknowledge or resolve the same alert
+func (s *GormStore) TransitAlert(deviceID string, id uint, transition *AlertTransition) (*models.Alert, error) {
+	updates := map[string]any{"status": transition.Status}
+	if transition.Status == models.AlertStatusResolved {
+		updates["state"] = models.AlertStateResolved
+		updates["resolved_by"] = transition.By
+		updates["resolved_at"] = transition.At
+	} else {
+		updates["acknowledged_by"] = transition.By
+		updates["acknowledged_at"] = transition.At
+	}
+	if transition.Note != "" {
+		updates["note"] = transition.Note
+	}
+
+	result := s.conn.
+		Model(&models.Alert{}).
+		Where("device_id = ? AND id = ? AND status IN ?", deviceID, id, transition.From).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var alert models.Alert
+	err := s.conn.First(&alert, "device_id = ? AND id = ?", deviceID, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAlertNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: alert %d is %s", ErrAlertStatusConflict, id, alert.Status)
+	}
+
+	return &alert, nil
+}
+
+func (s *GormStore) GetConfig(deviceID string) (*models.Config, error) {
+	config, err := findConfig(s.conn, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, ErrConfigNotFound
+	}
+	return config, nil
+}
+
+// findConfig returns the config of the device, nil when it has none
+func findConfig(tx *gorm.DB, deviceID string) (*models.Config, error) {
+	var config models.Config
+	err := tx.First(&config, "device_id = ?", deviceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// configColumns are the columns of a config written by an update, LastSeenAt
+// is tracked by the metrics
+var configColumns = []string{
+	"temperature_threshold",
+	"battery_threshold",
+	"temperature_hysteresis",
+	"battery_hysteresis",
+	"heartbeat_interval",
+	"version",
+}
+
+func (s *GormStore) UpdateConfigs(deviceIDs []string, origin models.ChangeOrigin, update ConfigUpdate) ([]models.Config, error) {
+	var updated []models.Config
+	err := s.conn.Transaction(func(tx *gorm.DB) error {
+		for _, deviceID := range deviceIDs {
+			config, err := updateConfig(tx, deviceID, origin, update)
+			if err != nil {
+				return err
+			}
+			if config != nil {
+				updated = append(updated, *config)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func updateConfig(tx *gorm.DB, deviceID string, origin models.ChangeOrigin, update ConfigUpdate) (*models.Config, error) {
+	old, err := findConfig(tx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *models.Config
+	if old != nil {
+		copied := *old
+		current = &copied
+	}
+	config, err := update(deviceID, current)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case config == nil && old == nil:
+		return nil, nil
+
+	case config == nil:
+		for _, model := range []any{&models.Metric{}, &models.Alert{}} {
+			var ids []uint
+			if err := tx.Model(model).Where("device_id = ?", deviceID).Limit(1).Pluck("id", &ids).Error; err != nil {
+				return nil, err
+			}
+			if len(ids) > 0 {
+				return nil, fmt.Errorf("%w: device %s still has metrics or alerts", ErrConfigInUse, deviceID)
+			}
+		}
+		if err := tx.Where("device_id = ?", deviceID).Delete(&models.Config{}).Error; err != nil {
+			return nil, err
+		}
+
+	case old == nil:
+		config.DeviceID = deviceID
+		config.Version = 1
+		config.LastSeenAt = nil
+		// a config created by someone else meanwhile is left alone
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(config)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("%w: config created while updating", ErrConfigConflict)
+		}
+
+	default:
+		config.DeviceID = deviceID
+		config.Version = old.Version + 1
+		config.LastSeenAt = old.LastSeenAt
+		// the version guards against writers in between the read and the
+		// update
+		result := tx.Model(&models.Config{}).
+			Where("device_id = ? AND version = ?", deviceID, old.Version).
+			Select(configColumns).
+			Updates(config)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("%w: config changed while updating", ErrConfigConflict)
+		}
+	}
+
+	change := newConfigChange(deviceID, old, config, origin)
+	if err := tx.Create(&change).Error; err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (s *GormStore) MarkConfigSeen(deviceID string, seen time.Time) error {
+	return s.conn.Model(&models.Config{}).Where("device_id = ?", deviceID).UpdateColumn("last_seen_at", seen).Error
+}
+
+func (s *GormStore) FindConfigChanges(deviceID string, query *models.ConfigChangeQuery) (*models.ConfigChangePage, error) {
+	limit := normalizePageLimit(query.Limit)
+
+	tx := s.conn.Where("device_id = ?", deviceID)
+	if query.From != nil {
+		tx = tx.Where("timestamp >= ?", query.From.UTC())
+	}
+	if query.To != nil {
+		tx = tx.Where("timestamp <= ?", query.To.UTC())
+	}
+	tx, err := applyTimeCursor(tx, query.Cursor, true)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, next, err := findPage(orderByTime(tx, true), limit, func(last *models.ConfigChange) string {
+		return encodeCursor(last.Timestamp, last.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ConfigChangePage{Changes: changes, NextCursor: next}, nil
+}
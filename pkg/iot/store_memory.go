@@ -0,0 +1,441 @@
+package iot
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+)
+
+// MemoryStore keeps metrics, alerts and configs in memory, it is meant for
+// tests which do not need a database. All methods copy in and out, so callers
+// never share rows with the store.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	nextID  uint
+	metrics []models.Metric
+	alerts  []models.Alert
+	configs map[string]models.Config
+	changes []models.ConfigChange
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{configs: map[string]models.Config{}}
+}
+
+// id hands out increasing ids for all tables, the caller holds the lock
+func (s *MemoryStore) id() uint {
+	s.nextID++
+	return s.nextID
+}
+
+func copyMetric(metric models.Metric) models.Metric {
+	metric.Values = slices.Clone(metric.Values)
+	return metric
+}
+
+func copyAlert(alert models.Alert) models.Alert {
+	if alert.RuleID != nil {
+		ruleID := *alert.RuleID
+		alert.RuleID = &ruleID
+	}
+	if alert.ResolvedAt != nil {
+		resolvedAt := *alert.ResolvedAt
+		alert.ResolvedAt = &resolvedAt
+	}
+	if alert.AcknowledgedAt != nil {
+		acknowledgedAt := *alert.AcknowledgedAt
+		alert.AcknowledgedAt = &acknowledgedAt
+	}
+	return alert
+}
+
+func copyConfig(config models.Config) models.Config {
+	config.TemperatureThreshold = common.ClonePtr(config.TemperatureThreshold)
+	config.BatteryThreshold = common.ClonePtr(config.BatteryThreshold)
+	config.TemperatureHysteresis = common.ClonePtr(config.TemperatureHysteresis)
+	config.BatteryHysteresis = common.ClonePtr(config.BatteryHysteresis)
+	config.HeartbeatInterval = common.ClonePtr(config.HeartbeatInterval)
+	config.LastSeenAt = common.ClonePtr(config.LastSeenAt)
+	return config
+}
+
+// compareByTime orders rows by (timestamp, id), the same as orderByTime
+func compareByTime(ts1 time.Time, id1 uint, ts2 time.Time, id2 uint) int {
+	if c := ts1.Compare(ts2); c != 0 {
+		return c
+	}
+	return cmp.Compare(id1, id2)
+}
+
+func inTimeRange(ts time.Time, from *time.Time, to *time.Time) bool {
+	return (from == nil || !ts.Before(*from)) && (to == nil || !ts.After(*to))
+}
+
+// pageByTime sorts rows by (timestamp, id) and returns the page after cursor,
+// with the cursor of the next page if there is one
+func pageByTime[T any](rows []T, key func(*T) (time.Time, uint), cursor string, limit int, desc bool) ([]T, string, error) {
+	limit = normalizePageLimit(limit)
+
+	order := func(a, b T) int {
+		ts1, id1 := key(&a)
+		ts2, id2 := key(&b)
+		if desc {
+			return compareByTime(ts2, id2, ts1, id1)
+		}
+		return compareByTime(ts1, id1, ts2, id2)
+	}
+	slices.SortFunc(rows, order)
+
+	if cursor != "" {
+		cursorTs, cursorID, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		rows = slices.DeleteFunc(rows, func(row T) bool {
+			ts, id := key(&row)
+			c := compareByTime(ts, id, cursorTs, cursorID)
+			return (desc && c >= 0) || (!desc && c <= 0)
+		})
+	}
+
+	if len(rows) <= limit {
+		return rows, "", nil
+	}
+	rows = rows[:limit]
+	ts, id := key(&rows[limit-1])
+	return rows, encodeCursor(ts, id), nil
+}
+
+func metricKey(m *models.Metric) (time.Time, uint) { return m.Timestamp, m.ID }
+
+func alertKey(a *models.Alert) (time.Time, uint) { return a.Timestamp, a.ID }
+
+func (s *MemoryStore) CreateMetrics(metrics []models.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for idx := range metrics {
+		metric := &metrics[idx]
+		metric.ID = s.id()
+		for v := range metric.Values {
+			metric.Values[v].ID = s.id()
+			metric.Values[v].MetricID = metric.ID
+		}
+		s.metrics = append(s.metrics, copyMetric(*metric))
+	}
+	return nil
+}
+
+// deviceMetrics returns copies of the metrics of the device matching keep,
+// the caller holds the lock
+func (s *MemoryStore) deviceMetrics(deviceID string, keep func(*models.Metric) bool) []models.Metric {
+	metrics := []models.Metric{}
+	for idx := range s.metrics {
+		metric := &s.metrics[idx]
+		if metric.DeviceID == deviceID && keep(metric) {
+			metrics = append(metrics, copyMetric(*metric))
+		}
+	}
+	return metrics
+}
+
+func (s *MemoryStore) FindMetrics(deviceID string, query *models.MetricQuery) (*models.MetricPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metrics := s.deviceMetrics(deviceID, func(m *models.Metric) bool {
+		return inTimeRange(m.Timestamp, query.From, query.To)
+	})
+	metrics, next, err := pageByTime(metrics, metricKey, query.Cursor, query.Limit, query.Order != models.SortOrderAsc)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MetricPage{Metrics: metrics, NextCursor: next}, nil
+}
+
+func (s *MemoryStore) FindMetricHistory(deviceID string, metric *models.Metric, query *MetricHistoryQuery) ([]models.Metric, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metrics := s.deviceMetrics(deviceID, func(m *models.Metric) bool {
+		return m.ID != metric.ID && !m.Timestamp.After(metric.Timestamp) &&
+			(query.After == nil || m.Timestamp.After(*query.After)) &&
+			(query.Until == nil || !m.Timestamp.After(*query.Until))
+	})
+	slices.SortFunc(metrics, func(a, b models.Metric) int {
+		return compareByTime(b.Timestamp, b.ID, a.Timestamp, a.ID)
+	})
+	if query.Limit > 0 && len(metrics) > query.Limit {
+		metrics = metrics[:query.Limit]
+	}
+	return metrics, nil
+}
+
+func (s *MemoryStore) AggregateMetrics(deviceID string, query *models.MetricAggregateQuery) ([]models.MetricBucket, error) {
+	s.mu.RLock()
+	metrics := s.deviceMetrics(deviceID, func(m *models.Metric) bool {
+		return inTimeRange(m.Timestamp, query.From, query.To)
+	})
+	s.mu.RUnlock()
+
+	slices.SortFunc(metrics, func(a, b models.Metric) int {
+		return compareByTime(a.Timestamp, a.ID, b.Timestamp, b.ID)
+	})
+	return bucketMetrics(query.Interval, func() (*models.Metric, error) {
+		if len(metrics) == 0 {
+			return nil, nil
+		}
+		metric := &metrics[0]
+		metrics = metrics[1:]
+		return metric, nil
+	})
+}
+
+func (s *MemoryStore) SaveAlert(alert *models.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if alert.ID != 0 {
+		for idx := range s.alerts {
+			if s.alerts[idx].ID == alert.ID {
+				s.alerts[idx] = copyAlert(*alert)
+				return nil
+			}
+		}
+	} else {
+		alert.ID = s.id()
+	}
+	s.alerts = append(s.alerts, copyAlert(*alert))
+	return nil
+}
+
+func (s *MemoryStore) RepeatAlert(id uint, seen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := slices.IndexFunc(s.alerts, func(a models.Alert) bool { return a.ID == id && a.State == models.AlertStateFiring })
+	if idx >= 0 {
+		s.alerts[idx].LastSeen = seen
+		s.alerts[idx].Count++
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindFiringAlerts(deviceID string) ([]models.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alerts := s.findAlerts(&models.FleetAlertQuery{AlertQuery: models.AlertQuery{State: models.AlertStateFiring}})
+	alerts = slices.DeleteFunc(alerts, func(a models.Alert) bool { return a.DeviceID != deviceID })
+	slices.SortStableFunc(alerts, func(a, b models.Alert) int { return b.Timestamp.Compare(a.Timestamp) })
+	return alerts, nil
+}
+
+// findAlerts returns copies of the alerts matching the filters of query, the
+// caller holds the lock
+func (s *MemoryStore) findAlerts(query *models.FleetAlertQuery) []models.Alert {
+	alerts := []models.Alert{}
+	for _, alert := range s.alerts {
+		if !strings.HasPrefix(alert.DeviceID, query.DevicePrefix) ||
+			(query.Status != "" && alert.Status != query.Status) ||
+			(query.State != "" && alert.State != query.State) ||
+			(len(query.Types) > 0 && !slices.Contains(query.Types, alert.Type)) ||
+			!inTimeRange(alert.Timestamp, query.From, query.To) {
+			continue
+		}
+		alerts = append(alerts, copyAlert(alert))
+	}
+	return alerts
+}
+
+func (s *MemoryStore) FindAlerts(deviceID string, query *models.FleetAlertQuery) (*models.AlertPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alerts := s.findAlerts(query)
+	if deviceID != "" {
+		alerts = slices.DeleteFunc(alerts, func(a models.Alert) bool { return a.DeviceID != deviceID })
+	}
+	alerts, next, err := pageByTime(alerts, alertKey, query.Cursor, query.Limit, true)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AlertPage{Alerts: alerts, NextCursor: next}, nil
+}
+
+func (s *MemoryStore) SummarizeAlerts(query *models.FleetAlertQuery) ([]models.AlertSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byDevice := map[string]*models.AlertSummary{}
+	for _, alert := range s.findAlerts(query) {
+		summary, ok := byDevice[alert.DeviceID]
+		if !ok {
+			summary = &models.AlertSummary{DeviceID: alert.DeviceID}
+			byDevice[alert.DeviceID] = summary
+		}
+		summary.Count++
+		if alert.State == models.AlertStateFiring {
+			summary.Firing++
+		}
+		if alert.Status == models.AlertStatusOpen {
+			summary.Open++
+		}
+		if alert.Timestamp.After(summary.LastAlertAt) {
+			summary.LastAlertAt = alert.Timestamp.UTC()
+		}
+	}
+
+	summaries := []models.AlertSummary{}
+	for _, summary := range byDevice {
+		summaries = append(summaries, *summary)
+	}
+	slices.SortFunc(summaries, func(a, b models.AlertSummary) int { return strings.Compare(a.DeviceID, b.DeviceID) })
+	return summaries, nil
+}
+
+func (s *MemoryStore) TransitAlert(deviceID string, id uint, transition *AlertTransition) (*models.Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := slices.IndexFunc(s.alerts, func(a models.Alert) bool { return a.DeviceID == deviceID && a.ID == id })
+	if idx < 0 {
+		return nil, ErrAlertNotFound
+	}
+	alert := &s.alerts[idx]
+	if !slices.Contains(transition.From, alert.Status) {
+		return nil, fmt.Errorf("%w: alert %d is %s", ErrAlertStatusConflict, id, alert.Status)
+	}
+
+	at := transition.At
+	alert.Status = transition.Status
+	if transition.Status == models.AlertStatusResolved {
+		alert.State = models.AlertStateResolved
+		alert.ResolvedBy = transition.By
+		alert.ResolvedAt = &at
+	} else {
+		alert.AcknowledgedBy = transition.By
+		alert.AcknowledgedAt = &at
+	}
+	if transition.Note != "" {
+		alert.Note = transition.Note
+	}
+
+	updated := copyAlert(*alert)
+	return &updated, nil
+}
+
+func (s *MemoryStore) GetConfig(deviceID string) (*models.Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	config, ok := s.configs[deviceID]
+	if !ok {
+		return nil, ErrConfigNotFound
+	}
+	config = copyConfig(config)
+	return &config, nil
+}
+
+func (s *MemoryStore) UpdateConfigs(deviceIDs []string, origin models.ChangeOrigin, update ConfigUpdate) ([]models.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the new configs are only kept once all updates went through
+	configs := map[string]*models.Config{}
+	var changes []models.ConfigChange
+	var updated []models.Config
+	for _, deviceID := range deviceIDs {
+		var old *models.Config
+		if stored, ok := s.configs[deviceID]; ok {
+			stored = copyConfig(stored)
+			old = &stored
+		}
+
+		var current *models.Config
+		if old != nil {
+			copied := copyConfig(*old)
+			current = &copied
+		}
+		config, err := update(deviceID, current)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil && old == nil {
+			continue
+		}
+
+		if config == nil {
+			inUse := slices.ContainsFunc(s.metrics, func(m models.Metric) bool { return m.DeviceID == deviceID }) ||
+				slices.ContainsFunc(s.alerts, func(a models.Alert) bool { return a.DeviceID == deviceID })
+			if inUse {
+				return nil, fmt.Errorf("%w: device %s still has metrics or alerts", ErrConfigInUse, deviceID)
+			}
+		} else {
+			stored := copyConfig(*config)
+			stored.DeviceID = deviceID
+			stored.Version = 1
+			stored.LastSeenAt = nil
+			if old != nil {
+				stored.Version = old.Version + 1
+				stored.LastSeenAt = old.LastSeenAt
+			}
+			config = &stored
+			updated = append(updated, copyConfig(stored))
+		}
+
+		configs[deviceID] = config
+		changes = append(changes, newConfigChange(deviceID, old, config, origin))
+	}
+
+	for deviceID, config := range configs {
+		if config == nil {
+			delete(s.configs, deviceID)
+		} else {
+			s.configs[deviceID] = *config
+		}
+	}
+	for _, change := range changes {
+		change.ID = s.id()
+		s.changes = append(s.changes, change)
+	}
+
+	return updated, nil
+}
+
+func (s *MemoryStore) MarkConfigSeen(deviceID string, seen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if config, ok := s.configs[deviceID]; ok {
+		config.LastSeenAt = &seen
+		s.configs[deviceID] = config
+	}
+	return nil
+}
+
+func (s *MemoryStore) FindConfigChanges(deviceID string, query *models.ConfigChangeQuery) (*models.ConfigChangePage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes := []models.ConfigChange{}
+	for _, change := range s.changes {
+		if change.DeviceID == deviceID && inTimeRange(change.Timestamp, query.From, query.To) {
+			changes = append(changes, change)
+		}
+	}
+	changes, next, err := pageByTime(changes, func(c *models.ConfigChange) (time.Time, uint) {
+		return c.Timestamp, c.ID
+	}, query.Cursor, query.Limit, true)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ConfigChangePage{Changes: changes, NextCursor: next}, nil
+}
@@ -0,0 +1,127 @@
+package iot
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/db"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+// lookPostgres finds a postgres server binary on the PATH, or in the
+// versioned directories debian and ubuntu install postgres into
+func lookPostgres(name string) (string, bool) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, true
+	}
+	paths, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql", "*", "bin", name))
+	if len(paths) == 0 {
+		return "", false
+	}
+	// the newest version sorts last
+	return paths[len(paths)-1], true
+}
+
+// startPostgres starts a throwaway postgres server in a temporary directory and
+// returns its connection string, the test is skipped when postgres is missing
+func startPostgres(t *testing.T) string {
+	t.Helper()
+
+	initdb, foundInitdb := lookPostgres("initdb")
+	pgCtl, foundPgCtl := lookPostgres("pg_ctl")
+	if !foundInitdb || !foundPgCtl {
+		t.Skip("Skipping postgres test: initdb or pg_ctl not found")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("Skipping postgres test: postgres can not run as root")
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	run := func(name string, args ...string) {
+		output, err := exec.Command(name, args...).CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	run(initdb, "-D", data, "-U", "postgres", "-A", "trust", "--no-sync")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1", port, dir)
+	run(pgCtl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start")
+	t.Cleanup(func() {
+		_ = exec.Command(pgCtl, "-D", data, "-m", "immediate", "-w", "stop").Run()
+	})
+
+	return fmt.Sprintf("host=127.0.0.1 port=%d user=postgres dbname=postgres sslmode=disable", port)
+}
+
+func TestGormStore_Postgres(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	dsn := startPostgres(t)
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Setup(conn))
+	// setting up again is fine
+	require.NoError(t, db.Setup(conn))
+
+	store := NewGormStore(conn)
+	t.Run("metrics", func(t *testing.T) { testStoreMetrics(t, store) })
+	t.Run("alerts", func(t *testing.T) { testStoreAlerts(t, store) })
+	t.Run("configs", func(t *testing.T) { testStoreConfigs(t, store) })
+
+	t.Run("aggregate", func(t *testing.T) {
+		deviceID := uuid.NewString()
+		createStoreConfig(t, store, deviceID)
+
+		start := time.Now().UTC().Truncate(24 * time.Hour)
+		var metrics []models.Metric
+		for n := range 50 {
+			metrics = append(metrics, models.Metric{
+				DeviceID:    deviceID,
+				Timestamp:   start.Add(time.Duration(n) * 17 * time.Minute),
+				Temperature: float64(n % 7),
+				Battery:     float64(100 - n),
+			})
+		}
+		require.NoError(t, store.CreateMetrics(metrics))
+
+		from := start.Add(time.Hour)
+		for _, query := range []*models.MetricAggregateQuery{
+			{Interval: time.Hour},
+			{Interval: 7 * time.Minute},
+			{Interval: 24 * time.Hour, From: &from},
+		} {
+			// the time_bucket aggregation of TimescaleDB matches the scan
+			scanned, err := (&GormStore{conn: conn}).AggregateMetrics(deviceID, query)
+			require.NoError(t, err)
+			aggregated, err := store.AggregateMetrics(deviceID, query)
+			require.NoError(t, err)
+
+			require.Len(t, aggregated, len(scanned))
+			for n := range scanned {
+				assert.True(t, scanned[n].Start.Equal(aggregated[n].Start))
+				assert.Equal(t, scanned[n].Count, aggregated[n].Count)
+				assert.Equal(t, scanned[n].Battery.Last, aggregated[n].Battery.Last)
+				assert.Equal(t, scanned[n].Temperature.Max, aggregated[n].Temperature.Max)
+				assert.InDelta(t, scanned[n].Temperature.Avg, aggregated[n].Temperature.Avg, 1e-9)
+			}
+		}
+	})
+}
@@ -0,0 +1,439 @@
+package iot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"liyu1981.xyz/iot-metrics-service/pkg/common"
+	"liyu1981.xyz/iot-metrics-service/pkg/db"
+	"liyu1981.xyz/iot-metrics-service/pkg/iot/mocks"
+	"liyu1981.xyz/iot-metrics-service/pkg/models"
+	_ "liyu1981.xyz/iot-metrics-service/pkg/testing"
+)
+
+// runStores runs test against every Store adapter
+func runStores(t *testing.T, test func(t *testing.T, store Store)) {
+	stores := map[string]func(t *testing.T) Store{
+		"gorm": func(t *testing.T) Store {
+			dbInstance, err := db.New(db.UseMemorySqliteDialector(), db.Options{})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = dbInstance.Close() })
+			return NewGormStore(dbInstance.Conn)
+		},
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+func createStoreConfig(t *testing.T, store Store, deviceID string) {
+	t.Helper()
+	_, err := store.UpdateConfigs([]string{deviceID}, models.ChangeOrigin{}, func(deviceID string, current *models.Config) (*models.Config, error) {
+		return &models.Config{TemperatureThreshold: ptr(30.0)}, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestStore_Metrics(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	runStores(t, testStoreMetrics)
+}
+
+func testStoreMetrics(t *testing.T, store Store) {
+	deviceID := uuid.NewString()
+	createStoreConfig(t, store, deviceID)
+
+	start := time.Now().UTC().Truncate(time.Hour)
+	metrics := []models.Metric{
+		{DeviceID: deviceID, Timestamp: start, Temperature: 10.0, Battery: 90.0},
+		{DeviceID: deviceID, Timestamp: start.Add(time.Minute), Temperature: 20.0, Battery: 80.0,
+			Values: []models.MetricValue{{Name: "humidity", Value: 40.0}}},
+		{DeviceID: deviceID, Timestamp: start.Add(3 * time.Minute), Temperature: 30.0, Battery: 70.0},
+	}
+	require.NoError(t, store.CreateMetrics(metrics))
+	for _, metric := range metrics {
+		assert.NotZero(t, metric.ID)
+	}
+
+	page, err := store.FindMetrics(deviceID, &models.MetricQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Metrics, 2)
+	assert.Equal(t, metrics[2].ID, page.Metrics[0].ID)
+	require.Len(t, page.Metrics[1].Values, 1)
+	assert.Equal(t, "humidity", page.Metrics[1].Values[0].Name)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = store.FindMetrics(deviceID, &models.MetricQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Metrics, 1)
+	assert.Equal(t, metrics[0].ID, page.Metrics[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	from := start.Add(time.Minute)
+	page, err = store.FindMetrics(deviceID, &models.MetricQuery{From: &from, Order: models.SortOrderAsc})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{metrics[1].ID, metrics[2].ID}, common.Mapper(page.Metrics, func(m models.Metric) uint { return m.ID }))
+
+	_, err = store.FindMetrics(deviceID, &models.MetricQuery{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	history, err := store.FindMetricHistory(deviceID, &metrics[2], &MetricHistoryQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, metrics[1].ID, history[0].ID)
+	require.Len(t, history[0].Values, 1)
+
+	history, err = store.FindMetricHistory(deviceID, &metrics[2], &MetricHistoryQuery{After: &start})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{metrics[1].ID}, common.Mapper(history, func(m models.Metric) uint { return m.ID }))
+
+	history, err = store.FindMetricHistory(deviceID, &metrics[2], &MetricHistoryQuery{Until: &start})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{metrics[0].ID}, common.Mapper(history, func(m models.Metric) uint { return m.ID }))
+
+	buckets, err := store.AggregateMetrics(deviceID, &models.MetricAggregateQuery{Interval: 2 * time.Minute})
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	assert.Equal(t, start, buckets[0].Start)
+	assert.Equal(t, 2, buckets[0].Count)
+	assert.Equal(t, 15.0, buckets[0].Temperature.Avg)
+	assert.Equal(t, 20.0, buckets[0].Temperature.Last)
+	assert.Equal(t, 1, buckets[1].Count)
+	assert.Equal(t, 70.0, buckets[1].Battery.Min)
+}
+
+func TestStore_Alerts(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	runStores(t, testStoreAlerts)
+}
+
+func testStoreAlerts(t *testing.T, store Store) {
+	prefix := uuid.NewString()
+	deviceA, deviceB := prefix+"-a", prefix+"-b"
+	createStoreConfig(t, store, deviceA)
+	createStoreConfig(t, store, deviceB)
+
+	now := time.Now().UTC()
+	firing := models.Alert{DeviceID: deviceA, Timestamp: now, Type: models.AlertTypeTemperature,
+		State: models.AlertStateFiring, Status: models.AlertStatusOpen, LastSeen: now, Count: 1}
+	older := models.Alert{DeviceID: deviceA, Timestamp: now.Add(-time.Minute), Type: models.AlertTypeBattery,
+		State: models.AlertStateFiring, Status: models.AlertStatusOpen, LastSeen: now, Count: 1}
+	other := models.Alert{DeviceID: deviceB, Timestamp: now, Type: models.AlertTypeBattery,
+		State: models.AlertStateResolved, Status: models.AlertStatusResolved, LastSeen: now, Count: 1}
+	for _, alert := range []*models.Alert{&firing, &older, &other} {
+		require.NoError(t, store.SaveAlert(alert))
+		assert.NotZero(t, alert.ID)
+	}
+
+	firing.Count++
+	require.NoError(t, store.SaveAlert(&firing))
+
+	alerts, err := store.FindFiringAlerts(deviceA)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, firing.ID, alerts[0].ID)
+	assert.Equal(t, 2, alerts[0].Count)
+
+	page, err := store.FindAlerts(deviceA, &models.FleetAlertQuery{AlertQuery: models.AlertQuery{Limit: 1}})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, firing.ID, page.Alerts[0].ID)
+	page, err = store.FindAlerts(deviceA, &models.FleetAlertQuery{AlertQuery: models.AlertQuery{Limit: 1, Cursor: page.NextCursor}})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, older.ID, page.Alerts[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = store.FindAlerts("", &models.FleetAlertQuery{
+		DevicePrefix: prefix,
+		AlertQuery:   models.AlertQuery{Types: []models.AlertType{models.AlertTypeBattery}},
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{older.ID, other.ID}, common.Mapper(page.Alerts, func(a models.Alert) uint { return a.ID }))
+
+	summaries, err := store.SummarizeAlerts(&models.FleetAlertQuery{DevicePrefix: prefix})
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, models.AlertSummary{DeviceID: deviceA, Count: 2, Firing: 2, Open: 2, LastAlertAt: summaries[0].LastAlertAt}, summaries[0])
+	assert.WithinDuration(t, now, summaries[0].LastAlertAt, time.Millisecond)
+	assert.Equal(t, deviceB, summaries[1].DeviceID)
+	assert.Zero(t, summaries[1].Firing)
+
+	acked, err := store.TransitAlert(deviceA, firing.ID, &AlertTransition{
+		From:   []models.AlertStatus{models.AlertStatusOpen},
+		Status: models.AlertStatusAcknowledged,
+		By:     "alice",
+		At:     now,
+		Note:   "on it",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusAcknowledged, acked.Status)
+	assert.Equal(t, models.AlertStateFiring, acked.State)
+	assert.Equal(t, "alice", acked.AcknowledgedBy)
+	assert.Equal(t, "on it", acked.Note)
+
+	// a repeat of the stale firing alert keeps the acknowledgement
+	later := now.Add(time.Minute)
+	require.NoError(t, store.RepeatAlert(firing.ID, later))
+	alerts, err = store.FindFiringAlerts(deviceA)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, models.AlertStatusAcknowledged, alerts[0].Status)
+	assert.Equal(t, "alice", alerts[0].AcknowledgedBy)
+	assert.Equal(t, 3, alerts[0].Count)
+	assert.WithinDuration(t, later, alerts[0].LastSeen, time.Millisecond)
+
+	resolved, err := store.TransitAlert(deviceA, firing.ID, &AlertTransition{
+		From:   []models.AlertStatus{models.AlertStatusOpen, models.AlertStatusAcknowledged},
+		Status: models.AlertStatusResolved,
+		By:     "bob",
+		At:     now,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStateResolved, resolved.State)
+	assert.Equal(t, "bob", resolved.ResolvedBy)
+	assert.NotNil(t, resolved.ResolvedAt)
+	assert.Equal(t, "on it", resolved.Note)
+
+	require.NoError(t, store.RepeatAlert(firing.ID, later.Add(time.Minute)))
+	page, err = store.FindAlerts(deviceA, &models.FleetAlertQuery{AlertQuery: models.AlertQuery{State: models.AlertStateResolved}})
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertStatusResolved, page.Alerts[0].Status)
+	assert.Equal(t, 3, page.Alerts[0].Count)
+
+	_, err = store.TransitAlert(deviceA, firing.ID, &AlertTransition{From: []models.AlertStatus{models.AlertStatusOpen}, Status: models.AlertStatusAcknowledged})
+	assert.ErrorIs(t, err, ErrAlertStatusConflict)
+	_, err = store.TransitAlert(deviceB, firing.ID, &AlertTransition{From: []models.AlertStatus{models.AlertStatusOpen}, Status: models.AlertStatusAcknowledged})
+	assert.ErrorIs(t, err, ErrAlertNotFound)
+}
+
+func TestStore_Configs(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	runStores(t, testStoreConfigs)
+}
+
+func testStoreConfigs(t *testing.T, store Store) {
+	deviceID, otherID := uuid.NewString(), uuid.NewString()
+
+	_, err := store.GetConfig(deviceID)
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+
+	createStoreConfig(t, store, deviceID)
+	config, err := store.GetConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), config.Version)
+	assert.Equal(t, ptr(30.0), config.TemperatureThreshold)
+
+	seen := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, store.MarkConfigSeen(deviceID, seen))
+	require.NoError(t, store.MarkConfigSeen(otherID, seen))
+
+	updated, err := store.UpdateConfigs([]string{deviceID}, models.ChangeOrigin{Actor: "alice"}, func(deviceID string, current *models.Config) (*models.Config, error) {
+		require.NotNil(t, current)
+		current.BatteryThreshold = ptr(15.0)
+		current.Version = 42
+		return current, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, updated, 1)
+	// the store numbers the versions and keeps the last seen time
+	assert.Equal(t, uint64(2), updated[0].Version)
+	assert.Equal(t, ptr(15.0), updated[0].BatteryThreshold)
+	require.NotNil(t, updated[0].LastSeenAt)
+	assert.True(t, seen.Equal(*updated[0].LastSeenAt))
+
+	// a failing update changes none of the configs
+	_, err = store.UpdateConfigs([]string{deviceID, otherID}, models.ChangeOrigin{}, func(id string, current *models.Config) (*models.Config, error) {
+		if id == otherID {
+			return nil, ErrConfigConflict
+		}
+		current.BatteryThreshold = ptr(5.0)
+		return current, nil
+	})
+	assert.ErrorIs(t, err, ErrConfigConflict)
+	config, err = store.GetConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, ptr(15.0), config.BatteryThreshold)
+	assert.Equal(t, uint64(2), config.Version)
+
+	require.NoError(t, store.CreateMetrics([]models.Metric{{DeviceID: deviceID, Timestamp: seen}}))
+	_, err = store.UpdateConfigs([]string{deviceID}, models.ChangeOrigin{}, func(string, *models.Config) (*models.Config, error) {
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrConfigInUse)
+
+	createStoreConfig(t, store, otherID)
+	updated, err = store.UpdateConfigs([]string{otherID}, models.ChangeOrigin{Actor: "bob"}, func(string, *models.Config) (*models.Config, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Empty(t, updated)
+	_, err = store.GetConfig(otherID)
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+
+	page, err := store.FindConfigChanges(otherID, &models.ConfigChangeQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 2)
+	assert.Nil(t, page.Changes[0].New)
+	assert.Equal(t, "bob", page.Changes[0].Actor)
+	assert.Nil(t, page.Changes[1].Old)
+	assert.Equal(t, uint64(1), page.Changes[1].Version)
+
+	page, err = store.FindConfigChanges(deviceID, &models.ConfigChangeQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, uint64(2), page.Changes[0].Version)
+	assert.Equal(t, ptr(15.0), page.Changes[0].New.BatteryThreshold)
+	assert.Nil(t, page.Changes[0].Old.BatteryThreshold)
+	require.NotEmpty(t, page.NextCursor)
+}
+
+// TestMemoryStore_IOT runs the metric, alert and config services without a
+// database, the registry, groups and rules are mocked
+func TestMemoryStore_IOT(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockIDevice := mocks.NewMockIDevice(ctrl)
+	mockIGroup := mocks.NewMockIGroup(ctrl)
+	mockIRule := mocks.NewMockIRule(ctrl)
+
+	iotObj := &IOT{Store: NewMemoryStore()}
+	if _, err := iotObj.WithServices(ServiceOpts{
+		Metric: iotObj.GetIMetric(),
+		Alert:  iotObj.GetIAlert(),
+		Config: iotObj.GetIConfig(),
+		Rule:   mockIRule,
+		Device: mockIDevice,
+		Group:  mockIGroup,
+	}); err != nil {
+		t.Fatalf("Failed to set up services: %v", err)
+	}
+
+	deviceID := uuid.NewString()
+	mockIDevice.EXPECT().MarkDeviceSeen(deviceID, gomock.Any()).Return(nil).Times(2)
+	mockIGroup.EXPECT().GetDeviceGroups([]string{deviceID}).Return(map[string][]models.GroupConfig{
+		deviceID: {{Tag: "g", ConfigPatch: models.ConfigPatch{BatteryThreshold: ptr(20.0)}}},
+	}, nil).AnyTimes()
+	mockIRule.EXPECT().GetDeviceRules(deviceID).Return(nil, nil).Times(2)
+
+	err := iotObj.Config.UpsertConfig(deviceID, &models.Config{TemperatureThreshold: ptr(30.0)}, models.ChangeOrigin{Actor: "alice"})
+	require.NoError(t, err)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 35.0, Battery: 10.0})
+	require.NoError(t, err)
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	// the battery threshold comes from the group
+	assert.ElementsMatch(t, []models.AlertType{models.AlertTypeTemperature, models.AlertTypeBattery},
+		common.Mapper(page.Alerts, func(a models.Alert) models.AlertType { return a.Type }))
+
+	acked, err := iotObj.Alert.AcknowledgeAlert(deviceID, page.Alerts[0].ID, "alice", "")
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusAcknowledged, acked.Status)
+
+	err = iotObj.Metric.UpsertMetric(deviceID, &models.Metric{Timestamp: time.Now(), Temperature: 20.0, Battery: 50.0})
+	require.NoError(t, err)
+
+	page, err = iotObj.Alert.GetDeviceAlerts(deviceID, &models.AlertQuery{State: models.AlertStateFiring})
+	require.NoError(t, err)
+	assert.Empty(t, page.Alerts)
+
+	config, err := iotObj.Config.PatchConfig(deviceID, &models.ConfigPatch{BatteryThreshold: ptr(5.0)}, 1, models.ChangeOrigin{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), config.Version)
+	assert.NotNil(t, config.LastSeenAt)
+
+	history, err := iotObj.Config.GetConfigHistory(deviceID, nil)
+	require.NoError(t, err)
+	assert.Len(t, history.Changes, 2)
+
+	// deleting resets the config the metrics reference
+	require.NoError(t, iotObj.Config.DeleteConfig(deviceID, models.ChangeOrigin{}))
+	config, err = iotObj.Config.GetDeviceConfig(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), config.Version)
+	assert.Nil(t, config.BatteryThreshold)
+}
+
+func TestMemoryStore_DirectServices(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	// the built-in rule, device and group services, the notifier, the pruner
+	// and the offline watcher work on the tables of Db, not on the store
+	for name, opts := range map[string]ServiceOpts{
+		"rule":   {Rule: (&IOT{}).GetIRule()},
+		"device": {Device: (&IOT{}).GetIDevice()},
+		"group":  {Group: (&IOT{}).GetIGroup()},
+	} {
+		_, err := (&IOT{Store: NewMemoryStore()}).WithServices(opts)
+		assert.ErrorIs(t, err, ErrStoreUnsupported, name)
+	}
+	_, err := (&IOT{Store: NewMemoryStore(), Notifier: &WebhookNotifier{}}).WithServices(ServiceOpts{})
+	assert.ErrorIs(t, err, ErrStoreUnsupported)
+	_, err = (&IOT{Store: NewMemoryStore(), Retention: &RetentionPruner{}}).WithServices(ServiceOpts{})
+	assert.ErrorIs(t, err, ErrStoreUnsupported)
+	_, err = NewOfflineWatcher(&IOT{Store: NewMemoryStore()}, time.Hour)
+	assert.ErrorIs(t, err, ErrStoreUnsupported)
+
+	_, err = (&IOT{Store: NewMemoryStore()}).WithServices(ServiceOpts{Metric: (&IOT{}).GetIMetric()})
+	assert.NoError(t, err)
+}
+
+// TestMemoryStore_RulesWithoutConfig checks a device without any config
+// against its stored rules, which the foreign keys of the sql stores would not
+// let store metrics for
+func TestMemoryStore_RulesWithoutConfig(t *testing.T) {
+	common.SetTestLoggerNop()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockIDevice := mocks.NewMockIDevice(ctrl)
+	mockIRule := mocks.NewMockIRule(ctrl)
+
+	iotObj := &IOT{Store: NewMemoryStore()}
+	if _, err := iotObj.WithServices(ServiceOpts{
+		Metric: iotObj.GetIMetric(),
+		Alert:  iotObj.GetIAlert(),
+		Config: iotObj.GetIConfig(),
+		Rule:   mockIRule,
+		Device: mockIDevice,
+	}); err != nil {
+		t.Fatalf("Failed to set up services: %v", err)
+	}
+
+	deviceID, failingID := uuid.NewString(), uuid.NewString()
+	mockIDevice.EXPECT().GetDevice(deviceID).Return(nil, ErrDeviceNotFound)
+	mockIRule.EXPECT().GetDeviceRules(deviceID).Return([]models.AlertRule{
+		{ID: 1, DeviceID: deviceID, Metric: "humidity", Operator: models.RuleOperatorGT, Threshold: 80, Severity: models.AlertSeverityCritical},
+	}, nil)
+
+	metric := &models.Metric{Timestamp: time.Now(), Values: []models.MetricValue{{Name: "humidity", Value: 90}}}
+	require.NoError(t, iotObj.Alert.CheckAndStoreAlerts(deviceID, metric))
+
+	page, err := iotObj.Alert.GetDeviceAlerts(deviceID, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, models.AlertType("humidity"), page.Alerts[0].Type)
+
+	// other errors of resolving the config are not taken for a missing one
+	mockIDevice.EXPECT().GetDevice(failingID).Return(nil, errors.New("registry down"))
+	assert.EqualError(t, iotObj.Alert.CheckAndStoreAlerts(failingID, metric), "registry down")
+}
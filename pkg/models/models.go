@@ -7,6 +7,47 @@ type AlertType string
 const (
 	AlertTypeTemperature AlertType = "temperature"
 	AlertTypeBattery     AlertType = "battery"
+	// AlertTypeOffline is raised when a device misses its heartbeat interval
+	AlertTypeOffline AlertType = "offline"
+)
+
+type AlertSeverity string
+
+const (
+	AlertSeverityInfo     AlertSeverity = "info"
+	AlertSeverityWarning  AlertSeverity = "warning"
+	AlertSeverityCritical AlertSeverity = "critical"
+)
+
+type AlertState string
+
+const (
+	AlertStateFiring   AlertState = "firing"
+	AlertStateResolved AlertState = "resolved"
+)
+
+type AlertStatus string
+
+const (
+	AlertStatusOpen         AlertStatus = "open"
+	AlertStatusAcknowledged AlertStatus = "acknowledged"
+	AlertStatusResolved     AlertStatus = "resolved"
+)
+
+type RuleOperator string
+
+const (
+	RuleOperatorGT      RuleOperator = ">"
+	RuleOperatorGTE     RuleOperator = ">="
+	RuleOperatorLT      RuleOperator = "<"
+	RuleOperatorLTE     RuleOperator = "<="
+	RuleOperatorEQ      RuleOperator = "=="
+	RuleOperatorOutside RuleOperator = "outside"
+)
+
+const (
+	MetricNameTemperature = "temperature"
+	MetricNameBattery     = "battery"
 )
 
 type Metric struct {
@@ -15,21 +56,201 @@ type Metric struct {
 	Timestamp   time.Time
 	Temperature float64
 	Battery     float64
+
+	// Values are the named series reported besides temperature and battery,
+	// e.g. humidity, voltage, rssi or pressure
+	Values []MetricValue `gorm:"foreignKey:MetricID;constraint:OnDelete:CASCADE"`
+}
+
+// Value returns the reading of a named series, temperature and battery included
+func (m *Metric) Value(name string) (float64, bool) {
+	switch name {
+	case MetricNameTemperature:
+		return m.Temperature, true
+	case MetricNameBattery:
+		return m.Battery, true
+	}
+	for _, v := range m.Values {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return 0, false
 }
 
+type MetricValue struct {
+	ID       uint   `gorm:"primaryKey"`
+	MetricID uint   `gorm:"index"`
+	Name     string `gorm:"type:varchar(64);index"`
+	Value    float64
+	Unit     string `gorm:"type:varchar(16)"`
+}
+
+// Config holds the settings of a device. Nil settings are unset, they are taken
+// from the groups of the device, and a threshold no group sets either raises
+// no alerts.
 type Config struct {
 	DeviceID             string `gorm:"primaryKey"`
-	TemperatureThreshold float64
-	BatteryThreshold     float64
+	TemperatureThreshold *float64
+	BatteryThreshold     *float64
+	// hysteresis bands of the thresholds, see AlertRule.Hysteresis
+	TemperatureHysteresis *float64
+	BatteryHysteresis     *float64
+	// HeartbeatInterval is how long the device may go without posting
+	// metrics before an offline alert is raised, zero disables the check
+	HeartbeatInterval *time.Duration
+	// LastSeenAt is when metrics of the device were last received
+	LastSeenAt *time.Time
+	// Version counts the changes of the config, starting at 1, for
+	// optimistic concurrency of updates
+	Version uint64 `gorm:"not null;default:1"`
 
 	Metrics []Metric `gorm:"foreignKey:DeviceID;references:DeviceID"`
 	Alerts  []Alert  `gorm:"foreignKey:DeviceID;references:DeviceID"`
 }
 
+// AlertRule raises an alert of the rule's metric type when a reading of
+// Metric compares true against Threshold. With RuleOperatorOutside the
+// reading must be outside [Threshold, UpperThreshold].
+type AlertRule struct {
+	ID             uint         `gorm:"primaryKey"`
+	DeviceID       string       `gorm:"index"`
+	Metric         string       `gorm:"type:varchar(64)"`
+	Operator       RuleOperator `gorm:"type:varchar(8)"`
+	Threshold      float64
+	UpperThreshold float64
+	Severity       AlertSeverity `gorm:"type:varchar(16)"`
+	// Hysteresis is how far the reading must come back inside the threshold
+	// before a firing alert of the rule is resolved
+	Hysteresis float64
+	// HoldFor and HoldSamples delay the alert until the breach lasted for
+	// the duration and for that many consecutive samples, zero means at once
+	HoldFor     time.Duration
+	HoldSamples int
+}
+
 type Alert struct {
 	ID        uint   `gorm:"primaryKey"`
 	DeviceID  string `gorm:"index"`
 	Timestamp time.Time
-	Type      AlertType `gorm:"type:varchar(20);check:type IN ('temperature','battery')"`
-	Message   string
+	// Type is the name of the metric which raised the alert
+	Type     AlertType     `gorm:"type:varchar(64)"`
+	Severity AlertSeverity `gorm:"type:varchar(16)"`
+	// RuleID is nil for alerts raised by the config thresholds
+	RuleID  *uint `gorm:"index"`
+	Message string
+	// an alert is firing from Timestamp until the reading is back inside the
+	// hysteresis band at ResolvedAt, repeated breaches meanwhile only bump
+	// LastSeen and Count
+	State      AlertState `gorm:"type:varchar(16);index"`
+	LastSeen   time.Time
+	Count      int
+	ResolvedAt *time.Time
+
+	// Status is the handling of the alert by operators, it is also resolved
+	// when the alert resolves by itself, in which case ResolvedBy is empty
+	Status         AlertStatus `gorm:"type:varchar(16);default:open;index"`
+	AcknowledgedBy string
+	AcknowledgedAt *time.Time
+	ResolvedBy     string
+	Note           string
+}
+
+// WebhookDelivery logs the delivery of one alert change to one webhook URL,
+// it is updated after every attempt
+type WebhookDelivery struct {
+	ID         uint `gorm:"primaryKey"`
+	AlertID    uint `gorm:"index"`
+	URL        string
+	Event      string `gorm:"type:varchar(32)"`
+	Attempts   int
+	StatusCode int
+	Error      string
+	Delivered  bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Device is the registry entry of a device. Devices post metrics without one
+// unless the server requires registration.
+type Device struct {
+	ID       string `gorm:"primaryKey;type:varchar(128)"`
+	Name     string
+	Model    string
+	Firmware string
+	Location string
+	// Tags are stored in DeviceTag rows, so devices can be looked up by tag
+	Tags       []string `gorm:"-"`
+	Enabled    bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	LastSeenAt *time.Time
+}
+
+type DeviceTag struct {
+	DeviceID string `gorm:"primaryKey;type:varchar(128)"`
+	Tag      string `gorm:"primaryKey;type:varchar(64);index"`
+}
+
+// ConfigPatch is a partial Config, nil fields are left alone
+type ConfigPatch struct {
+	TemperatureThreshold  *float64
+	BatteryThreshold      *float64
+	TemperatureHysteresis *float64
+	BatteryHysteresis     *float64
+	HeartbeatInterval     *time.Duration
+}
+
+// GroupConfig holds the config defaults of the devices tagged with Tag. A
+// device inherits a default for every field its own config leaves unset.
+type GroupConfig struct {
+	Tag string `gorm:"primaryKey;type:varchar(64)"`
+	// Priority decides between the groups of a device setting the same
+	// field, the higher wins and ties go to the first tag
+	Priority    int
+	ConfigPatch `gorm:"embedded"`
+	// MetricRetention and AlertRetention override the max age the retention
+	// pruner keeps the rows of the devices for, zero keeps them forever and
+	// nil leaves the default of the pruner
+	MetricRetention *time.Duration
+	AlertRetention  *time.Duration
+	UpdatedAt       time.Time
+}
+
+// ConfigValues are the settings of a device config as kept in its history,
+// nil when unset
+type ConfigValues struct {
+	TemperatureThreshold  *float64
+	BatteryThreshold      *float64
+	TemperatureHysteresis *float64
+	BatteryHysteresis     *float64
+	HeartbeatInterval     *time.Duration
+}
+
+type ChangeSource string
+
+const (
+	ChangeSourceHTTP ChangeSource = "http"
+	ChangeSourceGRPC ChangeSource = "grpc"
+)
+
+// ChangeOrigin tells who made a change and through which transport, Actor
+// is whatever the client identified itself as and may be empty
+type ChangeOrigin struct {
+	Actor  string
+	Source ChangeSource `gorm:"type:varchar(16)"`
+}
+
+// ConfigChange records one change of the config of a device. Old is nil when
+// the config was created, New is nil when it was deleted. The history is kept
+// after the config is deleted.
+type ConfigChange struct {
+	ID        uint   `gorm:"primaryKey"`
+	DeviceID  string `gorm:"index"`
+	Timestamp time.Time
+	// Version of the config after the change, 0 when it was deleted
+	Version      uint64
+	Old          *ConfigValues `gorm:"serializer:json"`
+	New          *ConfigValues `gorm:"serializer:json"`
+	ChangeOrigin `gorm:"embedded"`
 }
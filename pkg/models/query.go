@@ -0,0 +1,134 @@
+package models
+
+import "time"
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "asc"
+	SortOrderDesc SortOrder = "desc"
+)
+
+// MetricQuery describes a page of metrics to read for one device. From and To
+// are inclusive bounds, Cursor is the NextCursor of a previous page.
+type MetricQuery struct {
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Cursor string
+	Order  SortOrder
+}
+
+type MetricPage struct {
+	Metrics    []Metric
+	NextCursor string
+}
+
+// MetricAggregateQuery buckets metrics of one device into Interval wide
+// windows, bucket starts are the metric timestamps truncated in UTC.
+type MetricAggregateQuery struct {
+	From     *time.Time
+	To       *time.Time
+	Interval time.Duration
+}
+
+type MetricStats struct {
+	Min  float64
+	Max  float64
+	Avg  float64
+	Last float64
+}
+
+type MetricBucket struct {
+	Start       time.Time
+	Count       int
+	Temperature MetricStats
+	Battery     MetricStats
+}
+
+// AlertQuery filters a page of alerts of a device, newest first. Zero values
+// match everything, From and To are inclusive bounds on the alert timestamp and
+// Cursor is the NextCursor of a previous page.
+type AlertQuery struct {
+	Status AlertStatus
+	State  AlertState
+	Types  []AlertType
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Cursor string
+}
+
+type AlertPage struct {
+	Alerts     []Alert
+	NextCursor string
+}
+
+// FleetAlertQuery filters alerts across devices, DevicePrefix limits them to
+// devices whose id starts with it
+type FleetAlertQuery struct {
+	AlertQuery
+	DevicePrefix string
+}
+
+// AlertSummary counts the alerts of one device matching a FleetAlertQuery
+type AlertSummary struct {
+	DeviceID    string
+	Count       int
+	Firing      int
+	Open        int
+	LastAlertAt time.Time
+}
+
+// DeviceQuery filters a page of registered devices ordered by id, zero values
+// match everything
+type DeviceQuery struct {
+	Prefix  string
+	Tag     string
+	Enabled *bool
+	Limit   int
+	Cursor  string
+}
+
+type DevicePage struct {
+	Devices    []Device
+	NextCursor string
+}
+
+const (
+	ConfigSourceDevice  = "device"
+	ConfigSourceDefault = "default"
+	// ConfigSourceGroupPrefix is followed by the tag of the group
+	ConfigSourceGroupPrefix = "group:"
+)
+
+// ConfigSources tells per field of a resolved config where its value came
+// from, one of ConfigSourceDevice, ConfigSourceDefault or a group
+type ConfigSources struct {
+	TemperatureThreshold  string
+	BatteryThreshold      string
+	TemperatureHysteresis string
+	BatteryHysteresis     string
+	HeartbeatInterval     string
+}
+
+// ResolvedConfig is the config alerts of a device are checked against
+type ResolvedConfig struct {
+	Config  Config
+	Sources ConfigSources
+}
+
+// ConfigChangeQuery describes a page of the config history of a device,
+// newest first. From and To are inclusive bounds on the change timestamp and
+// Cursor is the NextCursor of a previous page.
+type ConfigChangeQuery struct {
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Cursor string
+}
+
+type ConfigChangePage struct {
+	Changes    []ConfigChange
+	NextCursor string
+}